@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"github.com/hhruszka/k8sexec"
+	corev1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"regexp"
+	"strings"
+)
+
+// annotations, which can be used by application teams on pods and namespaces to control scans of their workloads
+const (
+	annotationSkip     = "kubelse.io/skip"
+	annotationLevel    = "kubelse.io/level"
+	annotationSections = "kubelse.io/sections"
+	annotationShell    = "kubelse.io/shell"
+	annotationExclude  = "kubelse.io/exclude-paths"
+)
+
+var sectionsRegexp = regexp.MustCompile(`^[a-z]{3}([0-9]{3})?(,[a-z]{3}([0-9]{3})?)*$`)
+
+// scanSettings are lse.sh settings used for a single container. Empty values mean lse.sh and kubelse defaults.
+type scanSettings struct {
+	level    string
+	sections string
+	shell    string
+	// excludePaths are comma-separated paths excluded from searches of lse.sh
+	excludePaths string
+	// reduced is set when lse.sh stalled, it is run again at level 0 with network mounts excluded
+	reduced bool
+}
+
+// SkippedContainer is a container, which was excluded from a scan on purpose.
+type SkippedContainer struct {
+	Container Container `json:"Container"`
+	Reason    string    `json:"Reason"`
+}
+
+// validateLevel checks if a value is a valid lse.sh verbosity level.
+func validateLevel(value string) error {
+	if value != "0" && value != "1" && value != "2" {
+		return fmt.Errorf("invalid level %q, valid levels are 0, 1 or 2", value)
+	}
+	return nil
+}
+
+// validateSections checks if a value is a valid comma-separated list of lse.sh sections or tests, e.g. fst,sud000.
+func validateSections(value string) error {
+	if !sectionsRegexp.MatchString(value) {
+		return fmt.Errorf("invalid sections %q, expected comma-separated section or test ids, e.g. fst,sud000", value)
+	}
+	return nil
+}
+
+// settingsFor returns lse.sh settings for a container. Values provided with the CLI options are overridden with
+// the pod's annotations, invalid annotations are reported and ignored. Paths excluded by default on the cluster's
+// provider are always excluded.
+func settingsFor(container Container) scanSettings {
+	settings := scanSettings{level: level, sections: sections, excludePaths: strings.Trim(providerProfile().ExcludePaths+","+excludePaths, ",")}
+
+	if value, ok := container.Annotations[annotationLevel]; ok {
+		if err := validateLevel(strings.TrimSpace(value)); err != nil {
+			log(fmt.Sprintf("[-] Ignoring %s annotation of %s pod: %s\n", annotationLevel, container.Pod, err.Error()))
+		} else {
+			settings.level = strings.TrimSpace(value)
+		}
+	}
+	if value, ok := container.Annotations[annotationSections]; ok {
+		value = strings.ReplaceAll(value, " ", "")
+		if err := validateSections(value); err != nil {
+			log(fmt.Sprintf("[-] Ignoring %s annotation of %s pod: %s\n", annotationSections, container.Pod, err.Error()))
+		} else {
+			settings.sections = value
+		}
+	}
+	if value, ok := container.Annotations[annotationShell]; ok {
+		if value = strings.TrimSpace(value); value == "" || strings.ContainsAny(value, " \t") {
+			log(fmt.Sprintf("[-] Ignoring %s annotation of %s pod: invalid shell %q\n", annotationShell, container.Pod, value))
+		} else {
+			settings.shell = value
+		}
+	}
+	if value, ok := container.Annotations[annotationExclude]; ok {
+		value = strings.ReplaceAll(value, " ", "")
+		if err := validateExcludePaths(value); err != nil {
+			log(fmt.Sprintf("[-] Ignoring %s annotation of %s pod: %s\n", annotationExclude, container.Pod, err.Error()))
+		} else if value != "" {
+			// paths excluded by the pod are excluded in addition to paths excluded with the CLI option
+			settings.excludePaths = strings.Trim(settings.excludePaths+","+value, ",")
+		}
+	}
+	return settings
+}
+
+// skipAnnotated tells if a resource is annotated to be excluded from scans.
+func skipAnnotated(annotations map[string]string) bool {
+	return strings.EqualFold(strings.TrimSpace(annotations[annotationSkip]), "true")
+}
+
+// namespaceSkipped tells if the scanned namespace is annotated to be excluded from scans. Namespaces that cannot
+// be read, e.g. due to missing permissions, are not skipped.
+func namespaceSkipped(k8s *k8sexec.K8SExec) bool {
+	ns, err := clientsetOf(k8s).CoreV1().Namespaces().Get(context.TODO(), k8s.Namespace, metaV1.GetOptions{})
+	if err != nil {
+		log(fmt.Sprintf("[-] Cannot read annotations of %s namespace: %s\n", k8s.Namespace, err.Error()))
+		return false
+	}
+	return skipAnnotated(ns.Annotations)
+}
+
+// skipContainers records all containers of a pod as skipped for a given reason.
+func skipContainers(pod corev1.Pod, reason string) {
+	for _, container := range pod.Spec.Containers {
+		skippedContainers = append(skippedContainers, SkippedContainer{Container{Pod: pod.Name, Container: container.Name, Workload: workloadOf(pod)}, reason})
+	}
+}
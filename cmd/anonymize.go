@@ -0,0 +1,221 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// anonymization CLI options variables
+var (
+	anonymize    bool
+	anonymizeMap string
+)
+
+// kinds of anonymized names, pseudonyms are the kind followed by a number, e.g. pod-3
+const (
+	PseudonymNamespace = "namespace"
+	PseudonymPod       = "pod"
+	PseudonymWorkload  = "workload"
+	PseudonymCluster   = "cluster"
+	PseudonymHost      = "host"
+	PseudonymIP        = "ip"
+)
+
+// wellKnownNamespaces are not internal naming, they are kept in anonymized reports
+var wellKnownNamespaces = []string{"default", "kube-system", "kube-public", "kube-node-lease"}
+
+var (
+	ipv4Regexp = regexp.MustCompile(`\b(?:[0-9]{1,3}\.){3}[0-9]{1,3}\b`)
+	// names of services and pods in the cluster DNS domain, e.g. api.payments.svc.cluster.local
+	clusterDNSRegexp = regexp.MustCompile(`\b[a-z0-9](?:[a-z0-9.-]*[a-z0-9])?\.svc(?:\.cluster\.local)?\b`)
+	// pseudonyms are never anonymized again, so that anonymizing is idempotent
+	pseudonymRegexp = regexp.MustCompile(`^(namespace|pod|workload|cluster|host|ip)-[0-9]+$`)
+	// the hostname lse.sh prints in its header, colors are escaped in json reports
+	hostnameRegexp = regexp.MustCompile(`Hostname:(?:\x1b\[[0-9;]*m|\\u001b\[[0-9;]*m)*\s+([A-Za-z0-9][A-Za-z0-9.-]*)`)
+)
+
+// Pseudonyms maps internal names to pseudonyms. The mapping is kept across runs, so that a name gets the same
+// pseudonym in all reports shared externally, and it is stored apart from the reports, so that it can be used to
+// map findings reported back by consultants to workloads.
+type Pseudonyms struct {
+	// Names are pseudonyms by internal names
+	Names map[string]string `json:"Names"`
+	// Next are numbers of the next pseudonym of every kind
+	Next map[string]int `json:"Next"`
+
+	mu sync.Mutex
+}
+
+// pseudonyms of the run, loaded from and saved to '--anonymize-map'
+var pseudonyms = newPseudonyms()
+
+func newPseudonyms() *Pseudonyms {
+	return &Pseudonyms{Names: make(map[string]string), Next: make(map[string]int)}
+}
+
+// defaultAnonymizeMap returns where pseudonyms are stored by default: in the user's configuration directory,
+// not in the reports directory, which is shared.
+func defaultAnonymizeMap() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "kubelse", "pseudonyms.json"), nil
+}
+
+// loadPseudonyms reads pseudonyms of previous runs, the file does not have to exist yet.
+func loadPseudonyms(file string) error {
+	pseudonyms = newPseudonyms()
+	content, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(content, pseudonyms); err != nil {
+		return fmt.Errorf("invalid pseudonyms %s: %s", file, err.Error())
+	}
+	if pseudonyms.Names == nil {
+		pseudonyms.Names = make(map[string]string)
+	}
+	if pseudonyms.Next == nil {
+		pseudonyms.Next = make(map[string]int)
+	}
+	return nil
+}
+
+// save writes pseudonyms to a file readable only by the user.
+func (p *Pseudonyms) save(file string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	content, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(file, content, 0600)
+}
+
+// add returns a pseudonym of a name, a new one if the name has none yet. Names shorter than 3 characters are not
+// anonymized, since they would be replaced in unrelated words, and neither are pseudonyms.
+func (p *Pseudonyms) add(kind string, name string) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.addLocked(kind, name)
+}
+
+func (p *Pseudonyms) addLocked(kind string, name string) string {
+	if len(name) < 3 || pseudonymRegexp.MatchString(name) {
+		return name
+	}
+	if pseudonym, ok := p.Names[name]; ok {
+		return pseudonym
+	}
+	p.Next[kind]++
+	pseudonym := fmt.Sprintf("%s-%d", kind, p.Next[kind])
+	p.Names[name] = pseudonym
+	return pseudonym
+}
+
+// addRun adds pseudonyms of names of a run: the cluster, its API server, the namespace and pods and workloads of
+// scanned and skipped containers.
+func (p *Pseudonyms) addRun(ns string, info ClusterInfo, containers []Container) {
+	if !slices.Contains(wellKnownNamespaces, ns) {
+		p.add(PseudonymNamespace, ns)
+	}
+	for _, name := range []string{info.Name, info.Context} {
+		if name != "in-cluster" {
+			p.add(PseudonymCluster, name)
+		}
+	}
+	if server, err := url.Parse(info.Server); err == nil && server.Hostname() != "" && net.ParseIP(server.Hostname()) == nil {
+		p.add(PseudonymHost, server.Hostname())
+	}
+	for _, skipped := range skippedContainers {
+		containers = append(containers, skipped.Container)
+	}
+	for _, container := range containers {
+		p.add(PseudonymPod, container.Pod)
+		// a workload keeps its kind, e.g. Deployment/workload-2
+		if kind, name, found := strings.Cut(container.Workload, "/"); found && kind != "Pod" {
+			p.add(PseudonymWorkload, name)
+		}
+	}
+}
+
+// anonymizeText replaces names with their pseudonyms in a text. Hostnames printed by lse.sh, addresses in the
+// cluster DNS domain and IPv4 addresses, but loopback and unspecified ones, get pseudonyms as they are found.
+func (p *Pseudonyms) anonymizeText(text string) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, match := range hostnameRegexp.FindAllStringSubmatch(text, -1) {
+		p.addLocked(PseudonymHost, match[1])
+	}
+	for _, name := range clusterDNSRegexp.FindAllString(text, -1) {
+		p.addLocked(PseudonymHost, name)
+	}
+	for _, address := range ipv4Regexp.FindAllString(text, -1) {
+		if ip := net.ParseIP(address); ip != nil && !ip.IsLoopback() && !ip.IsUnspecified() {
+			p.addLocked(PseudonymIP, address)
+		}
+	}
+
+	// longer names first, so that a name is not partially replaced with a pseudonym of its prefix
+	names := make([]string, 0, len(p.Names))
+	for name := range p.Names {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if len(names[i]) != len(names[j]) {
+			return len(names[i]) > len(names[j])
+		}
+		return names[i] < names[j]
+	})
+	var replacements []string
+	for _, name := range names {
+		replacements = append(replacements, name, p.Names[name])
+	}
+	return strings.NewReplacer(replacements...).Replace(text)
+}
+
+// anonymized returns content of a report, or any other output shared with the reports, anonymized with
+// '--anonymize'.
+func anonymized(content []byte) []byte {
+	if !anonymize {
+		return content
+	}
+	return []byte(pseudonyms.anonymizeText(string(content)))
+}
+
+// anonymizedName returns a file name anonymized with '--anonymize'.
+func anonymizedName(name string) string {
+	if !anonymize {
+		return name
+	}
+	return pseudonyms.anonymizeText(name)
+}
+
+// savePseudonyms saves pseudonyms of the run, so that later runs use the same ones.
+func savePseudonyms() {
+	if !anonymize {
+		return
+	}
+	if err := pseudonyms.save(anonymizeMap); err != nil {
+		log(fmt.Sprintf("[-] Error saving pseudonyms: %s\n", err.Error()))
+		return
+	}
+	log(fmt.Sprintf("[+] Pseudonyms saved to %s, do not share it with the reports\n", anonymizeMap))
+}
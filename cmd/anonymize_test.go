@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAnonymizeText(t *testing.T) {
+	p := newPseudonyms()
+	p.addRun("payments", ClusterInfo{Name: "prod-eu", Server: "https://api.prod.example.com:6443"}, []Container{
+		{Pod: "api-7d9f-x2x", Container: "app", Workload: "Deployment/api"},
+		{Pod: "api-7d9f-x2xab", Container: "app", Workload: "Deployment/api"},
+	})
+	text := p.anonymizeText("\x1b[1;34m    Hostname:\x1b[0m api-7d9f-x2xab\nredis.payments.svc.cluster.local 10.12.0.7:6379 127.0.0.1 api-7d9f-x2x prod-eu https://api.prod.example.com:6443 Deployment/api")
+	expected := "\x1b[1;34m    Hostname:\x1b[0m pod-2\nhost-2 ip-1:6379 127.0.0.1 pod-1 cluster-1 https://host-1:6443 Deployment/workload-1"
+	if text != expected {
+		t.Errorf("expected %q, got %q", expected, text)
+	}
+	// pseudonyms are stable
+	if again := p.anonymizeText("10.12.0.7 api-7d9f-x2xab"); again != "ip-1 pod-2" {
+		t.Errorf("expected stable pseudonyms, got %q", again)
+	}
+	if p.anonymizeText(expected) != expected {
+		t.Errorf("expected anonymizing to be idempotent")
+	}
+}
+
+func TestAnonymizedScan(t *testing.T) {
+	cluster, k8s := startTestCluster(t, testPod("billing-web-1", "nginx", nil))
+	container := debian
+	container.LseOutput = append([]string{"    Hostname: billing-web-1", "[*] net000 Services listening only on localhost............. yes!", "---", "tcp 10.1.2.3:8080", "---"}, lseOutput...)
+	cluster.SetContainer("billing-web-1", "app", container)
+	anonymize, anonymizeMap = true, filepath.Join(t.TempDir(), "pseudonyms.json")
+	t.Cleanup(func() { anonymize, pseudonyms = false, newPseudonyms() })
+
+	for run := 0; run < 2; run++ {
+		if err := loadPseudonyms(anonymizeMap); err != nil {
+			t.Fatal(err)
+		}
+		containers, err := getContainers(k8s, nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		startRun(k8s)
+		if _, err := scanContainers(k8s, containers); err != nil {
+			t.Fatal(err)
+		}
+	}
+	reports, _ := filepath.Glob(filepath.Join(directory, "pod-1-app-*"))
+	if len(reports) != 2 {
+		files, _ := filepath.Glob(filepath.Join(directory, "*"))
+		t.Fatalf("expected 2 reports of pod-1 with stable pseudonyms, got %v", files)
+	}
+	files, _ := filepath.Glob(filepath.Join(directory, "*"))
+	for _, file := range files {
+		content, _ := os.ReadFile(file)
+		if strings.Contains(string(content), "billing-web-1") || strings.Contains(string(content), "10.1.2.3") {
+			t.Errorf("%s is not anonymized", file)
+		}
+	}
+	if err := loadPseudonyms(anonymizeMap); err != nil {
+		t.Fatal(err)
+	}
+	if pseudonyms.Names["billing-web-1"] != "pod-1" || pseudonyms.Names["10.1.2.3"] != "ip-1" {
+		t.Errorf("unexpected pseudonyms %v", pseudonyms.Names)
+	}
+}
@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/hhruszka/k8sexec"
+	authorizationV1 "k8s.io/api/authorization/v1"
+	"slices"
+	"strings"
+)
+
+// API probe CLI options variables
+var apiProbe bool
+
+// apiProbeScript checks from a container whether the Kubernetes API is reachable and, with the mounted service
+// account token, asks it for rules of the token with a SelfSubjectRulesReview. It prints 'notool', 'unreachable',
+// 'reachable notoken', 'reachable unauthorized', or 'reachable rules' followed by the review in json; the token
+// itself is never printed.
+const apiProbeScript = `sa=/var/run/secrets/kubernetes.io/serviceaccount
+api="https://${KUBERNETES_SERVICE_HOST:-kubernetes.default.svc}:${KUBERNETES_SERVICE_PORT:-443}"
+ns=` + "`" + `cat $sa/namespace 2>/dev/null` + "`" + `
+review="{\"apiVersion\":\"authorization.k8s.io/v1\",\"kind\":\"SelfSubjectRulesReview\",\"spec\":{\"namespace\":\"$ns\"}}"
+if command -v curl >/dev/null 2>&1; then
+  code=` + "`" + `curl -sk -m 5 -o /dev/null -w '%{http_code}' "$api/version" 2>/dev/null` + "`" + `
+  if [ -z "$code" ] || [ "$code" = "000" ]; then echo unreachable; exit 0; fi
+  [ -r $sa/token ] || { echo reachable notoken; exit 0; }
+  rules=` + "`" + `curl -skf -m 5 -X POST -H "Authorization: Bearer $(cat $sa/token)" -H "Content-Type: application/json" -d "$review" "$api/apis/authorization.k8s.io/v1/selfsubjectrulesreviews" 2>/dev/null` + "`" + `
+elif command -v wget >/dev/null 2>&1; then
+  wget -q -T 5 --no-check-certificate -O - "$api/version" 2>&1 | grep -q 'HTTP/\|{' || { echo unreachable; exit 0; }
+  [ -r $sa/token ] || { echo reachable notoken; exit 0; }
+  rules=` + "`" + `wget -q -T 5 --no-check-certificate -O - --header="Authorization: Bearer $(cat $sa/token)" --header="Content-Type: application/json" --post-data="$review" "$api/apis/authorization.k8s.io/v1/selfsubjectrulesreviews" 2>/dev/null` + "`" + `
+else
+  echo notool; exit 0
+fi
+if [ -n "$rules" ]; then echo reachable rules; echo "$rules"; else echo reachable unauthorized; fi
+exit 0`
+
+// resources every authenticated user may create, they do not widen the blast radius of a token
+var selfReviewResources = []string{"selfsubjectaccessreviews", "selfsubjectrulesreviews", "selfsubjectreviews"}
+
+// sensitiveRules are verbs and resources, which let a token read credentials or run code in other pods
+var sensitiveRules = map[string][]string{
+	"secrets":     {"get", "list", "watch", "*"},
+	"pods":        {"create", "*"},
+	"pods/exec":   {"create", "get", "*"},
+	"pods/attach": {"create", "get", "*"},
+	"*":           {"*", "get", "list", "create"},
+}
+
+// APIAccess is access of a container to the Kubernetes API with its service account token.
+type APIAccess struct {
+	Reachable     bool     `json:"Reachable"`
+	Authenticated bool     `json:"Authenticated"`
+	Rules         []string `json:"Rules,omitempty"`
+	// Sensitive are rules allowing to read secrets, create pods or exec into them
+	Sensitive []string `json:"Sensitive,omitempty"`
+}
+
+// allowedRules returns rules of a SelfSubjectRulesReview as '<verbs> <resources>', leaving out self reviews,
+// and the ones, which are sensitive.
+func allowedRules(review authorizationV1.SelfSubjectRulesReview) ([]string, []string) {
+	var rules, sensitive []string
+	for _, rule := range review.Status.ResourceRules {
+		var resources []string
+		for _, resource := range rule.Resources {
+			if !slices.Contains(selfReviewResources, resource) {
+				resources = append(resources, resource)
+			}
+		}
+		if len(resources) == 0 {
+			continue
+		}
+		text := fmt.Sprintf("%s %s", strings.Join(rule.Verbs, ","), strings.Join(resources, ","))
+		if len(rule.ResourceNames) > 0 {
+			text += " (" + strings.Join(rule.ResourceNames, ",") + ")"
+		}
+		rules = append(rules, text)
+		for _, resource := range resources {
+			verbs := sensitiveRules[resource]
+			if slices.ContainsFunc(rule.Verbs, func(verb string) bool { return slices.Contains(verbs, verb) }) {
+				sensitive = append(sensitive, text)
+				break
+			}
+		}
+	}
+	return rules, sensitive
+}
+
+// apiProbeCommand returns a command, which probes the Kubernetes API from a container.
+func apiProbeCommand(container ContainerInfo) []string {
+	return []string{container.shell, "-c", apiProbeScript}
+}
+
+// probeAPI runs the API probe in a container. It returns access of the container to the Kubernetes API and
+// whether the probe could run at all.
+func probeAPI(ctx context.Context, k8s *k8sexec.K8SExec, container ContainerInfo) (APIAccess, bool) {
+	execStatus := execInContainer(ctx, k8s, container.container.Pod, container.container.Container, apiProbeCommand(container), nil)
+	if execStatus.RetCode != k8sexec.Success || len(execStatus.Stdout) == 0 {
+		return APIAccess{}, false
+	}
+	var access APIAccess
+	switch strings.TrimSpace(execStatus.Stdout[0]) {
+	case "notool":
+		return APIAccess{}, false
+	case "reachable notoken", "reachable unauthorized":
+		access.Reachable = true
+	case "reachable rules":
+		access.Reachable = true
+		var review authorizationV1.SelfSubjectRulesReview
+		if err := json.Unmarshal([]byte(strings.Join(execStatus.Stdout[1:], "\n")), &review); err == nil {
+			access.Authenticated = true
+			access.Rules, access.Sensitive = allowedRules(review)
+		}
+	}
+	return access, true
+}
+
+// apiFindings returns output of the API probe in the format of lse.sh: api000 tells whether the Kubernetes API is
+// reachable, api010 whether the service account token is allowed any verbs on resources and api020 whether any
+// of them lets it read secrets, create pods or exec into them.
+func apiFindings(access APIAccess, probed bool) []string {
+	var reachable []string
+	if access.Reachable {
+		reachable = append(reachable, "the Kubernetes API is reachable")
+		if access.Authenticated {
+			reachable = append(reachable, "the mounted service account token is accepted")
+		}
+	}
+	lines := []string{"", lseSectionLine("kubernetes api")}
+	lines = append(lines, lseTestLines("i", "api000", "Can we reach the Kubernetes API?", reachable, !probed)...)
+	lines = append(lines, lseTestLines("*", "api010", "Can we use the Kubernetes API with the service account token?", access.Rules, !probed)...)
+	return append(lines, lseTestLines("!", "api020", "Can the service account token read secrets or run pods?", access.Sensitive, !probed)...)
+}
@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"github.com/hhruszka/k8sexec"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sort"
+	"strings"
+)
+
+// argocd CLI options variables
+var (
+	argocdApp   string
+	argocdLabel string
+)
+
+// argocdSelector returns a label selector of pods tracked by an ArgoCD application.
+func argocdSelector() string {
+	return fmt.Sprintf("%s=%s", argocdLabel, argocdApp)
+}
+
+// argocdNamespaces returns namespaces, in which an ArgoCD application has pods.
+func argocdNamespaces(k8s *k8sexec.K8SExec) ([]string, error) {
+	pods, err := clientsetOf(k8s).CoreV1().Pods(metaV1.NamespaceAll).List(context.TODO(), metaV1.ListOptions{LabelSelector: argocdSelector()})
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	var namespaces []string
+	for _, pod := range pods.Items {
+		if !seen[pod.Namespace] {
+			seen[pod.Namespace] = true
+			namespaces = append(namespaces, pod.Namespace)
+		}
+	}
+	sort.Strings(namespaces)
+	return namespaces, nil
+}
+
+// scanArgoCDApp scans, or lists, pods of an ArgoCD application namespace by namespace, since an application can
+// deploy to many of them. Every namespace is scanned in a run of its own and the returned manifest combines
+// entries of all runs.
+func scanArgoCDApp(k8s *k8sexec.K8SExec) (Manifest, error) {
+	namespaces, err := argocdNamespaces(k8s)
+	if err != nil {
+		return Manifest{}, fmt.Errorf("[-] Error listing pods of ArgoCD application %s: %s\n", argocdApp, err.Error())
+	}
+	if len(namespaces) == 0 {
+		return Manifest{}, fmt.Errorf("[-] No pods labeled with %s found in any namespace\n", argocdSelector())
+	}
+	log(fmt.Sprintf("[+] ArgoCD application %s has pods in namespaces: %s\n", argocdApp, strings.Join(namespaces, ", ")))
+	if err := validateNamespaces(k8s, namespaces); err != nil {
+		return Manifest{}, err
+	}
+
+	labelSelector = argocdSelector()
+	return scanNamespaces(k8s, namespaces, func(client *k8sexec.K8SExec) ([]Container, error) {
+		if list {
+			return nil, listContainers(client)
+		}
+		return getContainers(client, nil, nil)
+	})
+}
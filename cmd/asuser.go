@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// as user CLI options variables
+var asUser string
+
+// asUserScript switches, where possible, to a user given by its uid and runs the wrapped command, the shell found
+// in a container, with the same arguments and stdin. setpriv, runuser and su are tried in turn, the outcome is
+// printed as the first line of the report.
+const asUserScript = `uid=%d
+name= gid=
+while IFS=: read -r n _ u g _; do
+  if [ "$u" = "$uid" ]; then name=$n gid=$g; break; fi
+done < /etc/passwd 2>/dev/null
+current=$(id -u 2>/dev/null)
+if [ "$current" = "$uid" ]; then
+  echo "kubelse: enumerating as uid $uid"
+elif [ "$current" != 0 ]; then
+  echo "kubelse: cannot switch to uid $uid without root, enumerating as uid ${current:-unknown}"
+elif command -v setpriv >/dev/null 2>&1; then
+  echo "kubelse: enumerating as uid $uid (setpriv)"
+  exec setpriv --reuid="$uid" --regid="${gid:-$uid}" --clear-groups "$0" "$@"
+elif [ -n "$name" ] && command -v runuser >/dev/null 2>&1; then
+  echo "kubelse: enumerating as uid $uid (runuser -u $name)"
+  exec runuser -u "$name" -- "$0" "$@"
+elif [ -n "$name" ] && command -v su >/dev/null 2>&1; then
+  echo "kubelse: enumerating as uid $uid (su $name)"
+  exec su -s "$(command -v "$0")" -c 'exec "$0" "$@"' -- "$name" "$0" "$@"
+else
+  echo "kubelse: cannot switch to uid $uid, setpriv is missing and the uid has no passwd entry for runuser or su, enumerating as uid 0"
+fi
+exec "$0" "$@"`
+
+// validateAsUser checks that a user to enumerate as is given by a uid.
+func validateAsUser(value string) error {
+	if value == "" {
+		return nil
+	}
+	if uid, err := strconv.Atoi(value); err != nil || uid < 0 {
+		return fmt.Errorf("Invalid value of the option '--as-user'. A numeric uid is expected, got %q", value)
+	}
+	return nil
+}
+
+// asUserCommand wraps a command running lse.sh, so that it enumerates from the perspective of the '--as-user' user
+// rather than of the container's user, usually root, which overstates what a compromised application could do.
+func asUserCommand(command []string) []string {
+	if asUser == "" {
+		return command
+	}
+	uid, _ := strconv.Atoi(asUser)
+	wrapped := []string{command[0], "-c", fmt.Sprintf(asUserScript, uid), command[0]}
+	return append(wrapped, command[1:]...)
+}
@@ -0,0 +1,34 @@
+package cmd
+
+import (
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestAsUserCommandKeepsArgumentsAndStdin(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	asUser = strconv.Itoa(os.Getuid())
+	t.Cleanup(func() { asUser = "" })
+
+	command := asUserCommand([]string{"sh", "-s", "--", "-c", "-l", "1"})
+	run := exec.Command(command[0], command[1:]...)
+	run.Stdin = strings.NewReader(`echo "args: $*"`)
+	output, err := run.Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "kubelse: enumerating as uid " + asUser + "\nargs: -c -l 1\n"
+	if string(output) != expected {
+		t.Errorf("expected %q, got %q", expected, output)
+	}
+	if err := validateAsUser("app"); err == nil {
+		t.Error("a user name was accepted as a uid")
+	}
+}
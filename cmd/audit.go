@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"github.com/hhruszka/k8sexec"
+	"github.com/spf13/pflag"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+	"runtime"
+)
+
+// audit CLI options variables
+var (
+	impersonateUser   string
+	impersonateGroups string
+	auditComment      bool
+)
+
+// userAgent returns the user agent of requests to the Kubernetes API, which identifies kubelse and the current run
+// in audit logs of the cluster.
+func userAgent() string {
+	agent := fmt.Sprintf("kubelse/%s (%s/%s)", valueOrDefault(AppVersion, "dev"), runtime.GOOS, runtime.GOARCH)
+	if runID != "" {
+		agent += " run/" + runID
+	}
+	return agent
+}
+
+// newExecClient creates a client of the cluster identified by its user agent, which impersonates a user and groups
+// if requested.
+func newExecClient(kubeconfig string, namespace string) (*k8sexec.K8SExec, error) {
+	config, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
+	if err != nil {
+		return nil, err
+	}
+	allowClusterHost(config.Host)
+	if err := checkCredentialPlugin(config); err != nil {
+		return nil, err
+	}
+	config.UserAgent = userAgent()
+	if impersonateUser != "" {
+		config.Impersonate.UserName = impersonateUser
+		config.Impersonate.Groups = untangleOption(impersonateGroups)
+	}
+
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	return &k8sexec.K8SExec{Config: config, Clientset: clientset, Namespace: namespace}, nil
+}
+
+// identifyRun puts the ID of a new run in the user agent of execs, which are started with the client's
+// configuration.
+func identifyRun(k8s *k8sexec.K8SExec) {
+	if k8s != nil && k8s.Config != nil {
+		k8s.Config.UserAgent = userAgent()
+	}
+}
+
+// auditCommand wraps a command run in a container, so that the exec request, recorded in audit logs of the cluster
+// with its command, carries the run ID in a shell comment. The wrapped command gets the same arguments and stdin.
+func auditCommand(command []string) []string {
+	if !auditComment || runID == "" {
+		return command
+	}
+	wrapped := []string{command[0], "-c", fmt.Sprintf(`exec "$0" "$@" # kubelse run %s`, runID), command[0]}
+	return append(wrapped, command[1:]...)
+}
+
+// validateImpersonation checks that groups are impersonated together with a user, as the Kubernetes API requires.
+func validateImpersonation() error {
+	if impersonateGroups != "" && impersonateUser == "" {
+		return errors.New("The option '--impersonate-group' requires a user to be impersonated with '--impersonate'")
+	}
+	return nil
+}
+
+// addAuditFlags adds options identifying exec activity of kubelse in audit logs.
+func addAuditFlags(flags *pflag.FlagSet) {
+	flags.StringVar(&impersonateUser, "impersonate", "", "a user to impersonate, e.g. a dedicated scanner identity, so that audit logs attribute execs to it")
+	flags.StringVar(&impersonateGroups, "impersonate-group", "", "comma-separated groups to impersonate, requires '--impersonate'")
+	flags.BoolVar(&auditComment, "audit-comment", false, "put the run ID in a shell comment of every scan exec, so that audit logs of the cluster record it with the exec command")
+}
@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/spf13/pflag"
+	"io"
+	"k8s.io/client-go/rest"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// authentication CLI options variables
+var authTimeout time.Duration
+
+// credentialPluginHints tell how to install well-known exec credential plugins, when a kubeconfig has no install hint
+var credentialPluginHints = map[string]string{
+	"aws":                    "install the AWS CLI v2 and log in, e.g. with 'aws sso login'",
+	"aws-iam-authenticator":  "install it from https://github.com/kubernetes-sigs/aws-iam-authenticator",
+	"gke-gcloud-auth-plugin": "install it with 'gcloud components install gke-gcloud-auth-plugin' and log in with 'gcloud auth login'",
+	"gcloud":                 "install the Google Cloud CLI and log in with 'gcloud auth login'",
+	"kubelogin":              "install it with 'az aks install-cli' and log in with 'az login'",
+}
+
+// AuthError is a failure to authenticate to the cluster, which is reported as it is, not as an internal error.
+type AuthError struct {
+	message string
+}
+
+func (e *AuthError) Error() string {
+	return e.message
+}
+
+// clientError returns an error of creating a client of the cluster, authentication failures as they are.
+func clientError(err error) error {
+	var authErr *AuthError
+	if errors.As(err, &authErr) {
+		return err
+	}
+	return fmt.Errorf("Internal application error: %s\n", err.Error())
+}
+
+// pluginInstallHint returns how to install an exec credential plugin, the hint of the kubeconfig if it has one.
+func pluginInstallHint(provider *clientcmdapi.ExecConfig) string {
+	hint := strings.TrimSpace(provider.InstallHint)
+	if hint == "" {
+		hint = valueOrDefault(credentialPluginHints[filepath.Base(provider.Command)], "install it or fix the command of the kubeconfig user")
+	}
+	return hint
+}
+
+// checkCredentialPlugin runs the exec credential plugin of the kubeconfig user, e.g. 'aws eks get-token',
+// gke-gcloud-auth-plugin or kubelogin, upfront, so that a missing plugin, an expired login or a plugin waiting for
+// a login longer than '--auth-timeout' is reported clearly instead of failing the first request to the cluster.
+// client-go runs the plugin again, plugins cache tokens between runs.
+func checkCredentialPlugin(config *rest.Config) error {
+	provider := config.ExecProvider
+	if provider == nil {
+		return nil
+	}
+	name := filepath.Base(provider.Command)
+	path, err := exec.LookPath(provider.Command)
+	if err != nil {
+		return &AuthError{fmt.Sprintf("[-] The credential plugin %q of the kubeconfig is not installed or not in PATH, %s\n", name, pluginInstallHint(provider))}
+	}
+	if authTimeout <= 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), authTimeout)
+	defer cancel()
+	plugin := exec.CommandContext(ctx, path, provider.Args...)
+	// children of a killed plugin, e.g. a browser helper, must not keep its output open
+	plugin.WaitDelay = time.Second
+	plugin.Env = os.Environ()
+	for _, env := range provider.Env {
+		plugin.Env = append(plugin.Env, env.Name+"="+env.Value)
+	}
+	// the plugin may ask for a login, e.g. a device code, only if somebody can answer it
+	promptable := interactive && provider.InteractiveMode != clientcmdapi.NeverExecInteractiveMode
+	info, _ := json.Marshal(map[string]any{
+		"apiVersion": provider.APIVersion,
+		"kind":       "ExecCredential",
+		"spec":       map[string]any{"interactive": promptable},
+	})
+	plugin.Env = append(plugin.Env, "KUBERNETES_EXEC_INFO="+string(info))
+	var stderr bytes.Buffer
+	plugin.Stderr = &stderr
+	if promptable {
+		plugin.Stdin, plugin.Stderr = os.Stdin, io.MultiWriter(os.Stderr, &stderr)
+	}
+
+	_, err = plugin.Output()
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return &AuthError{fmt.Sprintf("[-] The credential plugin %q did not return credentials within '--auth-timeout' %s, it may wait for a login, e.g. in a browser, log in to the cluster first\n", name, authTimeout)}
+	case err != nil:
+		message := err.Error()
+		if lines := strings.Split(strings.TrimSpace(stderr.String()), "\n"); lines[len(lines)-1] != "" {
+			message = lines[len(lines)-1]
+		}
+		return &AuthError{fmt.Sprintf("[-] The credential plugin %q failed, log in to the cluster again or %s: %s\n", name, pluginInstallHint(provider), message)}
+	}
+	return nil
+}
+
+// addAuthFlags adds options of authentication to the cluster.
+func addAuthFlags(flags *pflag.FlagSet) {
+	flags.DurationVar(&authTimeout, "auth-timeout", time.Minute, "how long an exec credential plugin of the kubeconfig, e.g. aws eks get-token or kubelogin, may take to return credentials, 0 disables the check")
+}
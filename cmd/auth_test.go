@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCredentialPluginFailuresAreExplained(t *testing.T) {
+	dir := t.TempDir()
+	kubeconfigOf := func(command string) string {
+		file := filepath.Join(dir, filepath.Base(command)+".yaml")
+		content := fmt.Sprintf(`apiVersion: v1
+kind: Config
+clusters:
+- name: test
+  cluster:
+    server: https://127.0.0.1:6443
+users:
+- name: test
+  user:
+    exec:
+      apiVersion: client.authentication.k8s.io/v1beta1
+      command: %s
+      args: [eks, get-token]
+      interactiveMode: Never
+contexts:
+- name: test
+  context: {cluster: test, user: test}
+current-context: test
+`, command)
+		if err := os.WriteFile(file, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		return file
+	}
+	plugin := func(name string, script string) string {
+		file := filepath.Join(dir, name)
+		if err := os.WriteFile(file, []byte("#!/bin/sh\n"+script+"\n"), 0700); err != nil {
+			t.Fatal(err)
+		}
+		return file
+	}
+	defer func(timeout time.Duration) { authTimeout = timeout }(authTimeout)
+	authTimeout = 200 * time.Millisecond
+
+	for _, tc := range []struct {
+		command  string
+		expected string
+	}{
+		{"aws", "not installed or not in PATH, install the AWS CLI v2"},
+		{plugin("expired", `echo "Error loading SSO Token: Token for sso has expired" >&2; exit 255`), "failed, log in to the cluster again or install it or fix the command of the kubeconfig user: Error loading SSO Token"},
+		{plugin("browser", "sleep 5"), "did not return credentials within '--auth-timeout' 200ms"},
+		{plugin("valid", `echo '{"kind":"ExecCredential","apiVersion":"client.authentication.k8s.io/v1beta1","status":{"token":"t"}}'`), ""},
+	} {
+		t.Setenv("PATH", dir+string(os.PathListSeparator)+"/usr/bin:/bin")
+		_, err := newExecClient(kubeconfigOf(tc.command), "default")
+		switch {
+		case tc.expected == "" && err != nil:
+			t.Errorf("%s: expected the plugin accepted, got %v", tc.command, err)
+		case tc.expected != "" && (err == nil || !strings.Contains(clientError(err).Error(), tc.expected)):
+			t.Errorf("%s: expected an error containing %q, got %v", tc.command, tc.expected, err)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// error budget CLI options variables
+var maxFailures string
+
+// failureBudget counts failed execs of a run and tells when there were too many of them, which usually means that
+// execs are blocked, e.g. by RBAC, an admission webhook or a policy engine, and scanning further is pointless.
+type failureBudget struct {
+	mu       sync.Mutex
+	limit    int
+	failures int
+	aborted  []Container
+	// cancel cancels the run, once the budget is exhausted
+	cancel context.CancelCauseFunc
+}
+
+// errorBudget is the failure budget of the current run, it is nil if failures are not limited
+var errorBudget *failureBudget
+
+// parseMaxFailures parses a value of the max failures option, i.e. a number of failures or a percentage of
+// containers to be scanned, and returns the number of tolerated failures.
+func parseMaxFailures(value string, containers int) (int, error) {
+	if percent, ok := strings.CutSuffix(value, "%"); ok {
+		p, err := strconv.Atoi(percent)
+		if err != nil || p < 0 || p > 100 {
+			return 0, errors.New("a percentage has to be 0-100%")
+		}
+		return containers * p / 100, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, errors.New("expected a non-negative number of failures or a percentage, e.g. 10 or 5%")
+	}
+	return n, nil
+}
+
+// newErrorBudget sets up the failure budget of a run scanning a number of containers. It returns a context of
+// the run, which is cancelled once the budget is exhausted.
+func newErrorBudget(ctx context.Context, containers int) context.Context {
+	errorBudget = nil
+	if maxFailures == "" {
+		return ctx
+	}
+	limit, _ := parseMaxFailures(maxFailures, containers)
+	ctx, cancel := context.WithCancelCause(ctx)
+	errorBudget = &failureBudget{limit: limit, cancel: cancel}
+	return ctx
+}
+
+// record counts a result of a scan and returns true if it exhausted the budget, in which case the run is
+// cancelled.
+func (b *failureBudget) record(result Result) bool {
+	if b == nil || result.status() != StatusFailed {
+		return false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	if b.failures != b.limit+1 {
+		return false
+	}
+	b.cancel(errBudgetExhausted)
+	return true
+}
+
+// exhausted tells if more execs failed than the budget tolerates.
+func (b *failureBudget) exhausted() bool {
+	if b == nil {
+		return false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.failures > b.limit
+}
+
+// abort records a container, which was not scanned since the budget was exhausted.
+func (b *failureBudget) abort(container Container) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.aborted = append(b.aborted, container)
+}
+
+// finish adds containers, which were not scanned, to skipped containers of the run and returns an error if
+// the run was aborted.
+func (b *failureBudget) finish() error {
+	if !b.exhausted() {
+		return nil
+	}
+	for _, container := range b.aborted {
+		skippedContainers = append(skippedContainers, SkippedContainer{container, "run aborted, too many failed execs"})
+	}
+	return fmt.Errorf("[-] Run aborted after %d failed execs exceeded the '--max-failures %s' budget, %d containers were not scanned\n", b.failures, maxFailures, len(b.aborted))
+}
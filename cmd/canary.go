@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/hhruszka/k8sexec"
+	"math/rand"
+	"time"
+)
+
+// runCanary scans a number of randomly selected containers, reports how the scans went and decides, based on
+// the canary threshold or user's confirmation, if the remaining containers should be scanned. It returns
+// results of the canary scans and containers that were not part of the canary group.
+func runCanary(ctx context.Context, k8s *k8sexec.K8SExec, containers []ContainerInfo) ([]Result, []ContainerInfo, error) {
+	shuffled := make([]ContainerInfo, len(containers))
+	copy(shuffled, containers)
+	rand.Shuffle(len(shuffled), func(i, j int) {
+		shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
+	})
+	canaries, remaining := shuffled[:canary], shuffled[canary:]
+
+	log(fmt.Sprintf("[*] Scanning %d canary containers first\n", len(canaries)))
+	results := scanTargets(ctx, k8s, canaries)
+	// canaries are not scanned e.g. when their pods are terminating or the error budget is spent, which tells
+	// nothing good about the rest
+	if len(results) == 0 {
+		return results, nil, fmt.Errorf("[-] None of %d canary containers was scanned, aborting\n", len(canaries))
+	}
+
+	var (
+		succeeded int
+		total     time.Duration
+	)
+	for _, result := range results {
+		if result.retCode == k8sexec.Success {
+			succeeded++
+		}
+		total += result.duration
+	}
+	rate := 100 * succeeded / len(results)
+	log(fmt.Sprintf("[+] Canary scan: %d/%d succeeded (%d%%), average duration %s\n", succeeded, len(results), rate, (total / time.Duration(len(results))).Round(time.Second)))
+
+	switch {
+	case canaryThreshold > 0 && rate >= canaryThreshold:
+		log(fmt.Sprintf("[+] Canary success rate meets the %d%% threshold\n", canaryThreshold))
+	case canaryThreshold > 0:
+		return results, nil, fmt.Errorf("[-] Canary success rate %d%% is below the %d%% threshold, aborting\n", rate, canaryThreshold)
+	case !quiet && interactive:
+		if !promptYN(fmt.Sprintf("\nDo you wish to proceed with testing the remaining %d containers? (Y/N): ", len(remaining))) {
+			return results, nil, errors.New("Action cancelled.")
+		}
+	}
+
+	log(fmt.Sprintf("[*] Scanning remaining %d containers\n", len(remaining)))
+	return results, remaining, nil
+}
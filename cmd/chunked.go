@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"github.com/hhruszka/k8sexec"
+	"regexp"
+	"strings"
+)
+
+// chunked execution CLI options variables
+var chunked bool
+
+// chunkSections are sections of lse.sh run by '--chunked' one exec at a time, in the order lse.sh runs them
+var chunkSections = []string{"usr", "sud", "fst", "sys", "sec", "ret", "net", "srv", "sof", "ctn"}
+
+// lse.sh prints these banners after its info header, they are not sections of tests
+var infoBannerRegexp = regexp.MustCompile(`^(Current Output Verbosity Level|Already running as)`)
+
+// lseChunks returns selections of lse.sh run in separate execs of a container: sections or tests selected for
+// the container, or all sections run by default.
+func lseChunks(container ContainerInfo) []string {
+	if container.settings.sections == "" {
+		return chunkSections
+	}
+	var chunks []string
+	for _, selection := range strings.Split(container.settings.sections, ",") {
+		if selection = strings.TrimSpace(selection); selection != "" {
+			chunks = append(chunks, selection)
+		}
+	}
+	return chunks
+}
+
+// isFinishedLine tells if a line of lse.sh output is the line it prints when it exits.
+func isFinishedLine(line string) bool {
+	match := sectionRegexp.FindStringSubmatch(strings.TrimSpace(stripANSI(line)))
+	return match != nil && match[1] == "FINISHED"
+}
+
+// chunkTests returns output of tests of a chunk: the info header lse.sh prints in every exec is dropped from all
+// chunks but the first, and the FINISHED line from all chunks but the last.
+func chunkTests(stdout []string, first bool, last bool) []string {
+	var lines []string
+	inHeader := !first
+	for _, line := range stdout {
+		if inHeader {
+			match := sectionRegexp.FindStringSubmatch(strings.TrimSpace(stripANSI(line)))
+			if match == nil || infoBannerRegexp.MatchString(match[1]) {
+				continue
+			}
+			inHeader = false
+		}
+		if !last && isFinishedLine(line) {
+			break
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// execChunked runs lse.sh in a container one section at a time, so that no exec outlives limits of exec sessions
+// enforced by some managed clusters, and aggregates output of all execs as if lse.sh ran once. The first failed
+// exec gives the exit code, a stalled exec, or one aborted for usage of the container, stops the scan with output
+// produced until then.
+func execChunked(ctx context.Context, k8s *k8sexec.K8SExec, container ContainerInfo, payload []byte) (*k8sexec.ExecutionStatus, bool) {
+	chunks := lseChunks(container)
+	aggregated := &k8sexec.ExecutionStatus{Pod: container.container.Pod, Container: container.container.Container, RetCode: k8sexec.Success}
+	for idx, chunk := range chunks {
+		chunkContainer := container
+		chunkContainer.settings.sections = chunk
+		execStatus, terminating := execUnlessTerminating(ctx, k8s, container.container, lseCommand(chunkContainer), payload)
+		if terminating {
+			return execStatus, true
+		}
+		stopped := execStalled(execStatus) || execOverloaded(execStatus) != "" || ctx.Err() != nil
+		aggregated.Stdout = append(aggregated.Stdout, chunkTests(execStatus.Stdout, idx == 0, idx == len(chunks)-1 || stopped)...)
+		aggregated.Stderr = append(aggregated.Stderr, nonEmpty(execStatus.Stderr)...)
+		if stopped {
+			aggregated.Error = append(execStatus.Error, aggregated.Error...)
+			aggregated.RetCode = execStatus.RetCode
+			return aggregated, false
+		}
+		if execStatus.RetCode != k8sexec.Success {
+			aggregated.Error = append(aggregated.Error, fmt.Sprintf("section %s: %s", chunk, strings.Join(nonEmpty(execStatus.Error), "; ")))
+			if aggregated.RetCode == k8sexec.Success {
+				aggregated.RetCode = execStatus.RetCode
+			}
+		}
+	}
+	return aggregated, false
+}
+
+// execLse runs lse.sh in a container, in a single exec or with '--chunked' in an exec per section.
+func execLse(ctx context.Context, k8s *k8sexec.K8SExec, container ContainerInfo, payload []byte) (*k8sexec.ExecutionStatus, bool) {
+	if chunked {
+		return execChunked(ctx, k8s, container, payload)
+	}
+	return execUnlessTerminating(ctx, k8s, container.container, lseCommand(container), payload)
+}
+
+// nonEmpty returns lines, which are not empty.
+func nonEmpty(lines []string) []string {
+	var kept []string
+	for _, line := range lines {
+		if line != "" {
+			kept = append(kept, line)
+		}
+	}
+	return kept
+}
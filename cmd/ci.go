@@ -0,0 +1,168 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// CI CLI options variables
+var (
+	ciMode string
+	ciFile string
+)
+
+// ciSeverities maps finding severities to levels of GitHub workflow commands and GitLab code quality issues,
+// info findings are not annotated.
+var ciSeverities = map[string][2]string{
+	SeverityCritical:    {"error", "critical"},
+	SeverityInteresting: {"warning", "minor"},
+}
+
+// CodeQualityIssue is an issue of a GitLab code quality report.
+type CodeQualityIssue struct {
+	Description string              `json:"description"`
+	CheckName   string              `json:"check_name"`
+	Fingerprint string              `json:"fingerprint"`
+	Severity    string              `json:"severity"`
+	Location    CodeQualityLocation `json:"location"`
+	// Content is a markdown body shown with the issue, guidance on fixing the finding
+	Content *CodeQualityContent `json:"content,omitempty"`
+}
+
+// CodeQualityContent is a markdown body of a GitLab code quality issue.
+type CodeQualityContent struct {
+	Body string `json:"body"`
+}
+
+// CodeQualityLocation is a location of a GitLab code quality issue, workloads are used as paths.
+type CodeQualityLocation struct {
+	Path  string         `json:"path"`
+	Lines map[string]int `json:"lines"`
+}
+
+// escapeWorkflowCommand escapes a value of a GitHub workflow command, properties need ':' and ',' escaped too.
+func escapeWorkflowCommand(value string, property bool) string {
+	value = strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(value)
+	if property {
+		value = strings.NewReplacer(":", "%3A", ",", "%2C").Replace(value)
+	}
+	return value
+}
+
+// ciFindings returns merged findings, which are annotated in CI, with sorted names of their workloads. Findings
+// triaged as accepted risks are not annotated, so that analysts' decisions are not raised again.
+func ciFindings(results []Result) ([]string, map[string][]*mergedFinding) {
+	workloads := mergeFindings(results)
+	var names []string
+	for name, findings := range workloads {
+		var annotated []*mergedFinding
+		for _, merged := range findings {
+			if _, ok := ciSeverities[merged.finding.Severity]; ok && !merged.finding.AcceptedRisk() {
+				annotated = append(annotated, merged)
+			}
+		}
+		if len(annotated) > 0 {
+			workloads[name] = annotated
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, workloads
+}
+
+// githubAnnotations returns workflow commands annotating findings of every workload in a GitHub Actions run.
+func githubAnnotations(results []Result) []string {
+	var commands []string
+	names, workloads := ciFindings(results)
+	owners := workloadOwners(results)
+	for _, name := range names {
+		for _, merged := range workloads[name] {
+			finding := merged.finding
+			title := fmt.Sprintf("%s/%s: %s %s", scannedNamespace(), name, finding.ID, finding.Name)
+			message := fmt.Sprintf("Affected containers: %s", strings.Join(merged.containers, ", "))
+			if owner := owners[name]; owner != "" {
+				message = fmt.Sprintf("Owner: %s\n%s", owner, message)
+			}
+			if len(finding.Details) > 0 {
+				message += "\n" + strings.Join(finding.Details, "\n")
+			}
+			if finding.Remediation != "" {
+				message += "\n" + fmt.Sprintf(tr("Remediation: %s"), finding.Remediation)
+			}
+			commands = append(commands, fmt.Sprintf("::%s title=%s::%s", ciSeverities[finding.Severity][0],
+				escapeWorkflowCommand(title, true), escapeWorkflowCommand(message, false)))
+		}
+	}
+	return commands
+}
+
+// gitlabCodeQuality returns a GitLab code quality report of findings of every workload.
+func gitlabCodeQuality(results []Result) []CodeQualityIssue {
+	issues := []CodeQualityIssue{}
+	names, workloads := ciFindings(results)
+	owners := workloadOwners(results)
+	for _, name := range names {
+		for _, merged := range workloads[name] {
+			finding := merged.finding
+			path := fmt.Sprintf("%s/%s", scannedNamespace(), name)
+			sum := sha256.Sum256([]byte(path + "\x00" + finding.ID + "\x00" + strings.Join(finding.Details, "\n")))
+			var content *CodeQualityContent
+			if finding.Remediation != "" {
+				content = &CodeQualityContent{Body: fmt.Sprintf("**%s** %s", fmt.Sprintf(tr("Remediation of %s:"), finding.ID), finding.Remediation)}
+			}
+			issues = append(issues, CodeQualityIssue{
+				Description: fmt.Sprintf("%s %s (%s), owner: %s, affected containers: %s", finding.ID, finding.Name, finding.Section, valueOrDefault(owners[name], "unowned"), strings.Join(merged.containers, ", ")),
+				CheckName:   finding.ID,
+				Fingerprint: fmt.Sprintf("%x", sum),
+				Severity:    ciSeverities[finding.Severity][1],
+				Location:    CodeQualityLocation{Path: path, Lines: map[string]int{"begin": 1}},
+				Content:     content,
+			})
+		}
+	}
+	return issues
+}
+
+// emitCIAnnotations surfaces findings of a run in a CI pipeline, i.e. prints GitHub workflow commands or saves
+// a GitLab code quality report or a SARIF log. Annotations are printed even in the quiet mode, since CI needs them.
+func emitCIAnnotations(results []Result) {
+	switch ciMode {
+	case "github":
+		for _, command := range githubAnnotations(results) {
+			fmt.Println(command)
+		}
+	case "gitlab":
+		fileName := ciFile
+		if fileName == "" {
+			fileName = filepath.Join(directory, "gl-code-quality-report.json")
+		}
+		content, err := json.MarshalIndent(gitlabCodeQuality(results), "", "  ")
+		if err == nil {
+			err = os.WriteFile(fileName, content, 0666)
+		}
+		if err != nil {
+			log(fmt.Sprintf("[-] Error saving GitLab code quality report: %s\n", err.Error()))
+			return
+		}
+		log(fmt.Sprintf("[+] GitLab code quality report saved to %s\n", fileName))
+	case "sarif":
+		fileName := ciFile
+		if fileName == "" {
+			fileName = filepath.Join(directory, "kubelse.sarif")
+		}
+		content, err := json.MarshalIndent(sarifLog(results), "", "  ")
+		if err == nil {
+			err = os.WriteFile(fileName, content, 0666)
+		}
+		if err != nil {
+			log(fmt.Sprintf("[-] Error saving SARIF log: %s\n", err.Error()))
+			return
+		}
+		log(fmt.Sprintf("[+] SARIF log saved to %s\n", fileName))
+	}
+}
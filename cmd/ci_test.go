@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGithubAnnotations(t *testing.T) {
+	namespace = "default"
+	results := []Result{
+		{container: ContainerInfo{container: Container{Pod: "web-1", Container: "app", Workload: "Deployment/web"}}, scanReport: lseOutput},
+		{container: ContainerInfo{container: Container{Pod: "web-2", Container: "app", Workload: "Deployment/web"}}, scanReport: lseOutput},
+	}
+
+	commands := githubAnnotations(results)
+	if len(commands) != 1 {
+		t.Fatalf("expected identical findings of a workload to be annotated once, got %v", commands)
+	}
+	expected := "::error title=default/Deployment/web%3A fst010 Can we write to /etc/passwd?::Affected containers: web-1/app, web-2/app%0A-rw-rw-rw- 1 root root 922 /etc/passwd%0ARemediation: " + remediationOf("fst010")
+	if commands[0] != expected {
+		t.Errorf("expected %q, got %q", expected, commands[0])
+	}
+
+	issues := gitlabCodeQuality(results)
+	if len(issues) != 1 || issues[0].Severity != "critical" || issues[0].Location.Path != "default/Deployment/web" || issues[0].Content == nil {
+		t.Errorf("unexpected code quality report %+v", issues)
+	}
+}
+
+func TestCIAnnotationsHonourTriage(t *testing.T) {
+	namespace = "default"
+	results := []Result{
+		{container: ContainerInfo{container: Container{Pod: "web-1", Container: "app", Workload: "Deployment/web"}}, scanReport: lseOutput,
+			triage: map[string]*TriageDecision{"fst010": {State: TriageAcceptedRisk, Comment: "read-only root filesystem", Analyst: "alice"}}},
+		{container: ContainerInfo{container: Container{Pod: "api-1", Container: "app", Workload: "Deployment/api"}}, scanReport: lseOutput,
+			triage: map[string]*TriageDecision{"fst010": {State: TriageFalsePositive}}},
+		{container: ContainerInfo{container: Container{Pod: "db-1", Container: "app", Workload: "StatefulSet/db"}}, scanReport: lseOutput},
+	}
+
+	if commands := githubAnnotations(results); len(commands) != 1 || !strings.Contains(commands[0], "StatefulSet/db") {
+		t.Errorf("expected only the untriaged finding annotated, got %v", commands)
+	}
+	if issues := gitlabCodeQuality(results); len(issues) != 1 || issues[0].Location.Path != "default/StatefulSet/db" {
+		t.Errorf("expected only the untriaged finding in the code quality report, got %+v", issues)
+	}
+
+	sarif := sarifLog(results)
+	suppressions := make(map[string][]SarifSuppression)
+	for _, result := range sarif.Runs[0].Results {
+		suppressions[result.Locations[0].PhysicalLocation.ArtifactLocation.URI] = result.Suppressions
+	}
+	if len(suppressions) != 3 || len(suppressions["default/StatefulSet/db"]) != 0 {
+		t.Fatalf("expected every finding in the SARIF log and the untriaged one not suppressed, got %+v", suppressions)
+	}
+	if accepted := suppressions["default/Deployment/web"]; len(accepted) != 1 || accepted[0].Kind != "external" || accepted[0].Justification != "accepted-risk: read-only root filesystem (alice)" {
+		t.Errorf("unexpected suppression of an accepted risk %+v", accepted)
+	}
+	if falsePositive := suppressions["default/Deployment/api"]; len(falsePositive) != 1 || falsePositive[0].Status != "accepted" {
+		t.Errorf("unexpected suppression of a false positive %+v", falsePositive)
+	}
+}
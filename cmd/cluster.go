@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"github.com/hhruszka/k8sexec"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// ClusterInfo identifies a cluster, so that reports can be attributed to it later.
+type ClusterInfo struct {
+	Name    string `json:"Name"`
+	Context string `json:"Context,omitempty"`
+	Server  string `json:"Server"`
+	Version string `json:"Version,omitempty"`
+	// Provider is a recognized provider of the cluster, e.g. eks or openshift
+	Provider  string `json:"Provider,omitempty"`
+	Namespace string `json:"Namespace"`
+}
+
+// cluster describes the cluster being scanned
+var cluster ClusterInfo
+
+// scannedNamespace returns the namespace scanned by the current run, it differs from '--namespace' when an
+// invocation scans many namespaces, e.g. of an ArgoCD application.
+func scannedNamespace() string {
+	return valueOrDefault(cluster.Namespace, namespace)
+}
+
+// getClusterInfo reads a name of the cluster from the current context of the kubeconfig and asks the API server
+// for its version and provider. The in-cluster configuration does not name the cluster.
+func getClusterInfo(k8s *k8sexec.K8SExec) ClusterInfo {
+	if replayFile != "" {
+		return fixture.Cluster
+	}
+	info := ClusterInfo{Name: "in-cluster", Server: k8s.Config.Host, Namespace: k8s.Namespace}
+
+	if kubeconfig != "" {
+		if raw, err := clientcmd.LoadFromFile(kubeconfig); err == nil {
+			info.Context = raw.CurrentContext
+			if context, ok := raw.Contexts[raw.CurrentContext]; ok {
+				info.Name = context.Cluster
+			}
+		}
+	}
+	if version, err := clientsetOf(k8s).Discovery().ServerVersion(); err == nil {
+		info.Version = version.GitVersion
+	}
+	info.Provider = providerOf(k8s, info.Version)
+	return info
+}
@@ -0,0 +1,226 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"github.com/hhruszka/k8sexec"
+	"github.com/spf13/cobra"
+	"github.com/spf13/cobra/doc"
+	corev1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// docs CLI options variables
+var manDirectory string
+
+// completionTimeout limits how long the cluster is queried for completions, so that a slow or unreachable cluster
+// does not hang the shell
+const completionTimeout = 5 * time.Second
+
+// completionClient returns a client of the cluster completions are queried from, it is replaced in tests.
+var completionClient = func() (*k8sexec.K8SExec, error) {
+	return newClient(kubeconfig, namespace)
+}
+
+// completeList completes the last item of a comma-separated list, e.g. '-p web-1,we<TAB>', with names.
+func completeList(names []string, toComplete string) []string {
+	prefix, last := "", toComplete
+	if idx := strings.LastIndex(toComplete, ","); idx >= 0 {
+		prefix, last = toComplete[:idx+1], toComplete[idx+1:]
+	}
+	var completions []string
+	for _, name := range names {
+		if strings.HasPrefix(name, last) {
+			completions = append(completions, prefix+name)
+		}
+	}
+	return completions
+}
+
+// completeNamespaces completes names of namespaces of the cluster.
+func completeNamespaces(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	applySelection(cmd)
+	k8s, err := completionClient()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), completionTimeout)
+	defer cancel()
+	namespaces, err := clientsetOf(k8s).CoreV1().Namespaces().List(ctx, metaV1.ListOptions{})
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	var names []string
+	for _, ns := range namespaces.Items {
+		names = append(names, ns.Name)
+	}
+	return completeList(names, toComplete), cobra.ShellCompDirectiveNoFileComp
+}
+
+// completionPods returns pods of the namespace given with '-n', only the given ones if there are any.
+func completionPods(names []string) ([]corev1.Pod, error) {
+	k8s, err := completionClient()
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), completionTimeout)
+	defer cancel()
+	pods, err := clientsetOf(k8s).CoreV1().Pods(namespace).List(ctx, metaV1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	if len(names) == 0 {
+		return pods.Items, nil
+	}
+	var selected []corev1.Pod
+	for _, pod := range pods.Items {
+		if slices.Contains(names, pod.Name) {
+			selected = append(selected, pod)
+		}
+	}
+	return selected, nil
+}
+
+// completePods completes names of pods of the namespace given with '-n'.
+func completePods(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	applySelection(cmd)
+	pods, err := completionPods(nil)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	var names []string
+	for _, pod := range pods {
+		names = append(names, pod.Name)
+	}
+	return completeList(names, toComplete), cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeContainers completes names of containers of pods given with '-p', or of all pods of the namespace.
+func completeContainers(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	applySelection(cmd)
+	pods, err := completionPods(untangleOption(podscli))
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	names := make(map[string]bool)
+	for _, pod := range pods {
+		for _, container := range pod.Spec.Containers {
+			names[container.Name] = true
+		}
+	}
+	return completeList(sortedKeys(names), toComplete), cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeSelector completes label selectors with labels of pods of the namespace, e.g. app=nginx.
+func completeSelector(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	applySelection(cmd)
+	pods, err := completionPods(nil)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	labels := make(map[string]bool)
+	for _, pod := range pods {
+		for key, value := range pod.Labels {
+			labels[key+"="+value] = true
+		}
+	}
+	return completeList(sortedKeys(labels), toComplete), cobra.ShellCompDirectiveNoFileComp
+}
+
+// completePodAndContainer completes arguments of commands taking a pod and its container, e.g. shell.
+func completePodAndContainer(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	applySelection(cmd)
+	switch len(args) {
+	case 0:
+		names, directive := completePods(cmd, args, toComplete)
+		// a pod is a single argument, not a list
+		return slices.DeleteFunc(names, func(name string) bool { return strings.Contains(name, ",") }), directive
+	case 1:
+		pods, err := completionPods(args[:1])
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveError
+		}
+		var names []string
+		for _, pod := range pods {
+			for _, container := range pod.Spec.Containers {
+				if strings.HasPrefix(container.Name, toComplete) {
+					names = append(names, container.Name)
+				}
+			}
+		}
+		return names, cobra.ShellCompDirectiveNoFileComp
+	}
+	return nil, cobra.ShellCompDirectiveNoFileComp
+}
+
+// registerFlagCompletions completes options of a command naming resources of the cluster with their live names.
+// Options, which the command does not have, are skipped.
+func registerFlagCompletions(command *cobra.Command) {
+	command.RegisterFlagCompletionFunc("namespace", completeNamespaces)
+	command.RegisterFlagCompletionFunc("pods", completePods)
+	command.RegisterFlagCompletionFunc("containers", completeContainers)
+	command.RegisterFlagCompletionFunc("selector", completeSelector)
+}
+
+var completionCmd = &cobra.Command{
+	Use:   "completion bash|zsh|fish|powershell",
+	Short: "Generate a shell completion script",
+	Long: `
+Prints a completion script of a shell, which completes commands and options, and names of namespaces, pods,
+containers and pod labels queried from the cluster, e.g. for bash:
+
+  source <(kubelse completion bash)`,
+	ValidArgs: []string{"bash", "zsh", "fish", "powershell"},
+	Args:      cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+	RunE: func(command *cobra.Command, args []string) error {
+		switch args[0] {
+		case "bash":
+			return cmd.GenBashCompletionV2(os.Stdout, true)
+		case "zsh":
+			return cmd.GenZshCompletion(os.Stdout)
+		case "fish":
+			return cmd.GenFishCompletion(os.Stdout, true)
+		default:
+			return cmd.GenPowerShellCompletionWithDesc(os.Stdout)
+		}
+	},
+}
+
+var docsCmd = &cobra.Command{
+	Use:   "docs",
+	Short: "Generate documentation of kubelse",
+}
+
+var docsManCmd = &cobra.Command{
+	Use:   "man",
+	Short: "Generate man pages",
+	Long: `
+Generates man pages of kubelse and all its commands in a directory, e.g. to be installed in
+/usr/local/share/man/man1.`,
+	Args: cobra.NoArgs,
+	RunE: func(command *cobra.Command, args []string) error {
+		if err := os.MkdirAll(manDirectory, 0755); err != nil {
+			return err
+		}
+		header := &doc.GenManHeader{Title: strings.ToUpper(appName), Section: "1", Source: strings.TrimSpace(appName + " " + AppVersion)}
+		cmd.DisableAutoGenTag = true
+		if err := doc.GenManTree(cmd, header, manDirectory); err != nil {
+			return fmt.Errorf("[-] Error generating man pages: %s\n", err.Error())
+		}
+		log(fmt.Sprintf("[+] Man pages saved to %s\n", manDirectory))
+		return nil
+	},
+}
+
+func init() {
+	// the completion command replaces the default one of cobra
+	cmd.CompletionOptions.DisableDefaultCmd = true
+	docsManCmd.Flags().StringVar(&manDirectory, "dir", "man", "a directory man pages are saved to")
+
+	docsCmd.AddCommand(docsManCmd)
+	cmd.AddCommand(completionCmd, docsCmd)
+}
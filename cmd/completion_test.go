@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"github.com/hhruszka/k8sexec"
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"reflect"
+	"testing"
+)
+
+func TestCompletionOfNamespacesAndPods(t *testing.T) {
+	_, k8s := startTestCluster(t, testPod("web-1", "nginx", nil), testPod("web-2", "nginx", nil), testPod("db-1", "postgres", nil),
+		&corev1.Namespace{ObjectMeta: metaV1.ObjectMeta{Name: "kube-system"}})
+	completionClient = func() (*k8sexec.K8SExec, error) { return k8s, nil }
+	t.Cleanup(func() {
+		completionClient = func() (*k8sexec.K8SExec, error) { return newClient(kubeconfig, namespace) }
+	})
+
+	if names, directive := completeNamespaces(cmd, nil, "kube"); !reflect.DeepEqual(names, []string{"kube-system"}) || directive != cobra.ShellCompDirectiveNoFileComp {
+		t.Errorf("unexpected namespaces %v", names)
+	}
+	if names, _ := completePods(cmd, nil, "web"); !reflect.DeepEqual(names, []string{"web-1", "web-2"}) {
+		t.Errorf("unexpected pods %v", names)
+	}
+	// the last pod of a comma-separated list is completed
+	if names, _ := completePods(cmd, nil, "web-1,d"); !reflect.DeepEqual(names, []string{"web-1,db-1"}) {
+		t.Errorf("unexpected pods %v", names)
+	}
+}
+
+func TestCompletionOfContainersSelectorsAndShellArguments(t *testing.T) {
+	sidecar := testPod("web-1", "nginx", nil)
+	sidecar.Labels = map[string]string{"app": "web"}
+	sidecar.Spec.Containers = append(sidecar.Spec.Containers, corev1.Container{Name: "proxy", Image: "envoy"})
+	_, k8s := startTestCluster(t, sidecar, testPod("db-1", "postgres", nil))
+	completionClient = func() (*k8sexec.K8SExec, error) { return k8s, nil }
+	t.Cleanup(func() {
+		completionClient = func() (*k8sexec.K8SExec, error) { return newClient(kubeconfig, namespace) }
+		podscli = ""
+	})
+
+	if names, _ := completeContainers(cmd, nil, ""); !reflect.DeepEqual(names, []string{"app", "proxy"}) {
+		t.Errorf("unexpected containers %v", names)
+	}
+	// containers of pods given with '-p' only
+	podscli = "db-1"
+	if names, _ := completeContainers(cmd, nil, ""); !reflect.DeepEqual(names, []string{"app"}) {
+		t.Errorf("unexpected containers of db-1 %v", names)
+	}
+	if names, _ := completeSelector(cmd, nil, "ap"); !reflect.DeepEqual(names, []string{"app=web"}) {
+		t.Errorf("unexpected selectors %v", names)
+	}
+	if names, _ := completePodAndContainer(shellCmd, nil, "w"); !reflect.DeepEqual(names, []string{"web-1"}) {
+		t.Errorf("unexpected pods %v", names)
+	}
+	if names, _ := completePodAndContainer(shellCmd, []string{"web-1"}, "p"); !reflect.DeepEqual(names, []string{"proxy"}) {
+		t.Errorf("unexpected containers of web-1 %v", names)
+	}
+}
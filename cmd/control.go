@@ -0,0 +1,224 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"github.com/spf13/cobra"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// control socket CLI options variables
+var (
+	controlSocket       string
+	controlClientSocket string
+)
+
+// controlTimeout is how long a command of the control socket may take to be sent and answered
+const controlTimeout = 10 * time.Second
+
+// scanController pauses execs in containers and limits how many of them run at once, on commands received on
+// the control socket, so that operators can react to cluster alerts without aborting a long run.
+type scanController struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	paused bool
+	// limit is the maximum number of concurrent execs, 0 if they are limited only by workers of stages
+	limit  int
+	active int
+}
+
+func newScanController() *scanController {
+	c := &scanController{}
+	c.cond = sync.NewCond(&c.mu)
+	return c
+}
+
+var scanControl = newScanController()
+
+// acquire blocks while scans are paused or the concurrency limit is reached and registers an exec.
+func (c *scanController) acquire() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for c.paused || (c.limit > 0 && c.active >= c.limit) {
+		c.cond.Wait()
+	}
+	c.active++
+}
+
+// release unregisters a finished exec.
+func (c *scanController) release() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.active--
+	c.cond.Broadcast()
+}
+
+// set pauses or resumes execs and sets the concurrency limit.
+func (c *scanController) set(paused bool, limit int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.paused, c.limit = paused, limit
+	c.cond.Broadcast()
+}
+
+// state returns whether execs are paused, the concurrency limit and the number of execs in progress.
+func (c *scanController) state() (bool, int, int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.paused, c.limit, c.active
+}
+
+// controlStatus describes the state of the run: whether it is paused, its concurrency and execs in progress.
+func controlStatus() string {
+	paused, limit, active := scanControl.state()
+	state := "running"
+	if paused {
+		state = "paused"
+	}
+	concurrency := "not limited"
+	if limit > 0 {
+		concurrency = fmt.Sprintf("limited to %d execs", limit)
+	}
+	lines := []string{fmt.Sprintf("run %s of namespace %s: %s, concurrency %s, %d execs in progress", valueOrDefault(runID, "not started"), scannedNamespace(), state, concurrency, active)}
+	return strings.Join(append(lines, runningLines(running.list(), 20)...), "\n")
+}
+
+// handleControlCommand runs a command received on the control socket and returns its response, errors start
+// with "error:".
+func handleControlCommand(command string) string {
+	fields := strings.Fields(command)
+	if len(fields) == 0 {
+		return "error: no command, valid commands are pause, resume, status and reduce-concurrency <n>"
+	}
+	paused, limit, active := scanControl.state()
+	switch {
+	case fields[0] == "pause" && len(fields) == 1:
+		scanControl.set(true, limit)
+		log(fmt.Sprintf("\n[*] Scans paused with the control socket, %d execs in progress finish\n", active))
+		return fmt.Sprintf("paused, %d execs in progress finish, no new execs start until resumed", active)
+	case fields[0] == "resume" && len(fields) == 1:
+		scanControl.set(false, limit)
+		log(fmt.Sprintln("\n[*] Scans resumed with the control socket"))
+		return "resumed"
+	case fields[0] == "status" && len(fields) == 1:
+		return controlStatus()
+	case fields[0] == "reduce-concurrency" && len(fields) == 2:
+		n, err := strconv.Atoi(fields[1])
+		if err != nil || n < 0 {
+			return fmt.Sprintf("error: invalid concurrency %q, it has to be a number of execs, 0 removes the limit", fields[1])
+		}
+		scanControl.set(paused, n)
+		if n == 0 {
+			log(fmt.Sprintln("\n[*] Concurrency limit removed with the control socket"))
+			return "concurrency not limited"
+		}
+		log(fmt.Sprintf("\n[*] Concurrency limited to %d execs with the control socket\n", n))
+		return fmt.Sprintf("concurrency limited to %d execs, %d execs in progress", n, active)
+	}
+	return fmt.Sprintf("error: invalid command %q, valid commands are pause, resume, status and reduce-concurrency <n>", command)
+}
+
+// serveControlSocket accepts commands on a unix socket, readable by the user running kubelse only, until the
+// returned function is called. A socket left by a run, which did not exit cleanly, is replaced, any other file
+// is not.
+func serveControlSocket(path string) (func(), error) {
+	if conn, err := net.Dial("unix", path); err == nil {
+		conn.Close()
+		return nil, fmt.Errorf("%s is in use by another run", path)
+	}
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and is not a socket", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	listener, err := listenControlSocket(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				conn.SetDeadline(time.Now().Add(controlTimeout))
+				command, err := bufio.NewReader(conn).ReadString('\n')
+				if err != nil && command == "" {
+					return
+				}
+				fmt.Fprintln(conn, handleControlCommand(command))
+			}()
+		}
+	}()
+	log(fmt.Sprintf("[+] Accepting pause, resume, status and reduce-concurrency commands on %s\n", path))
+	return func() { listener.Close() }, nil
+}
+
+// sendControlCommand sends a command to the control socket of a running scan and returns its response.
+func sendControlCommand(path string, command string) (string, error) {
+	conn, err := net.DialTimeout("unix", path, controlTimeout)
+	if err != nil {
+		return "", fmt.Errorf("[-] No scan is accepting commands on %s: %s\n", path, err.Error())
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(controlTimeout))
+	if _, err := fmt.Fprintln(conn, command); err != nil {
+		return "", err
+	}
+	var response strings.Builder
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		response.WriteString(scanner.Text() + "\n")
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	if message, ok := strings.CutPrefix(response.String(), "error: "); ok {
+		return "", errors.New(strings.ToUpper(message[:1]) + strings.TrimSpace(message[1:]))
+	}
+	return response.String(), nil
+}
+
+var controlCmd = &cobra.Command{
+	Use:   "control pause|resume|status|reduce-concurrency <n> --socket <path>",
+	Short: "Pause, resume or slow down a running scan through its control socket",
+	Long: `
+Sends a command to a scan started with '--control-socket': pause stops new execs in containers, execs in progress
+finish, until resume, status prints the state of the run and containers being scanned and reduce-concurrency
+limits how many execs run at once, 0 removes the limit. The run is not aborted, e.g. when the cluster alerts.`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if controlClientSocket == "" {
+			return errors.New("A control socket of the scan has to be provided with '--socket'")
+		}
+		response, err := sendControlCommand(controlClientSocket, strings.Join(args, " "))
+		if err != nil {
+			return err
+		}
+		fmt.Print(response)
+		return nil
+	},
+}
+
+func init() {
+	controlCmd.Flags().StringVar(&controlClientSocket, "socket", "", "a control socket of the scan, provided to it with '--control-socket'")
+
+	cmd.AddCommand(controlCmd)
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package cmd
+
+import "net"
+
+// listenControlSocket creates a unix socket, its access is restricted after it is created on this platform.
+func listenControlSocket(path string) (net.Listener, error) {
+	return net.Listen("unix", path)
+}
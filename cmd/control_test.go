@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestControlSocketPausesAndLimitsExecs(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "control.sock")
+	stop, err := serveControlSocket(socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		stop()
+		scanControl.set(false, 0)
+	})
+	if _, err := serveControlSocket(socket); err == nil {
+		t.Errorf("expected the socket in use by another run rejected")
+	}
+
+	acquired := make(chan bool)
+	exec := func() {
+		scanControl.acquire()
+		acquired <- true
+	}
+	expectBlocked := func(what string) {
+		select {
+		case <-acquired:
+			t.Fatalf("expected execs blocked %s", what)
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+
+	if _, err := sendControlCommand(socket, "pause"); err != nil {
+		t.Fatal(err)
+	}
+	go exec()
+	expectBlocked("while paused")
+	if status, err := sendControlCommand(socket, "status"); err != nil || !strings.Contains(status, ": paused, concurrency not limited, 0 execs in progress") {
+		t.Errorf("expected a paused run, got %q (%v)", status, err)
+	}
+	if _, err := sendControlCommand(socket, "resume"); err != nil {
+		t.Fatal(err)
+	}
+	<-acquired
+
+	if response, err := sendControlCommand(socket, "reduce-concurrency 1"); err != nil || !strings.HasPrefix(response, "concurrency limited to 1 execs") {
+		t.Fatalf("expected the concurrency limited, got %q (%v)", response, err)
+	}
+	go exec()
+	expectBlocked("over the limit")
+	scanControl.release()
+	<-acquired
+	scanControl.release()
+
+	if _, err := sendControlCommand(socket, "reduce-concurrency many"); err == nil || !strings.HasPrefix(err.Error(), "Invalid concurrency") {
+		t.Errorf("expected an invalid concurrency rejected, got %v", err)
+	}
+	if _, err := sendControlCommand(socket, "abort"); err == nil {
+		t.Errorf("expected an unknown command rejected")
+	}
+}
+
+func TestControlSocketDoesNotReplaceOtherFiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "kubeconfig")
+	if err := os.WriteFile(path, []byte("apiVersion: v1"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := serveControlSocket(path); err == nil || !strings.Contains(err.Error(), "not a socket") {
+		t.Errorf("expected a regular file rejected, got %v", err)
+	}
+	if content, err := os.ReadFile(path); err != nil || string(content) != "apiVersion: v1" {
+		t.Errorf("expected the file kept, got %q (%v)", content, err)
+	}
+
+	socket := filepath.Join(t.TempDir(), "control.sock")
+	stop, err := serveControlSocket(socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+	if info, err := os.Stat(socket); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("expected the socket accessible by the owner only, got %v (%v)", info.Mode(), err)
+	}
+}
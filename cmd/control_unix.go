@@ -0,0 +1,16 @@
+//go:build unix
+
+package cmd
+
+import (
+	"net"
+	"syscall"
+)
+
+// listenControlSocket creates a unix socket accessible by the user running kubelse only. The umask is restricted
+// while the socket is created, so that it is never accessible by others.
+func listenControlSocket(path string) (net.Listener, error) {
+	umask := syscall.Umask(0077)
+	defer syscall.Umask(umask)
+	return net.Listen("unix", path)
+}
@@ -0,0 +1,266 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/spf13/cobra"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// coverage CLI options variables
+var (
+	coverage          bool
+	coverageDirectory string
+	coverageSince     time.Duration
+	coverageJSON      bool
+)
+
+// CoverageGroup is coverage of containers of a namespace or a workload.
+type CoverageGroup struct {
+	Name     string  `json:"Name"`
+	Total    int     `json:"Total"`
+	Covered  int     `json:"Covered"`
+	Coverage float64 `json:"Coverage"`
+}
+
+// CoverageReport tells how many containers in scope of runs were enumerated. A container is identified by its
+// namespace, workload and name, so that replicas and pods recreated between runs count once, and it is covered if
+// any run scanned it, even partially.
+type CoverageReport struct {
+	Runs        []string        `json:"Runs"`
+	From        time.Time       `json:"From"`
+	To          time.Time       `json:"To"`
+	Total       int             `json:"Total"`
+	Covered     int             `json:"Covered"`
+	Failed      int             `json:"Failed"`
+	NotTestable int             `json:"NotTestable"`
+	Skipped     int             `json:"Skipped"`
+	Coverage    float64         `json:"Coverage"`
+	SkipReasons map[string]int  `json:"SkipReasons"`
+	Namespaces  []CoverageGroup `json:"Namespaces"`
+	Workloads   []CoverageGroup `json:"Workloads"`
+}
+
+// percentage returns a share of covered containers in percent, rounded to one decimal place.
+func percentage(covered int, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(int(float64(covered)*1000/float64(total)+0.5)) / 10
+}
+
+// coverageGroups returns coverage of groups sorted by name.
+func coverageGroups(totals map[string]int, covered map[string]int) []CoverageGroup {
+	groups := []CoverageGroup{}
+	for name, total := range totals {
+		groups = append(groups, CoverageGroup{Name: name, Total: total, Covered: covered[name], Coverage: percentage(covered[name], total)})
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].Name < groups[j].Name })
+	return groups
+}
+
+// newCoverage returns coverage of containers in scope of runs. A container, which was not covered, is counted as
+// failed, not testable or skipped by its latest run.
+func newCoverage(manifests []Manifest) CoverageReport {
+	report := CoverageReport{Runs: []string{}, SkipReasons: make(map[string]int)}
+	sort.Slice(manifests, func(i, j int) bool { return manifests[i].Started.Before(manifests[j].Started) })
+
+	type containerCoverage struct {
+		namespace, workload string
+		covered             bool
+		// outcome of the latest run, which did not cover the container
+		outcome, reason string
+	}
+	containers := make(map[string]*containerCoverage)
+	record := func(manifest Manifest, entry ManifestEntry, outcome string) {
+		ns := valueOrDefault(entry.Namespace, manifest.Namespace)
+		workload := valueOrDefault(entry.Workload, "Pod/"+entry.Pod)
+		key := ns + "/" + workload + "/" + entry.Container
+		if containers[key] == nil {
+			containers[key] = &containerCoverage{namespace: ns, workload: ns + "/" + workload}
+		}
+		switch {
+		case outcome == "covered":
+			containers[key].covered = true
+		case !containers[key].covered:
+			containers[key].outcome, containers[key].reason = outcome, entry.Reason
+		}
+	}
+	for idx, manifest := range manifests {
+		if idx == 0 || manifest.Started.Before(report.From) {
+			report.From = manifest.Started
+		}
+		if manifest.Finished.After(report.To) {
+			report.To = manifest.Finished
+		}
+		report.Runs = append(report.Runs, manifest.RunID)
+		for _, entry := range manifest.Scanned {
+			if entry.Status == StatusFailed {
+				record(manifest, entry, StatusFailed)
+			} else {
+				record(manifest, entry, "covered")
+			}
+		}
+		for _, entry := range manifest.NotTestable {
+			record(manifest, entry, "not testable")
+		}
+		for _, entry := range manifest.Skipped {
+			record(manifest, entry, "skipped")
+		}
+	}
+
+	namespaces, coveredNamespaces := make(map[string]int), make(map[string]int)
+	workloads, coveredWorkloads := make(map[string]int), make(map[string]int)
+	for _, container := range containers {
+		report.Total++
+		namespaces[container.namespace]++
+		workloads[container.workload]++
+		switch {
+		case container.covered:
+			report.Covered++
+			coveredNamespaces[container.namespace]++
+			coveredWorkloads[container.workload]++
+		case container.outcome == StatusFailed:
+			report.Failed++
+		case container.outcome == "not testable":
+			report.NotTestable++
+		default:
+			report.Skipped++
+			report.SkipReasons[valueOrDefault(container.reason, "unknown")]++
+		}
+	}
+	report.Coverage = percentage(report.Covered, report.Total)
+	report.Namespaces = coverageGroups(namespaces, coveredNamespaces)
+	report.Workloads = coverageGroups(workloads, coveredWorkloads)
+	return report
+}
+
+// String summarizes coverage in a line, e.g. "87.5% of 40 containers in scope covered, ...".
+func (c CoverageReport) String() string {
+	return fmt.Sprintf("%.1f%% of %d containers in scope covered, %d failed, %d not testable, %d skipped", c.Coverage, c.Total, c.Failed, c.NotTestable, c.Skipped)
+}
+
+// renderCoverage renders a coverage report as text: the summary, skip reasons and coverage of every namespace
+// and workload.
+func renderCoverage(report CoverageReport) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "Coverage of %d runs from %s to %s\n", len(report.Runs), formatTimestamp(report.From), formatTimestamp(report.To))
+	fmt.Fprintf(&buf, "%s\n", report.String())
+	if len(report.SkipReasons) > 0 {
+		buf.WriteString("\nSkipped containers:\n")
+		var reasons []string
+		for reason := range report.SkipReasons {
+			reasons = append(reasons, reason)
+		}
+		sort.Strings(reasons)
+		for _, reason := range reasons {
+			fmt.Fprintf(&buf, "  %4d  %s\n", report.SkipReasons[reason], reason)
+		}
+	}
+	w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	for _, section := range []struct {
+		title  string
+		groups []CoverageGroup
+	}{{"Namespace", report.Namespaces}, {"Workload", report.Workloads}} {
+		fmt.Fprintf(w, "\n%s\tCovered\tTotal\tCoverage\n", section.title)
+		for _, group := range section.groups {
+			fmt.Fprintf(w, "%s\t%d\t%d\t%.1f%%\n", group.Name, group.Covered, group.Total, group.Coverage)
+		}
+	}
+	w.Flush()
+	return buf.Bytes()
+}
+
+// saveCoverage saves a coverage report of a run in the reports directory, as json in the json output format and
+// as text otherwise.
+func saveCoverage(manifest Manifest) {
+	report := newCoverage([]Manifest{manifest})
+	ext, content := ".text", renderCoverage(report)
+	if format == "json" {
+		ext = ".json"
+		content, _ = json.MarshalIndent(report, "", "  ")
+	}
+	fileName, err := writeReportWithFallback(fmt.Sprintf("kubelse-coverage-%s-%s%s", fileTimestamp(manifest.Started), shortRunID(), ext), content)
+	if err != nil {
+		log(fmt.Sprintf("[-] Error saving coverage report: %s\n", err.Error()))
+		return
+	}
+	log(fmt.Sprintf("[+] Coverage: %s, saved to %s\n", report.String(), fileName))
+}
+
+// loadManifests reads run manifests saved under a directory, which runs started since a time.
+func loadManifests(dir string, since time.Time) ([]Manifest, error) {
+	var manifests []Manifest
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || !strings.HasPrefix(entry.Name(), "kubelse-manifest-") || filepath.Ext(path) != ".json" {
+			return nil
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		var manifest Manifest
+		if err := json.Unmarshal(content, &manifest); err != nil {
+			log(fmt.Sprintf("[-] Skipping invalid manifest %s: %s\n", path, err.Error()))
+			return nil
+		}
+		if !manifest.Started.Before(since) {
+			manifests = append(manifests, manifest)
+		}
+		return nil
+	})
+	return manifests, err
+}
+
+var coverageCmd = &cobra.Command{
+	Use:   "coverage",
+	Short: "Report how many containers in scope were enumerated over a period",
+	Long: `
+Reads run manifests saved in a directory, by default of the last 30 days, and reports how many containers in
+scope of the runs were enumerated, overall and per namespace and workload, with reasons containers were skipped.
+A container counts once per namespace, workload and name, and it is covered if any run scanned it.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		manifests, err := loadManifests(coverageDirectory, now().Add(-coverageSince))
+		if err != nil {
+			return err
+		}
+		if len(manifests) == 0 {
+			return fmt.Errorf("[-] No run manifests of the last %s found in %s\n", coverageSince, coverageDirectory)
+		}
+		report := newCoverage(manifests)
+		if coverageJSON {
+			content, err := json.MarshalIndent(report, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(content))
+			return nil
+		}
+		fmt.Print(string(renderCoverage(report)))
+		return nil
+	},
+}
+
+func init() {
+	workingDirectory, err := os.Getwd()
+	if err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+	coverageCmd.Flags().StringVarP(&coverageDirectory, "directory", "d", workingDirectory, "a directory with run manifests")
+	coverageCmd.Flags().DurationVar(&coverageSince, "since", 30*24*time.Hour, "include runs started within a duration, e.g. 168h")
+	coverageCmd.Flags().BoolVar(&coverageJSON, "json", false, "print the coverage report as json")
+
+	cmd.AddCommand(coverageCmd)
+}
@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCoverageAggregatesRunManifests(t *testing.T) {
+	started := time.Date(2024, 4, 1, 6, 0, 0, 0, time.UTC)
+	first := Manifest{
+		RunID:     "run-1",
+		Namespace: "payments",
+		Started:   started,
+		Finished:  started.Add(time.Minute),
+		Scanned: []ManifestEntry{
+			{Pod: "api-1", Container: "app", Workload: "Deployment/api", Status: StatusComplete},
+			{Pod: "db-0", Container: "db", Workload: "StatefulSet/db", Status: StatusFailed},
+		},
+		NotTestable: []ManifestEntry{{Pod: "distroless", Container: "app", Reason: "missing shell or utilities required by lse.sh"}},
+		Skipped:     []ManifestEntry{{Pod: "api-2", Container: "istio-proxy", Workload: "Deployment/api", Reason: "ignored by container istio-proxy"}},
+	}
+	second := Manifest{
+		RunID:     "run-2",
+		Namespace: "payments",
+		Started:   started.Add(24 * time.Hour),
+		Finished:  started.Add(24*time.Hour + time.Minute),
+		// a recreated replica of the api workload and a successful scan of the database failed before
+		Scanned: []ManifestEntry{
+			{Pod: "api-9", Container: "app", Workload: "Deployment/api", Status: StatusPartial},
+			{Pod: "db-0", Container: "db", Workload: "StatefulSet/db", Status: StatusComplete},
+		},
+	}
+
+	report := newCoverage([]Manifest{second, first})
+	if report.Total != 4 || report.Covered != 2 || report.NotTestable != 1 || report.Skipped != 1 || report.Failed != 0 {
+		t.Fatalf("expected 2 of 4 containers covered, got %s", report)
+	}
+	if report.Coverage != 50 || report.SkipReasons["ignored by container istio-proxy"] != 1 {
+		t.Errorf("expected 50%% coverage and the skip reason, got %+v", report)
+	}
+	if !report.From.Equal(first.Started) || !report.To.Equal(second.Finished) || len(report.Runs) != 2 {
+		t.Errorf("expected the period of both runs, got %s - %s of %v", report.From, report.To, report.Runs)
+	}
+	workloads := make(map[string]CoverageGroup)
+	for _, group := range report.Workloads {
+		workloads[group.Name] = group
+	}
+	if api := workloads["payments/Deployment/api"]; api.Total != 2 || api.Covered != 1 || api.Coverage != 50 {
+		t.Errorf("expected the app container of the api workload covered once, got %+v", api)
+	}
+	if db := workloads["payments/StatefulSet/db"]; db.Covered != 1 {
+		t.Errorf("expected the database covered by the second run, got %+v", db)
+	}
+	if _, ok := workloads["payments/Pod/distroless"]; !ok {
+		t.Errorf("expected a pod without a workload as a workload of its own, got %+v", report.Workloads)
+	}
+	if len(report.Namespaces) != 1 || report.Namespaces[0].Total != 4 {
+		t.Errorf("expected all containers in the payments namespace, got %+v", report.Namespaces)
+	}
+
+	// manifests are found in run directories, older ones are left out
+	dir := t.TempDir()
+	old := first
+	old.Started = started.Add(-60 * 24 * time.Hour)
+	for name, manifest := range map[string]Manifest{"run-1/kubelse-manifest-1.json": first, "run-2/kubelse-manifest-2.json": second, "kubelse-manifest-0.json": old} {
+		content, _ := json.Marshal(manifest)
+		os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0755)
+		if err := os.WriteFile(filepath.Join(dir, name), content, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	manifests, err := loadManifests(dir, started.Add(-time.Hour))
+	if err != nil || len(manifests) != 2 {
+		t.Fatalf("expected manifests of both recent runs, got %d: %v", len(manifests), err)
+	}
+}
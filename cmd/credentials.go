@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"github.com/hhruszka/k8sexec"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/kubernetes"
+	"sync"
+)
+
+var (
+	// execHook, if set, runs commands instead of the cluster, e.g. when a recorded run is replayed or in tests
+	execHook func(pod string, container string, args []string, stdin []byte) *k8sexec.ExecutionStatus
+	// clientMu guards replacing credentials of the exec client while workers use it
+	clientMu sync.RWMutex
+	// clientGeneration is incremented every time the exec client is recreated
+	clientGeneration int
+)
+
+// clientsetOf returns the clientset of a client, it is replaced when credentials are refreshed while workers use it.
+func clientsetOf(k8s *k8sexec.K8SExec) *kubernetes.Clientset {
+	clientMu.RLock()
+	defer clientMu.RUnlock()
+	return k8s.Clientset
+}
+
+// unauthorized tells if an exec was rejected because of expired or invalid credentials. The API server rejects
+// the upgrade of the exec connection with a status error, messages of other errors may mention 401 as well.
+func unauthorized(err error) bool {
+	return apierrors.IsUnauthorized(err)
+}
+
+// refreshCredentials reloads the kubeconfig, e.g. updated by an OIDC or exec-credential plugin in the meantime,
+// and recreates the exec client with fresh credentials. Workers that hit an expired token at the same time
+// refresh the client only once.
+func refreshCredentials(k8s *k8sexec.K8SExec, generation int) error {
+	clientMu.Lock()
+	defer clientMu.Unlock()
+
+	if generation != clientGeneration {
+		// already refreshed by another worker
+		return nil
+	}
+
+	fresh, err := newClient(kubeconfig, k8s.Namespace)
+	if err != nil {
+		return err
+	}
+	k8s.Config, k8s.Clientset = fresh.Config, fresh.Clientset
+	clientGeneration++
+	log(fmt.Sprintln("[*] Credentials expired, the kubeconfig was reloaded and the client recreated"))
+	return nil
+}
+
+// execInContainer runs a command in a container. If the exec is rejected because the credentials expired during
+// a long run, the credentials are refreshed and the command is run once again.
+func execInContainer(ctx context.Context, k8s *k8sexec.K8SExec, pod string, container string, args []string, stdin []byte) *k8sexec.ExecutionStatus {
+	return execInContainerContext(ctx, k8s, pod, container, args, stdin, nil)
+}
+
+// execInContainerContext runs a command in a container as execInContainer does. If progress is given, the exec is
+// streamed, progress is called whenever the command writes output and the exec is cancelled with the context.
+// Nothing is run once the context is cancelled.
+func execInContainerContext(ctx context.Context, k8s *k8sexec.K8SExec, pod string, container string, args []string, stdin []byte, progress func()) *k8sexec.ExecutionStatus {
+	waitForWindow()
+	scanControl.acquire()
+	defer scanControl.release()
+	if ctx.Err() != nil {
+		return cancelledExec(ctx, pod, container)
+	}
+	if execHook != nil {
+		return execHook(pod, container, args, stdin)
+	}
+
+	exec := func() (*k8sexec.ExecutionStatus, int, error) {
+		clientMu.RLock()
+		client, generation := *k8s, clientGeneration
+		clientMu.RUnlock()
+
+		// execs without progress are not cancelled, as k8sexec does not cancel them
+		execCtx := context.TODO()
+		if progress != nil {
+			execCtx = ctx
+		}
+		execStatus, err := streamExec(execCtx, &client, pod, container, args, stdin, progress)
+		return execStatus, generation, err
+	}
+
+	execStatus, generation, err := exec()
+	if !unauthorized(err) {
+		recordExec(pod, container, args, stdin, execStatus)
+		return execStatus
+	}
+	if err := refreshCredentials(k8s, generation); err != nil {
+		log(fmt.Sprintf("[-] Error refreshing credentials: %s\n", err.Error()))
+		return execStatus
+	}
+	execStatus, _, _ = exec()
+	recordExec(pod, container, args, stdin, execStatus)
+	return execStatus
+}
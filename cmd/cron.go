@@ -0,0 +1,185 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// cron summary CLI options variables
+var cronSummary bool
+
+// lse.sh tests listing recurrent tasks: cron jobs and systemd timers
+var cronJobTests = map[string]string{
+	"ret000": "user crontab",
+	"ret020": "cron",
+	"ret040": "other users' crontabs",
+	"ret500": "user systemd timer",
+	"ret900": "systemd timer",
+}
+
+// lse.sh tests finding recurrent tasks, or paths they run, writable by the scanned user
+var cronWritableTests = map[string]bool{"ret010": true, "ret050": true, "ret060": true, "ret510": true}
+
+// timerRegexp matches a timer and the unit it activates in a row of 'systemctl list-timers'
+var timerRegexp = regexp.MustCompile(`(\S+\.timer)\s+(\S+)\s*$`)
+
+// CronJob is a unique cron job or systemd timer found in containers of a workload.
+type CronJob struct {
+	Source     string   `json:"Source"`
+	Job        string   `json:"Job"`
+	Containers []string `json:"Containers"`
+}
+
+// CronWorkload summarizes recurrent tasks of a workload.
+type CronWorkload struct {
+	Workload   string    `json:"Workload"`
+	Containers int       `json:"Containers"`
+	Jobs       []CronJob `json:"Jobs"`
+	// Writable are recurrent tasks, or paths they run, writable by the scanned user
+	Writable []CronJob `json:"Writable"`
+}
+
+// cronJobs returns jobs a finding of a recurrent tasks test lists. Rows of systemd timers are reduced to the timer
+// and the unit it activates, since their times differ from container to container.
+func cronJobs(finding Finding) []string {
+	var jobs []string
+	for _, detail := range finding.Details {
+		detail = strings.TrimSpace(stripANSI(detail))
+		if detail == "" {
+			continue
+		}
+		if finding.ID == "ret500" || finding.ID == "ret900" {
+			match := timerRegexp.FindStringSubmatch(detail)
+			if match == nil {
+				// headers and the count of listed timers
+				continue
+			}
+			detail = fmt.Sprintf("%s -> %s", match[1], match[2])
+		}
+		jobs = append(jobs, detail)
+	}
+	return jobs
+}
+
+// newCronSummary aggregates cron jobs and systemd timers found by lse.sh into summaries of workloads, in which
+// every unique job is listed once with containers it was found in.
+func newCronSummary(results []Result) []CronWorkload {
+	type aggregated struct {
+		containers     map[string]bool
+		jobs, writable map[string]*CronJob
+	}
+	add := func(jobs map[string]*CronJob, source string, job string, container string) {
+		key := source + "\x00" + job
+		if jobs[key] == nil {
+			jobs[key] = &CronJob{Source: source, Job: job}
+		}
+		if containers := jobs[key].Containers; len(containers) == 0 || containers[len(containers)-1] != container {
+			jobs[key].Containers = append(jobs[key].Containers, container)
+		}
+	}
+
+	workloads := make(map[string]*aggregated)
+	for _, result := range results {
+		name := valueOrDefault(result.container.container.Workload, "unknown")
+		if workloads[name] == nil {
+			workloads[name] = &aggregated{containers: map[string]bool{}, jobs: map[string]*CronJob{}, writable: map[string]*CronJob{}}
+		}
+		workload, container := workloads[name], result.container.container.String()
+		workload.containers[container] = true
+		for _, finding := range result.findings().Positive() {
+			if source, ok := cronJobTests[finding.ID]; ok {
+				for _, job := range cronJobs(finding) {
+					add(workload.jobs, source, job, container)
+				}
+			}
+			if cronWritableTests[finding.ID] {
+				for _, detail := range finding.Details {
+					if detail = strings.TrimSpace(stripANSI(detail)); detail != "" {
+						add(workload.writable, finding.Name, detail, container)
+					}
+				}
+			}
+		}
+	}
+
+	sorted := func(jobs map[string]*CronJob) []CronJob {
+		list := []CronJob{}
+		for _, job := range jobs {
+			list = append(list, *job)
+		}
+		sort.Slice(list, func(i, j int) bool {
+			if list[i].Source != list[j].Source {
+				return list[i].Source < list[j].Source
+			}
+			return list[i].Job < list[j].Job
+		})
+		return list
+	}
+	summary := []CronWorkload{}
+	for name, workload := range workloads {
+		if len(workload.jobs) == 0 && len(workload.writable) == 0 {
+			continue
+		}
+		summary = append(summary, CronWorkload{Workload: name, Containers: len(workload.containers), Jobs: sorted(workload.jobs), Writable: sorted(workload.writable)})
+	}
+	sort.Slice(summary, func(i, j int) bool { return summary[i].Workload < summary[j].Workload })
+	return summary
+}
+
+// cronSummaryLines renders summaries of recurrent tasks of workloads.
+func cronSummaryLines(summary []CronWorkload) []string {
+	unique := make(map[string]bool)
+	for _, workload := range summary {
+		for _, job := range workload.Jobs {
+			unique[job.Source+"\x00"+job.Job] = true
+		}
+	}
+	lines := []string{
+		"================================( kubelse cron jobs and timers )================================",
+		fmt.Sprintf("          Run ID: %s", runID),
+		fmt.Sprintf("         Cluster: %s (%s)", valueOrDefault(cluster.Name, "unknown"), valueOrDefault(cluster.Server, "unknown")),
+		fmt.Sprintf("       Namespace: %s", scannedNamespace()),
+		fmt.Sprintf("       Workloads: %d", len(summary)),
+		fmt.Sprintf("     Unique jobs: %d", len(unique)),
+		"",
+	}
+	for _, workload := range summary {
+		lines = append(lines, fmt.Sprintf("=====( %s: %d jobs in %d containers )=====", workload.Workload, len(workload.Jobs), workload.Containers))
+		for _, job := range workload.Jobs {
+			lines = append(lines, fmt.Sprintf("[%s] %s", job.Source, job.Job))
+			lines = append(lines, fmt.Sprintf("    Containers (%d of %d): %s", len(job.Containers), workload.Containers, strings.Join(job.Containers, ", ")))
+		}
+		for _, job := range workload.Writable {
+			lines = append(lines, fmt.Sprintf("[!] %s: %s", job.Source, job.Job))
+			lines = append(lines, fmt.Sprintf("    Containers (%d of %d): %s", len(job.Containers), workload.Containers, strings.Join(job.Containers, ", ")))
+		}
+		lines = append(lines, "")
+	}
+	return lines
+}
+
+// saveCronSummary saves summaries of recurrent tasks of workloads of a run in the reports directory.
+func saveCronSummary(started time.Time, results []Result) {
+	summary := newCronSummary(results)
+	report := renderReport(cronSummaryLines(summary))
+	if format == "json" {
+		report, _ = json.MarshalIndent(map[string]interface{}{
+			"RunID":     runID,
+			"Cluster":   cluster,
+			"Namespace": scannedNamespace(),
+			"Workloads": summary,
+		}, "", "  ")
+	}
+
+	fileName := reportFileName(directory, "."+format, "kubelse-cron", fileTimestamp(started), shortRunID())
+	fileName, err := writeReportWithFallback(fileName, report)
+	if err != nil {
+		log(fmt.Sprintf("[-] Error saving cron summary: %s\n", err.Error()))
+		return
+	}
+	log(fmt.Sprintf("[+] Cron jobs and timers of %d workloads saved to %s\n", len(summary), fileName))
+}
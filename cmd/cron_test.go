@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"testing"
+)
+
+func TestCronSummaryDeduplicatesJobsOfWorkloads(t *testing.T) {
+	report := func(next string) []string {
+		return []string{
+			"=====================( recurrent tasks )=====================",
+			"[*] ret020 Cron jobs........................................ yes!",
+			"---",
+			"/etc/cron.d/logrotate:0 * * * * root /usr/sbin/logrotate /etc/logrotate.conf",
+			"---",
+			"[i] ret900 Systemd timers................................... yes!",
+			"---",
+			"NEXT                        LEFT     LAST PASSED UNIT             ACTIVATES",
+			next + " 5h left  n/a  n/a    apt-daily.timer  apt-daily.service",
+			"",
+			"1 timers listed.",
+			"---",
+		}
+	}
+	result := func(pod string, workload string, lines []string) Result {
+		return Result{container: ContainerInfo{container: Container{Pod: pod, Container: "app", Workload: workload}}, scanReport: lines}
+	}
+	summary := newCronSummary([]Result{
+		result("web-1", "deployment/web", report("Mon 2024-04-01 06:00:00 UTC")),
+		result("web-2", "deployment/web", report("Mon 2024-04-01 07:13:00 UTC")),
+		result("db-1", "statefulset/db", []string{"[*] ret020 Cron jobs........................................ nope"}),
+	})
+
+	if len(summary) != 1 || summary[0].Workload != "deployment/web" || summary[0].Containers != 2 {
+		t.Fatalf("expected a summary of the web workload only, got %+v", summary)
+	}
+	jobs := summary[0].Jobs
+	if len(jobs) != 2 || jobs[0].Job != "/etc/cron.d/logrotate:0 * * * * root /usr/sbin/logrotate /etc/logrotate.conf" || jobs[1].Job != "apt-daily.timer -> apt-daily.service" {
+		t.Fatalf("expected the cron job and the timer once each, got %+v", jobs)
+	}
+	for _, job := range jobs {
+		if len(job.Containers) != 2 {
+			t.Errorf("expected %s in both containers, got %v", job.Job, job.Containers)
+		}
+	}
+}
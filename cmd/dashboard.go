@@ -0,0 +1,237 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+	"sort"
+	"time"
+)
+
+// dashboard CLI options variables
+var dashboard bool
+
+// number of containers in the chart of the riskiest containers
+const dashboardTopContainers = 10
+
+const (
+	dashboardHeader = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="UTF-8"/>
+<title>%s</title>
+<style>
+body { font-family: sans-serif; margin: 24px; color: #222; }
+h1 { font-size: 1.4em; }
+.run { color: #555; margin-bottom: 16px; }
+.tiles { display: flex; gap: 12px; flex-wrap: wrap; margin-bottom: 24px; }
+.tile { border: 1px solid #ddd; border-radius: 6px; padding: 10px 16px; min-width: 110px; }
+.tile .value { font-size: 1.8em; font-weight: bold; }
+.charts { display: flex; gap: 32px; flex-wrap: wrap; }
+.chart { border: 1px solid #ddd; border-radius: 6px; padding: 12px; }
+.chart h2 { font-size: 1.1em; margin-top: 0; }
+.legend span { display: inline-block; margin-right: 12px; }
+.legend i { display: inline-block; width: 10px; height: 10px; margin-right: 4px; }
+svg text { font-size: 12px; }
+table { border-collapse: collapse; }
+td, th { border: 1px solid #ddd; padding: 4px 10px; text-align: right; }
+td:first-child, th:first-child { text-align: left; }
+</style>
+</head>
+<body>
+`
+	// dashboardScript draws charts of the dashboard data with SVG, so that the dashboard works offline and can be
+	// attached to emails
+	dashboardScript = `<script>
+(function () {
+  var data = JSON.parse(document.getElementById("data").textContent);
+  var colors = { critical: "#d33", interesting: "#e90", info: "#39c" };
+  var ns = "http://www.w3.org/2000/svg";
+
+  function element(name, attributes, text) {
+    var node = document.createElementNS(ns, name);
+    for (var key in attributes) { node.setAttribute(key, attributes[key]); }
+    if (text !== undefined) { node.textContent = text; }
+    return node;
+  }
+
+  function pie(id, slices) {
+    var svg = element("svg", { width: 220, height: 220, viewBox: "-110 -110 220 220" });
+    var total = slices.reduce(function (sum, slice) { return sum + slice.value; }, 0);
+    if (total === 0) {
+      svg.appendChild(element("text", { x: 0, y: 0, "text-anchor": "middle" }, "no findings"));
+    }
+    var angle = -Math.PI / 2;
+    slices.forEach(function (slice) {
+      if (slice.value === 0) { return; }
+      var sweep = 2 * Math.PI * slice.value / total;
+      var path;
+      if (slice.value === total) {
+        path = element("circle", { r: 100, fill: slice.color });
+      } else {
+        var x1 = 100 * Math.cos(angle), y1 = 100 * Math.sin(angle);
+        var x2 = 100 * Math.cos(angle + sweep), y2 = 100 * Math.sin(angle + sweep);
+        path = element("path", { d: "M0,0 L" + x1 + "," + y1 + " A100,100 0 " + (sweep > Math.PI ? 1 : 0) + ",1 " + x2 + "," + y2 + " Z", fill: slice.color });
+      }
+      path.appendChild(element("title", {}, slice.label + ": " + slice.value));
+      svg.appendChild(path);
+      angle += sweep;
+    });
+    document.getElementById(id).appendChild(svg);
+  }
+
+  // bars draws horizontal stacked bars, one per row, with segments of every series
+  function bars(id, rows, series, max) {
+    var height = 24, labelWidth = 220, width = 360;
+    var svg = element("svg", { width: labelWidth + width + 60, height: Math.max(rows.length, 1) * height + 4 });
+    if (rows.length === 0) {
+      svg.appendChild(element("text", { x: 0, y: 16 }, "no data"));
+    }
+    rows.forEach(function (row, idx) {
+      var y = idx * height + 2, x = labelWidth;
+      svg.appendChild(element("text", { x: 0, y: y + 15 }, row.label));
+      series.forEach(function (name) {
+        var value = row[name] || 0;
+        var w = max > 0 ? width * value / max : 0;
+        var bar = element("rect", { x: x, y: y, width: w, height: height - 6, fill: colors[name] || "#777" });
+        bar.appendChild(element("title", {}, row.label + ": " + name + " " + value));
+        svg.appendChild(bar);
+        x += w;
+      });
+      svg.appendChild(element("text", { x: x + 6, y: y + 15 }, row.total));
+    });
+    document.getElementById(id).appendChild(svg);
+  }
+
+  pie("severities", ["critical", "interesting", "info"].map(function (name) {
+    return { label: name, value: data.Severities[name] || 0, color: colors[name] };
+  }));
+  bars("risk", data.Riskiest.map(function (row) {
+    return { label: row.Container, risk: row.Score, total: row.Score };
+  }), ["risk"], 100);
+  var max = data.Namespaces.reduce(function (max, row) { return Math.max(max, (row.Critical || 0) + (row.Interesting || 0)); }, 0);
+  bars("namespaces", data.Namespaces.map(function (row) {
+    return { label: row.Namespace, critical: row.Critical, interesting: row.Interesting, total: row.Critical + row.Interesting };
+  }), ["critical", "interesting"], max);
+})();
+</script>
+</body>
+</html>
+`
+)
+
+// DashboardContainer is a container in the chart of the riskiest containers.
+type DashboardContainer struct {
+	Container string `json:"Container"`
+	Score     int    `json:"Score"`
+}
+
+// DashboardNamespace are counts of containers and findings of a namespace.
+type DashboardNamespace struct {
+	Namespace   string `json:"Namespace"`
+	Scanned     int    `json:"Scanned"`
+	NotTestable int    `json:"NotTestable"`
+	Skipped     int    `json:"Skipped"`
+	Critical    int    `json:"Critical"`
+	Interesting int    `json:"Interesting"`
+}
+
+// DashboardData is data of the charts of a dashboard.
+type DashboardData struct {
+	Severities map[string]int       `json:"Severities"`
+	Riskiest   []DashboardContainer `json:"Riskiest"`
+	Namespaces []DashboardNamespace `json:"Namespaces"`
+}
+
+// dashboardData summarizes a manifest of a run for its dashboard.
+func dashboardData(manifest Manifest) DashboardData {
+	data := DashboardData{Severities: manifest.FindingsCount(), Riskiest: []DashboardContainer{}, Namespaces: []DashboardNamespace{}}
+
+	namespaces := make(map[string]*DashboardNamespace)
+	namespaceOf := func(entry ManifestEntry) *DashboardNamespace {
+		name := valueOrDefault(entry.Namespace, manifest.Namespace)
+		if _, ok := namespaces[name]; !ok {
+			namespaces[name] = &DashboardNamespace{Namespace: name}
+		}
+		return namespaces[name]
+	}
+	for _, entry := range manifest.Scanned {
+		counts := namespaceOf(entry)
+		counts.Scanned++
+		counts.Critical += entry.Findings[SeverityCritical]
+		counts.Interesting += entry.Findings[SeverityInteresting]
+		if entry.Risk != nil {
+			data.Riskiest = append(data.Riskiest, DashboardContainer{Container: entry.Pod + "/" + entry.Container, Score: entry.Risk.Score})
+		}
+	}
+	for _, entry := range manifest.NotTestable {
+		namespaceOf(entry).NotTestable++
+	}
+	for _, entry := range manifest.Skipped {
+		namespaceOf(entry).Skipped++
+	}
+
+	sort.SliceStable(data.Riskiest, func(i, j int) bool { return data.Riskiest[i].Score > data.Riskiest[j].Score })
+	data.Riskiest = data.Riskiest[:min(len(data.Riskiest), dashboardTopContainers)]
+	for _, counts := range namespaces {
+		data.Namespaces = append(data.Namespaces, *counts)
+	}
+	sort.Slice(data.Namespaces, func(i, j int) bool { return data.Namespaces[i].Namespace < data.Namespaces[j].Namespace })
+	return data
+}
+
+// renderDashboard renders a dashboard of a run: tiles with counts of containers and findings, a pie chart of
+// severities, a bar chart of the riskiest containers and counts of every namespace.
+func renderDashboard(manifest Manifest) []byte {
+	var buf bytes.Buffer
+	data := dashboardData(manifest)
+
+	title := fmt.Sprintf("kubelse run %s", manifest.RunID)
+	fmt.Fprintf(&buf, dashboardHeader, html.EscapeString(title))
+	fmt.Fprintf(&buf, "<h1>%s</h1>\n", html.EscapeString(title))
+	fmt.Fprintf(&buf, "<div class=\"run\">Cluster %s, started %s, finished in %s</div>\n", html.EscapeString(valueOrDefault(manifest.Cluster.Name, "unknown")),
+		html.EscapeString(formatTimestamp(manifest.Started)), manifest.Finished.Sub(manifest.Started).Round(time.Second))
+
+	buf.WriteString("<div class=\"tiles\">\n")
+	for _, tile := range []struct {
+		label string
+		value int
+	}{
+		{"scanned containers", len(manifest.Scanned)},
+		{"not testable", len(manifest.NotTestable)},
+		{"skipped", len(manifest.Skipped)},
+		{"critical findings", data.Severities[SeverityCritical]},
+		{"interesting findings", data.Severities[SeverityInteresting]},
+	} {
+		fmt.Fprintf(&buf, "<div class=\"tile\"><div class=\"value\">%d</div>%s</div>\n", tile.value, tile.label)
+	}
+	buf.WriteString("</div>\n<div class=\"charts\">\n")
+	buf.WriteString("<div class=\"chart\"><h2>Findings by severity</h2><div id=\"severities\"></div>\n" +
+		"<div class=\"legend\"><span><i style=\"background:#d33\"></i>critical</span><span><i style=\"background:#e90\"></i>interesting</span><span><i style=\"background:#39c\"></i>info</span></div></div>\n")
+	fmt.Fprintf(&buf, "<div class=\"chart\"><h2>Top %d riskiest containers</h2><div id=\"risk\"></div></div>\n", dashboardTopContainers)
+	buf.WriteString("<div class=\"chart\"><h2>Findings by namespace</h2><div id=\"namespaces\"></div>\n<table>\n" +
+		"<tr><th>Namespace</th><th>Scanned</th><th>Not testable</th><th>Skipped</th><th>Critical</th><th>Interesting</th></tr>\n")
+	for _, ns := range data.Namespaces {
+		fmt.Fprintf(&buf, "<tr><td>%s</td><td>%d</td><td>%d</td><td>%d</td><td>%d</td><td>%d</td></tr>\n", html.EscapeString(ns.Namespace),
+			ns.Scanned, ns.NotTestable, ns.Skipped, ns.Critical, ns.Interesting)
+	}
+	buf.WriteString("</table></div>\n</div>\n")
+
+	// json.Marshal escapes <, > and &, so the data cannot end the script element
+	content, _ := json.Marshal(data)
+	fmt.Fprintf(&buf, "<script id=\"data\" type=\"application/json\">%s</script>\n", content)
+	buf.WriteString(dashboardScript)
+	return buf.Bytes()
+}
+
+// saveDashboard saves a dashboard of a run in the reports directory, next to its merged report.
+func saveDashboard(manifest Manifest, suffix string) {
+	fileName := fmt.Sprintf("kubelse-dashboard-%s-%s%s.html", fileTimestamp(manifest.Started), manifest.RunID[:min(8, len(manifest.RunID))], suffix)
+	fileName, err := writeReportWithFallback(fileName, renderDashboard(manifest))
+	if err != nil {
+		log(fmt.Sprintf("[-] Error saving dashboard: %s\n", err.Error()))
+		return
+	}
+	log(fmt.Sprintf("[+] Dashboard saved to %s\n", fileName))
+}
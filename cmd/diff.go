@@ -0,0 +1,197 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/spf13/cobra"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// diff CLI options variables
+var (
+	diffDirectory string
+	diffClusters  string
+)
+
+// clusterFindings are positive findings of workloads of a cluster keyed by namespace/workload and a finding key
+type clusterFindings map[string]map[string]Finding
+
+// findingKey identifies the same finding in different containers, i.e. the same test with the same details.
+func findingKey(finding Finding) string {
+	return finding.ID + "\x00" + strings.Join(finding.Details, "\n")
+}
+
+// latestClusterReports returns reports of the latest run of every cluster. A run is identified by its run ID or,
+// for reports saved without it, by a directory the reports were saved in.
+func latestClusterReports(runs map[string][]Report) map[string][]Report {
+	var (
+		reports = make(map[string]map[string][]Report)
+		latest  = make(map[string]string)
+		times   = make(map[string]time.Time)
+	)
+	for run, runReports := range runs {
+		for _, report := range runReports {
+			name := report.Header["Cluster"]
+			id := valueOrDefault(report.Header["Run ID"], run)
+			if reports[name] == nil {
+				reports[name] = make(map[string][]Report)
+			}
+			reports[name][id] = append(reports[name][id], report)
+
+			if info, err := os.Stat(report.Path); err == nil && info.ModTime().After(times[name]) {
+				times[name], latest[name] = info.ModTime(), id
+			}
+		}
+	}
+
+	clusters := make(map[string][]Report)
+	for name, id := range latest {
+		clusters[name] = reports[name][id]
+	}
+	return clusters
+}
+
+// collectClusterFindings groups positive findings of reports by workload.
+func collectClusterFindings(reports []Report) clusterFindings {
+	findings := make(clusterFindings)
+	for _, report := range reports {
+		workload := valueOrDefault(report.Header["Namespace"], "unknown") + "/" + valueOrDefault(report.Header["Workload"], "Pod/"+report.Pod())
+		if findings[workload] == nil {
+			findings[workload] = make(map[string]Finding)
+		}
+		for _, finding := range report.Positive() {
+			findings[workload][findingKey(finding)] = finding
+		}
+	}
+	return findings
+}
+
+// triageNote returns a triage decision on a finding to be shown next to it, if it was triaged.
+func triageNote(finding Finding) string {
+	if finding.Triage == nil {
+		return ""
+	}
+	return " (" + finding.Triage.String() + ")"
+}
+
+// sortedFindings returns findings sorted by their test ID.
+func sortedFindings(findings []Finding) []Finding {
+	sort.Slice(findings, func(i, j int) bool {
+		return findings[i].ID < findings[j].ID
+	})
+	return findings
+}
+
+var diffCmd = &cobra.Command{
+	Use:   "diff --clusters <cluster-a>,<cluster-b>",
+	Short: "Compare findings of the same workloads across two clusters",
+	Long: `
+Compares positive findings of the latest scans of two clusters, found in saved reports, for workloads matched by
+their namespace and workload name, e.g. staging and production, and prints the findings present in only one of
+them. Findings triaged as false positives are left out, other triage decisions are shown. Clusters are named as in the Cluster field of report headers, i.e. the cluster name from the kubeconfig.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		names := untangleOption(diffClusters)
+		if len(names) != 2 {
+			return errors.New("Two clusters have to be provided with '--clusters', e.g. --clusters staging,prod")
+		}
+
+		runs, err := loadReports(diffDirectory)
+		if err != nil {
+			return err
+		}
+		clusters := latestClusterReports(runs)
+		file, err := resultsDBPath()
+		if err != nil {
+			return err
+		}
+		db, err := loadResultsDB(file)
+		if err != nil {
+			return err
+		}
+		for _, name := range names {
+			if len(clusters[name]) == 0 {
+				return fmt.Errorf("[-] No reports of cluster %q found in %s\n", name, diffDirectory)
+			}
+		}
+		db.applyTriage(clusters[names[0]])
+		db.applyTriage(clusters[names[1]])
+		a, b := collectClusterFindings(clusters[names[0]]), collectClusterFindings(clusters[names[1]])
+
+		workloads := make(map[string]bool)
+		for workload := range a {
+			workloads[workload] = true
+		}
+		for workload := range b {
+			workloads[workload] = true
+		}
+		var sorted []string
+		for workload := range workloads {
+			sorted = append(sorted, workload)
+		}
+		sort.Strings(sorted)
+
+		var (
+			buf     bytes.Buffer
+			drifted int
+		)
+		w := tabwriter.NewWriter(&buf, 0, 0, 1, ' ', 0)
+		for _, workload := range sorted {
+			findingsA, inA := a[workload]
+			findingsB, inB := b[workload]
+			switch {
+			case !inA:
+				fmt.Fprintf(w, "%s\tscanned only in %s\n", workload, names[1])
+				continue
+			case !inB:
+				fmt.Fprintf(w, "%s\tscanned only in %s\n", workload, names[0])
+				continue
+			}
+
+			var onlyA, onlyB []Finding
+			for key, finding := range findingsA {
+				if _, ok := findingsB[key]; !ok {
+					onlyA = append(onlyA, finding)
+				}
+			}
+			for key, finding := range findingsB {
+				if _, ok := findingsA[key]; !ok {
+					onlyB = append(onlyB, finding)
+				}
+			}
+			if len(onlyA) == 0 && len(onlyB) == 0 {
+				continue
+			}
+			drifted++
+			fmt.Fprintf(w, "%s\t%d findings only in %s, %d only in %s\n", workload, len(onlyA), names[0], len(onlyB), names[1])
+			for _, finding := range sortedFindings(onlyA) {
+				fmt.Fprintf(w, "\t- [%s] %s %s %s%s\n", names[0], finding.Severity, finding.ID, finding.Name, triageNote(finding))
+			}
+			for _, finding := range sortedFindings(onlyB) {
+				fmt.Fprintf(w, "\t+ [%s] %s %s %s%s\n", names[1], finding.Severity, finding.ID, finding.Name, triageNote(finding))
+			}
+		}
+		w.Flush()
+		fmt.Print(buf.String())
+		log(fmt.Sprintf("[+] %d of %d workloads differ between %s and %s\n", drifted, len(sorted), names[0], names[1]))
+		return nil
+	},
+}
+
+func init() {
+	workingDirectory, err := os.Getwd()
+	if err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+	diffCmd.Flags().StringVarP(&diffDirectory, "directory", "d", workingDirectory, "a directory with scan reports of both clusters")
+	diffCmd.Flags().StringVar(&diffClusters, "clusters", "", "two comma-separated cluster names, e.g. staging,prod")
+	diffCmd.Flags().StringVar(&resultsDBFile, "results-db", "", "a file results of runs are kept in, findings triaged as false positives are not compared, if not provided then results.json in the kubelse user configuration directory")
+
+	cmd.AddCommand(diffCmd)
+}
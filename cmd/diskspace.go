@@ -0,0 +1,161 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// output size CLI options variables
+var maxOutputSize string
+
+// an average size of a report of lse.sh, used to estimate space needed by a run
+const averageReportSize = 256 * 1024
+
+// errOutputQuotaExceeded is returned when writing a report would exceed the output size limit
+var errOutputQuotaExceeded = errors.New("output size limit exceeded")
+
+// units of sizes, sizes are in binary units, i.e. 1K is 1024 bytes
+var sizeUnits = map[string]int64{"": 1, "K": 1 << 10, "M": 1 << 20, "G": 1 << 30, "T": 1 << 40}
+
+// a size in bytes or with a unit, e.g. 500M, 2G or 2GiB
+var sizeRegexp = regexp.MustCompile(`^(?i)(\d+)\s*([KMGT]?)(i?B)?$`)
+
+// parseSize parses a size in bytes or with a unit, e.g. 500M, 2G or 2GiB.
+func parseSize(value string) (int64, error) {
+	match := sizeRegexp.FindStringSubmatch(strings.TrimSpace(value))
+	if match == nil {
+		return 0, errors.New("expected a positive size in bytes or with a unit, e.g. 500M or 2G")
+	}
+	n, err := strconv.ParseInt(match[1], 10, 64)
+	if err != nil || n <= 0 {
+		return 0, errors.New("expected a positive size in bytes or with a unit, e.g. 500M or 2G")
+	}
+	return n * sizeUnits[strings.ToUpper(match[2])], nil
+}
+
+// formatSize formats a size in bytes with a binary unit.
+func formatSize(size int64) string {
+	for _, unit := range []string{"T", "G", "M", "K"} {
+		if size >= sizeUnits[unit] {
+			return fmt.Sprintf("%.1f%sB", float64(size)/float64(sizeUnits[unit]), unit)
+		}
+	}
+	return fmt.Sprintf("%dB", size)
+}
+
+// existingDirectory returns the directory or its closest existing parent, since the reports directory may be
+// created only when the first report is saved.
+func existingDirectory(dir string) string {
+	dir, _ = filepath.Abs(dir)
+	for {
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return dir
+		}
+		dir = parent
+	}
+}
+
+// estimateOutputSize estimates space needed by reports of a number of containers.
+func estimateOutputSize(containers int) int64 {
+	size := int64(containers) * averageReportSize
+	if merge {
+		// merged reports hold findings of all containers once more
+		size *= 2
+	}
+	return size
+}
+
+// checkOutputSpace checks before a run that there is enough free space in the reports directory for reports of
+// a number of containers and that they fit in the output size limit.
+func checkOutputSpace(containers int) error {
+	estimate := estimateOutputSize(containers)
+	if free, err := freeSpace(existingDirectory(directory)); err != nil {
+		log(fmt.Sprintf("[-] Could not check free space in %s: %s\n", directory, err.Error()))
+	} else if free < estimate {
+		return fmt.Errorf("[-] Not enough free space in %s, reports of %d containers need about %s and only %s is free\n", directory, containers, formatSize(estimate), formatSize(free))
+	}
+	if outputQuota != nil && outputQuota.limit < estimate {
+		log(fmt.Sprintf("[-] Reports of %d containers need about %s, more than the '--max-output-size %s' limit, the run stops when the limit is reached\n", containers, formatSize(estimate), maxOutputSize))
+	}
+	return nil
+}
+
+// sizeQuota limits the size of reports written by a run.
+type sizeQuota struct {
+	mu       sync.Mutex
+	limit    int64
+	written  int64
+	exceeded bool
+	skipped  []Container
+}
+
+// outputQuota is the output size limit of the current run, it is nil if the output size is not limited
+var outputQuota *sizeQuota
+
+// newOutputQuota sets up the output size limit of a run.
+func newOutputQuota() {
+	outputQuota = nil
+	if maxOutputSize == "" {
+		return
+	}
+	limit, _ := parseSize(maxOutputSize)
+	outputQuota = &sizeQuota{limit: limit}
+}
+
+// reserve accounts for a file of a size about to be written and returns errOutputQuotaExceeded, if it does not
+// fit in the limit. Once the limit is reached nothing more is written.
+func (q *sizeQuota) reserve(size int) error {
+	if q == nil {
+		return nil
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.exceeded || q.written+int64(size) > q.limit {
+		if !q.exceeded {
+			log(fmt.Sprintf("\n[-] Reports reached the '--max-output-size %s' limit, nothing more is written and remaining containers are not scanned\n", maxOutputSize))
+		}
+		q.exceeded = true
+		return errOutputQuotaExceeded
+	}
+	q.written += int64(size)
+	return nil
+}
+
+// reached tells if the limit was reached.
+func (q *sizeQuota) reached() bool {
+	if q == nil {
+		return false
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.exceeded
+}
+
+// skip records a container, which was not scanned since the limit was reached.
+func (q *sizeQuota) skip(container Container) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.skipped = append(q.skipped, container)
+}
+
+// finish adds containers, which were not scanned, to skipped containers of the run and returns an error if
+// the limit was reached.
+func (q *sizeQuota) finish() error {
+	if !q.reached() {
+		return nil
+	}
+	for _, container := range q.skipped {
+		skippedContainers = append(skippedContainers, SkippedContainer{container, "output size limit exceeded"})
+	}
+	return fmt.Errorf("[-] Run stopped after reports reached the '--max-output-size %s' limit, %d containers were not scanned\n", maxOutputSize, len(q.skipped))
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package cmd
+
+import "errors"
+
+// freeSpace is not supported on this platform, free space is not checked.
+func freeSpace(dir string) (int64, error) {
+	return 0, errors.New("not supported on this platform")
+}
@@ -0,0 +1,14 @@
+//go:build unix
+
+package cmd
+
+import "syscall"
+
+// freeSpace returns space in bytes available to the user in a file system of a directory.
+func freeSpace(dir string) (int64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(dir, &stat); err != nil {
+		return 0, err
+	}
+	return int64(stat.Bavail) * int64(stat.Bsize), nil
+}
@@ -0,0 +1,140 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"github.com/hhruszka/k8sexec"
+	corev1 "k8s.io/api/core/v1"
+	policyV1 "k8s.io/api/policy/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"strings"
+)
+
+// disruption CLI options variables
+var respectPDBCritical bool
+
+// criticalPriorityClasses are priority classes of pods critical to clusters and nodes
+var criticalPriorityClasses = []string{"system-cluster-critical", "system-node-critical"}
+
+// DisruptionRisk is how sensitive a container is to the exec load of lse.sh: the QoS class of its pod, its CPU
+// limit, under which lse.sh competes with the workload for CPU, and the priority class of its pod.
+type DisruptionRisk struct {
+	QOSClass      corev1.PodQOSClass
+	CPULimit      string
+	PriorityClass string
+	// Critical is why the workload of the container is critical, e.g. a PodDisruptionBudget allowing no
+	// disruptions, empty if it is not
+	Critical string
+}
+
+// String describes the risk for logs and reasons of skipped containers.
+func (r DisruptionRisk) String() string {
+	details := []string{r.Critical, "QoS " + string(r.QOSClass)}
+	if r.CPULimit != "" {
+		details = append(details, "CPU limit "+r.CPULimit)
+	}
+	return strings.Join(details, ", ")
+}
+
+// podQOSClass returns the QoS class of a pod from its status or, if it is not set yet, from requests and limits of
+// its containers.
+func podQOSClass(pod *corev1.Pod) corev1.PodQOSClass {
+	if pod.Status.QOSClass != "" {
+		return pod.Status.QOSClass
+	}
+	guaranteed, besteffort := true, true
+	for _, container := range pod.Spec.Containers {
+		for _, resource := range []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory} {
+			request, requested := container.Resources.Requests[resource]
+			limit, limited := container.Resources.Limits[resource]
+			if requested || limited {
+				besteffort = false
+			}
+			if !limited || (requested && request.Cmp(limit) != 0) {
+				guaranteed = false
+			}
+		}
+	}
+	switch {
+	case besteffort:
+		return corev1.PodQOSBestEffort
+	case guaranteed:
+		return corev1.PodQOSGuaranteed
+	}
+	return corev1.PodQOSBurstable
+}
+
+// podDisruptionRisk returns the QoS class, the CPU limit and the priority class of a container of a pod, whether it
+// is critical is found out when PodDisruptionBudgets are checked.
+func podDisruptionRisk(pod *corev1.Pod, name string) DisruptionRisk {
+	risk := DisruptionRisk{QOSClass: podQOSClass(pod), PriorityClass: pod.Spec.PriorityClassName}
+	for _, container := range pod.Spec.Containers {
+		if limit, ok := container.Resources.Limits[corev1.ResourceCPU]; ok && container.Name == name {
+			risk.CPULimit = limit.String()
+		}
+	}
+	return risk
+}
+
+// blocksDisruptions tells if a PodDisruptionBudget allows no pod it selects to be disrupted.
+func blocksDisruptions(pdb policyV1.PodDisruptionBudget) bool {
+	if pdb.Status.ObservedGeneration > 0 {
+		return pdb.Status.DisruptionsAllowed == 0
+	}
+	// the status of a budget, which was not reconciled yet, is empty
+	if maxUnavailable := pdb.Spec.MaxUnavailable; maxUnavailable != nil {
+		return maxUnavailable.String() == "0" || maxUnavailable.String() == "0%"
+	}
+	return pdb.Spec.MinAvailable != nil && pdb.Spec.MinAvailable.String() == "100%"
+}
+
+// checkDisruption finds containers of critical workloads, which are protected by PodDisruptionBudgets allowing no
+// disruptions or run with a critical priority class, and warns about them, since exec load of lse.sh inside
+// resource-limited containers can throttle them. With '--respect-pdb-critical' they are skipped.
+func checkDisruption(k8s *k8sexec.K8SExec, containers []Container) []Container {
+	var budgets []policyV1.PodDisruptionBudget
+	list, err := clientsetOf(k8s).PolicyV1().PodDisruptionBudgets(k8s.Namespace).List(context.TODO(), metaV1.ListOptions{})
+	if err != nil {
+		log(fmt.Sprintf("[-] Error listing PodDisruptionBudgets, critical workloads are recognized by priority classes only: %s\n", err.Error()))
+	} else {
+		budgets = list.Items
+	}
+
+	var critical, kept []Container
+	for _, container := range containers {
+		for _, pdb := range budgets {
+			selector, err := metaV1.LabelSelectorAsSelector(pdb.Spec.Selector)
+			if err == nil && pdb.Spec.Selector != nil && selector.Matches(labels.Set(container.Labels)) && blocksDisruptions(pdb) {
+				container.Disruption.Critical = fmt.Sprintf("PodDisruptionBudget %s allows no disruptions", pdb.Name)
+				break
+			}
+		}
+		if container.Disruption.Critical == "" && contains(criticalPriorityClasses, container.Disruption.PriorityClass) {
+			container.Disruption.Critical = "priority class " + container.Disruption.PriorityClass
+		}
+		if container.Disruption.Critical == "" {
+			kept = append(kept, container)
+			continue
+		}
+		critical = append(critical, container)
+		if respectPDBCritical {
+			skippedContainers = append(skippedContainers, SkippedContainer{container, "critical workload, " + container.Disruption.String()})
+		} else {
+			kept = append(kept, container)
+		}
+	}
+
+	if len(critical) > 0 {
+		action := "they are scanned, lse.sh may throttle resource-limited ones, '--respect-pdb-critical' skips them"
+		if respectPDBCritical {
+			action = "they are skipped with '--respect-pdb-critical'"
+		}
+		log(fmt.Sprintf("[-] Following %d containers belong to critical workloads, %s:\n", len(critical), action))
+		for _, container := range critical {
+			log(fmt.Sprintf("%s\t%s\t%s\n", container.Pod, container.Container, container.Disruption.String()))
+		}
+		log("\n")
+	}
+	return kept
+}
@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"github.com/hhruszka/k8sexec"
+	"strings"
+)
+
+// package managers, which are used by lse.sh to check versions of installed packages
+var versionCheckPkgManagers []string = []string{"dpkg", "rpm"}
+
+// distroScript prints os-release of a container and the first package manager found there
+const distroScript = "cat /etc/os-release 2>/dev/null || cat /usr/lib/os-release 2>/dev/null; " +
+	"for pkg in dpkg rpm apk; do command -v $pkg >/dev/null 2>&1 && echo PKG_MANAGER=$pkg && break; done"
+
+// distroCommand returns a command, which detects a distribution and a package manager of a container.
+func distroCommand(container ContainerInfo) []string {
+	return []string{container.shell, "-c", distroScript}
+}
+
+// getDistroInContainer reads os-release of the given container and checks which package manager is available
+// there. It returns a distribution name and a package manager name, unknown values are returned as empty strings.
+func getDistroInContainer(ctx context.Context, k8s *k8sexec.K8SExec, container ContainerInfo) (string, string) {
+	execStatus := execInContainer(ctx, k8s, container.container.Pod, container.container.Container, distroCommand(container), nil)
+	if execStatus.RetCode != k8sexec.Success {
+		return "", ""
+	}
+
+	var id, prettyName, pkgManager string
+	for _, line := range execStatus.Stdout {
+		key, value, found := strings.Cut(strings.TrimSpace(line), "=")
+		if !found {
+			continue
+		}
+		value = strings.Trim(value, `"'`)
+		switch key {
+		case "ID":
+			id = value
+		case "PRETTY_NAME":
+			prettyName = value
+		case "PKG_MANAGER":
+			pkgManager = value
+		}
+	}
+
+	switch {
+	case prettyName != "" && id != "":
+		return fmt.Sprintf("%s (%s)", prettyName, id), pkgManager
+	case prettyName != "":
+		return prettyName, pkgManager
+	default:
+		return id, pkgManager
+	}
+}
+
+// pkgChecksMeaningful tells if lse.sh package version checks give meaningful results for a given package manager.
+func pkgChecksMeaningful(pkgManager string) bool {
+	for _, pkg := range versionCheckPkgManagers {
+		if pkg == pkgManager {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"strings"
+)
+
+// containerCommand is a command executed in a container by a stage of a scan.
+type containerCommand struct {
+	stage string
+	args  []string
+	// payload is set for commands, which the payload is passed to through stdin
+	payload bool
+}
+
+// containerCommands returns commands executed in a verified container by a scan, in the order they are executed,
+// built by the same functions the stages build them with.
+func containerCommands(container ContainerInfo) []containerCommand {
+	var commands []containerCommand
+	if container.settings.shell != "" {
+		commands = append(commands, containerCommand{stage: "verify", args: shellCheckCommand(container.settings.shell)})
+	} else {
+		for _, shell := range shells {
+			commands = append(commands, containerCommand{stage: "verify", args: shellVersionCommand(shell)})
+			if shell == container.shell {
+				break
+			}
+		}
+	}
+	for _, util := range utils {
+		commands = append(commands, containerCommand{stage: "verify", args: strings.Fields(util)})
+	}
+	commands = append(commands,
+		containerCommand{stage: "verify", args: distroCommand(container)},
+		containerCommand{stage: "verify", args: filesystemCommand(container)})
+
+	scans := []ContainerInfo{container}
+	if chunked {
+		scans = nil
+		for _, chunk := range lseChunks(container) {
+			chunkContainer := container
+			chunkContainer.settings.sections = chunk
+			scans = append(scans, chunkContainer)
+		}
+	}
+	for _, scan := range scans {
+		commands = append(commands, containerCommand{stage: "scan", args: lseCommand(scan), payload: true})
+	}
+	if stallTimeout > 0 && stallAction == "retry" {
+		reduced := container
+		reduced.settings.reduced = true
+		commands = append(commands, containerCommand{stage: "scan retry if stalled", args: lseCommand(reduced), payload: true})
+	}
+
+	if hashInventory {
+		commands = append(commands, containerCommand{stage: "hash inventory, with setuid binaries found by lse.sh", args: hashCommand(container, nil)})
+	}
+	if metadataProbe {
+		commands = append(commands, containerCommand{stage: "metadata probe", args: metadataProbeCommand(container)})
+	}
+	if apiProbe {
+		commands = append(commands, containerCommand{stage: "API probe", args: apiProbeCommand(container)})
+	}
+	return commands
+}
+
+// quoteArgs quotes arguments, which contain characters special to a shell, so that a printed command can be
+// copied and pasted.
+func quoteArgs(args []string) string {
+	var quoted []string
+	for _, arg := range args {
+		if arg == "" || strings.ContainsAny(arg, " \t\n'\"\\$`;&|<>()*?![]{}#~") {
+			arg = "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+		}
+		quoted = append(quoted, arg)
+	}
+	return strings.Join(quoted, " ")
+}
+
+// printCommands prints the exact commands, which would be executed in every container, and how lse.sh is
+// delivered to them, so that the execution can be reviewed before it happens.
+func printCommands(containers []ContainerInfo) {
+	// the digest is of the bytes actually sent into containers
+	prepared, applied := preparePayload(lse)
+	sum := sha256.Sum256(prepared)
+
+	var buf bytes.Buffer
+	fmt.Fprintln(&buf, "Payload delivery: the payload is streamed to the shell's stdin through the pods/exec subresource, it is not written to the container's filesystem")
+	fmt.Fprintf(&buf, "Filesystem writes: the /tmp writability check creates and removes an empty file %s in every container\n", tmpProbeFile)
+	fmt.Fprintf(&buf, "Payload: %s, %d bytes, sha256 %x\n", payloadName, len(prepared), sum)
+	if len(applied) > 0 {
+		fmt.Fprintf(&buf, "Payload transformations: %s, the digest is of the transformed payload\n", strings.Join(applied, "; "))
+	}
+	if imageConfig {
+		fmt.Fprintln(&buf, "Image configs are read from registries, no command is executed in containers for them")
+	}
+
+	// the payload, which was not read from a file, is referred to by its digest
+	payloadSource := fmt.Sprintf("lse-%x.sh", sum[:6])
+	if payloadPath != "" && len(applied) == 0 {
+		payloadSource = payloadPath
+	}
+	ns := scannedNamespace()
+	for _, container := range containers {
+		fmt.Fprintf(&buf, "\n%s/%s:\n", ns, container.container.String())
+		for _, command := range containerCommands(container) {
+			line := fmt.Sprintf("kubectl exec -n %s %s -c %s -- %s", ns, container.container.Pod, container.container.Container, quoteArgs(command.args))
+			if command.payload {
+				line = fmt.Sprintf("kubectl exec -i -n %s %s -c %s -- %s < %s", ns, container.container.Pod, container.container.Container, quoteArgs(command.args), quoteArgs([]string{payloadSource}))
+			}
+			fmt.Fprintf(&buf, "  [%s] %s\n", command.stage, line)
+		}
+	}
+	fmt.Println(buf.String())
+}
@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/spf13/pflag"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// prefix of environment variables setting options, e.g. KUBELSE_NAMESPACE sets '--namespace'
+const envPrefix = "KUBELSE_"
+
+// entrypoint mode CLI options variables
+var (
+	entrypoint bool
+	statusFile string
+)
+
+// RunStatus is a machine-readable outcome of a run written to the status file, so that whatever runs kubelse,
+// e.g. a Kubernetes Job, can tell how it ended without parsing logs.
+type RunStatus struct {
+	RunID       string         `json:"RunID"`
+	Status      string         `json:"Status"`
+	Error       string         `json:"Error,omitempty"`
+	Started     time.Time      `json:"Started"`
+	Finished    time.Time      `json:"Finished"`
+	Directory   string         `json:"Directory"`
+	Scanned     int            `json:"Scanned"`
+	Complete    int            `json:"Complete"`
+	Partial     int            `json:"Partial"`
+	Failed      int            `json:"Failed"`
+	NotTestable int            `json:"NotTestable"`
+	Skipped     int            `json:"Skipped"`
+	Findings    map[string]int `json:"Findings,omitempty"`
+}
+
+// envName returns a name of an environment variable setting an option.
+func envName(flag string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(flag, "-", "_"))
+}
+
+// applyEnvironment sets options, which were not provided on the command line, from KUBELSE_* environment
+// variables, e.g. populated from a ConfigMap with envFrom.
+func applyEnvironment(flags *pflag.FlagSet) error {
+	var err error
+	flags.VisitAll(func(flag *pflag.Flag) {
+		// KUBELSE_VERSION is too likely to be set for other purposes, e.g. by image builds
+		if err != nil || flag.Changed || flag.Name == "version" {
+			return
+		}
+		if value, ok := os.LookupEnv(envName(flag.Name)); ok {
+			if setErr := flags.Set(flag.Name, value); setErr != nil {
+				err = fmt.Errorf("Invalid value of %s: %s", envName(flag.Name), setErr.Error())
+			}
+		}
+	})
+	return err
+}
+
+// prepareEntrypoint adjusts defaults for running in a container, i.e. nobody is asked for confirmations, the
+// in-cluster configuration is used unless a kubeconfig was provided, a status file is written to the reports
+// volume and scanned pods get Kubernetes Events.
+func prepareEntrypoint(flags *pflag.FlagSet) {
+	interactive = false
+	if !flags.Changed("kubeconfig") {
+		if _, err := os.Stat(kubeconfig); err != nil {
+			kubeconfig = ""
+		}
+	}
+	if statusFile == "" {
+		statusFile = filepath.Join(directory, "kubelse-status.json")
+	}
+	if !flags.Changed("events") {
+		events = true
+	}
+}
+
+// saveRunStatus writes the status of a run to the status file.
+func saveRunStatus(started time.Time, manifest Manifest, runErr error) error {
+	status := RunStatus{
+		RunID:       runID,
+		Status:      "succeeded",
+		Started:     started,
+		Finished:    now(),
+		Directory:   valueOrDefault(runDirectory, directory),
+		Scanned:     len(manifest.Scanned),
+		NotTestable: len(manifest.NotTestable),
+		Skipped:     len(manifest.Skipped),
+		Findings:    manifest.FindingsCount(),
+	}
+	for _, entry := range manifest.Scanned {
+		switch entry.Status {
+		case StatusComplete:
+			status.Complete++
+		case StatusPartial:
+			status.Partial++
+		default:
+			status.Failed++
+		}
+	}
+	if runErr != nil {
+		status.Status, status.Error = "failed", strings.TrimSpace(runErr.Error())
+	}
+
+	content, err := json.MarshalIndent(status, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(statusFile), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(statusFile, content, 0666)
+}
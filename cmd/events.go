@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"github.com/hhruszka/k8sexec"
+	corev1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sync/atomic"
+	"time"
+)
+
+// events CLI options variables
+var events bool
+
+// reasons of Kubernetes Events emitted on scanned pods
+const (
+	EventScanStarted   = "LseScanStarted"
+	EventScanCompleted = "LseScanCompleted"
+	EventScanFailed    = "LseScanFailed"
+)
+
+// eventsFailed tells if an event could not be emitted, further failures are not logged, since they are most likely
+// caused by missing RBAC permissions and would be logged for every container
+var eventsFailed atomic.Bool
+
+// emitEvent emits a Kubernetes Event on the pod of a container, so that teams owning the pod see in
+// 'kubectl describe pod' that it was scanned and when.
+func emitEvent(k8s *k8sexec.K8SExec, container Container, eventType string, reason string, message string) {
+	if !events {
+		return
+	}
+	now := metaV1.NewTime(time.Now())
+	event := &corev1.Event{
+		ObjectMeta: metaV1.ObjectMeta{GenerateName: container.Pod + ".", Namespace: k8s.Namespace},
+		InvolvedObject: corev1.ObjectReference{
+			Kind:       "Pod",
+			APIVersion: "v1",
+			Namespace:  k8s.Namespace,
+			Name:       container.Pod,
+			UID:        container.UID,
+			FieldPath:  fmt.Sprintf("spec.containers{%s}", container.Container),
+		},
+		Reason:              reason,
+		Message:             message,
+		Type:                eventType,
+		Source:              corev1.EventSource{Component: "kubelse"},
+		FirstTimestamp:      now,
+		LastTimestamp:       now,
+		Count:               1,
+		ReportingController: "kubelse",
+		ReportingInstance:   runID,
+	}
+	_, err := clientsetOf(k8s).CoreV1().Events(k8s.Namespace).Create(context.TODO(), event, metaV1.CreateOptions{})
+	if err != nil && eventsFailed.CompareAndSwap(false, true) {
+		log(fmt.Sprintf("\n[-] Error emitting events on scanned pods, further errors are not logged: %s\n", err.Error()))
+	}
+}
+
+// emitStarted emits an event on the pod of a container, in which a scan starts.
+func emitStarted(k8s *k8sexec.K8SExec, container Container) {
+	emitEvent(k8s, container, corev1.EventTypeNormal, EventScanStarted, fmt.Sprintf("kubelse run %s started lse.sh in container %s", runID, container.Container))
+}
+
+// emitFinished emits an event on the pod of a scanned container with the outcome of the scan: completed with counts
+// of findings, or failed with what went wrong.
+func emitFinished(k8s *k8sexec.K8SExec, result Result) {
+	container := result.container.container
+	if status := result.status(); status != StatusComplete {
+		emitEvent(k8s, container, corev1.EventTypeWarning, EventScanFailed, fmt.Sprintf("kubelse run %s: lse.sh in container %s is %s, %s", runID, container.Container, status, result.exitDescription()))
+		return
+	}
+	counts := result.findings().CountBySeverity()
+	emitEvent(k8s, container, corev1.EventTypeNormal, EventScanCompleted, fmt.Sprintf("kubelse run %s: lse.sh in container %s completed in %s, %d critical and %d interesting findings",
+		runID, container.Container, result.duration.Round(time.Second), counts[SeverityCritical], counts[SeverityInteresting]))
+}
@@ -0,0 +1,188 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/hhruszka/k8sexec"
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/spf13/cobra"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// exec CLI options variables
+var (
+	execCommand string
+	execScript  string
+	execStdout  bool
+)
+
+// ExecResult is an outcome of running a command in a single container.
+type ExecResult struct {
+	Pod       string   `json:"Pod"`
+	Container string   `json:"Container"`
+	Shell     string   `json:"Shell,omitempty"`
+	RetCode   int      `json:"RetCode"`
+	Error     string   `json:"Error,omitempty"`
+	Output    string   `json:"Output,omitempty"`
+	Stdout    []string `json:"-"`
+	Stderr    []string `json:"-"`
+}
+
+// execInContainers runs a command, or a script passed through stdin, with a shell found in every container.
+func execInContainers(ctx context.Context, k8s *k8sexec.K8SExec, containers []Container, command string, script []byte) []ExecResult {
+	done := stage(ctx, source(ctx, containers), workerCount(len(containers)), func(p *pacer, container Container) (ExecResult, bool) {
+		p.wait()
+		result := ExecResult{Pod: container.Pod, Container: container.Container, RetCode: int(k8sexec.CommandNotFound)}
+		if shell, err := getShellInContainer(ctx, k8s, container); err != nil {
+			result.Error = "no shell found in the container"
+		} else {
+			result.Shell = shell
+			args := []string{shell, "-c", command}
+			if script != nil {
+				args = []string{shell, "-s"}
+			}
+			execStatus := execInContainer(ctx, k8s, container.Pod, container.Container, args, script)
+			result.RetCode = int(execStatus.RetCode)
+			result.Error = strings.TrimSpace(strings.Join(execStatus.Error, " "))
+			result.Stdout, result.Stderr = execStatus.Stdout, execStatus.Stderr
+		}
+		return result, true
+	})
+
+	var results []ExecResult
+	for result := range done {
+		results = append(results, result)
+		log(fmt.Sprintf("\rExecuted in %d containers", len(results)))
+	}
+	log("\n")
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Pod+"/"+results[i].Container < results[j].Pod+"/"+results[j].Container
+	})
+	return results
+}
+
+// saveExecResults saves outputs of every container in a directory of the run together with an index of results.
+func saveExecResults(dir string, results []ExecResult) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for idx := range results {
+		result := &results[idx]
+		base := filepath.Join(dir, strings.TrimSuffix(reportFileName(dir, ".out", result.Pod, result.Container), ".out"))
+		if err := os.WriteFile(base+".out", []byte(strings.Join(result.Stdout, "\n")), 0666); err != nil {
+			return err
+		}
+		result.Output = filepath.Base(base + ".out")
+		if stderr := strings.Join(result.Stderr, "\n"); strings.TrimSpace(stderr) != "" {
+			if err := os.WriteFile(base+".err", []byte(stderr), 0666); err != nil {
+				return err
+			}
+		}
+	}
+	content, err := json.MarshalIndent(map[string]interface{}{"RunID": runID, "Namespace": namespace, "Command": execCommand, "Script": execScript, "Results": results}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, "results.json"), content, 0666)
+}
+
+// printExecSummary renders a table with an exit code of the command in every container.
+func printExecSummary(results []ExecResult) {
+	var buf bytes.Buffer
+
+	t := table.NewWriter()
+	t.SetOutputMirror(&buf)
+	t.AppendHeader(table.Row{"#", "Pod", "Container", "Exit code", "Output lines", "Error"})
+	for idx, result := range results {
+		lines := 0
+		for _, line := range result.Stdout {
+			if line != "" {
+				lines++
+			}
+		}
+		t.AppendRow(table.Row{idx + 1, result.Pod, result.Container, result.RetCode, lines, result.Error})
+	}
+	t.Render()
+	log(buf.String())
+}
+
+var execCmd = &cobra.Command{
+	Use:   "exec",
+	Short: "Run a command in all selected containers",
+	Long: `
+Runs an arbitrary command, or a script passed through stdin of a shell, in all selected containers using the same
+worker pool as scans and saves output of every container in a directory of the run.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := validateScriptVerification(execScript, "--script"); err != nil {
+			return err
+		}
+		var script []byte
+		switch {
+		case execCommand != "" && execScript != "":
+			return errors.New("Options '--cmd' and '--script' cannot be used together")
+		case execScript != "":
+			var err error
+			if script, err = loadScript(execScript); err != nil {
+				return err
+			}
+		case execCommand == "":
+			return errors.New("Either a command '--cmd' or a script '--script' has to be provided")
+		}
+
+		k8s, containers, err := selectContainers(cmd)
+		if err != nil {
+			return err
+		}
+		startRun(k8s)
+		log(fmt.Sprintf("[+] Found %d containers in %s namespace\n", len(containers), namespace))
+		if !quiet && interactive {
+			if !promptYN(fmt.Sprintf("\nDo you wish to proceed with executing the command in %d containers? (Y/N): ", len(containers))) {
+				return errors.New("Action cancelled.")
+			}
+		}
+
+		ctx, cancel := newRunContext()
+		defer cancel(nil)
+		results := execInContainers(ctx, k8s, containers, execCommand, script)
+		dir := filepath.Join(directory, fmt.Sprintf("exec-%s-%s", fileTimestamp(time.Now()), shortRunID()))
+		if err := saveExecResults(dir, results); err != nil {
+			return fmt.Errorf("[-] Error saving results: %s\n", err.Error())
+		}
+		printExecSummary(results)
+		log(fmt.Sprintf("[+] Results saved to %s\n", dir))
+		if runInterrupted(ctx) {
+			return errors.New("[-] Run interrupted, the command was not run in remaining containers\n")
+		}
+
+		if execStdout {
+			for _, result := range results {
+				for _, line := range result.Stdout {
+					if line != "" {
+						fmt.Printf("%s/%s: %s\n", result.Pod, result.Container, line)
+					}
+				}
+			}
+		}
+		return nil
+	},
+}
+
+func init() {
+	addSelectionFlags(execCmd)
+	execCmd.Flags().StringVar(&execCommand, "cmd", "", "a command to be run with a shell in every container, e.g. 'id; cat /proc/1/status'")
+	execCmd.Flags().StringVar(&execScript, "script", "", "a script file to be passed through stdin of a shell in every container")
+	addScriptVerificationFlags(execCmd.Flags())
+	execCmd.Flags().BoolVar(&execStdout, "stdout", false, "print output of all containers prefixed with pod/container")
+
+	registerFlagCompletions(execCmd)
+	cmd.AddCommand(execCmd)
+}
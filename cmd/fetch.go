@@ -0,0 +1,207 @@
+package cmd
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/hhruszka/k8sexec"
+	"github.com/spf13/cobra"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// fetch CLI options variables
+var (
+	fetchPaths   string
+	fetchMaxSize int
+)
+
+// fetchScript prints at most a given number of bytes of a file passed as the first argument, read with head or,
+// in minimal images without head, with dd. Exec output is split into lines, so the bytes are encoded with base64
+// or, without base64, od and the encoding is printed on the first line.
+const fetchScript = `[ -r "$1" ] || { echo "cannot read $1" >&2; exit 1; }
+if command -v head >/dev/null 2>&1; then r() { head -c "$2" -- "$1"; }
+elif command -v dd >/dev/null 2>&1; then r() { dd if="$1" bs=1 count="$2" 2>/dev/null; }
+else echo "neither head nor dd is available" >&2; exit 127; fi
+if command -v base64 >/dev/null 2>&1; then echo base64; r "$1" "$2" | base64
+elif command -v od >/dev/null 2>&1; then echo hex; r "$1" "$2" | od -An -v -tx1
+else echo "neither base64 nor od is available" >&2; exit 127; fi`
+
+// FetchedFile describes a file retrieved from a container.
+type FetchedFile struct {
+	Pod       string `json:"Pod"`
+	Container string `json:"Container"`
+	Path      string `json:"Path"`
+	File      string `json:"File,omitempty"`
+	Size      int    `json:"Size"`
+	Truncated bool   `json:"Truncated,omitempty"`
+	Error     string `json:"Error,omitempty"`
+}
+
+// fetchFile retrieves a file from a container, reading one byte more than the size limit to detect truncation.
+func fetchFile(ctx context.Context, k8s *k8sexec.K8SExec, container Container, shell string, path string) ([]byte, FetchedFile) {
+	fetched := FetchedFile{Pod: container.Pod, Container: container.Container, Path: path}
+	args := []string{shell, "-c", fetchScript, shell, path, strconv.Itoa(fetchMaxSize + 1)}
+	execStatus := execInContainer(ctx, k8s, container.Pod, container.Container, args, nil)
+	if execStatus.RetCode != k8sexec.Success {
+		fetched.Error = strings.TrimSpace(strings.Join(append(execStatus.Stderr, execStatus.Error...), " "))
+		return nil, fetched
+	}
+
+	content, err := decodeFetched(execStatus.Stdout)
+	if err != nil {
+		fetched.Error = err.Error()
+		return nil, fetched
+	}
+	if len(content) > fetchMaxSize {
+		content, fetched.Truncated = content[:fetchMaxSize], true
+	}
+	fetched.Size = len(content)
+	return content, fetched
+}
+
+// decodeFetched returns bytes of a file printed by fetchScript, i.e. its encoding followed by encoded content.
+func decodeFetched(lines []string) ([]byte, error) {
+	if len(lines) == 0 {
+		return nil, errors.New("no content received")
+	}
+	encoded := strings.Join(strings.Fields(strings.Join(lines[1:], " ")), "")
+	switch strings.TrimSpace(lines[0]) {
+	case "base64":
+		return base64.StdEncoding.DecodeString(encoded)
+	case "hex":
+		return hex.DecodeString(encoded)
+	}
+	return nil, fmt.Errorf("unknown encoding %q of fetched content", lines[0])
+}
+
+// fetchedFilePath returns a relative path, under which a file fetched from a container is saved. Every element of
+// the path in the container is sanitized, so that the tree can be copied to systems with stricter file names.
+func fetchedFilePath(path string) string {
+	var elements []string
+	for _, element := range strings.Split(strings.TrimPrefix(filepath.ToSlash(filepath.Clean("/"+path)), "/"), "/") {
+		elements = append(elements, sanitizeFileName(element))
+	}
+	return filepath.Join(elements...)
+}
+
+// fetchFiles retrieves files from all containers into a tree <dir>/<pod>/<container>/<path>.
+func fetchFiles(ctx context.Context, k8s *k8sexec.K8SExec, containers []Container, paths []string, dir string) []FetchedFile {
+	done := stage(ctx, source(ctx, containers), workerCount(len(containers)), func(p *pacer, container Container) ([]FetchedFile, bool) {
+		p.wait()
+		var fetchedFiles []FetchedFile
+		shell, err := getShellInContainer(ctx, k8s, container)
+		for _, path := range paths {
+			if err != nil {
+				fetchedFiles = append(fetchedFiles, FetchedFile{Pod: container.Pod, Container: container.Container, Path: path, Error: "no shell found in the container"})
+				continue
+			}
+			content, fetched := fetchFile(ctx, k8s, container, shell, path)
+			if fetched.Error == "" {
+				fileName := filepath.Join(dir, reportFileName(dir, "", container.Pod), reportFileName(dir, "", container.Container), fetchedFilePath(path))
+				if err := os.MkdirAll(filepath.Dir(fileName), 0755); err != nil {
+					fetched.Error = err.Error()
+				} else if err := os.WriteFile(fileName, content, 0666); err != nil {
+					fetched.Error = err.Error()
+				} else {
+					fetched.File, _ = filepath.Rel(dir, fileName)
+				}
+			}
+			fetchedFiles = append(fetchedFiles, fetched)
+		}
+		return fetchedFiles, true
+	})
+
+	var (
+		files []FetchedFile
+		cnt   int
+	)
+	for fetchedFiles := range done {
+		files = append(files, fetchedFiles...)
+		cnt++
+		log(fmt.Sprintf("\rFetched files from %d containers", cnt))
+	}
+	log("\n")
+
+	sort.Slice(files, func(i, j int) bool {
+		if files[i].Pod+"/"+files[i].Container != files[j].Pod+"/"+files[j].Container {
+			return files[i].Pod+"/"+files[i].Container < files[j].Pod+"/"+files[j].Container
+		}
+		return files[i].Path < files[j].Path
+	})
+	return files
+}
+
+var fetchCmd = &cobra.Command{
+	Use:   "fetch",
+	Short: "Retrieve files from all selected containers",
+	Long: `
+Retrieves given files from all selected containers into a directory tree <pod>/<container>/<path>, so that
+configurations can be compared across a fleet. Files larger than the size limit are truncated.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		paths := untangleOption(fetchPaths)
+		if len(paths) == 0 {
+			return errors.New("Paths of files to be fetched have to be provided with '--path'")
+		}
+		if fetchMaxSize <= 0 {
+			return errors.New("Invalid value of the size limit '--max-size'. It has to be positive")
+		}
+
+		k8s, containers, err := selectContainers(cmd)
+		if err != nil {
+			return err
+		}
+		startRun(k8s)
+		log(fmt.Sprintf("[+] Fetching %d files from %d containers in %s namespace\n", len(paths), len(containers), namespace))
+
+		ctx, cancel := newRunContext()
+		defer cancel(nil)
+		dir := filepath.Join(directory, fmt.Sprintf("fetch-%s-%s", fileTimestamp(time.Now()), shortRunID()))
+		files := fetchFiles(ctx, k8s, containers, paths, dir)
+
+		var fetched, truncated, failed int
+		for _, file := range files {
+			switch {
+			case file.Error != "":
+				failed++
+			case file.Truncated:
+				truncated++
+				fetched++
+			default:
+				fetched++
+			}
+		}
+		content, err := json.MarshalIndent(map[string]interface{}{"RunID": runID, "Namespace": namespace, "MaxSize": fetchMaxSize, "Files": files}, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(dir, "index.json"), content, 0666); err != nil {
+			return fmt.Errorf("[-] Error saving index of fetched files: %s\n", err.Error())
+		}
+		log(fmt.Sprintf("[+] Fetched %d files (%d truncated), %d could not be fetched, saved to %s\n", fetched, truncated, failed, dir))
+		if runInterrupted(ctx) {
+			return errors.New("[-] Run interrupted, files were not fetched from remaining containers\n")
+		}
+		return nil
+	},
+}
+
+func init() {
+	addSelectionFlags(fetchCmd)
+	fetchCmd.Flags().StringVar(&fetchPaths, "path", "", "a file or comma-separated files to be fetched, e.g. /etc/passwd,/proc/self/status")
+	fetchCmd.Flags().IntVar(&fetchMaxSize, "max-size", 1024*1024, "maximal size of a fetched file in bytes, larger files are truncated")
+
+	registerFlagCompletions(fetchCmd)
+	cmd.AddCommand(fetchCmd)
+}
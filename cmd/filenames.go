@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const (
+	// maximal length of a file name on most file systems
+	maxFileNameLength = 255
+	// maximal length of a path on Windows (MAX_PATH), where reports are often opened by analysts
+	maxPathLength = 260
+)
+
+// characters not allowed in file names on Windows, control characters included
+var invalidFileNameRegexp = regexp.MustCompile(`[<>:"/\\|?*\x00-\x1f\x7f]`)
+
+// names of devices, which cannot be used as file names on Windows, even with an extension
+var reservedFileNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true, "COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true, "LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// sanitizeFileName replaces characters not allowed in file names on Windows with '_', strips trailing dots and
+// spaces and prefixes reserved device names, so that a pod or container name can be used as a part of a file name.
+func sanitizeFileName(name string) string {
+	name = strings.TrimRight(invalidFileNameRegexp.ReplaceAllString(name, "_"), ". ")
+	if name == "" {
+		return "_"
+	}
+	if stem, _, _ := strings.Cut(name, "."); reservedFileNames[strings.ToUpper(stem)] {
+		name = "_" + name
+	}
+	return name
+}
+
+// nameHash returns a short hash of a name, which keeps truncated names unique.
+func nameHash(name string) string {
+	sum := sha256.Sum256([]byte(name))
+	return hex.EncodeToString(sum[:])[:8]
+}
+
+// reportFileName joins sanitized parts of a report file name with '-' and appends the suffix, e.g. the timestamp
+// and extensions. The name is truncated, and a hash of the full name is appended, if it would exceed the maximal
+// length of a file name or its path in the directory would exceed the maximal path length.
+func reportFileName(dir string, suffix string, parts ...string) string {
+	for idx := range parts {
+		parts[idx] = sanitizeFileName(parts[idx])
+	}
+	name := strings.Join(parts, "-")
+
+	limit := maxFileNameLength
+	if absolute, err := filepath.Abs(dir); err == nil {
+		// the path separator between the directory and the name
+		limit = min(limit, maxPathLength-len(absolute)-1)
+	}
+	if len(name)+len(suffix) <= limit {
+		return name + suffix
+	}
+
+	hash := "~" + nameHash(name)
+	keep := max(limit-len(suffix)-len(hash), 1)
+	return strings.TrimRight(name[:min(keep, len(name))], ". ") + hash + suffix
+}
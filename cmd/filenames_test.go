@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSanitizeFileName(t *testing.T) {
+	for name, expected := range map[string]string{
+		"web-1":        "web-1",
+		"app:v1?":      "app_v1_",
+		"con":          "_con",
+		"nul.tar":      "_nul.tar",
+		"trailing. . ": "trailing",
+		"tab\there":    "tab_here",
+		"...":          "_",
+	} {
+		if actual := sanitizeFileName(name); actual != expected {
+			t.Errorf("sanitizeFileName(%q) = %q, expected %q", name, actual, expected)
+		}
+	}
+}
+
+func TestReportFileNameLimitsPathLength(t *testing.T) {
+	dir := t.TempDir()
+	suffix := "-2024-06-01T013005Z-1a2b3c4d.ansi"
+
+	if actual := reportFileName(dir, suffix, "web-1", "app"); actual != "web-1-app"+suffix {
+		t.Errorf("short names should not change, got %s", actual)
+	}
+
+	long := reportFileName(dir, suffix, strings.Repeat("pod", 100), "app")
+	other := reportFileName(dir, suffix, strings.Repeat("pod", 100), "sidecar")
+	absolute, _ := filepath.Abs(filepath.Join(dir, long))
+	switch {
+	case len(long) > maxFileNameLength || len(absolute) > maxPathLength:
+		t.Errorf("name %s is too long", long)
+	case !strings.HasSuffix(long, suffix) || !strings.Contains(long, "~"):
+		t.Errorf("truncated name %s should keep the suffix and carry a hash", long)
+	case long == other:
+		t.Errorf("truncated names of different containers should differ, both are %s", long)
+	}
+}
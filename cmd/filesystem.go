@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"context"
+	"github.com/hhruszka/k8sexec"
+	"strings"
+)
+
+// tmpProbeFile is an empty file created and removed in /tmp of containers to check if /tmp is writable, $$ is
+// the PID of the shell
+const tmpProbeFile = "/tmp/.kubelse-probe-$$"
+
+// filesystemScript prints the mount of the root filesystem of a container and if /tmp is writable
+const filesystemScript = "grep -E '^[^ ]+ / ' /proc/mounts 2>/dev/null | tail -n 1 | sed 's/^/ROOT_MOUNT=/'; " +
+	"f=" + tmpProbeFile + "; if : > \"$f\" 2>/dev/null; then rm -f \"$f\"; echo TMP_WRITABLE=yes; else echo TMP_WRITABLE=no; fi"
+
+// filesystemCommand returns a command, which checks writability of the filesystem of a container.
+func filesystemCommand(container ContainerInfo) []string {
+	return []string{container.shell, "-c", filesystemScript}
+}
+
+// getFilesystemInContainer checks if the root filesystem of the given container is mounted read-only and if /tmp
+// is writable. Both are relevant for lse.sh, because it needs /tmp for temporary files and some of its checks
+// report misleading failures in read-only containers.
+func getFilesystemInContainer(ctx context.Context, k8s *k8sexec.K8SExec, container ContainerInfo) (readOnlyRoot bool, tmpWritable bool) {
+	execStatus := execInContainer(ctx, k8s, container.container.Pod, container.container.Container, filesystemCommand(container), nil)
+	if execStatus.RetCode != k8sexec.Success {
+		// nothing is known, so assume that the container is writable
+		return false, true
+	}
+
+	tmpWritable = true
+	for _, line := range execStatus.Stdout {
+		key, value, found := strings.Cut(strings.TrimSpace(line), "=")
+		if !found {
+			continue
+		}
+		switch key {
+		case "ROOT_MOUNT":
+			// /proc/mounts line: device mountpoint fstype options dump pass
+			if fields := strings.Fields(value); len(fields) >= 4 {
+				readOnlyRoot = strings.HasPrefix(fields[3], "ro,") || fields[3] == "ro"
+			}
+		case "TMP_WRITABLE":
+			tmpWritable = value == "yes"
+		}
+	}
+	return readOnlyRoot, tmpWritable
+}
+
+// filesystemStatus returns a short description of the container's filesystem writability.
+func filesystemStatus(info ContainerInfo) string {
+	var status []string
+	if info.readOnlyRoot {
+		status = append(status, "read-only root")
+	}
+	if !info.tmpWritable {
+		status = append(status, "no /tmp write access")
+	}
+	if len(status) == 0 {
+		return "writable"
+	}
+	return strings.Join(status, ", ")
+}
@@ -0,0 +1,246 @@
+package cmd
+
+import (
+	"html"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// lse.sh marks tests with a character that corresponds to the test's level
+const (
+	SeverityCritical    = "critical"
+	SeverityInteresting = "interesting"
+	SeverityInfo        = "info"
+)
+
+var severities map[string]string = map[string]string{
+	"!": SeverityCritical,
+	"*": SeverityInteresting,
+	"i": SeverityInfo,
+}
+
+var (
+	ansiRegexp    = regexp.MustCompile(`\x1b\[[0-9;?]*[ -/]*[@-~]`)
+	htmlTagRegexp = regexp.MustCompile(`<[^>]*>`)
+	sectionRegexp = regexp.MustCompile(`^=+\( (.+) \)=+$`)
+	testRegexp    = regexp.MustCompile(`^\[([!*i])\] (\S+) (.*?)\.* (yes!|nope|skip)$`)
+	headerRegexp  = regexp.MustCompile(`^ *(\pL[\pL -]*): (.*)$`)
+)
+
+// Finding is a single lse.sh test found in a scan report together with its result and details.
+type Finding struct {
+	Section  string   `json:"Section"`
+	ID       string   `json:"ID"`
+	Severity string   `json:"Severity"`
+	Name     string   `json:"Name"`
+	Result   string   `json:"Result"`
+	Details  []string `json:"Details,omitempty"`
+	// Suppressed is an entry of the ignore file, which suppressed the finding
+	Suppressed string `json:"Suppressed,omitempty"`
+	// Remediation is guidance on fixing a positive finding, see data/remediation.yaml
+	Remediation string `json:"Remediation,omitempty"`
+	// Elevated tells why a finding was elevated to critical on the cluster's provider
+	Elevated string `json:"Elevated,omitempty"`
+	// Triage is a decision of an analyst on the finding, see 'kubelse triage'
+	Triage *TriageDecision `json:"Triage,omitempty"`
+}
+
+// Positive tells if lse.sh found something in a test, which is not suppressed by the ignore file nor triaged as
+// a false positive.
+func (f Finding) Positive() bool {
+	return f.Result == "yes!" && f.Suppressed == "" && (f.Triage == nil || f.Triage.State != TriageFalsePositive)
+}
+
+// AcceptedRisk tells if a finding was triaged as an accepted risk, such findings are not raised again in CI.
+func (f Finding) AcceptedRisk() bool {
+	return f.Triage != nil && f.Triage.State == TriageAcceptedRisk
+}
+
+// Report is a scan report read back from a file.
+type Report struct {
+	Path     string
+	Header   map[string]string
+	Findings []Finding
+	Lines    []string
+}
+
+// Pod returns a name of the pod the report was created for.
+func (r Report) Pod() string {
+	return r.Header["Pod"]
+}
+
+// Container returns a name of the container the report was created for.
+func (r Report) Container() string {
+	return r.Header["Container"]
+}
+
+// Positive returns findings of tests, in which lse.sh found something.
+func (r Report) Positive() []Finding {
+	var positive []Finding
+	for _, finding := range r.Findings {
+		if finding.Positive() {
+			positive = append(positive, finding)
+		}
+	}
+	return positive
+}
+
+// CountBySeverity returns the number of positive findings for every severity.
+func (r Report) CountBySeverity() map[string]int {
+	counts := make(map[string]int)
+	for _, finding := range r.Positive() {
+		counts[finding.Severity]++
+	}
+	return counts
+}
+
+// stripANSI removes ANSI escape sequences from a text.
+func stripANSI(text string) string {
+	return ansiRegexp.ReplaceAllString(text, "")
+}
+
+// plainText removes ANSI escape sequences and any other control characters but tabs and new lines from a text,
+// so that it is plain text regardless of colors lse.sh was run with.
+func plainText(text string) string {
+	return strings.Map(func(r rune) rune {
+		if (r < 0x20 && r != '\t' && r != '\n') || r == 0x7f {
+			return -1
+		}
+		return r
+	}, stripANSI(text))
+}
+
+// parseReport parses lines of a scan report, i.e. kubelse header followed by lse.sh output, into a Report.
+func parseReport(lines []string) Report {
+	var (
+		report   Report = Report{Header: make(map[string]string)}
+		section  string
+		inHeader bool
+	)
+
+	for idx := 0; idx < len(lines); idx++ {
+		line := strings.TrimRight(stripANSI(lines[idx]), " \r")
+		report.Lines = append(report.Lines, line)
+
+		if match := sectionRegexp.FindStringSubmatch(strings.TrimSpace(line)); match != nil {
+			section = match[1]
+			inHeader = section == "kubelse"
+			continue
+		}
+
+		if inHeader {
+			if match := headerRegexp.FindStringSubmatch(line); match != nil {
+				report.Header[headerLabel(strings.TrimSpace(match[1]))] = strings.TrimSpace(match[2])
+				continue
+			}
+			if strings.TrimSpace(line) == "" {
+				inHeader = false
+			}
+			continue
+		}
+
+		match := testRegexp.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		finding := Finding{
+			Section:  section,
+			ID:       match[2],
+			Severity: severities[match[1]],
+			Name:     match[3],
+			Result:   match[4],
+		}
+		if finding.Positive() {
+			finding.Remediation = remediationOf(finding.ID)
+		}
+
+		// details of a test are printed between '---' lines
+		if idx+1 < len(lines) && strings.TrimSpace(stripANSI(lines[idx+1])) == "---" {
+			idx++
+			report.Lines = append(report.Lines, "---")
+			for idx+1 < len(lines) {
+				idx++
+				detail := strings.TrimRight(stripANSI(lines[idx]), " \r")
+				report.Lines = append(report.Lines, detail)
+				if strings.TrimSpace(detail) == "---" {
+					break
+				}
+				if finding.Positive() {
+					finding.Details = append(finding.Details, detail)
+				}
+			}
+		}
+		// lse.sh prints its humanity message as a test, which is not a finding
+		if finding.ID == "nowar0" {
+			continue
+		}
+		report.Findings = append(report.Findings, finding)
+	}
+	return report
+}
+
+// reportExtensions are extensions of files, which are recognized as scan reports
+var reportExtensions []string = []string{".ansi", ".text", ".plain", ".html", ".json"}
+
+// isReportFile tells if a file name looks like a scan report saved by kubelse.
+func isReportFile(name string) bool {
+	for _, ext := range reportExtensions {
+		if filepath.Ext(name) == ext {
+			return true
+		}
+	}
+	return false
+}
+
+// loadReport reads and parses a scan report saved in any of the supported output formats.
+func loadReport(path string) (Report, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return Report{}, err
+	}
+
+	if filepath.Ext(path) == ".json" {
+		return loadJSONReport(path, content)
+	}
+
+	text := string(content)
+	if filepath.Ext(path) == ".html" {
+		text = html.UnescapeString(htmlTagRegexp.ReplaceAllString(htmlCodeRegexp.ReplaceAllString(text, ""), ""))
+	}
+
+	report := parseReport(strings.Split(text, "\n"))
+	report.Path = path
+	return report, nil
+}
+
+// loadReports finds and parses all scan reports under a given directory. Reports are grouped by a run, which
+// is a directory, relative to the given one, the reports were saved in.
+func loadReports(dir string) (map[string][]Report, error) {
+	runs := make(map[string][]Report)
+
+	err := filepath.WalkDir(dir, func(path string, entry os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || !isReportFile(entry.Name()) {
+			return nil
+		}
+		report, err := loadReport(path)
+		if err != nil {
+			return err
+		}
+		// files, which do not have kubelse header, are not kubelse reports
+		if report.Pod() == "" {
+			return nil
+		}
+		run, err := filepath.Rel(dir, filepath.Dir(path))
+		if err != nil {
+			return err
+		}
+		runs[run] = append(runs[run], report)
+		return nil
+	})
+	return runs, err
+}
@@ -0,0 +1,226 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"github.com/spf13/cobra"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// coverage gaps CLI options variables
+var (
+	trackGaps bool
+	gapsAll   bool
+	gapsNote  string
+)
+
+// states of coverage gaps
+const (
+	GapOpen       = "open"
+	GapAccepted   = "accepted"
+	GapRemediated = "remediated"
+)
+
+// CoverageGap is a container, which could not be tested, tracked across runs by an ID, so that it can be accepted
+// or remediated instead of being found again in every run.
+type CoverageGap struct {
+	ID        string    `json:"ID"`
+	Cluster   string    `json:"Cluster"`
+	Namespace string    `json:"Namespace"`
+	Workload  string    `json:"Workload"`
+	Container string    `json:"Container"`
+	Reason    string    `json:"Reason"`
+	State     string    `json:"State"`
+	Note      string    `json:"Note,omitempty"`
+	FirstSeen time.Time `json:"FirstSeen"`
+	LastSeen  time.Time `json:"LastSeen"`
+	// Runs is the number of runs, which could not test the container
+	Runs int `json:"Runs"`
+}
+
+// gapKey identifies a container across runs by its cluster, namespace, workload and name, so that replicas and
+// recreated pods share a gap. Pods without a workload are workloads themselves.
+func gapKey(clusterName string, ns string, entry ManifestEntry) string {
+	return strings.Join([]string{clusterName, ns, valueOrDefault(entry.Workload, "Pod/"+entry.Pod), entry.Container}, "/")
+}
+
+// track records containers of a run, which could not be tested, as gaps, giving new ones tracking IDs, which are
+// set in the manifest. Gaps of containers scanned by the run are remediated, remediated gaps, which containers
+// cannot be tested again, are reopened. It returns the numbers of new and reopened gaps.
+func (db *ResultsDB) track(manifest *Manifest) (int, int) {
+	var created, reopened int
+	for idx := range manifest.NotTestable {
+		entry := &manifest.NotTestable[idx]
+		ns := valueOrDefault(entry.Namespace, manifest.Namespace)
+		key := gapKey(manifest.Cluster.Name, ns, *entry)
+		gap, ok := db.Gaps[key]
+		if !ok {
+			db.NextGap++
+			gap = &CoverageGap{
+				ID:        fmt.Sprintf("GAP-%04d", db.NextGap),
+				Cluster:   manifest.Cluster.Name,
+				Namespace: ns,
+				Workload:  valueOrDefault(entry.Workload, "Pod/"+entry.Pod),
+				Container: entry.Container,
+				State:     GapOpen,
+				FirstSeen: manifest.Started,
+			}
+			db.Gaps[key] = gap
+			created++
+		}
+		if gap.State == GapRemediated {
+			gap.State = GapOpen
+			reopened++
+		}
+		gap.Reason, gap.LastSeen = entry.Reason, manifest.Started
+		gap.Runs++
+		entry.GapID = gap.ID
+	}
+	for _, entry := range manifest.Scanned {
+		if gap, ok := db.Gaps[gapKey(manifest.Cluster.Name, valueOrDefault(entry.Namespace, manifest.Namespace), entry)]; ok && gap.State != GapRemediated {
+			gap.State, gap.Note = GapRemediated, fmt.Sprintf("scanned by run %s", manifest.RunID)
+		}
+	}
+	return created, reopened
+}
+
+// gap returns a gap by its tracking ID.
+func (db *ResultsDB) gap(id string) (*CoverageGap, bool) {
+	for _, gap := range db.Gaps {
+		if strings.EqualFold(gap.ID, id) {
+			return gap, true
+		}
+	}
+	return nil, false
+}
+
+// sortedGaps returns gaps sorted by their tracking IDs, remediated ones only if all are requested.
+func (db *ResultsDB) sortedGaps(all bool) []*CoverageGap {
+	var gaps []*CoverageGap
+	for _, gap := range db.Gaps {
+		if all || gap.State != GapRemediated {
+			gaps = append(gaps, gap)
+		}
+	}
+	sort.Slice(gaps, func(i, j int) bool { return gaps[i].ID < gaps[j].ID })
+	return gaps
+}
+
+// trackCoverageGaps records containers of a run, which could not be tested, in the results DB.
+func trackCoverageGaps(manifest *Manifest) error {
+	file, err := resultsDBPath()
+	if err != nil {
+		return err
+	}
+	db, err := loadResultsDB(file)
+	if err != nil {
+		return err
+	}
+	created, reopened := db.track(manifest)
+	if err := db.save(file); err != nil {
+		return err
+	}
+	open := 0
+	for _, gap := range db.Gaps {
+		if gap.State == GapOpen {
+			open++
+		}
+	}
+	log(fmt.Sprintf("[+] Coverage gaps: %d new, %d reopened, %d open in total, tracked in %s\n", created, reopened, open, file))
+	return nil
+}
+
+// setGapsState sets a state of gaps given by their tracking IDs and saves the results DB.
+func setGapsState(ids []string, state string, note string) error {
+	file, err := resultsDBPath()
+	if err != nil {
+		return err
+	}
+	db, err := loadResultsDB(file)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		gap, ok := db.gap(id)
+		if !ok {
+			return fmt.Errorf("[-] No coverage gap %s in %s\n", id, file)
+		}
+		gap.State, gap.Note = state, note
+	}
+	if err := db.save(file); err != nil {
+		return err
+	}
+	log(fmt.Sprintf("[+] Coverage gaps %s are %s\n", strings.Join(ids, ", "), state))
+	return nil
+}
+
+var gapsCmd = &cobra.Command{
+	Use:   "gaps",
+	Short: "List containers, which could never be scanned, by their tracking IDs",
+	Long: `
+Lists coverage gaps tracked with '--track-gaps': containers, which could not be tested, e.g. since they have no
+shell, with tracking IDs kept across runs, their states and how many runs could not test them. Gaps are open until
+they are accepted, or remediated, which happens once a run scans the container.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		file, err := resultsDBPath()
+		if err != nil {
+			return err
+		}
+		db, err := loadResultsDB(file)
+		if err != nil {
+			return err
+		}
+		gaps := db.sortedGaps(gapsAll)
+		if len(gaps) == 0 {
+			fmt.Println("No coverage gaps")
+			return nil
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tState\tCluster\tNamespace\tWorkload\tContainer\tFirst seen\tLast seen\tRuns\tReason")
+		for _, gap := range gaps {
+			reason := gap.Reason
+			if gap.Note != "" {
+				reason += " (" + gap.Note + ")"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%d\t%s\n", gap.ID, gap.State, gap.Cluster, gap.Namespace, gap.Workload,
+				gap.Container, formatTimestamp(gap.FirstSeen), formatTimestamp(gap.LastSeen), gap.Runs, reason)
+		}
+		return w.Flush()
+	},
+}
+
+var gapsAcceptCmd = &cobra.Command{
+	Use:   "accept <id>... --note <reason>",
+	Short: "Accept coverage gaps, e.g. of distroless images covered by other controls",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if gapsNote == "" {
+			return errors.New("A reason the gaps are accepted has to be provided with '--note'")
+		}
+		return setGapsState(args, GapAccepted, gapsNote)
+	},
+}
+
+var gapsReopenCmd = &cobra.Command{
+	Use:   "reopen <id>...",
+	Short: "Reopen accepted or remediated coverage gaps",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return setGapsState(args, GapOpen, gapsNote)
+	},
+}
+
+func init() {
+	gapsCmd.PersistentFlags().StringVar(&resultsDBFile, "results-db", "", "a file results of runs are kept in, if not provided then results.json in the kubelse user configuration directory")
+	gapsCmd.Flags().BoolVar(&gapsAll, "all", false, "list also remediated gaps")
+	gapsAcceptCmd.Flags().StringVar(&gapsNote, "note", "", "a reason the gaps are accepted")
+	gapsReopenCmd.Flags().StringVar(&gapsNote, "note", "", "a reason the gaps are reopened")
+
+	gapsCmd.AddCommand(gapsAcceptCmd, gapsReopenCmd)
+	cmd.AddCommand(gapsCmd)
+}
@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCoverageGapsAreTrackedAcrossRuns(t *testing.T) {
+	resultsDBFile = filepath.Join(t.TempDir(), "results.json")
+	defer func() { resultsDBFile = "" }()
+	started := time.Date(2024, 4, 1, 6, 0, 0, 0, time.UTC)
+	run := func(id string, scanned []ManifestEntry, notTestable []ManifestEntry) Manifest {
+		started = started.Add(24 * time.Hour)
+		manifest := Manifest{RunID: id, Namespace: "payments", Cluster: ClusterInfo{Name: "prod"}, Started: started, Scanned: scanned, NotTestable: notTestable}
+		if err := trackCoverageGaps(&manifest); err != nil {
+			t.Fatal(err)
+		}
+		return manifest
+	}
+	distroless := func(pod string) ManifestEntry {
+		return ManifestEntry{Pod: pod, Container: "app", Workload: "Deployment/api", Reason: "missing shell or utilities required by lse.sh"}
+	}
+
+	first := run("run-1", nil, []ManifestEntry{distroless("api-1"), {Pod: "debug", Container: "busybox"}})
+	if first.NotTestable[0].GapID != "GAP-0001" || first.NotTestable[1].GapID != "GAP-0002" {
+		t.Fatalf("expected tracking IDs of both containers, got %+v", first.NotTestable)
+	}
+	// a recreated replica keeps the tracking ID of its workload
+	second := run("run-2", nil, []ManifestEntry{distroless("api-9")})
+	if second.NotTestable[0].GapID != "GAP-0001" {
+		t.Fatalf("expected the gap of the api workload, got %s", second.NotTestable[0].GapID)
+	}
+	if err := setGapsState([]string{"gap-0002"}, GapAccepted, "debug pod"); err != nil {
+		t.Fatal(err)
+	}
+	run("run-3", []ManifestEntry{{Pod: "api-10", Container: "app", Workload: "Deployment/api"}}, nil)
+
+	db, err := loadResultsDB(resultsDBFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	api, _ := db.gap("GAP-0001")
+	if api.State != GapRemediated || api.Runs != 2 || !api.FirstSeen.Before(api.LastSeen) {
+		t.Errorf("expected the api gap remediated after 2 runs, got %+v", api)
+	}
+	debug, _ := db.gap("GAP-0002")
+	if debug.State != GapAccepted || debug.Note != "debug pod" || debug.Workload != "Pod/debug" {
+		t.Errorf("expected the debug gap accepted, got %+v", debug)
+	}
+	if gaps := db.sortedGaps(false); len(gaps) != 1 || gaps[0].ID != "GAP-0002" {
+		t.Errorf("expected remediated gaps left out, got %+v", gaps)
+	}
+
+	// the api workload cannot be tested again
+	fourth := run("run-4", nil, []ManifestEntry{distroless("api-11")})
+	if db, _ = loadResultsDB(resultsDBFile); db.Gaps[gapKey("prod", "payments", fourth.NotTestable[0])].State != GapOpen {
+		t.Errorf("expected the api gap reopened")
+	}
+	if err := setGapsState([]string{"GAP-0009"}, GapAccepted, "unknown"); err == nil {
+		t.Errorf("expected an unknown gap rejected")
+	}
+}
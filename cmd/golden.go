@@ -0,0 +1,297 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/hhruszka/k8sexec"
+	corev1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sort"
+	"strings"
+	"time"
+)
+
+// golden baseline CLI options variables
+var (
+	golden        bool
+	goldenTimeout time.Duration
+)
+
+// goldenPollInterval is how often golden pods are checked until they are running
+var goldenPollInterval = time.Second
+
+// annotationGolden marks golden pods deployed by kubelse with the image they are a baseline of
+const annotationGolden = "kubelse.io/golden-image"
+
+// goldenCommand keeps a golden container running, without doing anything, until its pod is deleted.
+var goldenCommand = []string{"sh", "-c", "trap 'exit 0' TERM; while :; do sleep 3600 & wait; done"}
+
+// DriftFinding is a positive finding of a container, or details of it, missing in the golden container of the
+// same image.
+type DriftFinding struct {
+	ID       string   `json:"ID"`
+	Name     string   `json:"Name"`
+	Severity string   `json:"Severity"`
+	Category string   `json:"Category"`
+	Details  []string `json:"Details,omitempty"`
+}
+
+// ContainerDrift is a runtime drift of a container from the golden container of its image.
+type ContainerDrift struct {
+	Container string         `json:"Container"`
+	Image     string         `json:"Image"`
+	Golden    string         `json:"Golden"`
+	Findings  []DriftFinding `json:"Findings"`
+}
+
+// goldenPodName returns a name of the golden pod of an image.
+func goldenPodName(image string) string {
+	return "kubelse-golden-" + nameHash(image)
+}
+
+// isGolden tells if a container is a golden container deployed by kubelse.
+func isGolden(container Container) bool {
+	return container.Annotations[annotationGolden] != ""
+}
+
+// goldenPod returns a golden pod of the image of a container. The pod runs with the same security context and
+// image pull secrets as the container's pod, so that it enumerates from the same perspective, but without
+// a service account token.
+func goldenPod(reference *corev1.Pod, container Container) *corev1.Pod {
+	var securityContext *corev1.SecurityContext
+	for _, spec := range reference.Spec.Containers {
+		if spec.Name == container.Container {
+			securityContext = spec.SecurityContext
+		}
+	}
+	automount, grace := false, int64(0)
+	return &corev1.Pod{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:        goldenPodName(container.Image),
+			Namespace:   reference.Namespace,
+			Labels:      map[string]string{"app.kubernetes.io/managed-by": "kubelse"},
+			Annotations: map[string]string{annotationGolden: container.Image},
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{
+				Name:            "golden",
+				Image:           container.Image,
+				Command:         goldenCommand,
+				SecurityContext: securityContext,
+			}},
+			SecurityContext:               reference.Spec.SecurityContext,
+			ImagePullSecrets:              reference.Spec.ImagePullSecrets,
+			RestartPolicy:                 corev1.RestartPolicyNever,
+			AutomountServiceAccountToken:  &automount,
+			TerminationGracePeriodSeconds: &grace,
+		},
+	}
+}
+
+// waitForRunning waits until a pod is running or the golden timeout passes.
+func waitForRunning(k8s *k8sexec.K8SExec, name string) (*corev1.Pod, error) {
+	deadline := time.Now().Add(goldenTimeout)
+	for {
+		pod, err := clientsetOf(k8s).CoreV1().Pods(k8s.Namespace).Get(context.TODO(), name, metaV1.GetOptions{})
+		switch {
+		case err != nil:
+			return nil, err
+		case pod.Status.Phase == corev1.PodRunning:
+			return pod, nil
+		case pod.Status.Phase == corev1.PodFailed || pod.Status.Phase == corev1.PodSucceeded:
+			return nil, fmt.Errorf("pod %s exited", name)
+		case time.Now().After(deadline):
+			return nil, fmt.Errorf("pod %s did not start within %s", name, goldenTimeout)
+		}
+		time.Sleep(goldenPollInterval)
+	}
+}
+
+// deployGoldenPods deploys a golden pod, freshly started from the image, for every image of containers and returns
+// golden containers to be scanned together with the containers. Names of all deployed pods are returned, so that
+// they can be removed, even if some of them did not start.
+func deployGoldenPods(k8s *k8sexec.K8SExec, containers []Container) ([]Container, []string) {
+	references, images := make(map[string]Container), make(map[string]bool)
+	for _, container := range containers {
+		if !images[container.Image] && container.Image != "" {
+			references[container.Image], images[container.Image] = container, true
+		}
+	}
+
+	var (
+		goldens []Container
+		created []string
+	)
+	for _, image := range sortedKeys(images) {
+		container := references[image]
+		reference, err := k8s.GetPod(container.Pod, metaV1.GetOptions{})
+		if err != nil {
+			log(fmt.Sprintf("[-] Error deploying golden pod of %s: %s\n", image, err.Error()))
+			continue
+		}
+		pod, err := clientsetOf(k8s).CoreV1().Pods(k8s.Namespace).Create(context.TODO(), goldenPod(reference, container), metaV1.CreateOptions{})
+		if err != nil {
+			log(fmt.Sprintf("[-] Error deploying golden pod of %s: %s\n", image, err.Error()))
+			continue
+		}
+		created = append(created, pod.Name)
+		if pod, err = waitForRunning(k8s, pod.Name); err != nil {
+			log(fmt.Sprintf("[-] Error deploying golden pod of %s: %s\n", image, err.Error()))
+			continue
+		}
+		goldens = append(goldens, newContainer(*pod, "golden"))
+	}
+	log(fmt.Sprintf("[+] Deployed %d golden pods of %d images\n", len(goldens), len(references)))
+	return goldens, created
+}
+
+// removeGoldenPods deletes golden pods deployed for a run.
+func removeGoldenPods(k8s *k8sexec.K8SExec, names []string) {
+	for _, name := range names {
+		if err := clientsetOf(k8s).CoreV1().Pods(k8s.Namespace).Delete(context.TODO(), name, metaV1.DeleteOptions{}); err != nil {
+			log(fmt.Sprintf("[-] Error removing golden pod %s: %s\n", name, err.Error()))
+		}
+	}
+}
+
+// driftCategory tells what kind of drift a finding of a test is.
+func driftCategory(finding Finding) string {
+	switch {
+	case setuidBitTests[finding.ID] != "":
+		return setuidBitTests[finding.ID]
+	case strings.HasPrefix(finding.ID, "usr"):
+		return "users"
+	case strings.HasPrefix(finding.ID, "fst"):
+		return "files"
+	default:
+		return finding.Section
+	}
+}
+
+// findingDetails returns non-empty details of a finding without colors.
+func findingDetails(finding Finding) []string {
+	var details []string
+	for _, detail := range finding.Details {
+		if detail = strings.TrimSpace(stripANSI(detail)); detail != "" {
+			details = append(details, detail)
+		}
+	}
+	return details
+}
+
+// newDrift compares positive findings of containers with findings of the golden container of the same image. Only
+// findings, or details of them, missing in the golden container are kept, since the rest comes with the image.
+// Containers of images without a scanned golden container are returned as not compared.
+func newDrift(results []Result) (drift []ContainerDrift, uncompared []string) {
+	baselines := make(map[string]map[string]map[string]bool)
+	goldens := make(map[string]string)
+	for _, result := range results {
+		container := result.container.container
+		if !isGolden(container) || result.status() == StatusFailed {
+			continue
+		}
+		image := container.Annotations[annotationGolden]
+		baselines[image], goldens[image] = make(map[string]map[string]bool), container.Pod
+		for _, finding := range result.findings().Positive() {
+			baselines[image][finding.ID] = make(map[string]bool)
+			for _, detail := range findingDetails(finding) {
+				baselines[image][finding.ID][detail] = true
+			}
+		}
+	}
+
+	for _, result := range results {
+		container := result.container.container
+		if isGolden(container) {
+			continue
+		}
+		baseline, ok := baselines[container.Image]
+		if !ok {
+			uncompared = append(uncompared, container.String())
+			continue
+		}
+		containerDrift := ContainerDrift{Container: container.String(), Image: container.Image, Golden: goldens[container.Image], Findings: []DriftFinding{}}
+		for _, finding := range result.findings().Positive() {
+			var details []string
+			for _, detail := range findingDetails(finding) {
+				if !baseline[finding.ID][detail] {
+					details = append(details, detail)
+				}
+			}
+			if _, found := baseline[finding.ID]; found && len(details) == 0 {
+				continue
+			}
+			containerDrift.Findings = append(containerDrift.Findings, DriftFinding{ID: finding.ID, Name: finding.Name, Severity: finding.Severity, Category: driftCategory(finding), Details: details})
+		}
+		drift = append(drift, containerDrift)
+	}
+	sort.Slice(drift, func(i, j int) bool { return drift[i].Container < drift[j].Container })
+	sort.Strings(uncompared)
+	return drift, uncompared
+}
+
+// driftLines renders drift of containers from golden containers of their images.
+func driftLines(drift []ContainerDrift, uncompared []string) []string {
+	drifted := 0
+	for _, container := range drift {
+		if len(container.Findings) > 0 {
+			drifted++
+		}
+	}
+	lines := []string{
+		"================================( kubelse drift from golden images )================================",
+		fmt.Sprintf("          Run ID: %s", runID),
+		fmt.Sprintf("         Cluster: %s (%s)", valueOrDefault(cluster.Name, "unknown"), valueOrDefault(cluster.Server, "unknown")),
+		fmt.Sprintf("       Namespace: %s", scannedNamespace()),
+		fmt.Sprintf("      Containers: %d compared, %d drifted", len(drift), drifted),
+		"",
+	}
+	for _, container := range drift {
+		if len(container.Findings) == 0 {
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("=====( %s: %s, golden %s )=====", container.Container, container.Image, container.Golden))
+		for _, finding := range container.Findings {
+			lines = append(lines, fmt.Sprintf("[%s] %s %s (%s)", finding.Category, finding.ID, finding.Name, finding.Severity))
+			for _, detail := range finding.Details {
+				lines = append(lines, "    + "+detail)
+			}
+		}
+		lines = append(lines, "")
+	}
+	if len(uncompared) > 0 {
+		lines = append(lines, "=====( not compared, golden containers of their images could not be scanned )=====")
+		lines = append(lines, uncompared...)
+	}
+	return lines
+}
+
+// saveDrift saves drift of containers of a run from golden containers of their images in the reports directory.
+func saveDrift(started time.Time, results []Result) {
+	drift, uncompared := newDrift(results)
+	report := renderReport(driftLines(drift, uncompared))
+	if format == "json" {
+		report, _ = json.MarshalIndent(map[string]interface{}{
+			"RunID":      runID,
+			"Cluster":    cluster,
+			"Namespace":  scannedNamespace(),
+			"Containers": drift,
+			"Uncompared": uncompared,
+		}, "", "  ")
+	}
+
+	fileName := reportFileName(directory, "."+format, "kubelse-drift", fileTimestamp(started), shortRunID())
+	fileName, err := writeReportWithFallback(fileName, report)
+	if err != nil {
+		log(fmt.Sprintf("[-] Error saving drift from golden images: %s\n", err.Error()))
+		return
+	}
+	drifted := 0
+	for _, container := range drift {
+		if len(container.Findings) > 0 {
+			drifted++
+		}
+	}
+	log(fmt.Sprintf("[+] %d of %d containers drifted from golden images, drift saved to %s\n", drifted, len(drift), fileName))
+}
@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/spf13/cobra"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// grep CLI options variables
+var (
+	grepDirectory  string
+	grepSeverity   string
+	grepIgnoreCase bool
+)
+
+// grepFinding returns details of a finding matching a pattern, or the test's name if the name itself matches.
+// The second value tells if the finding matches at all.
+func grepFinding(finding Finding, pattern *regexp.Regexp) ([]string, bool) {
+	var matched []string
+	for _, detail := range finding.Details {
+		if pattern.MatchString(detail) {
+			matched = append(matched, detail)
+		}
+	}
+	if len(matched) > 0 {
+		return matched, true
+	}
+	if pattern.MatchString(finding.ID) || pattern.MatchString(finding.Name) {
+		return nil, true
+	}
+	return nil, false
+}
+
+var grepCmd = &cobra.Command{
+	Use:   "grep <pattern>",
+	Short: "Search findings across saved scan reports",
+	Long: `
+Searches findings of all scan reports saved in a directory for a regular expression and prints matching
+findings with their pod, container, section and test.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		expr := args[0]
+		if grepIgnoreCase {
+			expr = "(?i)" + expr
+		}
+		pattern, err := regexp.Compile(expr)
+		if err != nil {
+			return fmt.Errorf("Invalid pattern %q: %s\n", args[0], err.Error())
+		}
+
+		runs, err := loadReports(grepDirectory)
+		if err != nil {
+			return err
+		}
+
+		var names []string
+		for name := range runs {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		var (
+			buf     bytes.Buffer
+			matches int
+		)
+		w := tabwriter.NewWriter(&buf, 0, 0, 1, ' ', 0)
+		for _, name := range names {
+			for _, report := range runs[name] {
+				for _, finding := range report.Positive() {
+					if grepSeverity != "" && finding.Severity != grepSeverity {
+						continue
+					}
+					details, ok := grepFinding(finding, pattern)
+					if !ok {
+						continue
+					}
+					matches++
+					fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s %s\n", name, report.Pod(), report.Container(), finding.Section, finding.ID, finding.Name)
+					for _, detail := range details {
+						fmt.Fprintf(w, "\t\t\t\t  %s\n", strings.TrimSpace(detail))
+					}
+				}
+			}
+		}
+		w.Flush()
+		fmt.Print(buf.String())
+
+		if matches == 0 {
+			return fmt.Errorf("[-] No findings matching %q found in %s\n", args[0], grepDirectory)
+		}
+		return nil
+	},
+}
+
+func init() {
+	workingDirectory, err := os.Getwd()
+	if err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+	grepCmd.Flags().StringVarP(&grepDirectory, "directory", "d", workingDirectory, "a directory with scan reports")
+	grepCmd.Flags().StringVarP(&grepSeverity, "severity", "s", "", "search only findings of a given severity: critical, interesting or info")
+	grepCmd.Flags().BoolVarP(&grepIgnoreCase, "ignore-case", "i", false, "ignore case when matching the pattern")
+
+	cmd.AddCommand(grepCmd)
+}
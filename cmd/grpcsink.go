@@ -0,0 +1,148 @@
+package cmd
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	collectorv1 "k8slse/proto/kubelse/collector/v1"
+	"os"
+	"time"
+)
+
+// gRPC sink CLI options variables
+var (
+	grpcSink     string
+	grpcInsecure bool
+)
+
+const (
+	// an environment variable with a bearer token sent to the collector
+	grpcTokenVariable = "KUBELSE_GRPC_TOKEN"
+	// a timeout of a single call of the collector
+	grpcTimeout = 10 * time.Second
+)
+
+// newFinding converts a finding to a kubelse.collector.v1.Finding message.
+func newFinding(finding Finding) *collectorv1.Finding {
+	message := &collectorv1.Finding{
+		Id:       finding.ID,
+		Section:  finding.Section,
+		Severity: finding.Severity,
+		Name:     finding.Name,
+		Details:  finding.Details,
+	}
+	if finding.Triage != nil {
+		message.TriageState, message.TriageComment = finding.Triage.State, finding.Triage.Comment
+	}
+	return message
+}
+
+// newScanResult converts a result of a scan to a kubelse.collector.v1.ScanResult message.
+func newScanResult(instance string, result Result) *collectorv1.ScanResult {
+	container := result.container.container
+	message := &collectorv1.ScanResult{
+		Instance:  instance,
+		RunId:     runID,
+		Cluster:   cluster.Name,
+		Namespace: scannedNamespace(),
+		Pod:       container.Pod,
+		Container: container.Container,
+		Workload:  container.Workload,
+		Image:     container.Image,
+		Owner:     container.Owner,
+		Status:    result.status(),
+		Finished:  now().Format(time.RFC3339),
+	}
+	for _, finding := range result.findings().Positive() {
+		message.Findings = append(message.Findings, newFinding(finding))
+	}
+	return message
+}
+
+// grpcCollector streams results of a run to a gRPC collector as soon as containers are scanned. Results are
+// queued, so that a slow collector does not hold up scanning.
+type grpcCollector struct {
+	conn     *grpc.ClientConn
+	client   collectorv1.CollectorClient
+	token    string
+	instance string
+	queue    chan Result
+	done     chan struct{}
+	sent     int
+	failed   int
+}
+
+// newGRPCCollector sets up the gRPC collector of a run, it returns nil if results are not streamed.
+func newGRPCCollector() (*grpcCollector, error) {
+	if grpcSink == "" {
+		return nil, nil
+	}
+
+	creds := credentials.NewTLS(&tls.Config{})
+	if grpcInsecure {
+		creds = insecure.NewCredentials()
+	}
+	conn, err := grpc.NewClient(grpcSink, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return nil, err
+	}
+	instance, _ := os.Hostname()
+	collector := &grpcCollector{
+		conn:     conn,
+		client:   collectorv1.NewCollectorClient(conn),
+		token:    os.Getenv(grpcTokenVariable),
+		instance: valueOrDefault(instance, "kubelse"),
+		queue:    make(chan Result, 1000),
+		done:     make(chan struct{}),
+	}
+	go collector.run()
+	return collector, nil
+}
+
+// run sends queued results to the collector.
+func (c *grpcCollector) run() {
+	defer close(c.done)
+	for result := range c.queue {
+		if err := c.report(result); err != nil {
+			c.failed++
+			log(fmt.Sprintf("\n[-] Error sending result of %s to the gRPC collector: %s\n", result.container.container.String(), err.Error()))
+			continue
+		}
+		c.sent++
+	}
+}
+
+// report calls the Report method of the collector with a result of a scan.
+func (c *grpcCollector) report(result Result) error {
+	ctx, cancel := context.WithTimeout(context.Background(), grpcTimeout)
+	defer cancel()
+	if c.token != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+c.token)
+	}
+	_, err := c.client.Report(ctx, newScanResult(c.instance, result))
+	return err
+}
+
+// Write queues a result of a scan to be sent to the collector.
+func (c *grpcCollector) Write(result *Result) error {
+	c.queue <- *result
+	return nil
+}
+
+// Close waits until queued results are sent, results which could not be sent fail it.
+func (c *grpcCollector) Close() error {
+	close(c.queue)
+	<-c.done
+	c.conn.Close()
+	if c.failed > 0 {
+		return fmt.Errorf("%d of %d results could not be sent", c.failed, c.sent+c.failed)
+	}
+	log(fmt.Sprintf("[+] Sent %d results to the gRPC collector %s\n", c.sent, grpcSink))
+	return nil
+}
+
+func (c *grpcCollector) String() string { return "gRPC collector " + grpcSink }
@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	collectorv1 "k8slse/proto/kubelse/collector/v1"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// testCollector records results reported to it, or rejects them with an error.
+type testCollector struct {
+	collectorv1.UnimplementedCollectorServer
+	mu      sync.Mutex
+	results []*collectorv1.ScanResult
+	tokens  []string
+	err     error
+}
+
+func (c *testCollector) Report(ctx context.Context, result *collectorv1.ScanResult) (*collectorv1.ReportResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return nil, c.err
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	c.tokens = append(c.tokens, md.Get("authorization")...)
+	c.results = append(c.results, result)
+	return &collectorv1.ReportResponse{}, nil
+}
+
+// startTestCollector starts a gRPC collector without TLS and points '--grpc-sink' at it.
+func startTestCollector(t *testing.T, collector *testCollector) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer()
+	collectorv1.RegisterCollectorServer(server, collector)
+	go server.Serve(listener)
+	grpcSink, grpcInsecure = listener.Addr().String(), true
+	t.Cleanup(func() {
+		server.Stop()
+		grpcSink, grpcInsecure, sinks = "", false, nil
+	})
+}
+
+func TestResultsAreStreamedToGRPCCollector(t *testing.T) {
+	server := &testCollector{}
+	startTestCollector(t, server)
+	t.Setenv(grpcTokenVariable, "secret")
+
+	cluster, k8s := startTestCluster(t, testPod("web-1", "nginx", nil), testPod("web-2", "nginx", nil))
+	cluster.SetContainer("web-1", "app", debian)
+	cluster.SetContainer("web-2", "app", debian)
+
+	containers, err := getContainers(k8s, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := scanContainers(k8s, containers); err != nil {
+		t.Fatal(err)
+	}
+	collector := sinks[len(sinks)-1].(*grpcCollector)
+	if collector.sent != 2 || collector.failed != 0 {
+		t.Fatalf("expected 2 results to be sent, %d were sent and %d failed", collector.sent, collector.failed)
+	}
+
+	var pods []string
+	for _, result := range server.results {
+		pods = append(pods, result.Pod)
+		if result.RunId != runID || result.Status != StatusComplete || len(result.Findings) != 2 {
+			t.Errorf("unexpected result %v", result)
+		}
+		if finding := result.Findings[1]; finding.Id != "fst010" || finding.Severity != SeverityCritical {
+			t.Errorf("unexpected finding %v", finding)
+		}
+	}
+	if strings.Join(pods, ",") != "web-1,web-2" && strings.Join(pods, ",") != "web-2,web-1" {
+		t.Errorf("expected results of web-1 and web-2, got %v", pods)
+	}
+	if strings.Join(server.tokens, ",") != "Bearer secret,Bearer secret" {
+		t.Errorf("expected the token sent with every result, got %v", server.tokens)
+	}
+}
+
+func TestResultsNotSentFailGRPCCollector(t *testing.T) {
+	startTestCollector(t, &testCollector{err: status.Error(codes.Unavailable, "collector is down")})
+	collector, err := newGRPCCollector()
+	if err != nil {
+		t.Fatal(err)
+	}
+	collector.Write(&Result{container: ContainerInfo{container: Container{Pod: "web-1", Container: "app"}}})
+	if err := collector.Close(); err == nil || !strings.Contains(err.Error(), "1 of 1 results could not be sent") {
+		t.Errorf("expected an error of the result not sent, got %v", err)
+	}
+}
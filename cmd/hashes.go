@@ -0,0 +1,179 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/hhruszka/k8sexec"
+	"sort"
+	"strings"
+	"time"
+)
+
+// hash inventory CLI options variables
+var hashInventory bool
+
+// keyBinaries are binaries hashed in every container, an attacker replacing them gets root or a persistent foothold
+var keyBinaries = []string{
+	"/bin/sh", "/bin/bash", "/bin/dash", "/bin/busybox", "/bin/su", "/usr/bin/su", "/usr/bin/sudo",
+	"/usr/bin/passwd", "/usr/bin/newgrp", "/usr/bin/chsh", "/usr/bin/mount", "/bin/mount", "/usr/sbin/sshd",
+}
+
+// setuidTests are lse.sh tests listing setuid and setgid binaries, which are hashed as well
+var setuidTests = map[string]bool{"fst010": true, "fst020": true, "fst040": true, "fst050": true}
+
+// hashScript prints sha256 hashes of existing regular files given as its arguments, as 'sha256sum' does.
+const hashScript = `for f in "$@"; do [ -f "$f" ] && [ ! -L "$f" ] || continue; sha256sum "$f" 2>/dev/null || busybox sha256sum "$f" 2>/dev/null; done`
+
+// HashOutlier is a binary, which hash differs from the hash of the same binary in most of the containers of the
+// same image.
+type HashOutlier struct {
+	Image     string `json:"Image"`
+	Path      string `json:"Path"`
+	Container string `json:"Container"`
+	Hash      string `json:"Hash"`
+	Expected  string `json:"Expected"`
+	Majority  string `json:"Majority"`
+}
+
+// HashInventory lists hashes of binaries of scanned containers grouped by image, path and hash.
+type HashInventory struct {
+	RunID    string                                    `json:"RunID"`
+	Images   map[string]map[string]map[string][]string `json:"Images"`
+	Outliers []HashOutlier                             `json:"Outliers"`
+}
+
+// findingPaths returns paths listed in details of a finding, one per line.
+func findingPaths(finding Finding) []string {
+	var paths []string
+	for _, detail := range finding.Details {
+		if detail = strings.TrimSpace(stripANSI(detail)); strings.HasPrefix(detail, "/") && !strings.ContainsAny(detail, " \t") {
+			paths = append(paths, detail)
+		}
+	}
+	return paths
+}
+
+// setuidPaths returns paths of setuid and setgid binaries found by lse.sh in a scan report.
+func setuidPaths(report []string) []string {
+	var paths []string
+	for _, finding := range parseReport(report).Positive() {
+		if setuidTests[finding.ID] {
+			paths = append(paths, findingPaths(finding)...)
+		}
+	}
+	return paths
+}
+
+// hashCommand returns a command, which hashes key binaries and the given setuid and setgid binaries of a container.
+func hashCommand(container ContainerInfo, setuid []string) []string {
+	paths := append(append([]string{}, keyBinaries...), setuid...)
+	sort.Strings(paths)
+	args := []string{container.shell, "-c", hashScript, container.shell}
+	for idx, path := range paths {
+		if idx == 0 || path != paths[idx-1] {
+			args = append(args, path)
+		}
+	}
+	return args
+}
+
+// collectHashes hashes key binaries and setuid and setgid binaries found by lse.sh in a container. It returns
+// hashes by path, or nil if nothing could be hashed, e.g. because sha256sum is missing.
+func collectHashes(ctx context.Context, k8s *k8sexec.K8SExec, container ContainerInfo, report []string) map[string]string {
+	execStatus := execInContainer(ctx, k8s, container.container.Pod, container.container.Container, hashCommand(container, setuidPaths(report)), nil)
+	hashes := make(map[string]string)
+	for _, line := range execStatus.Stdout {
+		if hash, path, found := strings.Cut(strings.TrimSpace(line), "  "); found && len(hash) == 64 {
+			hashes[path] = hash
+		}
+	}
+	if len(hashes) == 0 {
+		return nil
+	}
+	return hashes
+}
+
+// newHashInventory groups hashes of binaries of containers by image and finds outliers: binaries, which hash
+// differs from the hash shared by a strict majority of containers of the same image having the binary.
+func newHashInventory(results []Result) HashInventory {
+	inventory := HashInventory{RunID: runID, Images: make(map[string]map[string]map[string][]string), Outliers: []HashOutlier{}}
+	for _, result := range results {
+		image := valueOrDefault(result.container.container.Image, "unknown")
+		for path, hash := range result.hashes {
+			if inventory.Images[image] == nil {
+				inventory.Images[image] = make(map[string]map[string][]string)
+			}
+			if inventory.Images[image][path] == nil {
+				inventory.Images[image][path] = make(map[string][]string)
+			}
+			inventory.Images[image][path][hash] = append(inventory.Images[image][path][hash], result.container.container.String())
+		}
+	}
+
+	for image, paths := range inventory.Images {
+		for path, hashes := range paths {
+			if len(hashes) < 2 {
+				continue
+			}
+			var expected string
+			total := 0
+			for hash, containers := range hashes {
+				total += len(containers)
+				if expected == "" || len(containers) > len(hashes[expected]) {
+					expected = hash
+				}
+			}
+			if len(hashes[expected])*2 <= total {
+				// no hash is shared by most of the containers, so none of them can be told tampered
+				expected = ""
+			}
+			for hash, containers := range hashes {
+				if hash == expected {
+					continue
+				}
+				for _, container := range containers {
+					inventory.Outliers = append(inventory.Outliers, HashOutlier{
+						Image: image, Path: path, Container: container, Hash: hash, Expected: expected,
+						Majority: fmt.Sprintf("%d of %d containers", len(hashes[expected]), total),
+					})
+				}
+			}
+		}
+	}
+	sort.Slice(inventory.Outliers, func(i, j int) bool {
+		a, b := inventory.Outliers[i], inventory.Outliers[j]
+		if a.Image != b.Image {
+			return a.Image < b.Image
+		}
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+		return a.Container < b.Container
+	})
+	return inventory
+}
+
+// saveHashInventory saves the hash inventory of a run in the reports directory and logs outliers.
+func saveHashInventory(started time.Time, results []Result) {
+	inventory := newHashInventory(results)
+	for _, outlier := range inventory.Outliers {
+		if outlier.Expected == "" {
+			log(fmt.Sprintf("[*] %s differs across containers of %s, %s has %.12s\n", outlier.Path, outlier.Image, outlier.Container, outlier.Hash))
+			continue
+		}
+		log(fmt.Sprintf("[!] %s in %s differs from other containers of %s (%s): %.12s instead of %.12s\n", outlier.Path, outlier.Container, outlier.Image, outlier.Majority, outlier.Hash, outlier.Expected))
+	}
+
+	content, err := json.MarshalIndent(inventory, "", "  ")
+	if err != nil {
+		log(fmt.Sprintf("[-] Error saving hash inventory: %s\n", err.Error()))
+		return
+	}
+	fileName := reportFileName(directory, ".json", "kubelse-hashes", fileTimestamp(started), shortRunID())
+	if fileName, err = writeReportWithFallback(fileName, content); err != nil {
+		log(fmt.Sprintf("[-] Error saving hash inventory: %s\n", err.Error()))
+		return
+	}
+	log(fmt.Sprintf("[+] Hash inventory of %d images saved to %s\n", len(inventory.Images), fileName))
+}
@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"github.com/hhruszka/k8sexec"
+	authorizationV1 "k8s.io/api/authorization/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"strings"
+	"time"
+)
+
+// explainAPIError turns an error returned by the Kubernetes API into an actionable message.
+func explainAPIError(action string, err error) error {
+	switch {
+	case strings.Contains(err.Error(), "getting credentials"):
+		return fmt.Errorf("[-] Health check failed: %s: the credential plugin of the kubeconfig failed, log in to the cluster again: %s\n", action, err.Error())
+	case apierrors.IsUnauthorized(err):
+		return fmt.Errorf("[-] Health check failed: %s: authentication failed, credentials in the kubeconfig may have expired, log in to the cluster again: %s\n", action, err.Error())
+	case apierrors.IsForbidden(err):
+		return fmt.Errorf("[-] Health check failed: %s: access forbidden, the user needs permissions to list pods and create pods/exec in namespace %q: %s\n", action, namespace, err.Error())
+	default:
+		return fmt.Errorf("[-] Health check failed: %s: cluster unreachable, check the kubeconfig server address and network connectivity: %s\n", action, err.Error())
+	}
+}
+
+// healthCheck probes the cluster before discovery: it calls the version endpoint, checks pods of the namespace
+// can be listed and exec'd into and runs a lightweight command in one of the pods, so that connectivity and
+// permission problems are reported upfront instead of failing deep inside the worker pool.
+func healthCheck(k8s *k8sexec.K8SExec) error {
+	log(fmt.Sprintln("[*] Checking connection to the cluster"))
+
+	start := time.Now()
+	serverVersion, err := clientsetOf(k8s).Discovery().ServerVersion()
+	if err != nil {
+		return explainAPIError("getting server version", err)
+	}
+	latency := time.Since(start)
+
+	pods, err := clientsetOf(k8s).CoreV1().Pods(k8s.Namespace).List(context.TODO(), metaV1.ListOptions{Limit: 1})
+	if err != nil {
+		return explainAPIError("listing pods", err)
+	}
+
+	review := &authorizationV1.SelfSubjectAccessReview{
+		Spec: authorizationV1.SelfSubjectAccessReviewSpec{
+			ResourceAttributes: &authorizationV1.ResourceAttributes{
+				Namespace:   k8s.Namespace,
+				Verb:        "create",
+				Resource:    "pods",
+				Subresource: "exec",
+			},
+		},
+	}
+	review, err = clientsetOf(k8s).AuthorizationV1().SelfSubjectAccessReviews().Create(context.TODO(), review, metaV1.CreateOptions{})
+	if err != nil {
+		return explainAPIError("checking pods/exec permission", err)
+	}
+	if !review.Status.Allowed {
+		return fmt.Errorf("[-] Health check failed: creating pods/exec is forbidden in namespace %q, containers cannot be scanned %s\n", k8s.Namespace, review.Status.Reason)
+	}
+
+	if len(pods.Items) > 0 && len(pods.Items[0].Spec.Containers) > 0 {
+		pod := pods.Items[0]
+		start = time.Now()
+		execStatus := execInContainer(context.TODO(), k8s, pod.Name, pod.Spec.Containers[0].Name, []string{"true"}, nil)
+		// a failing or missing command still proves that exec works, only rejected requests matter here
+		if message := strings.Join(execStatus.Error, " "); execStatus.RetCode == k8sexec.InternalAppError {
+			lower := strings.ToLower(message)
+			if strings.Contains(lower, "forbidden") || strings.Contains(lower, "unauthorized") {
+				return fmt.Errorf("[-] Health check failed: exec into pod %s was rejected: %s\n", pod.Name, message)
+			}
+			log(fmt.Sprintf("[-] Probe command in pod %s failed: %s\n", pod.Name, message))
+		}
+		log(fmt.Sprintf("[+] Exec into pod %s took %s\n", pod.Name, time.Since(start).Round(time.Millisecond)))
+	}
+
+	log(fmt.Sprintf("[+] Connected to Kubernetes %s, API latency %s\n", serverVersion.GitVersion, latency.Round(time.Millisecond)))
+	return nil
+}
@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"github.com/hhruszka/k8sexec"
+	corev1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Helm annotates every object of a release with its name, pods of charts following the Kubernetes and the
+// older Helm labeling conventions are labeled with it as well.
+const (
+	helmReleaseAnnotation   = "meta.helm.sh/release-name"
+	helmNamespaceAnnotation = "meta.helm.sh/release-namespace"
+)
+
+// helm CLI options variables
+var helmRelease string
+
+// helmReleaseSelectors returns label selectors of pods labeled as belonging to a Helm release.
+func helmReleaseSelectors(release string) []string {
+	return []string{
+		fmt.Sprintf("app.kubernetes.io/instance=%s,app.kubernetes.io/managed-by=Helm", release),
+		fmt.Sprintf("release=%s,heritage=Helm", release),
+	}
+}
+
+// helmReleaseAnnotated returns true if an object's annotations tell that it was installed by a Helm release.
+func helmReleaseAnnotated(annotations map[string]string, release string, namespace string) bool {
+	if annotations[helmReleaseAnnotation] != release {
+		return false
+	}
+	ns, ok := annotations[helmNamespaceAnnotation]
+	return !ok || ns == namespace
+}
+
+// helmReleaseWorkloadSelectors returns pod selectors of deployments, statefulsets and daemonsets installed by
+// a Helm release, so that pods of charts not labeling their pods with the release are found as well.
+func helmReleaseWorkloadSelectors(k8s *k8sexec.K8SExec, release string) ([]string, error) {
+	var selectors []string
+	add := func(selector *metaV1.LabelSelector) {
+		if s, err := metaV1.LabelSelectorAsSelector(selector); err == nil && !s.Empty() {
+			selectors = append(selectors, s.String())
+		}
+	}
+
+	apps := clientsetOf(k8s).AppsV1()
+	deployments, err := apps.Deployments(k8s.Namespace).List(context.TODO(), metaV1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, deployment := range deployments.Items {
+		if helmReleaseAnnotated(deployment.Annotations, release, k8s.Namespace) {
+			add(deployment.Spec.Selector)
+		}
+	}
+	statefulSets, err := apps.StatefulSets(k8s.Namespace).List(context.TODO(), metaV1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, statefulSet := range statefulSets.Items {
+		if helmReleaseAnnotated(statefulSet.Annotations, release, k8s.Namespace) {
+			add(statefulSet.Spec.Selector)
+		}
+	}
+	daemonSets, err := apps.DaemonSets(k8s.Namespace).List(context.TODO(), metaV1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, daemonSet := range daemonSets.Items {
+		if helmReleaseAnnotated(daemonSet.Annotations, release, k8s.Namespace) {
+			add(daemonSet.Spec.Selector)
+		}
+	}
+	return selectors, nil
+}
+
+// helmReleasePods returns pods belonging to a Helm release, i.e. pods labeled with the release and pods of
+// workloads annotated by Helm with the release.
+func helmReleasePods(k8s *k8sexec.K8SExec, release string) ([]corev1.Pod, error) {
+	workloadSelectors, err := helmReleaseWorkloadSelectors(k8s, release)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		pods []corev1.Pod
+		seen = make(map[string]bool)
+	)
+	for _, selector := range append(helmReleaseSelectors(release), workloadSelectors...) {
+		found, err := k8s.GetPods(metaV1.ListOptions{LabelSelector: selector})
+		if err != nil {
+			return nil, err
+		}
+		for _, pod := range found {
+			if !seen[pod.Name] {
+				seen[pod.Name] = true
+				pods = append(pods, pod)
+			}
+		}
+	}
+	log(fmt.Sprintf("[+] Found %d pods of Helm release %s\n", len(pods), release))
+	return pods, nil
+}
@@ -0,0 +1,210 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/robert-nix/ansihtml"
+	"html"
+	"regexp"
+	"strings"
+)
+
+// script and style blocks of html reports, which are not part of the report text
+var htmlCodeRegexp = regexp.MustCompile(`(?s)<(script|style)[^>]*>.*?</(script|style)>`)
+
+const (
+	htmlReportHeader = `<!DOCTYPE html>
+<html lang="%s">
+<head>
+<meta charset="UTF-8"/>
+<title>%s</title>
+<style>
+body { color: white; background-color: black; font-family: monospace; }
+#toolbar { position: sticky; top: 0; background-color: #222; padding: 6px; z-index: 1; }
+#toolbar input, #toolbar select { font-family: monospace; }
+details { border-left: 3px solid #444; margin: 4px 0; padding-left: 6px; }
+summary { cursor: pointer; color: #8cf; }
+summary::before { content: attr(data-label); }
+.entry { white-space: pre; }
+.remediation { white-space: normal; color: #9f9; margin: 2px 0 6px 2em; }
+.hidden { display: none; }
+</style>
+</head>
+<body>
+<div id="toolbar"></div>
+`
+	htmlReportFooter = `<script>
+(function () {
+  // the toolbar is created here, so that reports read back as text contain only the report
+  document.getElementById("toolbar").innerHTML = %s;
+
+  var rank = { info: 0, interesting: 1, critical: 2 };
+  var search = document.getElementById("search");
+  var severity = document.getElementById("severity");
+  var positive = document.getElementById("positive");
+
+  function filter() {
+    var query = search.value.toLowerCase();
+    var minimal = severity.value;
+    var filtering = query !== "" || minimal !== "" || positive.checked;
+    document.querySelectorAll("details").forEach(function (section) {
+      var visible = 0;
+      section.querySelectorAll(".entry").forEach(function (entry) {
+        var show = true;
+        if (minimal !== "" || positive.checked) {
+          var entrySeverity = entry.getAttribute("data-severity");
+          show = entrySeverity !== null;
+          if (show && minimal !== "") {
+            show = rank[entrySeverity] >= rank[minimal];
+          }
+          if (show && positive.checked) {
+            show = entry.getAttribute("data-positive") === "true";
+          }
+        }
+        if (show && query !== "") {
+          show = entry.textContent.toLowerCase().indexOf(query) !== -1;
+        }
+        entry.classList.toggle("hidden", !show);
+        if (show) {
+          visible++;
+        }
+      });
+      section.classList.toggle("hidden", filtering && visible === 0);
+      if (filtering && visible > 0) {
+        section.open = true;
+      }
+    });
+  }
+
+  search.addEventListener("input", filter);
+  severity.addEventListener("change", filter);
+  positive.addEventListener("change", filter);
+  document.getElementById("expand").addEventListener("click", function () {
+    document.querySelectorAll("details").forEach(function (section) { section.open = true; });
+  });
+  document.getElementById("collapse").addEventListener("click", function () {
+    document.querySelectorAll("details").forEach(function (section) { section.open = false; });
+  });
+})();
+</script>
+</body>
+</html>
+`
+)
+
+// htmlEntry is a test of lse.sh output together with its details, or any other line, which is shown or hidden
+// as a whole by filters of html reports.
+type htmlEntry struct {
+	lines       []string
+	severity    string
+	positive    bool
+	remediation string
+}
+
+// htmlSection is a section of lse.sh output, or the kubelse header, rendered as a collapsible block.
+type htmlSection struct {
+	name    string
+	entries []htmlEntry
+}
+
+// htmlSections splits report lines into sections and entries.
+func htmlSections(lines []string) []htmlSection {
+	var sections []htmlSection
+	for idx := 0; idx < len(lines); idx++ {
+		stripped := strings.TrimSpace(stripANSI(lines[idx]))
+		if match := sectionRegexp.FindStringSubmatch(stripped); match != nil || len(sections) == 0 {
+			name := "output"
+			if match != nil {
+				name = match[1]
+			}
+			sections = append(sections, htmlSection{name: name})
+		}
+		section := &sections[len(sections)-1]
+
+		entry := htmlEntry{lines: []string{lines[idx]}}
+		if match := testRegexp.FindStringSubmatch(strings.TrimRight(stripANSI(lines[idx]), " \r")); match != nil {
+			entry.severity, entry.positive = severities[match[1]], match[4] == "yes!"
+			if entry.positive {
+				entry.remediation = remediationOf(match[2])
+			}
+			// details of a test are printed between '---' lines
+			if idx+1 < len(lines) && strings.TrimSpace(stripANSI(lines[idx+1])) == "---" {
+				idx++
+				entry.lines = append(entry.lines, lines[idx])
+				for idx+1 < len(lines) {
+					idx++
+					entry.lines = append(entry.lines, lines[idx])
+					if strings.TrimSpace(stripANSI(lines[idx])) == "---" {
+						break
+					}
+				}
+			}
+		}
+		section.entries = append(section.entries, entry)
+	}
+	return sections
+}
+
+// htmlToolbar returns the toolbar of html reports, with labels translated into '--lang', as a JavaScript string.
+func htmlToolbar() string {
+	toolbar := fmt.Sprintf(`<input id="search" type="search" placeholder="%s" size="40"/> `+
+		`<select id="severity"><option value="">%s</option><option value="critical">%s</option>`+
+		`<option value="interesting">%s</option><option value="info">%s</option></select> `+
+		`<label><input id="positive" type="checkbox"/> %s</label> `+
+		`<button id="expand">%s</button> <button id="collapse">%s</button>`,
+		html.EscapeString(tr("search")), html.EscapeString(tr("all severities")), html.EscapeString(tr("critical")),
+		html.EscapeString(tr("interesting and critical")), html.EscapeString(tr("info, interesting and critical")),
+		html.EscapeString(tr("positive findings only")), html.EscapeString(tr("expand all")), html.EscapeString(tr("collapse all")))
+	content, _ := json.Marshal(toolbar)
+	return string(content)
+}
+
+// renderHTMLReport renders report lines as an html page, in which sections are collapsible and entries can be
+// searched and filtered by severity.
+func renderHTMLReport(lines []string) []byte {
+	var buf bytes.Buffer
+
+	title := tr("kubelse report")
+	if header := parseReport(lines).Header; header["Pod"] != "" {
+		title = fmt.Sprintf(tr("kubelse report: %s/%s"), header["Pod"], header["Container"])
+	}
+	fmt.Fprintf(&buf, htmlReportHeader, valueOrDefault(lang, defaultLanguage), html.EscapeString(title))
+
+	for _, section := range htmlSections(lines) {
+		var critical, interesting int
+		for _, entry := range section.entries {
+			switch {
+			case entry.positive && entry.severity == SeverityCritical:
+				critical++
+			case entry.positive && entry.severity == SeverityInteresting:
+				interesting++
+			}
+		}
+		label := section.name
+		if critical+interesting > 0 {
+			label = fmt.Sprintf(tr("%s (%d critical, %d interesting)"), section.name, critical, interesting)
+		}
+		fmt.Fprintf(&buf, "<details open=\"open\"><summary data-label=\"%s\"></summary>\n", html.EscapeString(label))
+		for _, entry := range section.entries {
+			attributes := ""
+			if entry.severity != "" {
+				attributes = fmt.Sprintf(" data-severity=\"%s\" data-positive=\"%t\"", entry.severity, entry.positive)
+			}
+			text := strings.Join(entry.lines, "\n")
+			if text == "" {
+				// empty lines would collapse otherwise
+				text = " "
+			}
+			remediation := ""
+			if entry.remediation != "" {
+				// the new line keeps the remediation on its own line, when the report is read back as text
+				remediation = fmt.Sprintf("\n<div class=\"remediation\">%s</div>", html.EscapeString(fmt.Sprintf(tr("Remediation: %s"), entry.remediation)))
+			}
+			fmt.Fprintf(&buf, "<div class=\"entry\"%s>%s%s</div>\n", attributes, ansihtml.ConvertToHTML([]byte(text)), remediation)
+		}
+		buf.WriteString("</details>\n")
+	}
+	fmt.Fprintf(&buf, htmlReportFooter, htmlToolbar())
+	return buf.Bytes()
+}
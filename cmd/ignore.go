@@ -0,0 +1,190 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+)
+
+// ignore file CLI options variables
+var ignoreFile string
+
+// defaultIgnoreFile is read from the working directory, if it exists and no other ignore file is given
+const defaultIgnoreFile = ".kubelseignore"
+
+// kinds of entries of an ignore file
+const (
+	IgnoreNamespace = "namespace"
+	IgnoreWorkload  = "workload"
+	IgnoreContainer = "container"
+	IgnoreFinding   = "finding"
+)
+
+// IgnoreRule is an entry of an ignore file: a namespace, workload or container to be skipped, or a finding to be
+// suppressed, optionally only in matching workloads. Patterns are shell globs, workloads are matched by
+// <namespace>/<kind>/<name>, e.g. payments/Deployment/api.
+type IgnoreRule struct {
+	Kind    string
+	Pattern string
+	Scope   string
+	Line    int
+}
+
+// String returns where a rule is defined, e.g. .kubelseignore:12.
+func (r IgnoreRule) String() string {
+	return fmt.Sprintf("%s:%d", ignoreFile, r.Line)
+}
+
+// ignoreRules are rules of the ignore file of the run
+var ignoreRules []IgnoreRule
+
+// parseIgnoreRules parses an ignore file. Every line is an entry, blank lines and lines starting with '#' are
+// skipped:
+//
+//	namespace <namespace>
+//	workload <namespace>/<kind>/<name>
+//	container <container> | <namespace>/<kind>/<name>/<container>
+//	finding <id> [<namespace>/<kind>/<name>]
+func parseIgnoreRules(content string) ([]IgnoreRule, error) {
+	var rules []IgnoreRule
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		// a comment may follow an entry, e.g. a reason of an exception
+		if idx := strings.Index(text, " #"); idx >= 0 {
+			text = text[:idx]
+		}
+		fields := strings.Fields(text)
+		rule := IgnoreRule{Kind: fields[0], Line: line}
+		switch {
+		case rule.Kind == IgnoreFinding && (len(fields) == 2 || len(fields) == 3):
+			rule.Pattern = fields[1]
+			if len(fields) == 3 {
+				rule.Scope = fields[2]
+			}
+		case (rule.Kind == IgnoreNamespace || rule.Kind == IgnoreWorkload || rule.Kind == IgnoreContainer) && len(fields) == 2:
+			rule.Pattern = fields[1]
+		default:
+			return nil, fmt.Errorf("line %d: invalid entry %q, expected namespace, workload, container or finding followed by a pattern", line, text)
+		}
+		for _, pattern := range []string{rule.Pattern, rule.Scope} {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("line %d: invalid pattern %q", line, pattern)
+			}
+		}
+		rules = append(rules, rule)
+	}
+	return rules, scanner.Err()
+}
+
+// loadIgnoreFile reads rules of an ignore file, the default ignore file does not have to exist.
+func loadIgnoreFile(file string) error {
+	ignoreRules = nil
+	content, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) && file == defaultIgnoreFile {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if ignoreRules, err = parseIgnoreRules(string(content)); err != nil {
+		return err
+	}
+	log(fmt.Sprintf("[+] Read %d entries of the ignore file %s\n", len(ignoreRules), file))
+	return nil
+}
+
+// globMatches tells if a glob pattern matches a value, patterns are validated when they are read.
+func globMatches(pattern string, value string) bool {
+	matched, _ := path.Match(pattern, value)
+	return matched
+}
+
+// ignoredContainer returns a rule, which skips a container of a namespace, if there is any.
+func ignoredContainer(ns string, container Container) (IgnoreRule, bool) {
+	workload := ns + "/" + container.Workload
+	for _, rule := range ignoreRules {
+		switch {
+		case rule.Kind == IgnoreNamespace && globMatches(rule.Pattern, ns),
+			rule.Kind == IgnoreWorkload && globMatches(rule.Pattern, workload),
+			rule.Kind == IgnoreContainer && strings.Contains(rule.Pattern, "/") && globMatches(rule.Pattern, workload+"/"+container.Container),
+			rule.Kind == IgnoreContainer && !strings.Contains(rule.Pattern, "/") && globMatches(rule.Pattern, container.Container):
+			return rule, true
+		}
+	}
+	return IgnoreRule{}, false
+}
+
+// skipIgnored moves containers skipped by the ignore file to skipped containers and returns the remaining ones.
+func skipIgnored(ns string, containers []Container) []Container {
+	var remaining []Container
+	for _, container := range containers {
+		if rule, ok := ignoredContainer(ns, container); ok {
+			skippedContainers = append(skippedContainers, SkippedContainer{container, fmt.Sprintf("ignored by %s", rule)})
+			continue
+		}
+		remaining = append(remaining, container)
+	}
+	return remaining
+}
+
+// findingRules returns rules suppressing findings in a container of a namespace.
+func findingRules(ns string, container Container) []IgnoreRule {
+	var rules []IgnoreRule
+	for _, rule := range ignoreRules {
+		if rule.Kind == IgnoreFinding && (rule.Scope == "" || globMatches(rule.Scope, ns+"/"+container.Workload)) {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+// findings returns findings of a scanned container, findings suppressed by the ignore file are marked suppressed
+// and are not positive anymore, as are findings triaged as false positives. Findings mentioning the node metadata service of the cluster's provider are
+// elevated to critical.
+func (r Result) findings() Report {
+	report := parseReport(r.scanReport)
+	for idx, finding := range report.Findings {
+		for _, rule := range r.suppress {
+			if finding.Result == "yes!" && globMatches(rule.Pattern, finding.ID) {
+				report.Findings[idx].Suppressed = rule.String()
+				break
+			}
+		}
+		if decision, ok := r.triage[finding.ID]; ok && finding.Result == "yes!" {
+			report.Findings[idx].Triage = decision
+		}
+		if reason := elevatedByProvider(report.Findings[idx]); reason != "" {
+			report.Findings[idx].Severity, report.Findings[idx].Elevated = SeverityCritical, reason
+		}
+	}
+	return report
+}
+
+// elevatedFindings returns IDs of findings of a scanned container elevated to critical on the cluster's provider.
+func (r Result) elevatedFindings() []string {
+	var elevated []string
+	for _, finding := range r.findings().Findings {
+		if finding.Elevated != "" {
+			elevated = append(elevated, finding.ID)
+		}
+	}
+	return elevated
+}
+
+// suppressedFindings returns IDs of findings of a scanned container suppressed by the ignore file.
+func (r Result) suppressedFindings() []string {
+	var suppressed []string
+	for _, finding := range r.findings().Findings {
+		if finding.Suppressed != "" {
+			suppressed = append(suppressed, finding.ID)
+		}
+	}
+	return suppressed
+}
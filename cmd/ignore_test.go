@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseIgnoreRules(t *testing.T) {
+	rules, err := parseIgnoreRules(`
+# reviewed exceptions
+namespace kube-*
+workload payments/Deployment/legacy-api  # decommissioned in Q3
+container istio-proxy
+container payments/Deployment/api/debug
+finding fst010
+finding sud040 payments/*/*
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 6 || rules[1].Pattern != "payments/Deployment/legacy-api" || rules[1].Line != 4 || rules[5].Scope != "payments/*/*" {
+		t.Errorf("unexpected rules %+v", rules)
+	}
+
+	ignoreRules = rules
+	t.Cleanup(func() { ignoreRules = nil })
+	for _, tc := range []struct {
+		ns        string
+		container Container
+		ignored   bool
+	}{
+		{"kube-system", Container{Workload: "DaemonSet/proxy", Container: "proxy"}, true},
+		{"payments", Container{Workload: "Deployment/legacy-api", Container: "app"}, true},
+		{"payments", Container{Workload: "Deployment/api", Container: "istio-proxy"}, true},
+		{"payments", Container{Workload: "Deployment/api", Container: "debug"}, true},
+		{"payments", Container{Workload: "Deployment/api", Container: "app"}, false},
+		{"orders", Container{Workload: "Deployment/api", Container: "debug"}, false},
+	} {
+		if _, ignored := ignoredContainer(tc.ns, tc.container); ignored != tc.ignored {
+			t.Errorf("%s/%s/%s: expected ignored %v", tc.ns, tc.container.Workload, tc.container.Container, tc.ignored)
+		}
+	}
+	if suppress := findingRules("payments", Container{Workload: "Deployment/api"}); len(suppress) != 2 {
+		t.Errorf("expected 2 finding rules in payments, got %+v", suppress)
+	}
+	if suppress := findingRules("orders", Container{Workload: "Deployment/api"}); len(suppress) != 1 {
+		t.Errorf("expected 1 finding rule in orders, got %+v", suppress)
+	}
+
+	for _, invalid := range []string{"pod web-1", "workload", "finding fst010 a b", "container [a"} {
+		if _, err := parseIgnoreRules(invalid); err == nil {
+			t.Errorf("expected an error for %q", invalid)
+		}
+	}
+}
+
+func TestIgnoreFileSkipsContainersAndSuppressesFindings(t *testing.T) {
+	cluster, k8s := startTestCluster(t, testPod("web-1", "nginx", nil), testPod("web-2", "nginx", nil), testPod("legacy-1", "nginx", nil))
+	for _, pod := range []string{"web-1", "web-2", "legacy-1"} {
+		cluster.SetContainer(pod, "app", debian)
+	}
+	rules, err := parseIgnoreRules("workload default/Pod/legacy-*\nfinding fst010 default/Pod/web-1\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ignoreFile, ignoreRules = defaultIgnoreFile, rules
+	t.Cleanup(func() { ignoreRules = nil })
+
+	containers, err := getContainers(k8s, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest, err := scanContainers(k8s, containers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(manifest.Skipped) != 1 || manifest.Skipped[0].Pod != "legacy-1" || manifest.Skipped[0].Reason != "ignored by .kubelseignore:1" {
+		t.Errorf("unexpected skipped containers %+v", manifest.Skipped)
+	}
+	for _, entry := range manifest.Scanned {
+		suppressed := strings.Join(entry.Suppressed, ",")
+		switch entry.Pod {
+		case "web-1":
+			if suppressed != "fst010" || entry.Findings[SeverityCritical] != 0 {
+				t.Errorf("expected fst010 suppressed in web-1, got %+v", entry)
+			}
+		case "web-2":
+			if suppressed != "" || entry.Findings[SeverityCritical] != 1 {
+				t.Errorf("expected fst010 reported in web-2, got %+v", entry)
+			}
+		default:
+			t.Errorf("unexpected scanned pod %s", entry.Pod)
+		}
+	}
+}
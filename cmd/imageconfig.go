@@ -0,0 +1,430 @@
+package cmd
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/hhruszka/k8sexec"
+	"io"
+	corev1 "k8s.io/api/core/v1"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// image config CLI options variables
+var imageConfig bool
+
+// media types of manifests the registry is asked for, image indexes are resolved to the linux/amd64 image or the
+// first linux one
+var manifestMediaTypes = []string{
+	"application/vnd.oci.image.index.v1+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+}
+
+// challengeRegexp matches parameters of a WWW-Authenticate challenge, e.g. realm="https://auth.docker.io/token"
+var challengeRegexp = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+// RuntimeSpec is how a container is run according to its pod, which is compared with defaults of its image.
+type RuntimeSpec struct {
+	Command []string
+	// RunAsUser is the user of the container or the pod, nil if neither sets it
+	RunAsUser    *int64
+	RunAsNonRoot bool
+	// Ports are declared container ports, e.g. 8080/tcp
+	Ports []string
+	// ImageID is the image the container runs, as reported by the container runtime, e.g. containerd or CRI-O
+	ImageID string
+	// PullSecrets are names of image pull secrets of the pod
+	PullSecrets []string
+}
+
+// podRuntimeSpec returns the runtime spec of a container of a pod.
+func podRuntimeSpec(pod *corev1.Pod, name string) RuntimeSpec {
+	var spec RuntimeSpec
+	if sc := pod.Spec.SecurityContext; sc != nil {
+		spec.RunAsUser, spec.RunAsNonRoot = sc.RunAsUser, sc.RunAsNonRoot != nil && *sc.RunAsNonRoot
+	}
+	for _, container := range podContainers(pod) {
+		if container.Name != name {
+			continue
+		}
+		spec.Command = container.Command
+		if sc := container.SecurityContext; sc != nil {
+			if sc.RunAsUser != nil {
+				spec.RunAsUser = sc.RunAsUser
+			}
+			if sc.RunAsNonRoot != nil {
+				spec.RunAsNonRoot = *sc.RunAsNonRoot
+			}
+		}
+		for _, port := range container.Ports {
+			spec.Ports = append(spec.Ports, fmt.Sprintf("%d/%s", port.ContainerPort, strings.ToLower(valueOrDefault(string(port.Protocol), "TCP"))))
+		}
+	}
+	for _, secret := range pod.Spec.ImagePullSecrets {
+		spec.PullSecrets = append(spec.PullSecrets, secret.Name)
+	}
+	for _, status := range append(append([]corev1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...) {
+		if status.Name == name {
+			spec.ImageID = status.ImageID
+		}
+	}
+	return spec
+}
+
+// ImageConfig is the configuration of an image, i.e. defaults of containers running it.
+type ImageConfig struct {
+	User         string              `json:"User"`
+	Entrypoint   []string            `json:"Entrypoint"`
+	Cmd          []string            `json:"Cmd"`
+	WorkingDir   string              `json:"WorkingDir"`
+	ExposedPorts map[string]struct{} `json:"ExposedPorts"`
+	Labels       map[string]string   `json:"Labels"`
+}
+
+// imageReference is an image in a registry.
+type imageReference struct {
+	registry   string
+	repository string
+	// reference is a tag or a digest
+	reference string
+}
+
+// parseImageReference parses an image of a pod, e.g. nginx:1.25 or ghcr.io/org/app@sha256:..., using the digest of
+// the image the container runtime reports, if it has one, so that the image actually run is inspected.
+func parseImageReference(image string, imageID string) (imageReference, error) {
+	name, digest, _ := strings.Cut(image, "@")
+	if idx := strings.LastIndex(name, ":"); idx > strings.LastIndex(name, "/") {
+		if digest == "" {
+			digest = name[idx+1:]
+		}
+		name = name[:idx]
+	}
+	// runtimes report e.g. docker.io/library/nginx@sha256:..., or docker-pullable://nginx@sha256:...
+	if _, id, ok := strings.Cut(imageID, "@"); ok && strings.HasPrefix(id, "sha256:") {
+		digest = id
+	}
+	if name == "" {
+		return imageReference{}, fmt.Errorf("invalid image %q", image)
+	}
+	ref := imageReference{registry: "docker.io", repository: name, reference: valueOrDefault(digest, "latest")}
+	if first, rest, ok := strings.Cut(name, "/"); ok && (strings.ContainsAny(first, ".:") || first == "localhost") {
+		ref.registry, ref.repository = first, rest
+	}
+	if ref.registry == "docker.io" {
+		ref.registry = "registry-1.docker.io"
+		if !strings.Contains(ref.repository, "/") {
+			ref.repository = "library/" + ref.repository
+		}
+	}
+	return ref, nil
+}
+
+// String returns the image reference, e.g. in log messages.
+func (r imageReference) String() string {
+	separator := ":"
+	if strings.HasPrefix(r.reference, "sha256:") {
+		separator = "@"
+	}
+	return r.registry + "/" + r.repository + separator + r.reference
+}
+
+// imageConfigResult is a config of an image read once for all containers running it.
+type imageConfigResult struct {
+	once   sync.Once
+	config *ImageConfig
+	err    error
+}
+
+// registryClient reads image configs with the registry API, anonymously, with tokens the registry hands out for
+// pulling public images or with credentials of the registry.
+type registryClient struct {
+	http    *http.Client
+	mu      sync.Mutex
+	configs map[string]*imageConfigResult
+	// clients are HTTP clients of registries with their own CAs by their hosts
+	clients map[string]*http.Client
+	// secrets are image pull secrets read by their namespaces and names
+	secrets map[string]*dockerConfigFile
+}
+
+// newRegistryClient returns a registry client, which reads a config of every image once.
+func newRegistryClient(client *http.Client) *registryClient {
+	return &registryClient{http: client, configs: make(map[string]*imageConfigResult), clients: make(map[string]*http.Client), secrets: make(map[string]*dockerConfigFile)}
+}
+
+// registrySession is authorization of requests reading an image from a registry.
+type registrySession struct {
+	client *http.Client
+	auth   *registryAuth
+	// authorization is the Authorization header of requests, once the registry asked for it
+	authorization string
+}
+
+// registry reads image configs of scanned containers
+var registry = newRegistryClient(&http.Client{Timeout: 30 * time.Second})
+
+// authorize returns an Authorization header for reading a repository from a registry, which asked for it with
+// a challenge: basic authentication with the credential or a bearer token, which is requested with it if there is
+// one.
+func (session *registrySession) authorize(challenge string, ref imageReference) (string, error) {
+	scheme, params, _ := strings.Cut(challenge, " ")
+	var basic string
+	if session.auth != nil {
+		basic = "Basic " + base64.StdEncoding.EncodeToString([]byte(session.auth.username+":"+session.auth.password))
+	}
+	switch {
+	case strings.EqualFold(scheme, "Basic") && basic != "":
+		return basic, nil
+	case !strings.EqualFold(scheme, "Bearer"):
+		return "", fmt.Errorf("%s requires credentials, provide them with '--pull-secrets', '--docker-config' or '--registry-config'", ref.registry)
+	}
+	values := make(map[string]string)
+	for _, match := range challengeRegexp.FindAllStringSubmatch(params, -1) {
+		values[strings.ToLower(match[1])] = match[2]
+	}
+	realm, err := url.Parse(values["realm"])
+	if err != nil || values["realm"] == "" {
+		return "", fmt.Errorf("invalid authentication challenge of %s: %s", ref.registry, challenge)
+	}
+	query := realm.Query()
+	if values["service"] != "" {
+		query.Set("service", values["service"])
+	}
+	query.Set("scope", valueOrDefault(values["scope"], "repository:"+ref.repository+":pull"))
+	realm.RawQuery = query.Encode()
+
+	req, err := http.NewRequest(http.MethodGet, realm.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	if basic != "" {
+		req.Header.Set("Authorization", basic)
+	}
+	resp, err := session.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s refused a token to pull %s%s: %s", realm.Host, ref.repository, authSource(session.auth), resp.Status)
+	}
+	var token struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", err
+	}
+	return "Bearer " + valueOrDefault(token.Token, token.AccessToken), nil
+}
+
+// authSource returns where a credential comes from in error messages, e.g. " with pull secret payments/regcred".
+func authSource(auth *registryAuth) string {
+	if auth == nil {
+		return ""
+	}
+	return " with " + auth.source
+}
+
+// get reads a manifest or a blob of a repository, authenticating with a token if the registry asks for one.
+func (session *registrySession) get(ref imageReference, path string, accept []string) ([]byte, error) {
+	for {
+		req, err := http.NewRequest(http.MethodGet, registryURL(ref.registry)+ref.repository+path, nil)
+		if err != nil {
+			return nil, err
+		}
+		if len(accept) > 0 {
+			req.Header.Set("Accept", strings.Join(accept, ", "))
+		}
+		if session.authorization != "" {
+			req.Header.Set("Authorization", session.authorization)
+		}
+		resp, err := session.client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		content, err := io.ReadAll(io.LimitReader(resp.Body, 4<<20))
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case resp.StatusCode == http.StatusUnauthorized && session.authorization == "":
+			if session.authorization, err = session.authorize(resp.Header.Get("WWW-Authenticate"), ref); err != nil {
+				return nil, err
+			}
+			continue
+		case resp.StatusCode != http.StatusOK:
+			return nil, fmt.Errorf("GET %s returned %s%s", req.URL.Path, resp.Status, authSource(session.auth))
+		}
+		return content, nil
+	}
+}
+
+// fetchImageConfig reads a config of an image: its manifest, the manifest of the linux/amd64 image, or the first
+// linux one, of an index, and the config blob.
+func (c *registryClient) fetchImageConfig(ref imageReference, auth *registryAuth) (*ImageConfig, error) {
+	client, err := c.registryHTTPClient(ref.registry)
+	if err != nil {
+		return nil, err
+	}
+	session := &registrySession{client: client, auth: auth}
+	content, err := session.get(ref, "/manifests/"+ref.reference, manifestMediaTypes)
+	if err != nil {
+		return nil, err
+	}
+	var manifest struct {
+		Manifests []struct {
+			Digest   string `json:"digest"`
+			Platform struct {
+				OS           string `json:"os"`
+				Architecture string `json:"architecture"`
+			} `json:"platform"`
+		} `json:"manifests"`
+		Config struct {
+			Digest string `json:"digest"`
+		} `json:"config"`
+	}
+	if err := json.Unmarshal(content, &manifest); err != nil {
+		return nil, fmt.Errorf("invalid manifest of %s: %s", ref, err.Error())
+	}
+	if len(manifest.Manifests) > 0 {
+		digest := ""
+		for _, entry := range manifest.Manifests {
+			if entry.Platform.OS == "linux" && (digest == "" || entry.Platform.Architecture == "amd64") {
+				digest = entry.Digest
+			}
+		}
+		if digest == "" {
+			return nil, fmt.Errorf("%s has no linux image", ref)
+		}
+		if content, err = session.get(ref, "/manifests/"+digest, manifestMediaTypes); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(content, &manifest); err != nil {
+			return nil, fmt.Errorf("invalid manifest of %s: %s", ref, err.Error())
+		}
+	}
+	if manifest.Config.Digest == "" {
+		return nil, fmt.Errorf("manifest of %s has no config", ref)
+	}
+	if content, err = session.get(ref, "/blobs/"+manifest.Config.Digest, nil); err != nil {
+		return nil, err
+	}
+	var blob struct {
+		Config ImageConfig `json:"config"`
+	}
+	if err := json.Unmarshal(content, &blob); err != nil {
+		return nil, fmt.Errorf("invalid config of %s: %s", ref, err.Error())
+	}
+	return &blob.Config, nil
+}
+
+// imageConfig returns a config of an image, it is read once for all containers running the image with the same
+// credential.
+func (c *registryClient) imageConfig(ref imageReference, auth *registryAuth) (*ImageConfig, error) {
+	key := ref.String()
+	if auth != nil {
+		key += "\x00" + auth.source
+	}
+	c.mu.Lock()
+	result, ok := c.configs[key]
+	if !ok {
+		result = &imageConfigResult{}
+		c.configs[key] = result
+	}
+	c.mu.Unlock()
+	// replicas wait for the config being read instead of reading it again
+	result.once.Do(func() {
+		result.config, result.err = c.fetchImageConfig(ref, auth)
+	})
+	return result.config, result.err
+}
+
+// rootUser tells if a user of an image config, e.g. root, 0 or 0:0, is root, images without a user run as root.
+func rootUser(user string) bool {
+	name, _, _ := strings.Cut(user, ":")
+	return name == "" || name == "root" || name == "0"
+}
+
+// imageConfigDetails returns a config of an image as details of a finding.
+func imageConfigDetails(ref imageReference, config *ImageConfig) []string {
+	details := []string{
+		"Image: " + ref.String(),
+		"User: " + valueOrDefault(config.User, "root (not set)"),
+		"Entrypoint: " + valueOrDefault(strings.Join(config.Entrypoint, " "), "none"),
+		"Cmd: " + valueOrDefault(strings.Join(config.Cmd, " "), "none"),
+		"WorkingDir: " + valueOrDefault(config.WorkingDir, "/"),
+	}
+	var ports, labels []string
+	for port := range config.ExposedPorts {
+		ports = append(ports, port)
+	}
+	sort.Strings(ports)
+	details = append(details, "ExposedPorts: "+valueOrDefault(strings.Join(ports, " "), "none"))
+	for name, value := range config.Labels {
+		labels = append(labels, fmt.Sprintf("Label: %s=%s", name, value))
+	}
+	sort.Strings(labels)
+	return append(details, labels...)
+}
+
+// imageConfigFindings returns the config of the image of a container and its discrepancies with the runtime spec
+// of the container in the format of lse.sh: img000 lists the config, img010 tells that the pod runs the container
+// as root although the image has another user, img020 that it runs as root by default, img030 that the pod
+// overrides the entrypoint of the image and img040 that the pod declares ports the image does not expose.
+func imageConfigFindings(k8s *k8sexec.K8SExec, container Container) []string {
+	var (
+		config *ImageConfig
+		auth   *registryAuth
+	)
+	spec := container.Runtime
+	ref, err := parseImageReference(container.Image, spec.ImageID)
+	if err == nil {
+		auth, err = registry.registryCredentials(k8s, container, ref.registry)
+	}
+	if err == nil {
+		config, err = registry.imageConfig(ref, auth)
+	}
+	if err != nil {
+		log(fmt.Sprintf("\n[-] Cannot read the image config of %s: %s\n", container.String(), err.Error()))
+	}
+
+	var asRoot, rootDefault, entrypoint, ports []string
+	if config != nil {
+		if spec.RunAsUser != nil && *spec.RunAsUser == 0 && !rootUser(config.User) {
+			asRoot = append(asRoot, fmt.Sprintf("runAsUser: 0, the image runs as %s", config.User))
+		}
+		if spec.RunAsUser == nil && !spec.RunAsNonRoot && rootUser(config.User) {
+			rootDefault = append(rootDefault, fmt.Sprintf("User: %s, the pod sets neither runAsUser nor runAsNonRoot", valueOrDefault(config.User, "not set")))
+		}
+		if len(spec.Command) > 0 && !slices.Equal(spec.Command, config.Entrypoint) {
+			entrypoint = append(entrypoint, "command: "+strings.Join(spec.Command, " "), "image entrypoint: "+valueOrDefault(strings.Join(config.Entrypoint, " "), "none"))
+		}
+		for _, port := range spec.Ports {
+			if _, ok := config.ExposedPorts[port]; !ok {
+				ports = append(ports, port)
+			}
+		}
+	}
+	var details []string
+	if config != nil {
+		details = imageConfigDetails(ref, config)
+	}
+
+	lines := []string{"", lseSectionLine("image config")}
+	lines = append(lines, lseTestLines("i", "img000", "What is the configuration of the image?", details, config == nil)...)
+	lines = append(lines, lseTestLines("!", "img010", "Does the pod run the container as root unlike the image?", asRoot, config == nil)...)
+	lines = append(lines, lseTestLines("*", "img020", "Does the container run as root by default?", rootDefault, config == nil)...)
+	lines = append(lines, lseTestLines("*", "img030", "Does the pod override the entrypoint of the image?", entrypoint, config == nil)...)
+	return append(lines, lseTestLines("i", "img040", "Does the pod declare ports the image does not expose?", ports, config == nil)...)
+}
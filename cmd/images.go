@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"regexp"
+	"strings"
+)
+
+// imagePatterns are compiled patterns of the images option, no image filtering is done if empty
+var imagePatterns []*regexp.Regexp
+
+// compileImagePatterns compiles comma-separated image patterns, in which '*' matches any sequence of characters,
+// including '/'.
+func compileImagePatterns(images string) error {
+	imagePatterns = nil
+	for _, pattern := range untangleOption(images) {
+		expr := "^" + strings.ReplaceAll(regexp.QuoteMeta(pattern), `\*`, ".*") + "$"
+		compiled, err := regexp.Compile(expr)
+		if err != nil {
+			return err
+		}
+		imagePatterns = append(imagePatterns, compiled)
+	}
+	return nil
+}
+
+// imageRepository returns an image reference without its tag and digest.
+func imageRepository(image string) string {
+	if idx := strings.Index(image, "@"); idx >= 0 {
+		image = image[:idx]
+	}
+	// a colon after the last slash separates a tag, other colons separate a registry port
+	if idx := strings.LastIndex(image, ":"); idx > strings.LastIndex(image, "/") {
+		image = image[:idx]
+	}
+	return image
+}
+
+// imageSelected tells if an image matches any of the image patterns. An image matches a pattern if the pattern
+// matches the image reference, with or without its tag, as written in the pod spec or with the docker.io registry
+// prefix removed.
+func imageSelected(image string) bool {
+	if len(imagePatterns) == 0 {
+		return true
+	}
+	candidates := []string{image, imageRepository(image)}
+	for _, prefix := range []string{"docker.io/library/", "docker.io/"} {
+		if strings.HasPrefix(image, prefix) {
+			short := strings.TrimPrefix(image, prefix)
+			candidates = append(candidates, short, imageRepository(short))
+			break
+		}
+	}
+	for _, pattern := range imagePatterns {
+		for _, candidate := range candidates {
+			if pattern.MatchString(candidate) {
+				return true
+			}
+		}
+	}
+	return false
+}
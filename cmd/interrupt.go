@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/hhruszka/k8sexec"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+// causes, for which a run is cancelled
+var (
+	errInterrupted     = errors.New("run interrupted")
+	errBudgetExhausted = errors.New("error budget exhausted")
+)
+
+// a reason, for which containers of an interrupted run are skipped
+const skipReasonInterrupted = "run interrupted"
+
+// newRunContext returns a context of a run, which is cancelled when kubelse receives SIGINT or SIGTERM. Once
+// cancelled, stages take up no new containers and running execs are abandoned. A second signal is handled as
+// usual, i.e. kills kubelse. The returned function releases the context once the run is finished.
+func newRunContext() (context.Context, context.CancelCauseFunc) {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		defer signal.Stop(signals)
+		select {
+		case <-signals:
+			signal.Stop(signals)
+			log(fmt.Sprintln("\n[-] Interrupted, running execs are abandoned and remaining containers are not scanned"))
+			cancel(errInterrupted)
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
+
+// runInterrupted tells if a run was cancelled by a signal.
+func runInterrupted(ctx context.Context) bool {
+	return errors.Is(context.Cause(ctx), errInterrupted)
+}
+
+// cancelledExec returns a status of an exec, which was not run or was abandoned since the run was cancelled.
+func cancelledExec(ctx context.Context, pod string, container string) *k8sexec.ExecutionStatus {
+	return k8sexec.NewExecutionStatus(pod, container, k8sexec.InternalAppError, context.Cause(ctx).Error(), "", "")
+}
+
+// interruptedContainers collects containers, which were not scanned since the run was interrupted.
+type interruptedContainers struct {
+	mu         sync.Mutex
+	containers []Container
+}
+
+// interruptedRun are containers of the current run, which were not scanned since it was interrupted
+var interruptedRun interruptedContainers
+
+// skipUnscanned records target containers of a cancelled run, which have neither a result nor were skipped
+// already: as aborted, if the error budget was exhausted, or as interrupted.
+func skipUnscanned(ctx context.Context, results []Result) {
+	if ctx.Err() == nil {
+		return
+	}
+	handled := make(map[string]bool)
+	for _, result := range results {
+		handled[result.container.container.String()] = true
+	}
+	for _, container := range terminatedPods.containers {
+		handled[container.String()] = true
+	}
+	if errorBudget != nil {
+		for _, container := range errorBudget.aborted {
+			handled[container.String()] = true
+		}
+	}
+	if outputQuota != nil {
+		for _, container := range outputQuota.skipped {
+			handled[container.String()] = true
+		}
+	}
+
+	interruptedRun.mu.Lock()
+	defer interruptedRun.mu.Unlock()
+	for _, target := range targetContainers {
+		switch {
+		case handled[target.container.String()]:
+		case !runInterrupted(ctx) && errorBudget != nil:
+			errorBudget.abort(target.container)
+		default:
+			interruptedRun.containers = append(interruptedRun.containers, target.container)
+		}
+	}
+}
+
+// finish adds containers, which were not scanned, to skipped containers of the run and returns an error if the
+// run was interrupted.
+func (i *interruptedContainers) finish(ctx context.Context) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	for _, container := range i.containers {
+		skippedContainers = append(skippedContainers, SkippedContainer{container, skipReasonInterrupted})
+	}
+	count := len(i.containers)
+	i.containers = nil
+	if !runInterrupted(ctx) {
+		return nil
+	}
+	return fmt.Errorf("[-] Run interrupted, %d containers were not scanned\n", count)
+}
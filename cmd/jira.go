@@ -0,0 +1,276 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// issues CLI options variables
+var (
+	createIssues string
+	jiraConfig   string
+)
+
+// JiraConfig configures creating Jira issues for findings, it is read from a json file provided with
+// '--jira-config'. The API token is read from the JIRA_API_TOKEN environment variable, so that it is not
+// kept in the file.
+type JiraConfig struct {
+	URL         string   `json:"URL"`
+	User        string   `json:"User"`
+	IssueType   string   `json:"IssueType"`
+	Labels      []string `json:"Labels"`
+	MinSeverity string   `json:"MinSeverity"`
+}
+
+// jiraIssueGroup is a finding found in one or more containers of a workload, for which a single issue is opened.
+type jiraIssueGroup struct {
+	workload   string
+	owner      string
+	finding    Finding
+	containers []string
+	excerpts   []string
+}
+
+// fingerprint identifies an issue group across runs, it is added to issues as a label to avoid duplicates.
+func (g jiraIssueGroup) fingerprint() string {
+	sum := sha256.Sum256([]byte(scannedNamespace() + "\x00" + g.workload + "\x00" + g.finding.ID))
+	return fmt.Sprintf("kubelse-%x", sum[:8])
+}
+
+// parseIssuesTarget returns a Jira project key from a '--create-issues' value, i.e. jira://PROJECT.
+func parseIssuesTarget(target string) (string, error) {
+	project, ok := strings.CutPrefix(target, "jira://")
+	if !ok || project == "" || strings.Contains(project, "/") {
+		return "", errors.New("expected jira://PROJECT")
+	}
+	return project, nil
+}
+
+// loadJiraConfig reads the Jira configuration file and fills in defaults.
+func loadJiraConfig(path string) (JiraConfig, error) {
+	config := JiraConfig{IssueType: "Bug", MinSeverity: SeverityCritical}
+	if path != "" {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return config, err
+		}
+		if err := json.Unmarshal(content, &config); err != nil {
+			return config, fmt.Errorf("invalid Jira configuration %s: %s", path, err.Error())
+		}
+	}
+	if config.URL == "" {
+		config.URL = os.Getenv("JIRA_URL")
+	}
+	if config.URL == "" {
+		return config, errors.New("a Jira URL has to be provided in the Jira configuration file or with JIRA_URL")
+	}
+	if config.MinSeverity != SeverityCritical && config.MinSeverity != SeverityInteresting && config.MinSeverity != SeverityInfo {
+		return config, fmt.Errorf("invalid MinSeverity %q, valid values are critical, interesting or info", config.MinSeverity)
+	}
+	config.URL = strings.TrimSuffix(config.URL, "/")
+	return config, nil
+}
+
+// severityAtLeast tells if a severity is at least as high as the minimal one.
+func severityAtLeast(severity string, minimal string) bool {
+	rank := map[string]int{SeverityInfo: 0, SeverityInteresting: 1, SeverityCritical: 2}
+	return rank[severity] >= rank[minimal]
+}
+
+// jiraIssueGroups groups positive findings of results, which are severe enough, by workload and test. Findings
+// triaged as accepted risks are left out, so that issues resolved by analysts are not opened again.
+func jiraIssueGroups(results []Result, minSeverity string) []*jiraIssueGroup {
+	var (
+		groups []*jiraIssueGroup
+		index  = make(map[string]*jiraIssueGroup)
+	)
+	for _, result := range results {
+		workload := valueOrDefault(result.container.container.Workload, "unknown")
+		for _, finding := range result.findings().Positive() {
+			if !severityAtLeast(finding.Severity, minSeverity) || finding.AcceptedRisk() {
+				continue
+			}
+			key := workload + "\x00" + finding.ID
+			group, ok := index[key]
+			if !ok {
+				group = &jiraIssueGroup{workload: workload, owner: result.container.container.Owner, finding: finding}
+				index[key] = group
+				groups = append(groups, group)
+			}
+			container := result.container.container.String()
+			group.containers = append(group.containers, container)
+			excerpt := fmt.Sprintf("===== %s (%s) =====\n%s %s: %s", container, valueOrDefault(baseName(result.reportFile), "report not saved"), finding.ID, finding.Name, finding.Result)
+			if len(finding.Details) > 0 {
+				excerpt += "\n" + strings.Join(finding.Details, "\n")
+			}
+			group.excerpts = append(group.excerpts, excerpt)
+		}
+	}
+	sort.SliceStable(groups, func(i, j int) bool {
+		return groups[i].workload < groups[j].workload
+	})
+	return groups
+}
+
+// jiraClient is a minimal client of the Jira REST API.
+type jiraClient struct {
+	config JiraConfig
+	token  string
+	http   *http.Client
+}
+
+// do sends a request to the Jira REST API and decodes a json response, if out is not nil.
+func (c jiraClient) do(method string, path string, contentType string, body io.Reader, out interface{}) error {
+	req, err := http.NewRequest(method, c.config.URL+path, body)
+	if err != nil {
+		return err
+	}
+	if c.config.User != "" {
+		req.SetBasicAuth(c.config.User, c.token)
+	} else if c.token != "" {
+		// personal access tokens of Jira Data Center
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	req.Header.Set("Accept", "application/json")
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	// attachments are rejected without it
+	req.Header.Set("X-Atlassian-Token", "no-check")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%s %s returned %s: %s", method, path, resp.Status, strings.TrimSpace(string(message)))
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// openIssue returns a key of an unresolved issue with a label, or an empty string if there is none.
+func (c jiraClient) openIssue(project string, label string) (string, error) {
+	jql := fmt.Sprintf(`project = "%s" AND labels = "%s" AND resolution = Unresolved`, project, label)
+	var found struct {
+		Issues []struct {
+			Key string `json:"key"`
+		} `json:"issues"`
+	}
+	if err := c.do(http.MethodGet, "/rest/api/2/search?maxResults=1&fields=key&jql="+url.QueryEscape(jql), "", nil, &found); err != nil {
+		return "", err
+	}
+	if len(found.Issues) == 0 {
+		return "", nil
+	}
+	return found.Issues[0].Key, nil
+}
+
+// createIssue opens an issue for an issue group and attaches report excerpts of the affected containers.
+func (c jiraClient) createIssue(project string, group *jiraIssueGroup) (string, error) {
+	finding := group.finding
+	description := strings.Join([]string{
+		fmt.Sprintf("kubelse found *%s %s* (%s, %s) in workload %s of namespace %s.", finding.ID, finding.Name, finding.Section, finding.Severity, group.workload, scannedNamespace()),
+		"",
+		fmt.Sprintf("Run ID: %s", runID),
+		fmt.Sprintf("Cluster: %s", valueOrDefault(cluster.Name, "unknown")),
+		fmt.Sprintf("Owner: %s", valueOrDefault(group.owner, "unowned")),
+		fmt.Sprintf("Affected containers (%d): %s", len(group.containers), strings.Join(group.containers, ", ")),
+		"",
+		"Report excerpts are attached.",
+	}, "\n")
+	if finding.Remediation != "" {
+		description += "\n\n" + fmt.Sprintf(tr("Remediation: %s"), finding.Remediation)
+	}
+	if finding.Triage != nil {
+		description += fmt.Sprintf("\n\nTriage: %s by %s", finding.Triage, valueOrDefault(finding.Triage.Analyst, "unknown"))
+	}
+	labels := append([]string{"kubelse", group.fingerprint()}, c.config.Labels...)
+	if group.owner != "" {
+		// Jira labels cannot contain spaces
+		labels = append(labels, "owner-"+safeFileName(group.owner))
+	}
+	issue := map[string]interface{}{
+		"fields": map[string]interface{}{
+			"project":     map[string]string{"key": project},
+			"issuetype":   map[string]string{"name": c.config.IssueType},
+			"summary":     fmt.Sprintf("[kubelse] %s/%s: %s %s", scannedNamespace(), group.workload, finding.ID, finding.Name),
+			"description": description,
+			"labels":      labels,
+		},
+	}
+	content, err := json.Marshal(issue)
+	if err != nil {
+		return "", err
+	}
+	var created struct {
+		Key string `json:"key"`
+	}
+	if err := c.do(http.MethodPost, "/rest/api/2/issue", "application/json", bytes.NewReader(content), &created); err != nil {
+		return "", err
+	}
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile("file", fmt.Sprintf("kubelse-%s-%s.txt", finding.ID, shortRunID()))
+	if err != nil {
+		return created.Key, err
+	}
+	io.WriteString(part, strings.Join(group.excerpts, "\n\n")+"\n")
+	if err := writer.Close(); err != nil {
+		return created.Key, err
+	}
+	return created.Key, c.do(http.MethodPost, "/rest/api/2/issue/"+created.Key+"/attachments", writer.FormDataContentType(), &body, nil)
+}
+
+// createJiraIssues opens one Jira issue per severe finding of every workload, unless an unresolved issue for
+// it was opened by an earlier run.
+func createJiraIssues(results []Result) {
+	project, err := parseIssuesTarget(createIssues)
+	if err != nil {
+		log(fmt.Sprintf("[-] Invalid value of the issues option '--create-issues': %s\n", err.Error()))
+		return
+	}
+	config, err := loadJiraConfig(jiraConfig)
+	if err != nil {
+		log(fmt.Sprintf("[-] Error creating Jira issues: %s\n", err.Error()))
+		return
+	}
+	client := jiraClient{config: config, token: os.Getenv("JIRA_API_TOKEN"), http: &http.Client{Timeout: 30 * time.Second}}
+
+	created, existing := 0, 0
+	for _, group := range jiraIssueGroups(results, config.MinSeverity) {
+		key, err := client.openIssue(project, group.fingerprint())
+		if err != nil {
+			log(fmt.Sprintf("[-] Error searching Jira issues: %s\n", err.Error()))
+			return
+		}
+		if key != "" {
+			existing++
+			continue
+		}
+		key, err = client.createIssue(project, group)
+		if err != nil {
+			log(fmt.Sprintf("[-] Error creating Jira issue for %s %s: %s\n", group.workload, group.finding.ID, err.Error()))
+			continue
+		}
+		created++
+		log(fmt.Sprintf("[+] Created Jira issue %s for %s %s\n", key, group.workload, group.finding.ID))
+	}
+	log(fmt.Sprintf("[+] Created %d Jira issues in project %s, %d findings already have unresolved issues\n", created, project, existing))
+}
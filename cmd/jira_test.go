@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCreateJiraIssuesSkipsUnresolved(t *testing.T) {
+	var (
+		created     []string
+		attachments int
+		existing    = map[string]bool{}
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch {
+		case req.URL.Path == "/rest/api/2/search":
+			var issues []map[string]string
+			for label := range existing {
+				if strings.Contains(req.URL.Query().Get("jql"), label) {
+					issues = append(issues, map[string]string{"key": "SEC-1"})
+				}
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"issues": issues})
+		case req.URL.Path == "/rest/api/2/issue":
+			var issue struct {
+				Fields struct {
+					Summary string   `json:"summary"`
+					Labels  []string `json:"labels"`
+				} `json:"fields"`
+			}
+			json.NewDecoder(req.Body).Decode(&issue)
+			created = append(created, issue.Fields.Summary)
+			existing[issue.Fields.Labels[1]] = true
+			json.NewEncoder(w).Encode(map[string]string{"key": "SEC-1"})
+		case strings.HasSuffix(req.URL.Path, "/attachments"):
+			attachments++
+			w.Write([]byte("[]"))
+		default:
+			http.NotFound(w, req)
+		}
+	}))
+	defer server.Close()
+
+	t.Setenv("JIRA_URL", server.URL)
+	createIssues, namespace = "jira://SEC", "default"
+	t.Cleanup(func() { createIssues = "" })
+	results := []Result{
+		{container: ContainerInfo{container: Container{Pod: "web-1", Container: "app", Workload: "Deployment/web"}}, scanReport: lseOutput},
+		{container: ContainerInfo{container: Container{Pod: "web-2", Container: "app", Workload: "Deployment/web"}}, scanReport: lseOutput},
+		// accepted risks do not get issues
+		{container: ContainerInfo{container: Container{Pod: "api-1", Container: "app", Workload: "Deployment/api"}}, scanReport: lseOutput,
+			triage: map[string]*TriageDecision{"fst010": {State: TriageAcceptedRisk, Comment: "read-only root filesystem"}}},
+	}
+
+	// the api group is left out only because of its triage state
+	if groups := jiraIssueGroups(results[2:], SeverityCritical); len(groups) != 0 {
+		t.Errorf("expected no issue groups of an accepted risk, got %d", len(groups))
+	}
+	untriaged := results[2]
+	untriaged.triage = nil
+	if groups := jiraIssueGroups([]Result{untriaged}, SeverityCritical); len(groups) != 1 || groups[0].workload != "Deployment/api" {
+		t.Errorf("expected an issue group of the untriaged api workload, got %d groups", len(groups))
+	}
+
+	createJiraIssues(results)
+	createJiraIssues(results)
+	if len(created) != 1 || attachments != 1 {
+		t.Fatalf("expected a single issue with an attachment, got %v and %d attachments", created, attachments)
+	}
+	if created[0] != "[kubelse] default/Deployment/web: fst010 Can we write to /etc/passwd?" {
+		t.Errorf("unexpected summary %q", created[0])
+	}
+}
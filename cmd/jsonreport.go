@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"encoding/json"
+)
+
+// JSONReport is a scan report saved in the json output format.
+type JSONReport struct {
+	RunID     string            `json:"RunID"`
+	Cluster   ClusterInfo       `json:"Cluster"`
+	Pod       string            `json:"Pod"`
+	Container string            `json:"Container"`
+	Workload  string            `json:"Workload,omitempty"`
+	Owner     string            `json:"Owner,omitempty"`
+	Status    string            `json:"Status"`
+	RetCode   int               `json:"RetCode"`
+	Truncated bool              `json:"Truncated,omitempty"`
+	Header    map[string]string `json:"Header"`
+	PSS       PSSResult         `json:"PSS"`
+	Risk      RiskScore         `json:"Risk"`
+	// NetworkPolicies is set only if NetworkPolicy coverage was checked
+	NetworkPolicies *NetworkPolicyCoverage `json:"NetworkPolicies,omitempty"`
+	Suggestions     []Suggestion           `json:"Suggestions,omitempty"`
+	Findings        []Finding              `json:"Findings"`
+	Output          []string               `json:"Output"`
+	// Stderr is set only if stderr of lse.sh is saved
+	Stderr []string `json:"Stderr,omitempty"`
+}
+
+// jsonReport renders a report of a scan in the json output format. The kubelse header is kept as key-value
+// pairs, findings are parsed out of lse.sh output and the output itself is kept for reference.
+func jsonReport(result Result) ([]byte, error) {
+	header := parseReport(reportHeader(result)).Header
+	scanReport := result.findings()
+
+	report := JSONReport{
+		RunID:           runID,
+		Cluster:         cluster,
+		Pod:             result.container.container.Pod,
+		Container:       result.container.container.Container,
+		Workload:        result.container.container.Workload,
+		Owner:           result.container.container.Owner,
+		Status:          result.status(),
+		RetCode:         int(result.retCode),
+		Truncated:       result.truncated,
+		Header:          header,
+		PSS:             result.container.container.PSS,
+		Risk:            result.risk,
+		NetworkPolicies: result.container.container.NetworkPolicies,
+		Suggestions:     result.container.container.Suggestions,
+		Findings:        scanReport.Findings,
+		Output:          scanReport.Lines,
+	}
+	if saveStderr {
+		report.Stderr = stderrOutput(result)
+	}
+	return json.MarshalIndent(report, "", "  ")
+}
+
+// loadJSONReport reads a scan report saved in the json output format. Other json files, e.g. run manifests,
+// are returned as reports without a header.
+func loadJSONReport(path string, content []byte) (Report, error) {
+	var saved JSONReport
+	if err := json.Unmarshal(content, &saved); err != nil {
+		return Report{Path: path, Header: make(map[string]string)}, nil
+	}
+
+	report := Report{Path: path, Header: saved.Header, Findings: saved.Findings, Lines: saved.Output}
+	if report.Header == nil {
+		report.Header = make(map[string]string)
+	}
+	if saved.Pod != "" {
+		report.Header["Pod"] = saved.Pod
+		report.Header["Container"] = saved.Container
+	}
+	return report, nil
+}
@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"fmt"
+	"k8slse/data"
+	"sigs.k8s.io/yaml"
+	"strings"
+)
+
+// localization CLI options variables
+var lang string
+
+// defaultLanguage is the language kubelse generates text in, it needs no translations
+const defaultLanguage = "en"
+
+// messages are translations of text generated by kubelse into '--lang', keyed by the English text, empty for
+// English
+var messages map[string]string
+
+// reportHeaderLabels are labels of lines of report headers, which are translated
+var reportHeaderLabels = []string{"Run ID", "Timestamp", "Cluster", "API server", "Kubernetes", "Provider", "Namespace",
+	"Pod", "Container", "Workload", "Image", "Owner", "Distribution", "Package manager", "Package checks", "Filesystem",
+	"Shell", "Payload", "Level", "Sections", "Scan status", "PSS level", "Risk score", "Suppressed", "Triaged",
+	"Elevated", "Network policies", "Note"}
+
+// headerLabels are English labels of report headers by their translations into any language, so that reports
+// written in any language are read back
+var headerLabels = make(map[string]string)
+
+func init() {
+	for _, language := range data.Languages() {
+		translations := mustParseMessages(language)
+		for _, label := range reportHeaderLabels {
+			if translated, ok := translations[label]; ok {
+				headerLabels[translated] = label
+			}
+		}
+	}
+}
+
+// mustParseMessages parses embedded translations into a language, which are validated by tests.
+func mustParseMessages(language string) map[string]string {
+	translations := make(map[string]string)
+	if err := yaml.Unmarshal(data.GetMessages(language), &translations); err != nil {
+		panic(err)
+	}
+	return translations
+}
+
+// loadLanguage selects the language of generated text: English, or a language kubelse has translations into.
+func loadLanguage(language string) error {
+	messages = nil
+	if language == "" || language == defaultLanguage {
+		return nil
+	}
+	if !contains(data.Languages(), language) {
+		return fmt.Errorf("unsupported language %q, supported languages are %s", language, strings.Join(append([]string{defaultLanguage}, data.Languages()...), ", "))
+	}
+	messages = mustParseMessages(language)
+	return nil
+}
+
+// tr returns text generated by kubelse, or a format string, translated into '--lang', or the English text if
+// there is no translation.
+func tr(text string) string {
+	if translated, ok := messages[text]; ok {
+		return translated
+	}
+	return text
+}
+
+// headerLine returns a line of a report header with a translated label, aligned as labels of lse.sh.
+func headerLine(label string, value string) string {
+	return fmt.Sprintf("%16s: %s", tr(label), value)
+}
+
+// headerLabel returns the English label of a line of a report header, which may have been translated.
+func headerLabel(label string) string {
+	if english, ok := headerLabels[label]; ok {
+		return english
+	}
+	return label
+}
@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"k8slse/data"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestTranslationsKeepFormatVerbsAndTestIDs(t *testing.T) {
+	verbs := regexp.MustCompile(`%[a-z]`)
+	catalog := mustParseRemediations(data.GetRemediation())
+	for _, language := range data.Languages() {
+		for text, translated := range mustParseMessages(language) {
+			if !slices.Equal(verbs.FindAllString(text, -1), verbs.FindAllString(translated, -1)) {
+				t.Errorf("%s: expected the format verbs of %q in %q", language, text, translated)
+			}
+		}
+		localized, err := parseRemediations(data.GetLocalizedRemediation(language))
+		if err != nil {
+			t.Fatalf("%s: invalid localized catalog: %s", language, err.Error())
+		}
+		for id := range localized {
+			if _, ok := catalog[id]; !ok {
+				t.Errorf("%s: guidance of %s missing in the English catalog", language, id)
+			}
+		}
+	}
+	if err := loadLanguage("xx"); err == nil {
+		t.Errorf("expected an error for an unsupported language")
+	}
+}
+
+func TestGermanReportsAreReadBack(t *testing.T) {
+	lang = "de"
+	if err := loadLanguage(lang); err != nil {
+		t.Fatal(err)
+	}
+	if err := loadRemediations(""); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		lang = defaultLanguage
+		loadLanguage(lang)
+		loadRemediations("")
+	})
+
+	result := Result{container: ContainerInfo{container: Container{Pod: "web-1", Container: "app", Workload: "deployment/web"}, readOnlyRoot: true}, scanReport: lseOutput}
+	lines := reportLines(result)
+	if !slices.Contains(lines, headerLine("Owner", "unowned")) || !strings.HasSuffix(headerLine("Owner", "unowned"), "Verantwortlich: unowned") {
+		t.Errorf("expected a German owner label, got %q", strings.Join(lines, "\n"))
+	}
+	report := parseReport(lines)
+	if report.Pod() != "web-1" || report.Container() != "app" || report.Header["Workload"] != "deployment/web" || report.Header["Note"] == "" {
+		t.Errorf("expected the German header to be read back, got %+v", report.Header)
+	}
+	english := mustParseRemediations(data.GetRemediation())
+	for _, finding := range report.Positive() {
+		if english[finding.ID] != "" && (finding.Remediation == "" || finding.Remediation == english[finding.ID]) {
+			t.Errorf("expected German guidance of %s, got %q", finding.ID, finding.Remediation)
+		}
+	}
+	if page := string(renderHTMLReport(lines)); !strings.Contains(page, `<html lang="de">`) || !strings.Contains(page, "Behebung: ") {
+		t.Errorf("expected a German html report")
+	}
+}
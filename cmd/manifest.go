@@ -0,0 +1,158 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ManifestEntry describes what happened to a single container during a run.
+type ManifestEntry struct {
+	Namespace       string `json:"Namespace,omitempty"`
+	Pod             string `json:"Pod"`
+	Container       string `json:"Container"`
+	Workload        string `json:"Workload,omitempty"`
+	Owner           string `json:"Owner,omitempty"`
+	Report          string `json:"Report,omitempty"`
+	Stderr          string `json:"Stderr,omitempty"`
+	RetCode         int    `json:"RetCode"`
+	Status          string `json:"Status,omitempty"`
+	ExitDescription string `json:"ExitDescription,omitempty"`
+	Attempts        int    `json:"Attempts,omitempty"`
+	Duration        string `json:"Duration,omitempty"`
+	Truncated       bool   `json:"Truncated,omitempty"`
+	Stalled         bool   `json:"Stalled,omitempty"`
+	// Overloaded is usage of the container over a threshold, for which the scan was aborted
+	Overloaded string `json:"Overloaded,omitempty"`
+	Reason     string `json:"Reason,omitempty"`
+	// GapID is a tracking ID of a container, which could not be tested, set only if gaps are tracked
+	GapID      string         `json:"GapID,omitempty"`
+	Findings   map[string]int `json:"Findings,omitempty"`
+	Suppressed []string       `json:"Suppressed,omitempty"`
+	PSSLevel   string         `json:"PSSLevel,omitempty"`
+	Risk       *RiskScore     `json:"Risk,omitempty"`
+	// NetworkPolicies is set only if NetworkPolicy coverage was checked
+	NetworkPolicies *NetworkPolicyCoverage `json:"NetworkPolicies,omitempty"`
+	// API is set only if access to the Kubernetes API was probed
+	API *APIAccess `json:"API,omitempty"`
+}
+
+// Manifest describes a single run of kubelse. It is saved next to the reports, so that the run can be audited
+// and its reports attributed later.
+type Manifest struct {
+	RunID     string `json:"RunID"`
+	Version   string `json:"Version"`
+	Namespace string `json:"Namespace"`
+	// Tenant is set only if namespaces are partitioned by '--tenant-annotation'
+	Tenant      string          `json:"Tenant,omitempty"`
+	Cluster     ClusterInfo     `json:"Cluster"`
+	Format      string          `json:"Format"`
+	Started     time.Time       `json:"Started"`
+	Finished    time.Time       `json:"Finished"`
+	Scanned     []ManifestEntry `json:"Scanned"`
+	NotTestable []ManifestEntry `json:"NotTestable"`
+	Skipped     []ManifestEntry `json:"Skipped"`
+	// Options are options of the run provided on the command line or in the environment, so that it can be repeated
+	Options map[string]string `json:"Options,omitempty"`
+	// Shard is set only if pods were split between instances with '--shard', e.g. 2/5, shards of a run share its ID
+	Shard string `json:"Shard,omitempty"`
+	// Policy is set only if a policy was evaluated
+	Policy *PolicyVerdict `json:"Policy,omitempty"`
+}
+
+// newManifest creates a manifest of a run from results of scanned containers and containers that were not
+// tested or skipped.
+func newManifest(started time.Time, results []Result) Manifest {
+	manifest := Manifest{
+		RunID:     runID,
+		Version:   AppVersion,
+		Namespace: scannedNamespace(),
+		Tenant:    tenant,
+		Cluster:   cluster,
+		Format:    format,
+		Options:   runOptions,
+		Shard:     shard,
+		Started:   started,
+		Finished:  now(),
+	}
+
+	for _, result := range results {
+		var reason string
+		if !result.reportable() {
+			reason = fmt.Sprintf("risk score %d below '--min-score' %d, the report was not saved", result.risk.Score, minScore)
+		}
+		risk := result.risk
+		manifest.Scanned = append(manifest.Scanned, ManifestEntry{
+			Namespace:       scannedNamespace(),
+			Pod:             result.container.container.Pod,
+			Container:       result.container.container.Container,
+			Workload:        result.container.container.Workload,
+			Owner:           result.container.container.Owner,
+			Report:          filepath.Base(result.reportFile),
+			Stderr:          baseName(result.stderrFile),
+			RetCode:         int(result.retCode),
+			Status:          result.status(),
+			ExitDescription: result.exitDescription(),
+			Attempts:        result.attempts,
+			Duration:        result.duration.Round(time.Millisecond).String(),
+			Truncated:       result.truncated,
+			Stalled:         result.stalled,
+			Overloaded:      result.overloaded,
+			Reason:          reason,
+			Findings:        result.findings().CountBySeverity(),
+			Suppressed:      result.suppressedFindings(),
+			PSSLevel:        result.container.container.PSS.Level,
+			Risk:            &risk,
+			NetworkPolicies: result.container.container.NetworkPolicies,
+			API:             result.api,
+		})
+	}
+	for _, container := range nontestableContainers {
+		manifest.NotTestable = append(manifest.NotTestable, ManifestEntry{
+			Namespace: scannedNamespace(),
+			Pod:       container.container.Pod,
+			Container: container.container.Container,
+			Workload:  container.container.Workload,
+			Reason:    "missing shell or utilities required by lse.sh",
+		})
+	}
+	for _, skipped := range skippedContainers {
+		manifest.Skipped = append(manifest.Skipped, ManifestEntry{
+			Namespace: scannedNamespace(),
+			Pod:       skipped.Container.Pod,
+			Container: skipped.Container.Container,
+			Workload:  skipped.Container.Workload,
+			Reason:    skipped.Reason,
+		})
+	}
+	sortManifest(&manifest)
+	return manifest
+}
+
+// FindingsCount returns the number of findings of every severity in all scanned containers.
+func (m Manifest) FindingsCount() map[string]int {
+	counts := make(map[string]int)
+	for _, entry := range m.Scanned {
+		for severity, count := range entry.Findings {
+			counts[severity] += count
+		}
+	}
+	return counts
+}
+
+// saveManifest saves a manifest of a run as a JSON file in the reports directory.
+func saveManifest(manifest Manifest) error {
+	content, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	fileName := filepath.Join(directory, fmt.Sprintf("kubelse-manifest-%s-%s.json", fileTimestamp(manifest.Started), shortRunID()))
+	if err := os.WriteFile(fileName, anonymized(content), 0666); err != nil {
+		return err
+	}
+	log(fmt.Sprintf("[+] Run manifest saved to %s\n", fileName))
+	return nil
+}
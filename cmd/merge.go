@@ -0,0 +1,166 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// mergedFinding is a finding, which was found with identical details in one or more containers of a workload.
+type mergedFinding struct {
+	finding    Finding
+	containers []string
+}
+
+// mergeFindings groups positive findings of results by workload and collapses identical findings, i.e. the same
+// test with the same details, into a single finding listing all affected containers.
+func mergeFindings(results []Result) map[string][]*mergedFinding {
+	workloads := make(map[string][]*mergedFinding)
+	index := make(map[string]*mergedFinding)
+
+	for _, result := range results {
+		workload := valueOrDefault(result.container.container.Workload, "unknown")
+		for _, finding := range result.findings().Positive() {
+			key := workload + "\x00" + finding.ID + "\x00" + strings.Join(finding.Details, "\n")
+			merged, ok := index[key]
+			if !ok {
+				merged = &mergedFinding{finding: finding}
+				index[key] = merged
+				workloads[workload] = append(workloads[workload], merged)
+			}
+			merged.containers = append(merged.containers, result.container.container.String())
+		}
+	}
+	return workloads
+}
+
+// workloadOwners returns owners of workloads of scanned containers.
+func workloadOwners(results []Result) map[string]string {
+	owners := make(map[string]string)
+	for _, result := range results {
+		owners[valueOrDefault(result.container.container.Workload, "unknown")] = result.container.container.Owner
+	}
+	return owners
+}
+
+// mergedReport returns lines of a report, which merges findings of all scanned containers. Workloads are grouped
+// by their owners, if owners are known.
+func mergedReport(results []Result) []string {
+	workloads := mergeFindings(results)
+	owners := workloadOwners(results)
+
+	var (
+		names []string
+		owned bool
+	)
+	for name := range workloads {
+		names = append(names, name)
+		owned = owned || owners[name] != ""
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if owners[names[i]] != owners[names[j]] {
+			return owners[names[i]] < owners[names[j]]
+		}
+		return names[i] < names[j]
+	})
+
+	markers := map[string]string{SeverityCritical: "!", SeverityInteresting: "*", SeverityInfo: "i"}
+
+	lines := []string{
+		fmt.Sprintf("================================( %s )================================", tr("kubelse merged report")),
+		headerLine("Run ID", runID),
+		headerLine("Cluster", fmt.Sprintf("%s (%s)", valueOrDefault(cluster.Name, "unknown"), valueOrDefault(cluster.Server, "unknown"))),
+		headerLine("Namespace", scannedNamespace()),
+		headerLine("Containers", fmt.Sprint(len(results))),
+		headerLine("Workloads", fmt.Sprint(len(names))),
+		"",
+	}
+	for idx, name := range names {
+		if owned && (idx == 0 || owners[name] != owners[names[idx-1]]) {
+			lines = append(lines, fmt.Sprintf("=================( %s )=================", fmt.Sprintf(tr("Owner: %s"), valueOrDefault(owners[name], tr("unowned")))), "")
+		}
+		containers := make(map[string]bool)
+		for _, merged := range workloads[name] {
+			for _, container := range merged.containers {
+				containers[container] = true
+			}
+		}
+		lines = append(lines, fmt.Sprintf("=====( %s )=====", fmt.Sprintf(tr("%s: %d findings in %d containers"), name, len(workloads[name]), len(containers))))
+		for _, merged := range workloads[name] {
+			finding := merged.finding
+			lines = append(lines, fmt.Sprintf("[%s] %s %s (%s)", markers[finding.Severity], finding.ID, finding.Name, finding.Section))
+			lines = append(lines, "    "+fmt.Sprintf(tr("Affected containers (%d): %s"), len(merged.containers), strings.Join(merged.containers, ", ")))
+			if len(finding.Details) > 0 {
+				lines = append(lines, "---")
+				lines = append(lines, finding.Details...)
+				lines = append(lines, "---")
+			}
+		}
+		lines = append(lines, "")
+	}
+	return lines
+}
+
+// jsonMergedReport renders merged findings of all scanned containers in the json output format.
+func jsonMergedReport(results []Result) []byte {
+	type jsonMergedFinding struct {
+		Finding
+		Containers []string `json:"Containers"`
+	}
+
+	workloads := make(map[string][]jsonMergedFinding)
+	owned := make(map[string][]string)
+	owners := workloadOwners(results)
+	for name, findings := range mergeFindings(results) {
+		for _, merged := range findings {
+			workloads[name] = append(workloads[name], jsonMergedFinding{Finding: merged.finding, Containers: merged.containers})
+		}
+		owner := valueOrDefault(owners[name], "unowned")
+		owned[owner] = append(owned[owner], name)
+	}
+	for _, names := range owned {
+		sort.Strings(names)
+	}
+	report, _ := json.MarshalIndent(map[string]interface{}{
+		"RunID":      runID,
+		"Cluster":    cluster,
+		"Namespace":  scannedNamespace(),
+		"Containers": len(results),
+		"Workloads":  workloads,
+		"Owners":     owned,
+	}, "", "  ")
+	return report
+}
+
+// saveMergedReport saves a report merging findings of all scanned containers in the reports directory. If owners
+// of containers are known, a merged report of every owner's containers is saved as well, so that every team can
+// receive only its findings.
+func saveMergedReport(started time.Time, results []Result) {
+	fileName := fmt.Sprintf("kubelse-merged-%s-%s", fileTimestamp(started), shortRunID())
+	writeMergedReport(fileName, results)
+
+	names, grouped := resultsByOwner(results)
+	if len(names) == 1 && names[0] == "" {
+		return
+	}
+	for _, owner := range names {
+		writeMergedReport(fmt.Sprintf("%s-%s", fileName, safeFileName(valueOrDefault(owner, "unowned"))), grouped[owner])
+	}
+}
+
+// writeMergedReport renders a merged report of results in the output format and saves it.
+func writeMergedReport(fileName string, results []Result) {
+	fileName = reportFileName(directory, "."+format, fileName)
+	report := renderReport(mergedReport(results))
+	if format == "json" {
+		report = jsonMergedReport(results)
+	}
+	fileName, err := writeReportWithFallback(fileName, report)
+	if err != nil {
+		log(fmt.Sprintf("[-] Error saving merged report: %s\n", err.Error()))
+		return
+	}
+	log(fmt.Sprintf("[+] Merged report saved to %s\n", fileName))
+}
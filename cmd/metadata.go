@@ -0,0 +1,133 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"github.com/hhruszka/k8sexec"
+	"strings"
+)
+
+// metadata probe CLI options variables
+var metadataProbe bool
+
+// metadataProbeScript probes instance metadata services of AWS, GCP and Azure from a container with curl or wget.
+// For every reachable service it prints '<cloud> reachable', or '<cloud> credentials <identity>' if credentials of
+// an identity can be obtained; credentials themselves are never printed. It prints 'notool' if neither curl nor
+// wget is available.
+const metadataProbeScript = `fetch() {
+  if command -v curl >/dev/null 2>&1; then
+    if [ -n "$2" ]; then curl -sf -m 3 -H "$2" "$1"; else curl -sf -m 3 "$1"; fi
+  else
+    if [ -n "$2" ]; then wget -q -T 3 -O - --header="$2" "$1"; else wget -q -T 3 -O - "$1"; fi
+  fi 2>/dev/null
+}
+command -v curl >/dev/null 2>&1 || command -v wget >/dev/null 2>&1 || { echo notool; exit 0; }
+imds=http://169.254.169.254
+header=""
+if command -v curl >/dev/null 2>&1; then
+  token=` + "`" + `curl -sf -m 3 -X PUT -H "X-aws-ec2-metadata-token-ttl-seconds: 60" $imds/latest/api/token 2>/dev/null` + "`" + `
+  [ -n "$token" ] && header="X-aws-ec2-metadata-token: $token"
+fi
+if role=` + "`" + `fetch $imds/latest/meta-data/iam/security-credentials/ "$header"` + "`" + `; then
+  if [ -n "$role" ]; then echo "aws credentials $role" | head -n 1; else echo "aws reachable"; fi
+elif [ -n "$header" ]; then
+  echo "aws reachable"
+fi
+if account=` + "`" + `fetch $imds/computeMetadata/v1/instance/service-accounts/default/email "Metadata-Flavor: Google"` + "`" + `; then
+  if [ -n "$account" ]; then echo "gcp credentials $account"; else echo "gcp reachable"; fi
+fi
+if fetch "$imds/metadata/instance/compute/name?api-version=2021-02-01&format=text" "Metadata: true" >/dev/null; then
+  if fetch "$imds/metadata/identity/oauth2/token?api-version=2018-02-01&resource=https://management.azure.com/" "Metadata: true" | grep -q access_token; then
+    echo "azure credentials managed identity"
+  else
+    echo "azure reachable"
+  fi
+fi
+exit 0`
+
+// MetadataAccess is access of a container to an instance metadata service of a cloud.
+type MetadataAccess struct {
+	Cloud       string
+	Credentials bool
+	Identity    string
+}
+
+// metadataProbeCommand returns a command, which probes instance metadata services from a container.
+func metadataProbeCommand(container ContainerInfo) []string {
+	return []string{container.shell, "-c", metadataProbeScript}
+}
+
+// probeMetadata runs the metadata probe in a container. It returns access to instance metadata services, which
+// is nil if none is reachable, and whether the probe could run at all.
+func probeMetadata(ctx context.Context, k8s *k8sexec.K8SExec, container ContainerInfo) ([]MetadataAccess, bool) {
+	execStatus := execInContainer(ctx, k8s, container.container.Pod, container.container.Container, metadataProbeCommand(container), nil)
+	if execStatus.RetCode != k8sexec.Success {
+		return nil, false
+	}
+	var access []MetadataAccess
+	for _, line := range execStatus.Stdout {
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) == 1 && fields[0] == "notool":
+			return nil, false
+		case len(fields) == 2 && fields[1] == "reachable":
+			access = append(access, MetadataAccess{Cloud: fields[0]})
+		case len(fields) > 2 && fields[1] == "credentials":
+			access = append(access, MetadataAccess{Cloud: fields[0], Credentials: true, Identity: strings.Join(fields[2:], " ")})
+		}
+	}
+	return access, true
+}
+
+// lseSectionLine formats a header of a section the way lse.sh does.
+func lseSectionLine(title string) string {
+	return fmt.Sprintf("%s( %s )=====", strings.Repeat("=", max(5, 75-len(title))), title)
+}
+
+// lseTestLines formats a result of a test the way lse.sh does, so that it is parsed as any other finding: the test
+// is found if it has details, which are printed between '---' lines. Tests, which could not run, are skipped.
+func lseTestLines(severity string, id string, name string, details []string, skipped bool) []string {
+	result := "nope"
+	switch {
+	case skipped:
+		result = "skip"
+	case len(details) > 0:
+		result = "yes!"
+	}
+	lines := []string{fmt.Sprintf("[%s] %s %s%s %s", severity, id, name, strings.Repeat(".", max(3, 60-len(id)-len(name))), result)}
+	if result == "yes!" {
+		lines = append(append(append(lines, "---"), details...), "---")
+	}
+	return lines
+}
+
+// metadataFindings returns output of the metadata probe in the format of lse.sh: cld000 tells whether an instance
+// metadata service is reachable, cld010 whether credentials of a cloud identity can be obtained from it.
+func metadataFindings(access []MetadataAccess, probed bool) []string {
+	var reachable, credentials []string
+	for _, service := range access {
+		reachable = append(reachable, fmt.Sprintf("%s: instance metadata service reachable", service.Cloud))
+		if service.Credentials {
+			credentials = append(credentials, fmt.Sprintf("%s: credentials of %s", service.Cloud, service.Identity))
+		}
+	}
+	lines := []string{"", lseSectionLine("cloud metadata")}
+	lines = append(lines, lseTestLines("*", "cld000", "Is a cloud instance metadata service reachable?", reachable, !probed)...)
+	return append(lines, lseTestLines("!", "cld010", "Can we get cloud credentials from the metadata service?", credentials, !probed)...)
+}
+
+// withProbeFindings puts findings of probes run by kubelse into a scan report before the line lse.sh prints
+// when it exits, or at its end if lse.sh did not finish.
+func withProbeFindings(report []string, findings []string) []string {
+	for idx := len(report) - 1; idx >= 0; idx-- {
+		if isFinishedLine(report[idx]) {
+			// a blank line printed by lse.sh precedes the FINISHED line
+			at := idx
+			if at > 0 && strings.TrimSpace(report[at-1]) == "" {
+				at--
+			}
+			return append(append(append([]string{}, report[:at]...), findings...), report[at:]...)
+		}
+	}
+	return append(append([]string{}, report...), findings...)
+}
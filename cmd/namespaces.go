@@ -0,0 +1,156 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/hhruszka/k8sexec"
+	authorizationV1 "k8s.io/api/authorization/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"strings"
+)
+
+// editDistance returns the Levenshtein distance of two strings.
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current := make([]int, len(b)+1)
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous = current
+	}
+	return previous[len(b)]
+}
+
+// similarNamespaces returns existing namespaces, which a mistyped namespace was probably meant to be. Nothing is
+// returned, if namespaces cannot be listed.
+func similarNamespaces(k8s *k8sexec.K8SExec, name string) []string {
+	namespaces, err := clientsetOf(k8s).CoreV1().Namespaces().List(context.TODO(), metaV1.ListOptions{})
+	if err != nil {
+		return nil
+	}
+	var similar []string
+	for _, ns := range namespaces.Items {
+		if editDistance(ns.Name, name) <= 2 || strings.HasPrefix(ns.Name, name) || strings.HasPrefix(name, ns.Name) {
+			similar = append(similar, ns.Name)
+		}
+	}
+	return similar
+}
+
+// namespaceAllowed tells if the user is allowed to perform an action on pods in a namespace.
+func namespaceAllowed(k8s *k8sexec.K8SExec, ns string, verb string, subresource string) (bool, error) {
+	review := &authorizationV1.SelfSubjectAccessReview{
+		Spec: authorizationV1.SelfSubjectAccessReviewSpec{
+			ResourceAttributes: &authorizationV1.ResourceAttributes{Namespace: ns, Verb: verb, Resource: "pods", Subresource: subresource},
+		},
+	}
+	review, err := clientsetOf(k8s).AuthorizationV1().SelfSubjectAccessReviews().Create(context.TODO(), review, metaV1.CreateOptions{})
+	if err != nil {
+		return false, err
+	}
+	return review.Status.Allowed, nil
+}
+
+// validateNamespaces checks that namespaces exist and that their pods can be listed and exec'd into, so that
+// a mistyped namespace is not mistaken for a namespace without findings.
+func validateNamespaces(k8s *k8sexec.K8SExec, namespaces []string) error {
+	// fixtures are recorded for a single namespace and do not include these requests
+	if replayFile != "" {
+		return nil
+	}
+	for _, ns := range namespaces {
+		_, err := clientsetOf(k8s).CoreV1().Namespaces().Get(context.TODO(), ns, metaV1.GetOptions{})
+		switch {
+		case apierrors.IsNotFound(err):
+			message := fmt.Sprintf("[-] Namespace %q does not exist", ns)
+			if similar := similarNamespaces(k8s, ns); len(similar) > 0 {
+				message += fmt.Sprintf(", did you mean %s?", strings.Join(similar, ", "))
+			}
+			return fmt.Errorf("%s\n", message)
+		case apierrors.IsForbidden(err):
+			// users allowed to scan a namespace are often not allowed to get it, permissions below tell enough
+		case err != nil:
+			return fmt.Errorf("[-] Error checking namespace %q: %s\n", ns, err.Error())
+		}
+
+		for _, permission := range []struct {
+			verb, subresource, description string
+		}{
+			{"list", "", "list pods"},
+			{"create", "exec", "create pods/exec"},
+		} {
+			allowed, err := namespaceAllowed(k8s, ns, permission.verb, permission.subresource)
+			if err != nil {
+				return fmt.Errorf("[-] Error checking permissions in namespace %q: %s\n", ns, err.Error())
+			}
+			if !allowed {
+				return fmt.Errorf("[-] Not allowed to %s in namespace %q, its containers cannot be scanned\n", permission.description, ns)
+			}
+		}
+	}
+	return nil
+}
+
+// scanNamespaces scans containers of many namespaces, e.g. of an ArgoCD application or from a targets file, one
+// namespace at a time. All namespaces are scanned in the same run, each with a client of its own, and the returned
+// manifest combines entries of all of them. Containers of a namespace are returned by containersOf, which gets
+// the client of the namespace; in the list mode nothing is scanned.
+func scanNamespaces(k8s *k8sexec.K8SExec, namespaces []string, containersOf func(*k8sexec.K8SExec) ([]Container, error)) (Manifest, error) {
+	var (
+		combined Manifest
+		failed   []string
+		exitErr  *ExitError
+	)
+	for _, ns := range namespaces {
+		client := *k8s
+		client.Namespace = ns
+
+		containers, err := containersOf(&client)
+		if err == nil && !list {
+			var manifest Manifest
+			manifest, err = scanContainers(&client, containers)
+			if combined.RunID == "" {
+				combined = manifest
+			} else {
+				combined.Scanned = append(combined.Scanned, manifest.Scanned...)
+				combined.NotTestable = append(combined.NotTestable, manifest.NotTestable...)
+				combined.Skipped = append(combined.Skipped, manifest.Skipped...)
+				combined.Finished = manifest.Finished
+				if combined.Tenant != manifest.Tenant {
+					// namespaces of several tenants
+					combined.Tenant = ""
+				}
+			}
+		}
+		if err != nil {
+			// e.g. a failed policy, its exit code is kept for the whole run
+			errors.As(err, &exitErr)
+			log(fmt.Sprintf("[-] Scanning namespace %s failed: %s\n", ns, strings.TrimSpace(err.Error())))
+			failed = append(failed, fmt.Sprintf("%s: %s", ns, strings.TrimSpace(err.Error())))
+		}
+	}
+	sortManifest(&combined)
+	// dashboards of every namespace are saved by its scan, the combined one compares namespaces
+	if dashboard && len(namespaces) > 1 && !list {
+		saveDashboard(combined, "-namespaces")
+	}
+	if len(failed) > 0 {
+		err := errors.New("[-] Scanning failed in namespaces:\n\t" + strings.Join(failed, "\n\t") + "\n")
+		if exitErr != nil {
+			return combined, &ExitError{Code: exitErr.Code, Err: err}
+		}
+		return combined, err
+	}
+	return combined, nil
+}
@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"github.com/hhruszka/k8sexec"
+	networkingV1 "k8s.io/api/networking/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"strings"
+)
+
+// NetworkPolicyCoverage lists NetworkPolicies, which select a pod for its ingress and egress traffic.
+type NetworkPolicyCoverage struct {
+	Ingress []string `json:"Ingress"`
+	Egress  []string `json:"Egress"`
+}
+
+// Covered tells if both ingress and egress traffic of a pod is restricted by some NetworkPolicy.
+func (c NetworkPolicyCoverage) Covered() bool {
+	return len(c.Ingress) > 0 && len(c.Egress) > 0
+}
+
+// String describes the coverage for the report header.
+func (c NetworkPolicyCoverage) String() string {
+	describe := func(policies []string) string {
+		if len(policies) == 0 {
+			return "not covered"
+		}
+		return strings.Join(policies, ",")
+	}
+	return fmt.Sprintf("ingress: %s; egress: %s", describe(c.Ingress), describe(c.Egress))
+}
+
+// policyTypes returns traffic directions a NetworkPolicy applies to. Policies without policyTypes always apply to
+// ingress and to egress only if they have egress rules.
+func policyTypes(policy networkingV1.NetworkPolicy) (ingress bool, egress bool) {
+	if len(policy.Spec.PolicyTypes) == 0 {
+		return true, len(policy.Spec.Egress) > 0
+	}
+	for _, policyType := range policy.Spec.PolicyTypes {
+		switch policyType {
+		case networkingV1.PolicyTypeIngress:
+			ingress = true
+		case networkingV1.PolicyTypeEgress:
+			egress = true
+		}
+	}
+	return ingress, egress
+}
+
+// networkPolicyCoverage returns the NetworkPolicy coverage of pods with the given labels.
+func networkPolicyCoverage(policies []networkingV1.NetworkPolicy, podLabels map[string]string) NetworkPolicyCoverage {
+	var coverage NetworkPolicyCoverage
+	for _, policy := range policies {
+		selector, err := metaV1.LabelSelectorAsSelector(&policy.Spec.PodSelector)
+		if err != nil || !selector.Matches(labels.Set(podLabels)) {
+			continue
+		}
+		ingress, egress := policyTypes(policy)
+		if ingress {
+			coverage.Ingress = append(coverage.Ingress, policy.Name)
+		}
+		if egress {
+			coverage.Egress = append(coverage.Egress, policy.Name)
+		}
+	}
+	return coverage
+}
+
+// checkNetworkPolicies determines the NetworkPolicy coverage of pods of the containers and reports pods, which
+// ingress or egress traffic is not restricted by any policy.
+func checkNetworkPolicies(k8s *k8sexec.K8SExec, containers []Container) ([]Container, error) {
+	list, err := clientsetOf(k8s).NetworkingV1().NetworkPolicies(k8s.Namespace).List(context.TODO(), metaV1.ListOptions{})
+	if err != nil {
+		return containers, fmt.Errorf("[-] Error listing network policies: %s\n", err.Error())
+	}
+
+	uncovered := make(map[string]NetworkPolicyCoverage)
+	var pods []string
+	for idx := range containers {
+		coverage := networkPolicyCoverage(list.Items, containers[idx].Labels)
+		containers[idx].NetworkPolicies = &coverage
+		if _, ok := uncovered[containers[idx].Pod]; !ok && !coverage.Covered() {
+			uncovered[containers[idx].Pod] = coverage
+			pods = append(pods, containers[idx].Pod)
+		}
+	}
+
+	log(fmt.Sprintf("[+] Found %d network policies in %s namespace\n", len(list.Items), k8s.Namespace))
+	if len(pods) > 0 {
+		log(fmt.Sprintf("[-] Following %d pods are not fully covered by network policies:\n", len(pods)))
+		for _, pod := range pods {
+			log(fmt.Sprintf("%s\t%s\n", pod, uncovered[pod].String()))
+		}
+		log("\n")
+	}
+	return containers, nil
+}
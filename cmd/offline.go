@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+)
+
+// offline mode CLI options variables
+var offline bool
+
+// offlineVariable enables the offline mode, e.g. in images built for air-gapped environments
+const offlineVariable = "KUBELSE_OFFLINE"
+
+// errOffline is returned for requests to anything else than the scanned cluster in the offline mode
+var errOffline = errors.New("network access outside of the cluster is disabled in the offline mode '--offline'")
+
+var (
+	// clusterHosts are API servers of clusters kubelse connects to, the only hosts allowed in the offline mode
+	clusterHosts   = make(map[string]bool)
+	clusterHostsMu sync.Mutex
+)
+
+// offlineTransport lets requests through to API servers of the scanned clusters only and fails any other request,
+// so that a component, which does not check the offline mode itself, cannot reach the network either.
+type offlineTransport struct {
+	next http.RoundTripper
+}
+
+func (t offlineTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	clusterHostsMu.Lock()
+	allowed := clusterHosts[hostKey(req.URL)]
+	clusterHostsMu.Unlock()
+	if !allowed {
+		return nil, fmt.Errorf("%s %s: %w", req.Method, req.URL.Redacted(), errOffline)
+	}
+	return t.next.RoundTrip(req)
+}
+
+// hostKey returns a host and port of a URL, the default port of its scheme if it has none.
+func hostKey(u *url.URL) string {
+	port := u.Port()
+	if port == "" {
+		port = "443"
+		if u.Scheme == "http" {
+			port = "80"
+		}
+	}
+	return net.JoinHostPort(u.Hostname(), port)
+}
+
+// allowClusterHost allows requests to an API server of a cluster, given as in a rest config, e.g.
+// https://10.0.0.1:6443 or 10.0.0.1, in the offline mode.
+func allowClusterHost(host string) {
+	if !strings.Contains(host, "://") {
+		host = "https://" + host
+	}
+	u, err := url.Parse(host)
+	if err != nil {
+		return
+	}
+	clusterHostsMu.Lock()
+	clusterHosts[hostKey(u)] = true
+	clusterHostsMu.Unlock()
+}
+
+// enterOfflineMode turns the offline mode on, if requested with '--offline' or KUBELSE_OFFLINE, and makes the
+// default HTTP transport fail closed for anything else than API servers of scanned clusters.
+func enterOfflineMode() {
+	if value := strings.ToLower(os.Getenv(offlineVariable)); value == "1" || value == "true" {
+		offline = true
+	}
+	if !offline {
+		return
+	}
+	if _, ok := http.DefaultTransport.(offlineTransport); !ok {
+		http.DefaultTransport = offlineTransport{next: http.DefaultTransport}
+	}
+}
+
+// requireOnline returns an error if a feature, which needs network access outside of the cluster, is used in the
+// offline mode.
+func requireOnline(feature string) error {
+	if offline {
+		return fmt.Errorf("[-] %s needs network access outside of the cluster, which is disabled in the offline mode '--offline'\n", feature)
+	}
+	return nil
+}
+
+// validateOffline checks that no option of a scan sends data outside of the cluster in the offline mode.
+func validateOffline() error {
+	if !offline {
+		return nil
+	}
+	var options []string
+	for _, option := range []struct{ name, specs string }{{"--sink", sinkSpecs}, {"--tenant-sink", tenantSink}} {
+		for _, spec := range untangleOption(option.specs) {
+			kind, _, _ := strings.Cut(strings.TrimSpace(spec), "=")
+			if kind == "http" || kind == "splunk" || strings.HasPrefix(spec, "s3://") {
+				options = append(options, "'"+option.name+" "+spec+"'")
+			}
+		}
+	}
+	if grpcSink != "" {
+		options = append(options, "'--grpc-sink'")
+	}
+	if createIssues != "" {
+		options = append(options, "'--create-issues'")
+	}
+	if imageConfig {
+		options = append(options, "'--image-config'")
+	}
+	if len(options) > 0 {
+		return fmt.Errorf("The offline mode option '--offline' cannot be used together with %s, which access the network outside of the cluster", strings.Join(options, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOfflineModeFailsClosed(t *testing.T) {
+	cluster := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer cluster.Close()
+	elsewhere := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer elsewhere.Close()
+
+	defer func(transport http.RoundTripper) { http.DefaultTransport, offline = transport, false }(http.DefaultTransport)
+	t.Setenv(offlineVariable, "1")
+	enterOfflineMode()
+	allowClusterHost(cluster.URL)
+
+	if resp, err := http.Get(cluster.URL + "/version"); err != nil {
+		t.Errorf("expected the API server of the cluster reachable, got %v", err)
+	} else {
+		resp.Body.Close()
+	}
+	if _, err := http.Get(elsewhere.URL); !errors.Is(err, errOffline) {
+		t.Errorf("expected requests to other hosts failed, got %v", err)
+	}
+	if err := requireOnline("kubelse self-update"); err == nil {
+		t.Errorf("expected self-update rejected")
+	}
+
+	sinkSpecs, grpcSink = "file,http=https://collector.example.com", ""
+	defer func() { sinkSpecs = "" }()
+	if err := validateOffline(); err == nil {
+		t.Errorf("expected the http sink rejected")
+	}
+	sinkSpecs = "file,archive=reports.tar.gz"
+	if err := validateOffline(); err != nil {
+		t.Errorf("expected local sinks accepted, got %v", err)
+	}
+}
@@ -0,0 +1,302 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"github.com/hhruszka/k8sexec"
+	"github.com/spf13/cobra"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/util/retry"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// operator CLI options variables
+var (
+	operatorKubeconfig string
+	operatorNamespace  string
+	operatorDirectory  string
+	operatorResync     time.Duration
+	operatorEvents     bool
+)
+
+// lseScanResource identifies LseScan custom resources, see deploy/lsescan-crd.yaml
+var lseScanResource = schema.GroupVersionResource{Group: "kubelse.io", Version: "v1alpha1", Resource: "lsescans"}
+
+// LseScanSpec describes what, how often and where to scan.
+type LseScanSpec struct {
+	// TargetNamespace is a namespace of pods to be scanned, the namespace of the LseScan if empty
+	TargetNamespace string
+	// Selector is a label selector of pods to be scanned, unique pods of the namespace if empty
+	Selector string
+	// Interval is how often a scan is run, a scan is run only once if it is zero
+	Interval time.Duration
+	// Format is an output format of the reports
+	Format string
+	// Directory is where reports are saved to
+	Directory string
+	// Level and Sections are lse.sh settings
+	Level    string
+	Sections string
+}
+
+// lseScanSpec reads a spec of an LseScan resource.
+func lseScanSpec(obj *unstructured.Unstructured) (LseScanSpec, error) {
+	spec := LseScanSpec{Format: "ansi", Directory: operatorDirectory}
+
+	spec.TargetNamespace, _, _ = unstructured.NestedString(obj.Object, "spec", "targetNamespace")
+	if spec.TargetNamespace == "" {
+		spec.TargetNamespace = obj.GetNamespace()
+	}
+	spec.Selector, _, _ = unstructured.NestedString(obj.Object, "spec", "selector")
+	spec.Level, _, _ = unstructured.NestedString(obj.Object, "spec", "level")
+	spec.Sections, _, _ = unstructured.NestedString(obj.Object, "spec", "sections")
+	if value, _, _ := unstructured.NestedString(obj.Object, "spec", "format"); value != "" {
+		spec.Format = value
+	}
+	if value, _, _ := unstructured.NestedString(obj.Object, "spec", "output", "directory"); value != "" {
+		spec.Directory = value
+	}
+	if value, _, _ := unstructured.NestedString(obj.Object, "spec", "interval"); value != "" {
+		interval, err := time.ParseDuration(value)
+		if err != nil {
+			return spec, fmt.Errorf("invalid interval %q: %s", value, err.Error())
+		}
+		spec.Interval = interval
+	}
+	return spec, validateLseScanSpec(spec)
+}
+
+// validateLseScanSpec checks the output format and lse.sh settings of a scan.
+func validateLseScanSpec(spec LseScanSpec) error {
+	if spec.Format != "ansi" && spec.Format != "text" && spec.Format != "plain" && spec.Format != "html" && spec.Format != "json" {
+		return fmt.Errorf("invalid format %q, valid formats are ansi, text, plain, html or json", spec.Format)
+	}
+	if spec.Level != "" {
+		if err := validateLevel(spec.Level); err != nil {
+			return err
+		}
+	}
+	if spec.Sections != "" {
+		if err := validateSections(spec.Sections); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scanDue tells if an LseScan should be run now.
+func scanDue(obj *unstructured.Unstructured, spec LseScanSpec) bool {
+	last, found, _ := unstructured.NestedString(obj.Object, "status", "lastScanTime")
+	if !found || last == "" {
+		return true
+	}
+	// the spec changed since the last scan
+	if generation, _, _ := unstructured.NestedInt64(obj.Object, "status", "observedGeneration"); generation != obj.GetGeneration() {
+		return true
+	}
+	if spec.Interval == 0 {
+		return false
+	}
+	lastTime, err := time.Parse(time.RFC3339, last)
+	if err != nil {
+		return true
+	}
+	return time.Since(lastTime) >= spec.Interval
+}
+
+// runLseScan runs a scan described by an LseScan resource. Scans use the same pipeline as CLI runs, which is
+// configured with package variables, therefore LseScan resources are processed one by one.
+func runLseScan(client *k8sexec.K8SExec, spec LseScanSpec) (Manifest, error) {
+	namespace, labelSelector, format, directory = spec.TargetNamespace, spec.Selector, spec.Format, spec.Directory
+	level, sections = spec.Level, spec.Sections
+
+	if err := os.MkdirAll(directory, 0755); err != nil {
+		return Manifest{}, err
+	}
+
+	k8s := *client
+	k8s.Namespace = spec.TargetNamespace
+
+	containers, err := getContainers(&k8s, nil, nil)
+	if err != nil {
+		return Manifest{}, err
+	}
+	startRun(&k8s)
+	return scanContainers(&k8s, containers)
+}
+
+// updateLseScanStatus writes an outcome of a scan back to the LseScan resource. The resource may have changed
+// during a long scan, so its latest version is read and the update is retried on conflicts; the observed generation
+// is the one of the scanned spec, so that a spec changed during the scan is scanned again.
+func updateLseScanStatus(client dynamic.Interface, obj *unstructured.Unstructured, spec LseScanSpec, manifest Manifest, scanErr error) error {
+	now := time.Now().UTC()
+	status := map[string]interface{}{
+		"observedGeneration": obj.GetGeneration(),
+		"lastScanTime":       now.Format(time.RFC3339),
+		"lastRunID":          runID,
+		"phase":              "Completed",
+		"message":            fmt.Sprintf("scanned %d containers", len(manifest.Scanned)),
+		"scanned":            int64(len(manifest.Scanned)),
+		"notTestable":        int64(len(manifest.NotTestable)),
+		"skipped":            int64(len(manifest.Skipped)),
+	}
+	if spec.Interval > 0 {
+		status["nextScanTime"] = now.Add(spec.Interval).Format(time.RFC3339)
+	}
+	if scanErr != nil {
+		status["phase"] = "Failed"
+		status["message"] = scanErr.Error()
+	}
+	findings := make(map[string]interface{})
+	for severity, count := range manifest.FindingsCount() {
+		findings[severity] = int64(count)
+	}
+	status["findings"] = findings
+
+	resource := client.Resource(lseScanResource).Namespace(obj.GetNamespace())
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		current, err := resource.Get(context.TODO(), obj.GetName(), metaV1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		if err := unstructured.SetNestedMap(current.Object, status, "status"); err != nil {
+			return err
+		}
+		_, err = resource.UpdateStatus(context.TODO(), current, metaV1.UpdateOptions{})
+		return err
+	})
+}
+
+// watchLseScans watches LseScan resources and signals through the returned channel whenever any of them changes,
+// so that new and changed scans are run without waiting for the resync. The watch is re-established when
+// the API server closes it.
+func watchLseScans(ctx context.Context, client dynamic.Interface) <-chan struct{} {
+	changes := make(chan struct{}, 1)
+	go func() {
+		for ctx.Err() == nil {
+			watcher, err := client.Resource(lseScanResource).Namespace(operatorNamespace).Watch(ctx, metaV1.ListOptions{})
+			if err != nil {
+				log(fmt.Sprintf("[-] Error watching LseScan resources: %s\n", err.Error()))
+				time.Sleep(operatorResync)
+				continue
+			}
+			for range watcher.ResultChan() {
+				select {
+				case changes <- struct{}{}:
+				default:
+				}
+			}
+			watcher.Stop()
+		}
+	}()
+	return changes
+}
+
+// reconcileLseScans runs all LseScan resources, which are due.
+func reconcileLseScans(client *k8sexec.K8SExec, dynamicClient dynamic.Interface) error {
+	list, err := dynamicClient.Resource(lseScanResource).Namespace(operatorNamespace).List(context.TODO(), metaV1.ListOptions{})
+	if err != nil {
+		return err
+	}
+
+	for idx := range list.Items {
+		obj := &list.Items[idx]
+		name := fmt.Sprintf("%s/%s", obj.GetNamespace(), obj.GetName())
+
+		spec, err := lseScanSpec(obj)
+		if err != nil {
+			// an invalid spec is reported once, the status update must not trigger it again through the watch
+			if generation, _, _ := unstructured.NestedInt64(obj.Object, "status", "observedGeneration"); generation == obj.GetGeneration() {
+				continue
+			}
+			log(fmt.Sprintf("[-] Invalid LseScan %s: %s\n", name, err.Error()))
+			if err := updateLseScanStatus(dynamicClient, obj, spec, Manifest{}, err); err != nil {
+				log(fmt.Sprintf("[-] Error updating status of LseScan %s: %s\n", name, err.Error()))
+			}
+			continue
+		}
+		if !scanDue(obj, spec) {
+			continue
+		}
+
+		log(fmt.Sprintf("[*] Running LseScan %s\n", name))
+		manifest, scanErr := runLseScan(client, spec)
+		if scanErr != nil {
+			log(fmt.Sprintf("[-] LseScan %s failed: %s\n", name, scanErr.Error()))
+		}
+		if err := updateLseScanStatus(dynamicClient, obj, spec, manifest, scanErr); err != nil {
+			log(fmt.Sprintf("[-] Error updating status of LseScan %s: %s\n", name, err.Error()))
+		}
+	}
+	return nil
+}
+
+var operatorCmd = &cobra.Command{
+	Use:   "operator",
+	Short: "Run scans described by LseScan custom resources",
+	Long: `
+Runs a controller, which watches LseScan custom resources (see deploy/lsescan-crd.yaml), runs
+scans that are due and writes their status and finding summaries back to the resources.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// there is nobody to confirm anything in the operator mode
+		interactive = false
+		// credentials are reloaded from the same kubeconfig, when they expire
+		kubeconfig = operatorKubeconfig
+		events = operatorEvents
+
+		client, err := newExecClient(operatorKubeconfig, operatorNamespace)
+		if err != nil {
+			return clientError(err)
+		}
+		dynamicClient, err := dynamic.NewForConfig(client.Config)
+		if err != nil {
+			return fmt.Errorf("Internal application error: %s\n", err.Error())
+		}
+
+		// scans requested through the scan API, nothing is ever received if the API is not served
+		var (
+			api      *scanAPI
+			requests chan string
+		)
+		if operatorListen != "" {
+			if api, err = startScanAPI(operatorListen, operatorTLSCert, operatorTLSKey); err != nil {
+				return fmt.Errorf("[-] Error starting the scan API: %s\n", err.Error())
+			}
+			requests = api.queue
+		}
+
+		log(fmt.Sprintf("[+] Operator started, watching LseScan resources and checking them every %s\n", operatorResync))
+		changes := watchLseScans(context.Background(), dynamicClient)
+		for {
+			if err := reconcileLseScans(client, dynamicClient); err != nil {
+				log(fmt.Sprintf("[-] Error listing LseScan resources: %s\n", err.Error()))
+			}
+			select {
+			case <-time.After(operatorResync):
+			case <-changes:
+			case id := <-requests:
+				api.run(client, id)
+			}
+		}
+	},
+}
+
+func init() {
+	addAuthFlags(operatorCmd.Flags())
+	operatorCmd.Flags().StringVarP(&operatorKubeconfig, "kubeconfig", "k", "", "absolute path to the kubeconfig file, if not provided then in-cluster configuration is used")
+	operatorCmd.Flags().StringVarP(&operatorNamespace, "namespace", "n", "", "a namespace of LseScan resources, if not provided then all namespaces are watched")
+	operatorCmd.Flags().StringVarP(&operatorDirectory, "directory", "d", filepath.Join(string(filepath.Separator), "reports"), "a default directory where reports should be saved to")
+	operatorCmd.Flags().StringVar(&operatorListen, "listen", "", "an address, e.g. :8080, of the scan API used by 'kubelse remote', the token is read from KUBELSE_API_TOKEN")
+	operatorCmd.Flags().StringVar(&operatorTLSCert, "tls-cert", "", "a certificate the scan API is served with over TLS, without it the API has to be exposed through a proxy terminating TLS")
+	operatorCmd.Flags().StringVar(&operatorTLSKey, "tls-key", "", "a private key of the --tls-cert certificate")
+	operatorCmd.Flags().DurationVar(&operatorResync, "resync", 30*time.Second, "how often LseScan resources are checked for scans due by their interval, changed resources are run right away")
+	operatorCmd.Flags().BoolVar(&operatorEvents, "events", true, "emit Kubernetes Events on scanned pods")
+
+	cmd.AddCommand(operatorCmd)
+}
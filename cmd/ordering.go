@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"sort"
+)
+
+// sortResults orders results of a run by pod and container, rather than by the completion order of workers, so
+// that summaries, manifests and merged reports of consecutive runs can be diffed.
+func sortResults(results []Result) {
+	sort.SliceStable(results, func(i, j int) bool {
+		a, b := results[i].container.container, results[j].container.container
+		if a.Pod != b.Pod {
+			return a.Pod < b.Pod
+		}
+		return a.Container < b.Container
+	})
+}
+
+// sortManifestEntries orders entries of a manifest by namespace, pod and container.
+func sortManifestEntries(entries []ManifestEntry) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		if a.Pod != b.Pod {
+			return a.Pod < b.Pod
+		}
+		return a.Container < b.Container
+	})
+}
+
+// sortManifest orders all entries of a manifest.
+func sortManifest(manifest *Manifest) {
+	sortManifestEntries(manifest.Scanned)
+	sortManifestEntries(manifest.NotTestable)
+	sortManifestEntries(manifest.Skipped)
+}
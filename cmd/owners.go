@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	corev1 "k8s.io/api/core/v1"
+	"os"
+	"regexp"
+	"sort"
+)
+
+// owners CLI options variables
+var (
+	ownersFile string
+	ownerLabel string
+)
+
+// OwnersMapping attributes workloads to owning teams, it is read from a json file provided with '--owners'.
+// Workloads are keyed by <namespace>/<kind>/<name>, e.g. payments/Deployment/api.
+type OwnersMapping struct {
+	Workloads  map[string]string `json:"Workloads"`
+	Namespaces map[string]string `json:"Namespaces"`
+	Default    string            `json:"Default"`
+}
+
+var owners OwnersMapping
+
+var unsafeFileNameRegexp = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// loadOwners reads the ownership mapping file.
+func loadOwners(path string) error {
+	owners = OwnersMapping{}
+	if path == "" {
+		return nil
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(content, &owners); err != nil {
+		return fmt.Errorf("invalid ownership mapping %s: %s", path, err.Error())
+	}
+	return nil
+}
+
+// ownerOf returns a team owning a pod, i.e. its workload's owner from the mapping file, the value of the owner
+// label of the pod, its namespace's owner from the mapping file or the default owner, whichever is found first.
+func ownerOf(pod corev1.Pod) string {
+	if owner, ok := owners.Workloads[pod.Namespace+"/"+workloadOf(pod)]; ok {
+		return owner
+	}
+	if owner := pod.Labels[ownerLabel]; ownerLabel != "" && owner != "" {
+		return owner
+	}
+	if owner, ok := owners.Namespaces[pod.Namespace]; ok {
+		return owner
+	}
+	return owners.Default
+}
+
+// resultsByOwner groups results by owners of scanned containers and returns the owners sorted, results of
+// containers without an owner are grouped under an empty owner.
+func resultsByOwner(results []Result) ([]string, map[string][]Result) {
+	grouped := make(map[string][]Result)
+	for _, result := range results {
+		owner := result.container.container.Owner
+		grouped[owner] = append(grouped[owner], result)
+	}
+	var names []string
+	for owner := range grouped {
+		names = append(names, owner)
+	}
+	sort.Strings(names)
+	return names, grouped
+}
+
+// safeFileName replaces characters, which are not safe in file names, e.g. path separators, with '-'.
+func safeFileName(name string) string {
+	return unsafeFileNameRegexp.ReplaceAllString(name, "-")
+}
@@ -0,0 +1,24 @@
+package cmd
+
+import (
+	"time"
+)
+
+// pace is a minimum delay between successive exec starts of a worker, execs are not delayed if it is zero
+var pace time.Duration
+
+// pacer delays execs of a single worker, so that they start at least pace apart.
+type pacer struct {
+	last time.Time
+}
+
+// wait blocks until pace has passed since the previous exec of the worker.
+func (p *pacer) wait() {
+	if pace <= 0 {
+		return
+	}
+	if elapsed := time.Since(p.last); !p.last.IsZero() && elapsed < pace {
+		time.Sleep(pace - elapsed)
+	}
+	p.last = time.Now()
+}
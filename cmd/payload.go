@@ -0,0 +1,212 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/spf13/cobra"
+	"k8slse/data"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+)
+
+// payload update CLI options variables
+var payloadFile string
+
+var lseVersionRegexp = regexp.MustCompile(`(?m)^lse_version="([^"]+)"`)
+
+// PayloadInfo describes a script installed in the payload cache.
+type PayloadInfo struct {
+	Source    string    `json:"Source"`
+	Version   string    `json:"Version"`
+	SHA256    string    `json:"SHA256"`
+	Installed time.Time `json:"Installed"`
+}
+
+// payloadCacheDir returns a directory, where a script used in preference to the embedded lse.sh is kept.
+func payloadCacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "kubelse"), nil
+}
+
+// lseVersion returns a version of lse.sh declared in a script, or an empty string.
+func lseVersion(script []byte) string {
+	if match := lseVersionRegexp.FindSubmatch(script); match != nil {
+		return string(match[1])
+	}
+	return ""
+}
+
+// preparePayload applies transformations needed before a script is sent into containers and returns the
+// transformed script with descriptions of the transformations that changed it.
+func preparePayload(script []byte) ([]byte, []string) {
+	var applied []string
+	// this is necessary, when cross-compiling on windows
+	prepared := bytes.Replace(script, []byte("\r\n"), []byte("\n"), -1)
+	prepared = bytes.Replace(prepared, []byte("\r"), []byte(""), -1)
+	if !bytes.Equal(prepared, script) {
+		applied = append(applied, "CRLF line endings normalized to LF")
+	}
+	return prepared, applied
+}
+
+// installPayload saves a script in the payload cache, so that it is used instead of the embedded lse.sh.
+func installPayload(script []byte, source string) (PayloadInfo, error) {
+	dir, err := payloadCacheDir()
+	if err != nil {
+		return PayloadInfo{}, err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return PayloadInfo{}, err
+	}
+	sum := sha256.Sum256(script)
+	info := PayloadInfo{Source: source, Version: lseVersion(script), SHA256: fmt.Sprintf("%x", sum), Installed: time.Now()}
+	content, err := json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return info, err
+	}
+	if err := os.WriteFile(filepath.Join(dir, "lse.sh"), script, 0644); err != nil {
+		return info, err
+	}
+	return info, os.WriteFile(filepath.Join(dir, "lse.json"), content, 0644)
+}
+
+// cachedPayload returns a script installed in the payload cache, if there is one and it was not modified since
+// it was installed.
+func cachedPayload() ([]byte, PayloadInfo, bool) {
+	dir, err := payloadCacheDir()
+	if err != nil {
+		return nil, PayloadInfo{}, false
+	}
+	content, err := os.ReadFile(filepath.Join(dir, "lse.json"))
+	if err != nil {
+		return nil, PayloadInfo{}, false
+	}
+	var info PayloadInfo
+	if err := json.Unmarshal(content, &info); err != nil {
+		return nil, PayloadInfo{}, false
+	}
+	script, err := os.ReadFile(filepath.Join(dir, "lse.sh"))
+	if err != nil {
+		return nil, PayloadInfo{}, false
+	}
+	if sum := sha256.Sum256(script); fmt.Sprintf("%x", sum) != info.SHA256 {
+		log(fmt.Sprintf("[-] Cached script %s does not match its recorded checksum, using the embedded lse.sh\n", filepath.Join(dir, "lse.sh")))
+		return nil, PayloadInfo{}, false
+	}
+	return script, info, true
+}
+
+// useCachedPayload makes a script installed in the payload cache the payload of scans.
+func useCachedPayload() {
+	script, info, ok := cachedPayload()
+	if !ok {
+		return
+	}
+	lse, payloadName = script, fmt.Sprintf("lse.sh %s from %s (sha256 %s)", valueOrDefault(info.Version, "unknown version"), info.Source, info.SHA256)
+	if dir, err := payloadCacheDir(); err == nil {
+		payloadPath = filepath.Join(dir, "lse.sh")
+	}
+	log(fmt.Sprintf("[+] Using cached %s\n", payloadName))
+}
+
+var payloadCmd = &cobra.Command{
+	Use:   "payload",
+	Short: "Show or replace the script run in containers",
+}
+
+var payloadShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Print the version, checksum and transformations of the script run in containers",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		script, source := data.GetScript(), "embedded"
+		if cached, info, ok := cachedPayload(); ok {
+			dir, _ := payloadCacheDir()
+			script, source = cached, fmt.Sprintf("%s, installed from %s at %s", filepath.Join(dir, "lse.sh"), info.Source, info.Installed.Format(time.RFC3339))
+		}
+		prepared, applied := preparePayload(script)
+
+		embeddedSum := sha256.Sum256(data.GetScript())
+		sum, preparedSum := sha256.Sum256(script), sha256.Sum256(prepared)
+		fmt.Printf("Source:            %s\n", source)
+		fmt.Printf("Version:           %s\n", valueOrDefault(lseVersion(script), "unknown"))
+		fmt.Printf("Size:              %d bytes\n", len(script))
+		fmt.Printf("SHA256:            %x\n", sum)
+		fmt.Printf("Sent SHA256:       %x\n", preparedSum)
+		if len(applied) == 0 {
+			applied = []string{"none"}
+		}
+		for idx, transformation := range applied {
+			if idx == 0 {
+				fmt.Printf("Transformations:   %s\n", transformation)
+			} else {
+				fmt.Printf("                   %s\n", transformation)
+			}
+		}
+		fmt.Printf("Embedded version:  %s (sha256 %x)\n", valueOrDefault(lseVersion(data.GetScript()), "unknown"), embeddedSum)
+		return nil
+	},
+}
+
+var payloadUpdateCmd = &cobra.Command{
+	Use:   "update --file <script>",
+	Short: "Use a local script instead of the embedded lse.sh without rebuilding",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if payloadFile == "" {
+			return errors.New("A script has to be provided with '--file'")
+		}
+		script, err := loadScript(payloadFile)
+		if err != nil {
+			return err
+		}
+		if lseVersion(script) == "" {
+			log(fmt.Sprintf("[-] %s does not declare lse_version, it may not accept lse.sh options\n", payloadFile))
+		}
+		source, err := filepath.Abs(payloadFile)
+		if err != nil {
+			source = payloadFile
+		}
+		info, err := installPayload(script, source)
+		if err != nil {
+			return fmt.Errorf("[-] Error installing %s: %s\n", payloadFile, err.Error())
+		}
+		log(fmt.Sprintf("[+] lse.sh %s (sha256 %s) will be used instead of the embedded one\n", valueOrDefault(info.Version, "of unknown version"), info.SHA256))
+		return nil
+	},
+}
+
+var payloadResetCmd = &cobra.Command{
+	Use:   "reset",
+	Short: "Go back to the embedded lse.sh",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir, err := payloadCacheDir()
+		if err != nil {
+			return err
+		}
+		for _, name := range []string{"lse.sh", "lse.json"} {
+			if err := os.Remove(filepath.Join(dir, name)); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+		log(fmt.Sprintln("[+] The embedded lse.sh will be used"))
+		return nil
+	},
+}
+
+func init() {
+	payloadUpdateCmd.Flags().StringVar(&payloadFile, "file", "", "a script file to be used instead of the embedded lse.sh")
+	addScriptVerificationFlags(payloadUpdateCmd.Flags())
+
+	payloadCmd.AddCommand(payloadShowCmd, payloadUpdateCmd, payloadResetCmd)
+	cmd.AddCommand(payloadCmd)
+}
@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/hhruszka/k8sexec"
+	"runtime"
+	"sync"
+)
+
+// scanPipelined verifies containers and starts scanning testable ones as soon as they are verified, instead of
+// waiting for the verification of all containers to finish. Since testable containers are not known upfront,
+// a confirmation is requested before the verification.
+func scanPipelined(ctx context.Context, k8s *k8sexec.K8SExec, containers []Container) (Manifest, error) {
+	if err := checkOutputSpace(len(containers)); err != nil {
+		return Manifest{}, err
+	}
+	if !quiet && interactive {
+		if promptYN(fmt.Sprintf("\nDo you wish to proceed with verifying and testing %d containers? (Y/N): ", len(containers))) {
+			log(fmt.Sprintln("Proceeding with testing..."))
+		} else {
+			return Manifest{}, errors.New("Action cancelled.")
+		}
+	}
+
+	var (
+		started  = now()
+		results  []Result
+		testable chan ContainerInfo = make(chan ContainerInfo, runtime.NumCPU()*2)
+		scanWg   sync.WaitGroup
+	)
+
+	ctx = newErrorBudget(ctx, len(containers))
+	newOutputQuota()
+	if err := newSinks(); err != nil {
+		return Manifest{}, err
+	}
+	scanWg.Add(1)
+	go func() {
+		defer scanWg.Done()
+		results = scanStream(ctx, k8s, testable, len(containers))
+	}()
+
+	targetContainers, nontestableContainers = verifyContainers(ctx, k8s, containers, testable)
+	close(testable)
+	scanWg.Wait()
+
+	log(fmt.Sprintf("[+] Found %d containers, %d of them tested\n", len(targetContainers)+len(nontestableContainers), len(targetContainers)))
+	if len(nontestableContainers) > 0 {
+		logContainers(fmt.Sprintf("[-] Following %d containers cannot be tested:\n", len(nontestableContainers)), nontestableContainers)
+	}
+	if len(targetContainers) == 0 {
+		return Manifest{}, errors.New("[-] Did not find any containers that can be tested")
+	}
+
+	return finishRun(ctx, started, retryFailed(ctx, k8s, results))
+}
@@ -0,0 +1,200 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// policy CLI options variables
+var (
+	policyCommand  string
+	policyExitCode int
+)
+
+// PolicyContainer is a scanned container as seen by a policy: its pod's metadata, status of the scan and positive
+// findings.
+type PolicyContainer struct {
+	Namespace   string            `json:"Namespace"`
+	Pod         string            `json:"Pod"`
+	Container   string            `json:"Container"`
+	Workload    string            `json:"Workload,omitempty"`
+	Image       string            `json:"Image,omitempty"`
+	Owner       string            `json:"Owner,omitempty"`
+	Labels      map[string]string `json:"Labels,omitempty"`
+	Annotations map[string]string `json:"Annotations,omitempty"`
+	PSS         PSSResult         `json:"PSS"`
+	Risk        RiskScore         `json:"Risk"`
+	Suggestions []Suggestion      `json:"Suggestions,omitempty"`
+	Status      string            `json:"Status"`
+	Findings    []Finding         `json:"Findings"`
+}
+
+// PolicyInput is the document a policy is evaluated against.
+type PolicyInput struct {
+	RunID      string            `json:"RunID"`
+	Cluster    ClusterInfo       `json:"Cluster"`
+	Containers []PolicyContainer `json:"Containers"`
+}
+
+// PolicyViolation is a violation of a policy reported by the policy command.
+type PolicyViolation struct {
+	Pod       string `json:"Pod,omitempty"`
+	Container string `json:"Container,omitempty"`
+	Rule      string `json:"Rule,omitempty"`
+	Message   string `json:"Message"`
+}
+
+// String describes a violation.
+func (v PolicyViolation) String() string {
+	var subject []string
+	for _, part := range []string{v.Pod, v.Container} {
+		if part != "" {
+			subject = append(subject, part)
+		}
+	}
+	description := v.Message
+	if v.Rule != "" {
+		description = fmt.Sprintf("%s: %s", v.Rule, v.Message)
+	}
+	if len(subject) > 0 {
+		description = fmt.Sprintf("%s: %s", strings.Join(subject, "/"), description)
+	}
+	return description
+}
+
+// PolicyVerdict is an outcome of evaluating a policy, it is recorded in the run manifest.
+type PolicyVerdict struct {
+	Command    string            `json:"Command"`
+	Passed     bool              `json:"Passed"`
+	Violations []PolicyViolation `json:"Violations"`
+}
+
+// policyInput builds the policy input document from results of a run.
+func policyInput(results []Result) PolicyInput {
+	input := PolicyInput{RunID: runID, Cluster: cluster, Containers: []PolicyContainer{}}
+	for _, result := range results {
+		container := result.container.container
+		findings := result.findings().Positive()
+		if findings == nil {
+			findings = []Finding{}
+		}
+		input.Containers = append(input.Containers, PolicyContainer{
+			Namespace:   scannedNamespace(),
+			Pod:         container.Pod,
+			Container:   container.Container,
+			Workload:    container.Workload,
+			Image:       container.Image,
+			Owner:       container.Owner,
+			Labels:      container.Labels,
+			Annotations: container.Annotations,
+			PSS:         container.PSS,
+			Risk:        result.risk,
+			Suggestions: container.Suggestions,
+			Status:      result.status(),
+			Findings:    findings,
+		})
+	}
+	return input
+}
+
+// parseViolations parses output of a policy command: a json list of violations or of messages, or a result of
+// 'opa eval --format json', which value is such a list. An empty output or an empty list means no violations.
+func parseViolations(output []byte) ([]PolicyViolation, error) {
+	if len(bytes.TrimSpace(output)) == 0 {
+		return nil, nil
+	}
+
+	var opa struct {
+		Result []struct {
+			Expressions []struct {
+				Value json.RawMessage `json:"value"`
+			} `json:"expressions"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal(output, &opa); err == nil && opa.Result != nil {
+		var violations []PolicyViolation
+		for _, result := range opa.Result {
+			for _, expression := range result.Expressions {
+				found, err := parseViolations(expression.Value)
+				if err != nil {
+					return nil, err
+				}
+				violations = append(violations, found...)
+			}
+		}
+		return violations, nil
+	}
+
+	var items []json.RawMessage
+	if err := json.Unmarshal(output, &items); err != nil {
+		return nil, errors.New("expected a json list of violations or an 'opa eval --format json' result")
+	}
+	var violations []PolicyViolation
+	for _, item := range items {
+		var message string
+		if err := json.Unmarshal(item, &message); err == nil {
+			violations = append(violations, PolicyViolation{Message: message})
+			continue
+		}
+		var violation PolicyViolation
+		if err := json.Unmarshal(item, &violation); err != nil || violation.Message == "" {
+			return nil, fmt.Errorf("expected a message or an object with a Message, got %s", item)
+		}
+		violations = append(violations, violation)
+	}
+	return violations, nil
+}
+
+// evaluatePolicy runs the policy command with the policy input passed through its stdin and returns violations
+// it printed to its stdout. The command is run with a shell, so it can be e.g. an opa eval with a query.
+func evaluatePolicy(results []Result) (PolicyVerdict, error) {
+	input, err := json.Marshal(policyInput(results))
+	if err != nil {
+		return PolicyVerdict{}, err
+	}
+
+	command := exec.Command("sh", "-c", policyCommand)
+	if runtime.GOOS == "windows" {
+		command = exec.Command("cmd", "/C", policyCommand)
+	}
+	command.Stdin, command.Stderr = bytes.NewReader(input), os.Stderr
+	output, err := command.Output()
+	if err != nil {
+		return PolicyVerdict{}, fmt.Errorf("policy command failed: %s", err.Error())
+	}
+
+	violations, err := parseViolations(output)
+	if err != nil {
+		return PolicyVerdict{}, err
+	}
+	if violations == nil {
+		violations = []PolicyViolation{}
+	}
+	return PolicyVerdict{Command: policyCommand, Passed: len(violations) == 0, Violations: violations}, nil
+}
+
+// applyPolicy evaluates the policy against results of a run, records the verdict in the manifest and returns an
+// error with the policy exit code if the policy failed.
+func applyPolicy(manifest *Manifest, results []Result) error {
+	verdict, err := evaluatePolicy(results)
+	if err != nil {
+		return fmt.Errorf("[-] Error evaluating policy: %s\n", err.Error())
+	}
+	manifest.Policy = &verdict
+	if verdict.Passed {
+		log(fmt.Sprintln("[+] Policy passed"))
+		return nil
+	}
+
+	log(fmt.Sprintf("[-] Policy failed with %d violations:\n", len(verdict.Violations)))
+	for _, violation := range verdict.Violations {
+		log(fmt.Sprintf("    %s\n", violation.String()))
+	}
+	return &ExitError{Code: policyExitCode, Err: fmt.Errorf("[-] Policy failed with %d violations\n", len(verdict.Violations))}
+}
@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"time"
+)
+
+// post hook CLI options variables
+var (
+	postHook        string
+	postHookTimeout time.Duration
+)
+
+// HookEvent describes a completed report to a post hook, it is passed through the hook's stdin.
+type HookEvent struct {
+	RunID     string         `json:"RunID"`
+	Cluster   ClusterInfo    `json:"Cluster"`
+	Namespace string         `json:"Namespace"`
+	Pod       string         `json:"Pod"`
+	Container string         `json:"Container"`
+	Workload  string         `json:"Workload,omitempty"`
+	Image     string         `json:"Image,omitempty"`
+	Owner     string         `json:"Owner,omitempty"`
+	Status    string         `json:"Status"`
+	RetCode   int            `json:"RetCode"`
+	Format    string         `json:"Format"`
+	Report    string         `json:"Report,omitempty"`
+	Stderr    string         `json:"Stderr,omitempty"`
+	Findings  map[string]int `json:"Findings"`
+}
+
+// absolutePath returns an absolute path of a file, or an empty string if there is no file.
+func absolutePath(fileName string) string {
+	if fileName == "" {
+		return ""
+	}
+	if absolute, err := filepath.Abs(fileName); err == nil {
+		return absolute
+	}
+	return fileName
+}
+
+// newHookEvent describes a result of a scan to a post hook.
+func newHookEvent(result Result) HookEvent {
+	container := result.container.container
+	return HookEvent{
+		RunID:     runID,
+		Cluster:   cluster,
+		Namespace: scannedNamespace(),
+		Pod:       container.Pod,
+		Container: container.Container,
+		Workload:  container.Workload,
+		Image:     container.Image,
+		Owner:     container.Owner,
+		Status:    result.status(),
+		RetCode:   int(result.retCode),
+		Format:    format,
+		Report:    absolutePath(result.reportFile),
+		Stderr:    absolutePath(result.stderrFile),
+		Findings:  result.findings().CountBySeverity(),
+	}
+}
+
+// runPostHook runs the post hook command with a shell once a report of a container is delivered, e.g. to upload it
+// or open a ticket. The event is passed through the hook's stdin and its main fields through environment variables
+// KUBELSE_*. Output of the hook is passed to stderr of kubelse.
+func runPostHook(result Result) error {
+	event := newHookEvent(result)
+	input, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), postHookTimeout)
+	defer cancel()
+	command := exec.CommandContext(ctx, "sh", "-c", postHook)
+	if runtime.GOOS == "windows" {
+		command = exec.CommandContext(ctx, "cmd", "/C", postHook)
+	}
+	command.Env = append(os.Environ(),
+		"KUBELSE_RUN_ID="+event.RunID,
+		"KUBELSE_NAMESPACE="+event.Namespace,
+		"KUBELSE_POD="+event.Pod,
+		"KUBELSE_CONTAINER="+event.Container,
+		"KUBELSE_STATUS="+event.Status,
+		"KUBELSE_FORMAT="+event.Format,
+		"KUBELSE_REPORT="+event.Report,
+	)
+	command.Stdin, command.Stdout, command.Stderr = bytes.NewReader(input), os.Stderr, os.Stderr
+	if err := command.Run(); err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("post hook timed out after %s", postHookTimeout)
+		}
+		return fmt.Errorf("post hook failed: %s", err.Error())
+	}
+	return nil
+}
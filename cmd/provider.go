@@ -0,0 +1,172 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"github.com/hhruszka/k8sexec"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"regexp"
+	"strings"
+)
+
+// provider CLI options variables
+var provider string
+
+// cluster providers, which kubelse recognizes
+const (
+	ProviderEKS       = "eks"
+	ProviderGKE       = "gke"
+	ProviderAKS       = "aks"
+	ProviderOpenShift = "openshift"
+	ProviderK3s       = "k3s"
+)
+
+// ProviderProfile is escalation context of a cluster provider: hints put into reports, addresses of the node
+// metadata service, which elevate findings mentioning them, and paths excluded from searches of lse.sh by default.
+type ProviderProfile struct {
+	Name  string
+	Hints []string
+	// Metadata matches addresses of the node metadata service in details of findings
+	Metadata *regexp.Regexp
+	// ExcludePaths are excluded from searches of lse.sh in addition to '--exclude-paths'
+	ExcludePaths string
+}
+
+// providerProfiles are profiles of recognized providers by their names
+var providerProfiles = map[string]ProviderProfile{
+	ProviderEKS: {
+		Name: "Amazon EKS",
+		Hints: []string{
+			"pods reaching IMDS (IMDSv1, or IMDSv2 with a hop limit above 1) get credentials of the node IAM role:",
+			"  curl -s http://169.254.169.254/latest/meta-data/iam/security-credentials/",
+			"IRSA and Pod Identity credentials: AWS_WEB_IDENTITY_TOKEN_FILE, AWS_CONTAINER_CREDENTIALS_FULL_URI",
+		},
+		Metadata: regexp.MustCompile(`169\.254\.169\.254|169\.254\.170\.23|fd00:ec2::254`),
+	},
+	ProviderGKE: {
+		Name: "Google GKE",
+		Hints: []string{
+			"pods without Workload Identity get tokens of the node service account from the metadata server:",
+			"  curl -s -H 'Metadata-Flavor: Google' http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token",
+			"legacy metadata endpoints expose kube-env with kubelet bootstrap credentials",
+		},
+		Metadata: regexp.MustCompile(`169\.254\.169\.254|metadata\.google\.internal`),
+	},
+	ProviderAKS: {
+		Name: "Azure AKS",
+		Hints: []string{
+			"pods reaching IMDS get tokens of the kubelet managed identity:",
+			"  curl -s -H 'Metadata: true' 'http://169.254.169.254/metadata/identity/oauth2/token?api-version=2018-02-01&resource=https://management.azure.com/'",
+			"host mounts of /etc/kubernetes expose azure.json with cloud provider credentials",
+		},
+		Metadata: regexp.MustCompile(`169\.254\.169\.254|168\.63\.129\.16`),
+	},
+	ProviderOpenShift: {
+		Name: "OpenShift",
+		Hints: []string{
+			"containers run with random UIDs unless their service account may use the anyuid or privileged SCC:",
+			"  oc adm policy who-can use scc privileged",
+		},
+		ExcludePaths: "/var/lib/containers/storage",
+	},
+	ProviderK3s: {
+		Name: "k3s",
+		Hints: []string{
+			"the k3s data directory holds the server token and the datastore, a host mount of it gives cluster admin:",
+			"  ls -la /var/lib/rancher/k3s/server/token /var/lib/rancher/k3s/server/db",
+		},
+		ExcludePaths: "/var/lib/rancher/k3s/agent/containerd",
+	},
+}
+
+// validateProvider validates a provider given with '--provider'.
+func validateProvider(value string) error {
+	if _, ok := providerProfiles[value]; ok || value == "auto" || value == "none" {
+		return nil
+	}
+	return fmt.Errorf("expected auto, none, %s", strings.Join(sortedKeys(providerNames()), ", "))
+}
+
+// providerNames returns names of recognized providers.
+func providerNames() map[string]bool {
+	names := make(map[string]bool)
+	for name := range providerProfiles {
+		names[name] = true
+	}
+	return names
+}
+
+// detectProvider recognizes a provider of a cluster by its version string, API groups and labels and provider IDs
+// of its nodes. Nodes may not be listable, then providers, which cannot be told by the version, are not recognized.
+func detectProvider(k8s *k8sexec.K8SExec, version string) string {
+	switch {
+	case strings.Contains(version, "-eks-"):
+		return ProviderEKS
+	case strings.Contains(version, "-gke."):
+		return ProviderGKE
+	case strings.Contains(version, "+k3s"):
+		return ProviderK3s
+	}
+	if groups, err := clientsetOf(k8s).Discovery().ServerGroups(); err == nil {
+		for _, group := range groups.Groups {
+			if group.Name == "config.openshift.io" {
+				return ProviderOpenShift
+			}
+		}
+	}
+	nodes, err := clientsetOf(k8s).CoreV1().Nodes().List(context.TODO(), metaV1.ListOptions{Limit: 1})
+	if err != nil || len(nodes.Items) == 0 {
+		return ""
+	}
+	node := nodes.Items[0]
+	for label := range node.Labels {
+		switch {
+		case strings.HasPrefix(label, "eks.amazonaws.com/"):
+			return ProviderEKS
+		case strings.HasPrefix(label, "cloud.google.com/gke-"):
+			return ProviderGKE
+		case strings.HasPrefix(label, "kubernetes.azure.com/"):
+			return ProviderAKS
+		case strings.HasPrefix(label, "node.openshift.io/"):
+			return ProviderOpenShift
+		}
+	}
+	switch {
+	case strings.HasPrefix(node.Spec.ProviderID, "k3s://"):
+		return ProviderK3s
+	case strings.HasPrefix(node.Spec.ProviderID, "azure://"):
+		return ProviderAKS
+	}
+	return ""
+}
+
+// providerOf returns a provider of a cluster: detected with '--provider auto', given with '--provider', or none.
+func providerOf(k8s *k8sexec.K8SExec, version string) string {
+	switch provider {
+	case "auto":
+		return detectProvider(k8s, version)
+	case "none":
+		return ""
+	}
+	return provider
+}
+
+// providerProfile returns a profile of the provider of the scanned cluster, an empty one if it is not recognized.
+func providerProfile() ProviderProfile {
+	return providerProfiles[cluster.Provider]
+}
+
+// elevatedByProvider tells why a positive finding is elevated to critical: its details mention the node metadata
+// service, which exposes cloud credentials of the node.
+func elevatedByProvider(finding Finding) string {
+	profile := providerProfile()
+	if profile.Metadata == nil || finding.Severity == SeverityCritical || !finding.Positive() {
+		return ""
+	}
+	for _, detail := range finding.Details {
+		if profile.Metadata.MatchString(detail) {
+			return fmt.Sprintf("the node metadata service of %s is reachable", profile.Name)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"testing"
+)
+
+func TestDetectProvider(t *testing.T) {
+	_, k8s := startTestCluster(t)
+	for version, expected := range map[string]string{"v1.29.3-eks-adc7111": ProviderEKS, "v1.29.4-gke.1043002": ProviderGKE, "v1.29.3+k3s1": ProviderK3s, "v1.29.3": ""} {
+		if detected := detectProvider(k8s, version); detected != expected {
+			t.Errorf("expected %q detected from %s, got %q", expected, version, detected)
+		}
+	}
+
+	node := &corev1.Node{ObjectMeta: metaV1.ObjectMeta{Name: "aks-nodepool1-0", Labels: map[string]string{"kubernetes.azure.com/cluster": "MC_rg_aks"}}}
+	_, k8s = startTestCluster(t, node)
+	if detected := detectProvider(k8s, "v1.29.3"); detected != ProviderAKS {
+		t.Errorf("expected AKS detected from node labels, got %q", detected)
+	}
+}
+
+func TestProviderElevatesMetadataFindings(t *testing.T) {
+	previous := cluster
+	t.Cleanup(func() { cluster = previous })
+
+	lines := []string{
+		"=====================( network )=====================",
+		"[*] net000 Services listening only on localhost............. yes!",
+		"---",
+		"tcp LISTEN 0 4096 127.0.0.1:8080 0.0.0.0:*",
+		"---",
+		"[*] net010 Can we sniff traffic with tcpdump?................ yes!",
+		"---",
+		"default via 10.0.0.1 dev eth0; 169.254.169.254 via 10.0.0.1 dev eth0",
+		"---",
+	}
+	result := Result{scanReport: lines}
+
+	cluster = ClusterInfo{Provider: ProviderEKS}
+	if elevated := result.elevatedFindings(); len(elevated) != 1 || elevated[0] != "net010" {
+		t.Errorf("expected net010 elevated on EKS, got %v", elevated)
+	}
+	if counts := result.findings().CountBySeverity(); counts[SeverityCritical] != 1 || counts[SeverityInteresting] != 1 {
+		t.Errorf("unexpected counts of elevated findings %v", counts)
+	}
+	cluster = ClusterInfo{Provider: ProviderOpenShift}
+	if elevated := result.elevatedFindings(); len(elevated) != 0 {
+		t.Errorf("expected no findings elevated on OpenShift, got %v", elevated)
+	}
+}
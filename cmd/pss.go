@@ -0,0 +1,207 @@
+package cmd
+
+import (
+	"fmt"
+	corev1 "k8s.io/api/core/v1"
+	"strings"
+)
+
+// Pod Security Standards levels, see https://kubernetes.io/docs/concepts/security/pod-security-standards/
+const (
+	PSSPrivileged = "privileged"
+	PSSBaseline   = "baseline"
+	PSSRestricted = "restricted"
+)
+
+// PSSViolation is a violation of a Pod Security Standards policy. Level is the policy that is violated, i.e.
+// a pod violating a baseline policy is privileged and a pod violating a restricted policy is at most baseline.
+type PSSViolation struct {
+	Level   string `json:"Level"`
+	Check   string `json:"Check"`
+	Message string `json:"Message"`
+}
+
+// PSSResult is an outcome of evaluating a pod against the Pod Security Standards.
+type PSSResult struct {
+	Level      string         `json:"Level"`
+	Violations []PSSViolation `json:"Violations,omitempty"`
+}
+
+var (
+	// capabilities that can be added by baseline pods
+	pssBaselineCapabilities = []string{"AUDIT_WRITE", "CHOWN", "DAC_OVERRIDE", "FOWNER", "FSETID", "KILL", "MKNOD",
+		"NET_BIND_SERVICE", "SETFCAP", "SETGID", "SETPCAP", "SETUID", "SYS_CHROOT"}
+	// sysctls that can be set by baseline pods
+	pssSafeSysctls = []string{"kernel.shm_rmid_forced", "net.ipv4.ip_local_port_range", "net.ipv4.ip_unprivileged_port_start",
+		"net.ipv4.tcp_syncookies", "net.ipv4.ping_group_range", "net.ipv4.ip_local_reserved_ports",
+		"net.ipv4.tcp_keepalive_time", "net.ipv4.tcp_fin_timeout", "net.ipv4.tcp_keepalive_intvl", "net.ipv4.tcp_keepalive_probes"}
+	// SELinux types that can be used by baseline pods
+	pssSELinuxTypes = []string{"", "container_t", "container_init_t", "container_kvm_t"}
+)
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// podContainers returns all containers of a pod, i.e. init, regular and ephemeral ones, as generic containers.
+func podContainers(pod *corev1.Pod) []corev1.Container {
+	containers := append([]corev1.Container{}, pod.Spec.InitContainers...)
+	containers = append(containers, pod.Spec.Containers...)
+	for _, ephemeral := range pod.Spec.EphemeralContainers {
+		containers = append(containers, corev1.Container(ephemeral.EphemeralContainerCommon))
+	}
+	return containers
+}
+
+// evaluatePSS evaluates a pod against the baseline and restricted Pod Security Standards policies and returns
+// the most restrictive level the pod satisfies together with all violations.
+func evaluatePSS(pod *corev1.Pod) PSSResult {
+	var violations []PSSViolation
+	violate := func(level, check, format string, args ...interface{}) {
+		violations = append(violations, PSSViolation{Level: level, Check: check, Message: fmt.Sprintf(format, args...)})
+	}
+
+	spec := pod.Spec
+	podSC := spec.SecurityContext
+	if podSC == nil {
+		podSC = &corev1.PodSecurityContext{}
+	}
+
+	// baseline: pod level checks
+	if spec.HostNetwork {
+		violate(PSSBaseline, "Host Namespaces", "hostNetwork is true")
+	}
+	if spec.HostPID {
+		violate(PSSBaseline, "Host Namespaces", "hostPID is true")
+	}
+	if spec.HostIPC {
+		violate(PSSBaseline, "Host Namespaces", "hostIPC is true")
+	}
+	if podSC.WindowsOptions != nil && podSC.WindowsOptions.HostProcess != nil && *podSC.WindowsOptions.HostProcess {
+		violate(PSSBaseline, "HostProcess", "pod is a Windows HostProcess pod")
+	}
+	for _, volume := range spec.Volumes {
+		if volume.HostPath != nil {
+			violate(PSSBaseline, "HostPath Volumes", "volume %q mounts host path %s", volume.Name, volume.HostPath.Path)
+		}
+	}
+	for _, sysctl := range podSC.Sysctls {
+		if !contains(pssSafeSysctls, sysctl.Name) {
+			violate(PSSBaseline, "Sysctls", "unsafe sysctl %s", sysctl.Name)
+		}
+	}
+	if podSC.SeccompProfile != nil && podSC.SeccompProfile.Type == corev1.SeccompProfileTypeUnconfined {
+		violate(PSSBaseline, "Seccomp", "pod seccomp profile is Unconfined")
+	}
+	if opts := podSC.SELinuxOptions; opts != nil {
+		if !contains(pssSELinuxTypes, opts.Type) || opts.User != "" || opts.Role != "" {
+			violate(PSSBaseline, "SELinux", "pod sets custom SELinux options")
+		}
+	}
+	for key, value := range pod.Annotations {
+		if strings.HasPrefix(key, "container.apparmor.security.beta.kubernetes.io/") && value != "runtime/default" && !strings.HasPrefix(value, "localhost/") {
+			violate(PSSBaseline, "AppArmor", "%s is %s", key, value)
+		}
+	}
+
+	// restricted: pod level checks
+	for _, volume := range spec.Volumes {
+		src := volume.VolumeSource
+		if src.ConfigMap == nil && src.CSI == nil && src.DownwardAPI == nil && src.EmptyDir == nil && src.Ephemeral == nil &&
+			src.PersistentVolumeClaim == nil && src.Projected == nil && src.Secret == nil {
+			violate(PSSRestricted, "Volume Types", "volume %q uses a restricted volume type", volume.Name)
+		}
+	}
+
+	for _, container := range podContainers(pod) {
+		sc := container.SecurityContext
+		if sc == nil {
+			sc = &corev1.SecurityContext{}
+		}
+
+		// baseline: container level checks
+		if sc.Privileged != nil && *sc.Privileged {
+			violate(PSSBaseline, "Privileged Containers", "container %q is privileged", container.Name)
+		}
+		if sc.WindowsOptions != nil && sc.WindowsOptions.HostProcess != nil && *sc.WindowsOptions.HostProcess {
+			violate(PSSBaseline, "HostProcess", "container %q is a Windows HostProcess container", container.Name)
+		}
+		if sc.Capabilities != nil {
+			for _, capability := range sc.Capabilities.Add {
+				if !contains(pssBaselineCapabilities, string(capability)) {
+					violate(PSSBaseline, "Capabilities", "container %q adds capability %s", container.Name, capability)
+				}
+			}
+		}
+		for _, port := range container.Ports {
+			if port.HostPort != 0 {
+				violate(PSSBaseline, "Host Ports", "container %q uses host port %d", container.Name, port.HostPort)
+			}
+		}
+		if sc.ProcMount != nil && *sc.ProcMount != corev1.DefaultProcMount {
+			violate(PSSBaseline, "/proc Mount Type", "container %q uses %s proc mount", container.Name, *sc.ProcMount)
+		}
+		if sc.SeccompProfile != nil && sc.SeccompProfile.Type == corev1.SeccompProfileTypeUnconfined {
+			violate(PSSBaseline, "Seccomp", "container %q seccomp profile is Unconfined", container.Name)
+		}
+		if opts := sc.SELinuxOptions; opts != nil {
+			if !contains(pssSELinuxTypes, opts.Type) || opts.User != "" || opts.Role != "" {
+				violate(PSSBaseline, "SELinux", "container %q sets custom SELinux options", container.Name)
+			}
+		}
+
+		// restricted: container level checks
+		if sc.AllowPrivilegeEscalation == nil || *sc.AllowPrivilegeEscalation {
+			violate(PSSRestricted, "Privilege Escalation", "container %q does not set allowPrivilegeEscalation to false", container.Name)
+		}
+		runAsNonRoot := podSC.RunAsNonRoot != nil && *podSC.RunAsNonRoot
+		if sc.RunAsNonRoot != nil {
+			runAsNonRoot = *sc.RunAsNonRoot
+		}
+		if !runAsNonRoot {
+			violate(PSSRestricted, "Running as Non-root", "container %q does not set runAsNonRoot to true", container.Name)
+		}
+		if (sc.RunAsUser != nil && *sc.RunAsUser == 0) || (sc.RunAsUser == nil && podSC.RunAsUser != nil && *podSC.RunAsUser == 0) {
+			violate(PSSRestricted, "Running as Non-root user", "container %q runs as user 0", container.Name)
+		}
+		seccomp := podSC.SeccompProfile
+		if sc.SeccompProfile != nil {
+			seccomp = sc.SeccompProfile
+		}
+		if seccomp == nil || (seccomp.Type != corev1.SeccompProfileTypeRuntimeDefault && seccomp.Type != corev1.SeccompProfileTypeLocalhost) {
+			violate(PSSRestricted, "Seccomp", "container %q does not use RuntimeDefault or Localhost seccomp profile", container.Name)
+		}
+		dropsAll := false
+		if sc.Capabilities != nil {
+			for _, capability := range sc.Capabilities.Drop {
+				if capability == "ALL" {
+					dropsAll = true
+				}
+			}
+			for _, capability := range sc.Capabilities.Add {
+				if capability != "NET_BIND_SERVICE" {
+					violate(PSSRestricted, "Capabilities", "container %q adds capability %s", container.Name, capability)
+				}
+			}
+		}
+		if !dropsAll {
+			violate(PSSRestricted, "Capabilities", "container %q does not drop ALL capabilities", container.Name)
+		}
+	}
+
+	result := PSSResult{Level: PSSRestricted, Violations: violations}
+	for _, violation := range violations {
+		switch {
+		case violation.Level == PSSBaseline:
+			result.Level = PSSPrivileged
+		case violation.Level == PSSRestricted && result.Level == PSSRestricted:
+			result.Level = PSSBaseline
+		}
+	}
+	return result
+}
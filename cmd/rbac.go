@@ -0,0 +1,200 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"github.com/spf13/cobra"
+	rbacV1 "k8s.io/api/rbac/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+	"strings"
+)
+
+// generate rbac CLI options variables
+var (
+	rbacNamespaces     string
+	rbacName           string
+	rbacServiceAccount string
+	rbacUser           string
+	rbacGroup          string
+	rbacHelm           bool
+	rbacPullSecrets    bool
+	rbacMetrics        bool
+)
+
+// rbacFeatures are optional features of scans, which require access beyond pods and exec.
+type rbacFeatures struct {
+	// Helm lists workloads to find pods of Helm releases with '--helm-release'
+	Helm bool
+	// NetworkPolicies lists network policies with '--network-policies'
+	NetworkPolicies bool
+	// Events creates events on scanned pods with '--events'
+	Events bool
+	// PullSecrets reads image pull secrets with '--pull-secrets'
+	PullSecrets bool
+	// Metrics reads usage of pods from the metrics API with '--max-cpu' or '--max-memory'
+	Metrics bool
+}
+
+// rbacScanRules returns rules a scan of pods of a namespace requires: pods are listed and read, scripts are run
+// with exec and PodDisruptionBudgets are listed to find critical workloads, and rules of enabled features.
+func rbacScanRules(features rbacFeatures) []rbacV1.PolicyRule {
+	rules := []rbacV1.PolicyRule{
+		{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"get", "list"}},
+		{APIGroups: []string{""}, Resources: []string{"pods/exec"}, Verbs: []string{"create"}},
+		{APIGroups: []string{"policy"}, Resources: []string{"poddisruptionbudgets"}, Verbs: []string{"list"}},
+	}
+	if features.Helm {
+		rules = append(rules, rbacV1.PolicyRule{APIGroups: []string{"apps"}, Resources: []string{"deployments", "statefulsets", "daemonsets"}, Verbs: []string{"list"}})
+	}
+	if features.NetworkPolicies {
+		rules = append(rules, rbacV1.PolicyRule{APIGroups: []string{"networking.k8s.io"}, Resources: []string{"networkpolicies"}, Verbs: []string{"list"}})
+	}
+	if features.Events {
+		rules = append(rules, rbacV1.PolicyRule{APIGroups: []string{""}, Resources: []string{"events"}, Verbs: []string{"create"}})
+	}
+	if features.PullSecrets {
+		rules = append(rules, rbacV1.PolicyRule{APIGroups: []string{""}, Resources: []string{"secrets"}, Verbs: []string{"get"}})
+	}
+	if features.Metrics {
+		rules = append(rules, rbacV1.PolicyRule{APIGroups: []string{"metrics.k8s.io"}, Resources: []string{"pods"}, Verbs: []string{"get"}})
+	}
+	return rules
+}
+
+// rbacSubject returns the subject access is granted to.
+func rbacSubject() (rbacV1.Subject, error) {
+	set := 0
+	for _, value := range []string{rbacServiceAccount, rbacUser, rbacGroup} {
+		if value != "" {
+			set++
+		}
+	}
+	if set > 1 {
+		return rbacV1.Subject{}, errors.New("Only one of the options '--service-account', '--user' and '--group' can be provided")
+	}
+
+	switch {
+	case rbacServiceAccount != "":
+		ns, name, found := strings.Cut(rbacServiceAccount, "/")
+		if !found || ns == "" || name == "" {
+			return rbacV1.Subject{}, fmt.Errorf("A service account has to be provided as <namespace>/<name>, got %q", rbacServiceAccount)
+		}
+		return rbacV1.Subject{Kind: rbacV1.ServiceAccountKind, Namespace: ns, Name: name}, nil
+	case rbacGroup != "":
+		return rbacV1.Subject{Kind: rbacV1.GroupKind, APIGroup: rbacV1.GroupName, Name: rbacGroup}, nil
+	default:
+		return rbacV1.Subject{Kind: rbacV1.UserKind, APIGroup: rbacV1.GroupName, Name: valueOrDefault(rbacUser, "kubelse")}, nil
+	}
+}
+
+// rbacObjects returns RBAC resources granting the least privileges a scan of namespaces requires: a ClusterRole
+// with the scan rules bound in every namespace by a RoleBinding, so that nothing outside of the namespaces is
+// accessible, and a ClusterRole allowing to read only these namespaces, which are checked before scans.
+func rbacObjects(namespaces []string, subject rbacV1.Subject, features rbacFeatures) []interface{} {
+	typeMeta := func(kind string) metaV1.TypeMeta {
+		return metaV1.TypeMeta{APIVersion: rbacV1.SchemeGroupVersion.String(), Kind: kind}
+	}
+	subjects := []rbacV1.Subject{subject}
+	namespacesRole := rbacName + "-namespaces"
+
+	objects := []interface{}{
+		&rbacV1.ClusterRole{
+			TypeMeta:   typeMeta("ClusterRole"),
+			ObjectMeta: metaV1.ObjectMeta{Name: rbacName},
+			Rules:      rbacScanRules(features),
+		},
+		&rbacV1.ClusterRole{
+			TypeMeta:   typeMeta("ClusterRole"),
+			ObjectMeta: metaV1.ObjectMeta{Name: namespacesRole},
+			Rules:      []rbacV1.PolicyRule{{APIGroups: []string{""}, Resources: []string{"namespaces"}, ResourceNames: namespaces, Verbs: []string{"get"}}},
+		},
+		&rbacV1.ClusterRoleBinding{
+			TypeMeta:   typeMeta("ClusterRoleBinding"),
+			ObjectMeta: metaV1.ObjectMeta{Name: namespacesRole},
+			RoleRef:    rbacV1.RoleRef{APIGroup: rbacV1.GroupName, Kind: "ClusterRole", Name: namespacesRole},
+			Subjects:   subjects,
+		},
+	}
+	for _, ns := range namespaces {
+		objects = append(objects, &rbacV1.RoleBinding{
+			TypeMeta:   typeMeta("RoleBinding"),
+			ObjectMeta: metaV1.ObjectMeta{Name: rbacName, Namespace: ns},
+			RoleRef:    rbacV1.RoleRef{APIGroup: rbacV1.GroupName, Kind: "ClusterRole", Name: rbacName},
+			Subjects:   subjects,
+		})
+	}
+	return objects
+}
+
+// rbacYAML renders RBAC resources as a multi-document YAML.
+func rbacYAML(objects []interface{}) (string, error) {
+	var documents []string
+	for _, object := range objects {
+		document, err := yaml.Marshal(object)
+		if err != nil {
+			return "", err
+		}
+		// the timestamp is set by the API server
+		documents = append(documents, strings.Replace(string(document), "  creationTimestamp: null\n", "", 1))
+	}
+	return strings.Join(documents, "---\n"), nil
+}
+
+var generateCmd = &cobra.Command{
+	Use:   "generate",
+	Short: "Generate resources kubelse needs",
+}
+
+var generateRBACCmd = &cobra.Command{
+	Use:   "rbac --namespaces <ns1>,<ns2>",
+	Short: "Print the minimal RBAC resources required to scan namespaces",
+	Long: `
+Prints YAML of the minimal RBAC resources required to scan pods of given namespaces, so that exactly the right
+access can be requested and granted, e.g. 'kubelse generate rbac --namespaces a,b | kubectl apply -f -'.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var namespaces []string
+		for _, ns := range strings.Split(rbacNamespaces, ",") {
+			if ns = strings.TrimSpace(ns); ns != "" {
+				namespaces = append(namespaces, ns)
+			}
+		}
+		if len(namespaces) == 0 {
+			return errors.New("Namespaces to be scanned have to be provided with '--namespaces'")
+		}
+		subject, err := rbacSubject()
+		if err != nil {
+			return err
+		}
+
+		output, err := rbacYAML(rbacObjects(namespaces, subject, rbacFeatures{
+			Helm:            rbacHelm,
+			NetworkPolicies: networkPolicies,
+			Events:          events,
+			PullSecrets:     rbacPullSecrets,
+			Metrics:         rbacMetrics,
+		}))
+		if err != nil {
+			return fmt.Errorf("[-] Error generating RBAC resources: %s\n", err.Error())
+		}
+		fmt.Print(output)
+		return nil
+	},
+}
+
+func init() {
+	generateRBACCmd.Flags().StringVar(&rbacNamespaces, "namespaces", "", "comma-separated namespaces to be scanned")
+	generateRBACCmd.Flags().StringVar(&rbacName, "name", "kubelse", "a name of the generated roles and bindings")
+	generateRBACCmd.Flags().StringVar(&rbacServiceAccount, "service-account", "", "a service account to be granted access, as <namespace>/<name>")
+	generateRBACCmd.Flags().StringVar(&rbacUser, "user", "", "a user to be granted access, the default if no subject is provided is the user kubelse")
+	generateRBACCmd.Flags().StringVar(&rbacGroup, "group", "", "a group to be granted access")
+	generateRBACCmd.Flags().BoolVar(&rbacHelm, "helm-release", false, "grant access needed to scan Helm releases with '--helm-release'")
+	generateRBACCmd.Flags().BoolVar(&networkPolicies, "network-policies", false, "grant access needed to check network policies with '--network-policies'")
+	generateRBACCmd.Flags().BoolVar(&events, "events", false, "grant access needed to emit events on scanned pods with '--events'")
+	generateRBACCmd.Flags().BoolVar(&rbacPullSecrets, "pull-secrets", false, "grant access needed to read image pull secrets with '--pull-secrets'")
+	generateRBACCmd.Flags().BoolVar(&rbacMetrics, "metrics", false, "grant access needed to sample usage of containers with '--max-cpu' or '--max-memory'")
+
+	generateCmd.AddCommand(generateRBACCmd)
+	cmd.AddCommand(generateCmd)
+}
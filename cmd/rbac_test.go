@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	rbacV1 "k8s.io/api/rbac/v1"
+	"strings"
+	"testing"
+)
+
+func TestGeneratedRBACIsScopedToNamespaces(t *testing.T) {
+	rbacName = "kubelse"
+	subject := rbacV1.Subject{Kind: rbacV1.ServiceAccountKind, Namespace: "security", Name: "scanner"}
+	output, err := rbacYAML(rbacObjects([]string{"a", "b"}, subject, rbacFeatures{NetworkPolicies: true}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, expected := range []string{
+		"kind: ClusterRole\n",
+		"- pods/exec\n",
+		"- networkpolicies\n",
+		"- poddisruptionbudgets\n",
+		"  resourceNames:\n  - a\n  - b\n",
+		"kind: RoleBinding\nmetadata:\n  name: kubelse\n  namespace: a\n",
+		"kind: RoleBinding\nmetadata:\n  name: kubelse\n  namespace: b\n",
+	} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("generated RBAC does not contain %q:\n%s", expected, output)
+		}
+	}
+	for _, unexpected := range []string{"deployments", "creationTimestamp", "kind: ClusterRoleBinding\nmetadata:\n  name: kubelse\n"} {
+		if strings.Contains(output, unexpected) {
+			t.Errorf("generated RBAC contains %q:\n%s", unexpected, output)
+		}
+	}
+	if got := strings.Count(output, "---\n"); got != 4 {
+		t.Errorf("expected 5 documents, got %d", got+1)
+	}
+}
@@ -0,0 +1,294 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/hhruszka/k8sexec"
+	corev1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// registry credentials CLI options variables
+var (
+	dockerConfig   string
+	registryConfig string
+	pullSecrets    bool
+)
+
+// RegistrySettings are settings of a registry in the '--registry-config' file, by the registry host, e.g.
+// registry.example.com:5000.
+type RegistrySettings struct {
+	// Mirror is a host images of the registry are read from instead, e.g. a pull-through cache
+	Mirror string `json:"Mirror"`
+	// PlainHTTP reads images without TLS, e.g. from a registry in the cluster
+	PlainHTTP          bool   `json:"PlainHTTP"`
+	CAFile             string `json:"CAFile"`
+	InsecureSkipVerify bool   `json:"InsecureSkipVerify"`
+	Username           string `json:"Username"`
+	// PasswordEnv is an environment variable the password, or a token, is read from, so that it is not kept in
+	// the file
+	PasswordEnv string `json:"PasswordEnv"`
+}
+
+// registrySettings are settings of registries by their hosts, read from '--registry-config'
+var registrySettings map[string]RegistrySettings
+
+// dockerCredentials are credentials of docker config.json, nil if there is none
+var dockerCredentials *dockerConfigFile
+
+// registryAuth is a credential of a registry and where it comes from, e.g. a pull secret.
+type registryAuth struct {
+	username string
+	password string
+	source   string
+}
+
+// dockerAuth is a credential of a registry in docker config.json or a pull secret.
+type dockerAuth struct {
+	Auth     string `json:"auth"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// dockerConfigFile is docker config.json, or .dockerconfigjson of a pull secret.
+type dockerConfigFile struct {
+	Auths       map[string]dockerAuth `json:"auths"`
+	CredHelpers map[string]string     `json:"credHelpers"`
+	CredsStore  string                `json:"credsStore"`
+}
+
+// normalizeRegistry returns a registry host of a key of docker config.json, e.g. registry-1.docker.io of
+// https://index.docker.io/v1/, the host images of Docker Hub are read from.
+func normalizeRegistry(key string) string {
+	if _, rest, ok := strings.Cut(key, "://"); ok {
+		key = rest
+	}
+	host, _, _ := strings.Cut(key, "/")
+	switch host {
+	case "docker.io", "index.docker.io":
+		return "registry-1.docker.io"
+	}
+	return host
+}
+
+// auth returns a credential of a registry: an entry of auths, or one returned by a credential helper.
+func (c *dockerConfigFile) auth(host string, source string) (*registryAuth, error) {
+	for key, entry := range c.Auths {
+		if normalizeRegistry(key) != host {
+			continue
+		}
+		username, password := entry.Username, entry.Password
+		if entry.Auth != "" {
+			decoded, err := base64.StdEncoding.DecodeString(entry.Auth)
+			if err != nil {
+				return nil, fmt.Errorf("invalid auth of %s in %s", key, source)
+			}
+			username, password, _ = strings.Cut(string(decoded), ":")
+		}
+		if username != "" || password != "" {
+			return &registryAuth{username: username, password: password, source: source}, nil
+		}
+	}
+	helper := c.CredsStore
+	for key, name := range c.CredHelpers {
+		if normalizeRegistry(key) == host {
+			helper = name
+		}
+	}
+	if helper == "" {
+		return nil, nil
+	}
+	return credentialHelper(helper, host)
+}
+
+// credentialHelper returns a credential of a registry from a docker credential helper, e.g.
+// docker-credential-ecr-login, or nil if the helper has none.
+func credentialHelper(helper string, host string) (*registryAuth, error) {
+	server := host
+	if host == "registry-1.docker.io" {
+		server = "https://index.docker.io/v1/"
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	command := exec.CommandContext(ctx, "docker-credential-"+helper, "get")
+	command.Stdin = strings.NewReader(server)
+	output, err := command.Output()
+	if err != nil {
+		// helpers exit with an error when they have no credential of the registry
+		if bytes.Contains(output, []byte("credentials not found")) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("docker-credential-%s failed for %s: %s", helper, host, err.Error())
+	}
+	var credential struct {
+		Username string `json:"Username"`
+		Secret   string `json:"Secret"`
+	}
+	if err := json.Unmarshal(output, &credential); err != nil {
+		return nil, fmt.Errorf("invalid output of docker-credential-%s: %s", helper, err.Error())
+	}
+	return &registryAuth{username: credential.Username, password: credential.Secret, source: "docker-credential-" + helper}, nil
+}
+
+// loadDockerConfig reads docker config.json: '--docker-config', or config.json in DOCKER_CONFIG or ~/.docker,
+// which does not have to exist.
+func loadDockerConfig(file string) error {
+	dockerCredentials = nil
+	explicit := file != ""
+	if !explicit {
+		dir := os.Getenv("DOCKER_CONFIG")
+		if dir == "" {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return nil
+			}
+			dir = filepath.Join(home, ".docker")
+		}
+		file = filepath.Join(dir, "config.json")
+	}
+	content, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) && !explicit {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	config := &dockerConfigFile{}
+	if err := json.Unmarshal(content, config); err != nil {
+		return fmt.Errorf("invalid docker config %s: %s", file, err.Error())
+	}
+	dockerCredentials = config
+	return nil
+}
+
+// loadRegistryConfig reads settings of registries from a json file.
+func loadRegistryConfig(file string) error {
+	registrySettings = nil
+	if file == "" {
+		return nil
+	}
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(content, &registrySettings); err != nil {
+		return fmt.Errorf("invalid registry configuration %s: %s", file, err.Error())
+	}
+	for host, settings := range registrySettings {
+		if settings.PasswordEnv != "" && settings.Username == "" {
+			return fmt.Errorf("a Username of %s has to be provided together with its PasswordEnv", host)
+		}
+		if settings.CAFile != "" {
+			if _, err := os.Stat(settings.CAFile); err != nil {
+				return fmt.Errorf("invalid CAFile of %s: %s", host, err.Error())
+			}
+		}
+	}
+	return nil
+}
+
+// registryHTTPClient returns an HTTP client of a registry, trusting its CA with '--registry-config'.
+func (c *registryClient) registryHTTPClient(host string) (*http.Client, error) {
+	settings := registrySettings[host]
+	if settings.CAFile == "" && !settings.InsecureSkipVerify {
+		return c.http, nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if client, ok := c.clients[host]; ok {
+		return client, nil
+	}
+	tlsConfig := &tls.Config{InsecureSkipVerify: settings.InsecureSkipVerify}
+	if settings.CAFile != "" {
+		pem, err := os.ReadFile(settings.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates in CAFile %s of %s", settings.CAFile, host)
+		}
+	}
+	transport := &http.Transport{Proxy: http.ProxyFromEnvironment}
+	if defaultTransport, ok := http.DefaultTransport.(*http.Transport); ok {
+		transport = defaultTransport.Clone()
+	}
+	transport.TLSClientConfig = tlsConfig
+	client := &http.Client{Timeout: c.http.Timeout, Transport: transport}
+	c.clients[host] = client
+	return client, nil
+}
+
+// registryURL returns a URL of the API of a registry, or of its mirror.
+func registryURL(host string) string {
+	settings := registrySettings[host]
+	scheme := "https://"
+	if settings.PlainHTTP {
+		scheme = "http://"
+	}
+	return scheme + valueOrDefault(settings.Mirror, host) + "/v2/"
+}
+
+// pullSecretAuth returns a credential of a registry from image pull secrets of a pod, which are read once.
+func (c *registryClient) pullSecretAuth(k8s *k8sexec.K8SExec, names []string, host string) (*registryAuth, error) {
+	for _, name := range names {
+		key := k8s.Namespace + "/" + name
+		c.mu.Lock()
+		config, ok := c.secrets[key]
+		c.mu.Unlock()
+		if !ok {
+			secret, err := clientsetOf(k8s).CoreV1().Secrets(k8s.Namespace).Get(context.TODO(), name, metaV1.GetOptions{})
+			if err != nil {
+				return nil, fmt.Errorf("cannot read the image pull secret %s: %s", key, err.Error())
+			}
+			config = &dockerConfigFile{}
+			switch secret.Type {
+			case corev1.SecretTypeDockerConfigJson:
+				err = json.Unmarshal(secret.Data[corev1.DockerConfigJsonKey], config)
+			case corev1.SecretTypeDockercfg:
+				err = json.Unmarshal(secret.Data[corev1.DockerConfigKey], &config.Auths)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("invalid image pull secret %s: %s", key, err.Error())
+			}
+			// helpers of the machine kubelse runs on are not used for credentials of pods
+			config.CredHelpers, config.CredsStore = nil, ""
+			c.mu.Lock()
+			c.secrets[key] = config
+			c.mu.Unlock()
+		}
+		if auth, err := config.auth(host, "pull secret "+key); auth != nil || err != nil {
+			return auth, err
+		}
+	}
+	return nil, nil
+}
+
+// registryCredentials returns a credential to read an image of a container from its registry: from image pull
+// secrets of its pod with '--pull-secrets', '--registry-config' or docker config.json, nil to read it anonymously.
+func (c *registryClient) registryCredentials(k8s *k8sexec.K8SExec, container Container, host string) (*registryAuth, error) {
+	if pullSecrets && len(container.Runtime.PullSecrets) > 0 {
+		if auth, err := c.pullSecretAuth(k8s, container.Runtime.PullSecrets, host); auth != nil || err != nil {
+			return auth, err
+		}
+	}
+	if settings := registrySettings[host]; settings.Username != "" {
+		return &registryAuth{username: settings.Username, password: os.Getenv(settings.PasswordEnv), source: "--registry-config"}, nil
+	}
+	if dockerCredentials != nil {
+		return dockerCredentials.auth(host, "docker config")
+	}
+	return nil, nil
+}
@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"fmt"
+	"k8slse/data"
+	"os"
+	"sigs.k8s.io/yaml"
+)
+
+// remediation CLI options variables
+var remediationFile string
+
+// remediations is guidance on fixing findings of lse.sh tests by test ID, the embedded catalog extended with
+// overrides given with '--remediation'
+var remediations = mustParseRemediations(data.GetRemediation())
+
+// parseRemediations parses a catalog of remediation guidance, a YAML map of test IDs to guidance.
+func parseRemediations(content []byte) (map[string]string, error) {
+	catalog := make(map[string]string)
+	if err := yaml.Unmarshal(content, &catalog); err != nil {
+		return nil, err
+	}
+	for id := range catalog {
+		if err := validateSections(id); err != nil {
+			return nil, fmt.Errorf("invalid test ID %q", id)
+		}
+	}
+	return catalog, nil
+}
+
+// mustParseRemediations parses the embedded catalog, which is validated by tests.
+func mustParseRemediations(content []byte) map[string]string {
+	catalog, err := parseRemediations(content)
+	if err != nil {
+		panic(err)
+	}
+	return catalog
+}
+
+// embeddedRemediations returns the embedded catalog with guidance translated into '--lang', where there is any.
+func embeddedRemediations() map[string]string {
+	catalog := mustParseRemediations(data.GetRemediation())
+	if localized := data.GetLocalizedRemediation(lang); messages != nil && localized != nil {
+		for id, guidance := range mustParseRemediations(localized) {
+			catalog[id] = guidance
+		}
+	}
+	return catalog
+}
+
+// loadRemediations overrides and extends the embedded catalog with guidance from a YAML file, e.g. pointing to
+// internal hardening standards. An empty guidance removes the embedded one.
+func loadRemediations(file string) error {
+	remediations = embeddedRemediations()
+	if file == "" {
+		return nil
+	}
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	overrides, err := parseRemediations(content)
+	if err != nil {
+		return err
+	}
+	for id, guidance := range overrides {
+		remediations[id] = guidance
+	}
+	return nil
+}
+
+// remediationOf returns guidance on fixing a finding of a test, if there is any.
+func remediationOf(id string) string {
+	return remediations[id]
+}
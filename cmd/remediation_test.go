@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"k8slse/data"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRemediationCatalog(t *testing.T) {
+	catalog, err := parseRemediations(data.GetRemediation())
+	if err != nil {
+		t.Fatalf("invalid embedded catalog: %s", err.Error())
+	}
+	if catalog["fst010"] == "" || catalog["sud000"] == "" {
+		t.Errorf("expected guidance of fst010 and sud000 in the embedded catalog")
+	}
+
+	file := filepath.Join(t.TempDir(), "remediation.yaml")
+	os.WriteFile(file, []byte("fst010: See https://wiki.example.com/hardening#setuid\nusr000: Nothing to do.\nsud000: ''\n"), 0644)
+	if err := loadRemediations(file); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { loadRemediations("") })
+	if remediationOf("fst010") != "See https://wiki.example.com/hardening#setuid" || remediationOf("usr000") != "Nothing to do." || remediationOf("sud000") != "" || remediationOf("fst000") != catalog["fst000"] {
+		t.Errorf("overrides were not applied to the catalog")
+	}
+
+	report := parseReport(lseOutput)
+	for _, finding := range report.Findings {
+		if finding.Positive() != (finding.Remediation != "") {
+			t.Errorf("expected remediation of positive findings only, got %+v", finding)
+		}
+	}
+	if page := string(renderHTMLReport(lseOutput)); !strings.Contains(page, `<div class="remediation">Remediation: See https://wiki.example.com/hardening#setuid</div>`) {
+		t.Errorf("expected remediation in the html report")
+	}
+
+	os.WriteFile(file, []byte("not-a-test: guidance\n"), 0644)
+	if err := loadRemediations(file); err == nil {
+		t.Errorf("expected an error for an invalid test ID")
+	}
+}
@@ -0,0 +1,206 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/spf13/cobra"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// remote CLI options variables
+var (
+	remoteServer string
+	remoteCAFile string
+	remoteWait   bool
+	remotePoll   time.Duration
+)
+
+// remoteClient calls the scan API of a central kubelse deployment.
+type remoteClient struct {
+	server string
+	token  string
+	client *http.Client
+}
+
+// newRemoteClient creates a client of the scan API, the token is read from the environment.
+func newRemoteClient() (*remoteClient, error) {
+	if err := requireOnline("kubelse remote"); err != nil {
+		return nil, err
+	}
+	if remoteServer == "" {
+		return nil, errors.New("A URL of the kubelse server has to be provided with '--server'")
+	}
+	token := os.Getenv(apiTokenVariable)
+	if token == "" {
+		return nil, fmt.Errorf("A token of the kubelse server has to be provided with the %s environment variable", apiTokenVariable)
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if remoteCAFile != "" {
+		pem, err := os.ReadFile(remoteCAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("No certificates found in %s", remoteCAFile)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+	return &remoteClient{server: strings.TrimSuffix(remoteServer, "/"), token: token, client: &http.Client{Transport: transport, Timeout: 30 * time.Second}}, nil
+}
+
+// do calls the scan API and decodes its json response.
+func (c *remoteClient) do(method string, path string, body interface{}, result interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		content, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(content)
+	}
+	req, err := http.NewRequest(method, c.server+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		var apiError struct{ Error string }
+		json.NewDecoder(resp.Body).Decode(&apiError)
+		return fmt.Errorf("%s: %s", resp.Status, valueOrDefault(apiError.Error, "no details"))
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+// requestScan asks the server to scan a namespace.
+func (c *remoteClient) requestScan(request ScanRequest) (APIScan, error) {
+	var scan APIScan
+	err := c.do(http.MethodPost, "/api/v1/scans", request, &scan)
+	return scan, err
+}
+
+// scanStatus returns the current state of a scan.
+func (c *remoteClient) scanStatus(id string) (APIScan, error) {
+	var scan APIScan
+	err := c.do(http.MethodGet, "/api/v1/scans/"+id, nil, &scan)
+	return scan, err
+}
+
+// waitForScan polls the server until a scan finishes, logging its phase changes.
+func (c *remoteClient) waitForScan(scan APIScan) (APIScan, error) {
+	phase := scan.Phase
+	for scan.Phase != PhaseCompleted && scan.Phase != PhaseFailed {
+		time.Sleep(remotePoll)
+		var err error
+		if scan, err = c.scanStatus(scan.ID); err != nil {
+			return scan, err
+		}
+		if scan.Phase != phase {
+			log(fmt.Sprintf("[*] Scan %s is %s\n", scan.ID, strings.ToLower(scan.Phase)))
+			phase = scan.Phase
+		}
+	}
+	return scan, nil
+}
+
+// printRemoteScan prints the state of a scan and, if it failed, returns an error.
+func printRemoteScan(scan APIScan) error {
+	fmt.Printf("Scan:         %s\n", scan.ID)
+	fmt.Printf("Namespace:    %s\n", scan.Request.Namespace)
+	fmt.Printf("Phase:        %s\n", scan.Phase)
+	if scan.Message != "" {
+		fmt.Printf("Message:      %s\n", strings.TrimSpace(scan.Message))
+	}
+	if scan.Phase == PhaseCompleted || scan.Phase == PhaseFailed {
+		fmt.Printf("Run ID:       %s\n", valueOrDefault(scan.RunID, "none"))
+		fmt.Printf("Containers:   %d scanned, %d not testable, %d skipped\n", scan.Scanned, scan.NotTestable, scan.Skipped)
+		fmt.Printf("Findings:     %d critical, %d interesting, %d info\n", scan.Findings[SeverityCritical], scan.Findings[SeverityInteresting], scan.Findings[SeverityInfo])
+	}
+	if scan.Phase == PhaseFailed {
+		return fmt.Errorf("[-] Scan %s failed\n", scan.ID)
+	}
+	return nil
+}
+
+var remoteCmd = &cobra.Command{
+	Use:   "remote",
+	Short: "Trigger and monitor scans run by a central kubelse deployment",
+	Long: `
+Triggers and monitors scans run by the operator of a central in-cluster kubelse deployment through its scan
+API ('kubelse operator --listen'), so that no cluster credentials are needed. The token of the API is read from
+the KUBELSE_API_TOKEN environment variable.`,
+}
+
+var remoteScanCmd = &cobra.Command{
+	Use:   "scan",
+	Short: "Request a scan of a namespace and wait for its outcome",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := newRemoteClient()
+		if err != nil {
+			return err
+		}
+		scan, err := client.requestScan(ScanRequest{Namespace: namespace, Selector: labelSelector, Format: format, Level: level, Sections: sections})
+		if err != nil {
+			return fmt.Errorf("[-] Error requesting a scan: %s\n", err.Error())
+		}
+		log(fmt.Sprintf("[+] Scan %s of namespace %s queued on %s\n", scan.ID, namespace, remoteServer))
+		if !remoteWait {
+			return printRemoteScan(scan)
+		}
+		if scan, err = client.waitForScan(scan); err != nil {
+			return fmt.Errorf("[-] Error checking scan %s: %s\n", scan.ID, err.Error())
+		}
+		return printRemoteScan(scan)
+	},
+}
+
+var remoteStatusCmd = &cobra.Command{
+	Use:   "status <scan>",
+	Short: "Print the state of a scan",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := newRemoteClient()
+		if err != nil {
+			return err
+		}
+		scan, err := client.scanStatus(args[0])
+		if err != nil {
+			return fmt.Errorf("[-] Error checking scan %s: %s\n", args[0], err.Error())
+		}
+		return printRemoteScan(scan)
+	},
+}
+
+func init() {
+	remoteCmd.PersistentFlags().StringVar(&remoteServer, "server", "", "a URL of the kubelse server, e.g. https://kubelse.internal")
+	remoteCmd.PersistentFlags().StringVar(&remoteCAFile, "ca-file", "", "a file with CA certificates of the server, if not provided then system certificates are used")
+	remoteCmd.PersistentFlags().BoolVarP(&quiet, "quiet", "q", false, "quiet execution - no status information")
+
+	remoteScanCmd.Flags().StringVarP(&namespace, "namespace", "n", "default", "a namespace to be scanned")
+	remoteScanCmd.Flags().StringVar(&labelSelector, "selector", "", "a label selector of pods, e.g. app=nginx, if not provided then unique pods of the namespace are scanned")
+	remoteScanCmd.Flags().StringVarP(&format, "output", "o", "ansi", "Output format of reports: ansi, text, plain, html or json")
+	remoteScanCmd.Flags().StringVar(&level, "level", "", "lse.sh verbosity level: 0, 1 or 2, if not provided then lse.sh default is used")
+	remoteScanCmd.Flags().StringVar(&sections, "sections", "", "comma-separated lse.sh sections or tests to be run, e.g. fst,sud, if not provided then all are run")
+	remoteScanCmd.Flags().BoolVar(&remoteWait, "wait", true, "wait until the scan finishes, '--wait=false' returns once the scan is queued")
+	remoteScanCmd.Flags().DurationVar(&remotePoll, "poll", 5*time.Second, "how often the state of the scan is checked")
+
+	remoteCmd.AddCommand(remoteScanCmd, remoteStatusCmd)
+	cmd.AddCommand(remoteCmd)
+}
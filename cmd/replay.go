@@ -0,0 +1,282 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/hhruszka/k8sexec"
+	"io"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// redacted replaces credentials in recorded fixtures, which are meant to be shared for demos and tests
+const redacted = "REDACTED"
+
+// tokenRegexp matches bearer tokens and JWTs, e.g. service account tokens, in exec output
+var tokenRegexp = regexp.MustCompile(`(?i)(bearer\s+)\S+|eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`)
+
+// RecordedResponse is a response of the Kubernetes API to a request, other than exec, recorded in a fixture.
+type RecordedResponse struct {
+	Method      string `json:"Method"`
+	URI         string `json:"URI"`
+	StatusCode  int    `json:"StatusCode"`
+	ContentType string `json:"ContentType,omitempty"`
+	Body        string `json:"Body"`
+}
+
+// RecordedExec is an exec in a container recorded in a fixture. Stdin, i.e. lse.sh, is identified by its hash.
+type RecordedExec struct {
+	Pod         string                  `json:"Pod"`
+	Container   string                  `json:"Container"`
+	Args        []string                `json:"Args"`
+	StdinSHA256 string                  `json:"StdinSHA256,omitempty"`
+	Status      k8sexec.ExecutionStatus `json:"Status"`
+}
+
+// Fixture is everything kubelse received from a cluster during a recorded run.
+type Fixture struct {
+	Namespace string             `json:"Namespace"`
+	Cluster   ClusterInfo        `json:"Cluster"`
+	Responses []RecordedResponse `json:"Responses"`
+	Execs     []RecordedExec     `json:"Execs"`
+}
+
+// record and replay CLI options variables
+var (
+	recordFile string
+	replayFile string
+)
+
+var (
+	fixtureMu sync.Mutex
+	// fixture is being recorded or replayed, if any of record or replay options was provided
+	fixture     *Fixture
+	replayExecs map[string]RecordedExec
+)
+
+// execKey identifies an exec in a fixture.
+func execKey(pod string, container string, args []string, stdin []byte) string {
+	key := pod + "\x00" + container + "\x00" + strings.Join(args, "\x00")
+	if stdin != nil {
+		sum := sha256.Sum256(stdin)
+		key += "\x00" + hex.EncodeToString(sum[:])
+	}
+	return key
+}
+
+// recorder is a transport recording responses of the API server.
+type recorder struct {
+	next http.RoundTripper
+}
+
+func (r *recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := r.next.RoundTrip(req)
+	// exec streams are recorded by execInContainer
+	if err != nil || strings.HasSuffix(req.URL.Path, "/exec") {
+		return resp, err
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	recorded := RecordedResponse{
+		Method:      req.Method,
+		URI:         req.URL.RequestURI(),
+		StatusCode:  resp.StatusCode,
+		ContentType: resp.Header.Get("Content-Type"),
+		Body:        string(body),
+	}
+	if strings.Contains(req.URL.Path, "/secrets") {
+		recorded = redactSecrets(recorded)
+	}
+
+	fixtureMu.Lock()
+	defer fixtureMu.Unlock()
+	fixture.Responses = append(fixture.Responses, recorded)
+	return resp, nil
+}
+
+// redactSecrets replaces values of Secret objects, e.g. image pull secrets, in a recorded response. Responses,
+// which cannot be redacted, are recorded as not found.
+func redactSecrets(recorded RecordedResponse) RecordedResponse {
+	var object map[string]interface{}
+	if err := json.Unmarshal([]byte(recorded.Body), &object); err == nil {
+		secrets := []interface{}{object}
+		if items, ok := object["items"].([]interface{}); ok {
+			secrets = items
+		}
+		for _, secret := range secrets {
+			if secret, ok := secret.(map[string]interface{}); ok {
+				redactSecret(secret)
+			}
+		}
+		if body, err := json.Marshal(object); err == nil {
+			recorded.Body = string(body)
+			return recorded
+		}
+	}
+	recorded.StatusCode, recorded.ContentType = http.StatusNotFound, "application/json"
+	recorded.Body = `{"kind":"Status","apiVersion":"v1","status":"Failure","message":"secret redacted from the fixture","reason":"NotFound","code":404}`
+	return recorded
+}
+
+// redactSecret replaces values of a Secret object, keys are kept.
+func redactSecret(secret map[string]interface{}) {
+	for _, field := range []string{"data", "stringData"} {
+		if values, ok := secret[field].(map[string]interface{}); ok {
+			for key := range values {
+				values[key] = redacted
+			}
+		}
+	}
+	// the last applied configuration annotation repeats the data
+	if metadata, ok := secret["metadata"].(map[string]interface{}); ok {
+		if annotations, ok := metadata["annotations"].(map[string]interface{}); ok {
+			delete(annotations, "kubectl.kubernetes.io/last-applied-configuration")
+		}
+	}
+}
+
+// redactTokens replaces bearer tokens and JWTs, e.g. service account tokens read by probes, in lines of output.
+func redactTokens(lines []string) []string {
+	if lines == nil {
+		return nil
+	}
+	result := make([]string, len(lines))
+	for idx, line := range lines {
+		result[idx] = tokenRegexp.ReplaceAllStringFunc(line, func(token string) string {
+			if match := tokenRegexp.FindStringSubmatch(token); match[1] != "" {
+				return match[1] + redacted
+			}
+			return redacted
+		})
+	}
+	return result
+}
+
+// recordExec records an exec in a container, if a run is being recorded.
+func recordExec(pod string, container string, args []string, stdin []byte, execStatus *k8sexec.ExecutionStatus) {
+	if fixture == nil || replayFile != "" {
+		return
+	}
+	recorded := RecordedExec{Pod: pod, Container: container, Args: args, Status: *execStatus}
+	recorded.Status.Stdout, recorded.Status.Stderr = redactTokens(execStatus.Stdout), redactTokens(execStatus.Stderr)
+	if stdin != nil {
+		sum := sha256.Sum256(stdin)
+		recorded.StdinSHA256 = hex.EncodeToString(sum[:])
+	}
+
+	fixtureMu.Lock()
+	defer fixtureMu.Unlock()
+	fixture.Execs = append(fixture.Execs, recorded)
+}
+
+// replayExec returns a recorded exec.
+func replayExec(pod string, container string, args []string, stdin []byte) *k8sexec.ExecutionStatus {
+	recorded, ok := replayExecs[execKey(pod, container, args, stdin)]
+	if !ok {
+		return k8sexec.NewExecutionStatus(pod, container, k8sexec.InternalAppError, fmt.Sprintf("exec %q not recorded in %s", strings.Join(args, " "), replayFile), "", "")
+	}
+	status := recorded.Status
+	return &status
+}
+
+// replayServer serves recorded API responses, the last recorded response is served for repeated requests.
+func replayServer(recorded *Fixture) *httptest.Server {
+	responses := make(map[string]RecordedResponse)
+	for _, response := range recorded.Responses {
+		responses[response.Method+" "+response.URI] = response
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		response, ok := responses[req.Method+" "+req.URL.RequestURI()]
+		if !ok {
+			http.Error(w, fmt.Sprintf(`{"kind":"Status","apiVersion":"v1","status":"Failure","message":"%s %s not recorded","reason":"NotFound","code":404}`, req.Method, req.URL.Path), http.StatusNotFound)
+			return
+		}
+		if response.ContentType != "" {
+			w.Header().Set("Content-Type", response.ContentType)
+		}
+		w.WriteHeader(response.StatusCode)
+		io.WriteString(w, response.Body)
+	}))
+}
+
+// newClient creates a client of the cluster. A run being recorded gets a client recording API responses and
+// a run being replayed gets a client talking to a local server serving recorded responses.
+func newClient(kubeconfig string, namespace string) (*k8sexec.K8SExec, error) {
+	if replayFile != "" {
+		content, err := os.ReadFile(replayFile)
+		if err != nil {
+			return nil, err
+		}
+		recorded := &Fixture{}
+		if err := json.Unmarshal(content, recorded); err != nil {
+			return nil, fmt.Errorf("invalid fixture %s: %s", replayFile, err.Error())
+		}
+		fixture, replayExecs, execHook = recorded, make(map[string]RecordedExec), replayExec
+		for _, recordedExec := range recorded.Execs {
+			replayExecs[execKey(recordedExec.Pod, recordedExec.Container, recordedExec.Args, nil)+stdinSuffix(recordedExec.StdinSHA256)] = recordedExec
+		}
+
+		config := &rest.Config{Host: replayServer(recorded).URL}
+		allowClusterHost(config.Host)
+		clientset, err := kubernetes.NewForConfig(config)
+		if err != nil {
+			return nil, err
+		}
+		return &k8sexec.K8SExec{Config: config, Clientset: clientset, Namespace: namespace}, nil
+	}
+
+	k8s, err := newExecClient(kubeconfig, namespace)
+	if err != nil || recordFile == "" {
+		return k8s, err
+	}
+	if fixture == nil {
+		fixture = &Fixture{Namespace: namespace}
+	}
+	k8s.Config.Wrap(func(rt http.RoundTripper) http.RoundTripper {
+		return &recorder{next: rt}
+	})
+	if k8s.Clientset, err = kubernetes.NewForConfig(k8s.Config); err != nil {
+		return nil, err
+	}
+	return k8s, nil
+}
+
+// stdinSuffix returns the part of an exec key identifying stdin.
+func stdinSuffix(sum string) string {
+	if sum == "" {
+		return ""
+	}
+	return "\x00" + sum
+}
+
+// saveRecording saves a recorded fixture, readable only by its owner, since it describes the cluster.
+func saveRecording() error {
+	if recordFile == "" || fixture == nil {
+		return nil
+	}
+	fixture.Cluster = cluster
+	content, err := json.MarshalIndent(fixture, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(recordFile, content, 0600); err != nil {
+		return err
+	}
+	log(fmt.Sprintf("[+] Recorded %d API responses and %d execs to %s\n", len(fixture.Responses), len(fixture.Execs), recordFile))
+	return nil
+}
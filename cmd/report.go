@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+)
+
+// reportHeader returns lines describing a scanned container, which are put at the beginning of every report,
+// so that the report can be attributed to a container without relying on its file name.
+func reportHeader(result Result) []string {
+	info := result.container
+
+	pkgChecks := tr("not meaningful, lse.sh checks package versions with dpkg or rpm only")
+	if pkgChecksMeaningful(info.pkgManager) {
+		pkgChecks = tr("meaningful")
+	}
+
+	header := []string{
+		"=====================================( kubelse )=====================================",
+		headerLine("Run ID", runID),
+		headerLine("Timestamp", formatTimestamp(now())),
+		headerLine("Cluster", valueOrDefault(cluster.Name, "unknown")),
+		headerLine("API server", valueOrDefault(cluster.Server, "unknown")),
+		headerLine("Kubernetes", valueOrDefault(cluster.Version, "unknown")),
+		headerLine("Provider", valueOrDefault(providerProfile().Name, "unknown")),
+		headerLine("Namespace", scannedNamespace()),
+		headerLine("Pod", info.container.Pod),
+		headerLine("Container", info.container.Container),
+		headerLine("Workload", valueOrDefault(info.container.Workload, "unknown")),
+		headerLine("Image", valueOrDefault(info.container.Image, "unknown")),
+		headerLine("Owner", valueOrDefault(info.container.Owner, "unowned")),
+		headerLine("Distribution", valueOrDefault(info.distro, "unknown")),
+		headerLine("Package manager", valueOrDefault(info.pkgManager, "none")),
+		headerLine("Package checks", pkgChecks),
+		headerLine("Filesystem", filesystemStatus(info)),
+		headerLine("Shell", info.shell),
+		headerLine("Payload", payloadName),
+		headerLine("Level", valueOrDefault(info.settings.level, "lse.sh default")),
+		headerLine("Sections", valueOrDefault(info.settings.sections, "all")),
+		headerLine("Scan status", fmt.Sprintf("%s (%s)", result.status(), result.exitDescription())),
+		headerLine("PSS level", valueOrDefault(info.container.PSS.Level, "unknown")),
+		headerLine("Risk score", result.risk.String()),
+	}
+	for _, hint := range providerProfile().Hints {
+		header = append(header, fmt.Sprintf("                  - %s", hint))
+	}
+	if suppressed := result.suppressedFindings(); len(suppressed) > 0 {
+		header = append(header, headerLine("Suppressed", fmt.Sprintf("%s (%s)", strings.Join(suppressed, ","), ignoreFile)))
+	}
+	for _, violation := range info.container.PSS.Violations {
+		header = append(header, fmt.Sprintf("                  - %s: %s: %s", violation.Level, violation.Check, violation.Message))
+	}
+	if triaged := result.triagedFindings(); len(triaged) > 0 {
+		header = append(header, headerLine("Triaged", strings.Join(triaged, ", ")))
+	}
+	if elevated := result.elevatedFindings(); len(elevated) > 0 {
+		header = append(header, headerLine("Elevated", fmt.Sprintf("%s (%s)", strings.Join(elevated, ","), providerProfile().Name)))
+	}
+	if info.container.NetworkPolicies != nil {
+		header = append(header, headerLine("Network policies", info.container.NetworkPolicies.String()))
+	}
+	if info.readOnlyRoot || !info.tmpWritable {
+		header = append(header, headerLine("Note", tr("checks of writable files and directories (e.g. fst000, fst160, fst170) and checks")),
+			"                  "+tr("using temporary files may report misleading results in this container"))
+	}
+	return append(header, "")
+}
+
+// reportLines returns lines of a container's report: the kubelse header, lse.sh output and suggested commands.
+func reportLines(result Result) []string {
+	lines := append(reportHeader(result), result.scanReport...)
+	return append(lines, reportSuggestions(result.container.container)...)
+}
+
+// valueOrDefault returns a value or, if the value is empty, its default description.
+func valueOrDefault(value, defaultValue string) string {
+	if value == "" {
+		return defaultValue
+	}
+	return value
+}
@@ -0,0 +1,228 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/spf13/cobra"
+	"html/template"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// report serve CLI options variables
+var (
+	reportsDirectory string
+	listenAddress    string
+)
+
+var reportServeTemplate = template.Must(template.New("reports").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="UTF-8"/>
+<title>kubelse reports</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; vertical-align: top; }
+pre { margin: 0; }
+.critical { color: #c00; font-weight: bold; }
+.interesting { color: #b80; }
+.info { color: #06c; }
+</style>
+</head>
+<body>
+<h1><a href="/">kubelse reports</a></h1>
+<form method="get" action="/">
+<input type="text" name="q" value="{{.Query}}" placeholder="search findings"/>
+<select name="severity">
+<option value="" {{if eq .Severity ""}}selected{{end}}>all severities</option>
+<option value="critical" {{if eq .Severity "critical"}}selected{{end}}>critical</option>
+<option value="interesting" {{if eq .Severity "interesting"}}selected{{end}}>interesting</option>
+<option value="info" {{if eq .Severity "info"}}selected{{end}}>info</option>
+</select>
+<input type="hidden" name="run" value="{{.Run}}"/>
+<input type="hidden" name="report" value="{{.Report}}"/>
+<input type="submit" value="Filter"/>
+</form>
+{{range .Runs}}
+<h2>Run: <a href="/?run={{.Name}}">{{.Name}}</a></h2>
+<table>
+<tr><th>Pod</th><th>Container</th><th>Critical</th><th>Interesting</th><th>Info</th><th>Report</th></tr>
+{{range .Reports}}
+<tr>
+<td>{{.Pod}}</td><td>{{.Container}}</td>
+<td class="critical">{{index .Counts "critical"}}</td><td class="interesting">{{index .Counts "interesting"}}</td><td class="info">{{index .Counts "info"}}</td>
+<td><a href="/?run={{.Run}}&report={{.Path}}">findings</a> <a href="/raw?report={{.Path}}">raw</a></td>
+</tr>
+{{end}}
+</table>
+{{end}}
+{{if .Filtered}}
+<h2>Findings ({{len .Findings}})</h2>
+<table>
+<tr><th>Run</th><th>Pod</th><th>Container</th><th>Section</th><th>Test</th><th>Details</th></tr>
+{{range .Findings}}
+<tr>
+<td>{{.Run}}</td><td>{{.Pod}}</td><td>{{.Container}}</td><td>{{.Finding.Section}}</td>
+<td class="{{.Finding.Severity}}">{{.Finding.ID}} {{.Finding.Name}}</td>
+<td><pre>{{range .Finding.Details}}{{.}}
+{{end}}</pre></td>
+</tr>
+{{end}}
+</table>
+{{end}}
+</body>
+</html>`))
+
+type servedReport struct {
+	Run       string
+	Path      string
+	Pod       string
+	Container string
+	Counts    map[string]int
+}
+
+type servedRun struct {
+	Name    string
+	Reports []servedReport
+}
+
+type servedFinding struct {
+	Run       string
+	Pod       string
+	Container string
+	Finding   Finding
+}
+
+type reportsPage struct {
+	Query    string
+	Severity string
+	Run      string
+	Report   string
+	Filtered bool
+	Runs     []servedRun
+	Findings []servedFinding
+}
+
+// matches tells if a finding matches a search query and a severity filter, the query is matched case-insensitively
+// against the test's id, name, section and details.
+func matches(finding Finding, query, severity string) bool {
+	if severity != "" && finding.Severity != severity {
+		return false
+	}
+	if query == "" {
+		return true
+	}
+	query = strings.ToLower(query)
+	text := strings.ToLower(strings.Join(append([]string{finding.ID, finding.Name, finding.Section}, finding.Details...), "\n"))
+	return strings.Contains(text, query)
+}
+
+func serveReports(w http.ResponseWriter, r *http.Request) {
+	runs, err := loadReports(reportsDirectory)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	page := reportsPage{
+		Query:    r.URL.Query().Get("q"),
+		Severity: r.URL.Query().Get("severity"),
+		Run:      r.URL.Query().Get("run"),
+		Report:   r.URL.Query().Get("report"),
+	}
+	page.Filtered = page.Query != "" || page.Severity != "" || page.Report != ""
+
+	var names []string
+	for name := range runs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if page.Run != "" && page.Run != name {
+			continue
+		}
+		run := servedRun{Name: name}
+		for _, report := range runs[name] {
+			path, _ := filepath.Rel(reportsDirectory, report.Path)
+			run.Reports = append(run.Reports, servedReport{Run: name, Path: path, Pod: report.Pod(), Container: report.Container(), Counts: report.CountBySeverity()})
+
+			if !page.Filtered || (page.Report != "" && page.Report != path) {
+				continue
+			}
+			for _, finding := range report.Positive() {
+				if matches(finding, page.Query, page.Severity) {
+					page.Findings = append(page.Findings, servedFinding{Run: name, Pod: report.Pod(), Container: report.Container(), Finding: finding})
+				}
+			}
+		}
+		page.Runs = append(page.Runs, run)
+	}
+
+	if err := reportServeTemplate.Execute(w, page); err != nil {
+		log(fmt.Sprintf("[-] Error rendering page: %s\n", err.Error()))
+	}
+}
+
+func serveRawReport(w http.ResponseWriter, r *http.Request) {
+	runs, err := loadReports(reportsDirectory)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// only reports found in the reports directory are served
+	requested := r.URL.Query().Get("report")
+	for _, reports := range runs {
+		for _, report := range reports {
+			if path, _ := filepath.Rel(reportsDirectory, report.Path); path == requested {
+				w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+				fmt.Fprint(w, strings.Join(report.Lines, "\n"))
+				return
+			}
+		}
+	}
+	http.NotFound(w, r)
+}
+
+var reportCmd = &cobra.Command{
+	Use:   "report",
+	Short: "Work with saved scan reports",
+}
+
+var reportServeCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Start a local web UI for browsing scan reports",
+	Long: `
+Starts a local web server, which lists runs and scanned containers found in a reports directory and allows
+to search and filter findings by severity.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if info, err := os.Stat(reportsDirectory); err != nil || !info.IsDir() {
+			return fmt.Errorf("Reports directory %q does not exist\n", reportsDirectory)
+		}
+
+		mux := http.NewServeMux()
+		mux.HandleFunc("/", serveReports)
+		mux.HandleFunc("/raw", serveRawReport)
+
+		log(fmt.Sprintf("[+] Serving reports from %s on http://%s\n", reportsDirectory, listenAddress))
+		return http.ListenAndServe(listenAddress, mux)
+	},
+}
+
+func init() {
+	workingDirectory, err := os.Getwd()
+	if err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+	reportServeCmd.Flags().StringVar(&reportsDirectory, "dir", workingDirectory, "a directory with scan reports")
+	reportServeCmd.Flags().StringVar(&listenAddress, "listen", "127.0.0.1:8080", "an address the web UI listens on")
+
+	reportCmd.AddCommand(reportServeCmd)
+	cmd.AddCommand(reportCmd)
+}
@@ -0,0 +1,155 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// rescan CLI options variables
+var (
+	rescanRun       string
+	rescanContainer string
+	rescanDirectory string
+)
+
+// runOptions are options of the current run, which were provided on the command line or in the environment
+var runOptions map[string]string
+
+// rescanIgnoredOptions select containers or the way they are found, they are replaced by the rescanned container
+var rescanIgnoredOptions = []string{"namespace", "pods", "containers", "selector", "helm-release", "argocd-app",
+	"targets-file", "images", "list", "watch", "canary", "dry-run", "record", "replay", "directory", "shard", "run-id"}
+
+// changedOptions returns options, which were set, by their names.
+func changedOptions(flags *pflag.FlagSet) map[string]string {
+	options := make(map[string]string)
+	flags.Visit(func(flag *pflag.Flag) {
+		options[flag.Name] = flag.Value.String()
+	})
+	return options
+}
+
+// findRunManifest returns a manifest of a run saved in a directory or its run directories, given the run ID or
+// its prefix, e.g. the 8 characters in file names. Manifests of shards of a run are merged.
+func findRunManifest(dir string, id string) (Manifest, error) {
+	manifests, err := loadManifests(dir, time.Time{})
+	if err != nil {
+		return Manifest{}, err
+	}
+	var found []Manifest
+	shards := make(map[string]int)
+	for _, manifest := range manifests {
+		if !strings.HasPrefix(manifest.RunID, id) {
+			continue
+		}
+		if idx, ok := shards[manifest.RunID]; ok {
+			merged := &found[idx]
+			merged.Scanned = append(merged.Scanned, manifest.Scanned...)
+			merged.NotTestable = append(merged.NotTestable, manifest.NotTestable...)
+			merged.Skipped = append(merged.Skipped, manifest.Skipped...)
+			continue
+		}
+		shards[manifest.RunID] = len(found)
+		found = append(found, manifest)
+	}
+	switch {
+	case len(found) == 0:
+		return Manifest{}, fmt.Errorf("[-] No manifest of run %s found in %s\n", id, dir)
+	case len(found) > 1:
+		return Manifest{}, fmt.Errorf("[-] Run ID %s is ambiguous, it matches %d runs in %s\n", id, len(found), dir)
+	}
+	return found[0], nil
+}
+
+// applyRunOptions sets options recorded in a manifest of a run, except for options selecting containers, which
+// are replaced by a target.
+func applyRunOptions(flags *pflag.FlagSet, manifest Manifest, target Target) error {
+	if len(manifest.Options) == 0 {
+		log(fmt.Sprintf("[-] Run %s recorded no options, default options are used\n", manifest.RunID))
+	}
+	var names []string
+	for name := range manifest.Options {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if flags.Lookup(name) == nil {
+			log(fmt.Sprintf("[-] Option '--%s' of run %s is not known to this version of kubelse, it is ignored\n", name, manifest.RunID))
+			continue
+		}
+		if !contains(rescanIgnoredOptions, name) {
+			if err := flags.Set(name, manifest.Options[name]); err != nil {
+				return fmt.Errorf("Invalid value of the option '--%s' of run %s: %s", name, manifest.RunID, err.Error())
+			}
+		}
+	}
+	for name, value := range map[string]string{"namespace": target.Namespace, "pods": target.Pod, "containers": target.Container, "directory": rescanDirectory} {
+		if err := flags.Set(name, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var rescanCmd = &cobra.Command{
+	Use:   "rescan --run <id> --container <namespace>/<pod>/<container>",
+	Short: "Scan a container again with options of a previous run",
+	Long: `
+Scans a single container again with the options recorded in the manifest of a previous run, e.g. to verify a fix,
+without reconstructing the options of the run. Options selecting containers are replaced by the container.`,
+	Args: cobra.NoArgs,
+	RunE: func(command *cobra.Command, args []string) error {
+		if rescanRun == "" || rescanContainer == "" {
+			return errors.New("A run has to be provided with '--run' and a container with '--container'")
+		}
+		targets, err := parseTargets(strings.NewReader(rescanContainer))
+		if err != nil || len(targets) != 1 || targets[0].Container == "" {
+			return fmt.Errorf("Invalid value of the container option '--container': expected namespace/pod/container, got %q", rescanContainer)
+		}
+		manifest, err := findRunManifest(rescanDirectory, rescanRun)
+		if err != nil {
+			return err
+		}
+		if !manifestHasContainer(manifest, targets[0]) {
+			log(fmt.Sprintf("[-] Container %s was not in scope of run %s\n", rescanContainer, manifest.RunID))
+		}
+		if err := applyRunOptions(cmd.Flags(), manifest, targets[0]); err != nil {
+			return err
+		}
+		log(fmt.Sprintf("[+] Scanning %s again with options of run %s\n", rescanContainer, manifest.RunID))
+		if err := cmd.PreRunE(cmd, nil); err != nil {
+			return err
+		}
+		return cmd.RunE(cmd, nil)
+	},
+}
+
+// manifestHasContainer tells if a container was scanned, not testable or skipped in a run.
+func manifestHasContainer(manifest Manifest, target Target) bool {
+	for _, entries := range [][]ManifestEntry{manifest.Scanned, manifest.NotTestable, manifest.Skipped} {
+		for _, entry := range entries {
+			if valueOrDefault(entry.Namespace, manifest.Namespace) == target.Namespace && entry.Pod == target.Pod && entry.Container == target.Container {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func init() {
+	workingDirectory, err := os.Getwd()
+	if err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+	rescanCmd.Flags().StringVar(&rescanRun, "run", "", "an ID of the run, or its prefix, e.g. the 8 characters in file names")
+	rescanCmd.Flags().StringVar(&rescanContainer, "container", "", "a container to be scanned again, namespace/pod/container")
+	rescanCmd.Flags().StringVarP(&rescanDirectory, "directory", "d", workingDirectory, "a reports directory with the manifest of the run, reports of the scan are saved in it too")
+
+	cmd.AddCommand(rescanCmd)
+}
@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"encoding/json"
+	"github.com/spf13/pflag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRescanAppliesOptionsOfTheRun(t *testing.T) {
+	var ns, pods, containers, selector, dir, level string
+	newFlags := func() *pflag.FlagSet {
+		flags := pflag.NewFlagSet("kubelse", pflag.ContinueOnError)
+		flags.StringVarP(&ns, "namespace", "n", "default", "")
+		flags.StringVarP(&pods, "pods", "p", "", "")
+		flags.StringVarP(&containers, "containers", "c", "", "")
+		flags.StringVar(&selector, "selector", "", "")
+		flags.StringVarP(&dir, "directory", "d", ".", "")
+		flags.StringVarP(&level, "level", "l", "", "")
+		return flags
+	}
+
+	flags := newFlags()
+	if err := flags.Parse([]string{"-n", "payments", "--selector", "app=api", "-l", "2", "-d", "/reports"}); err != nil {
+		t.Fatal(err)
+	}
+	options := changedOptions(flags)
+	if len(options) != 4 || options["level"] != "2" || options["selector"] != "app=api" {
+		t.Fatalf("expected the provided options recorded, got %v", options)
+	}
+
+	rescanDirectory = t.TempDir()
+	manifest := Manifest{RunID: "1a2b3c4d-0000", Namespace: "payments", Options: options, Scanned: []ManifestEntry{{Pod: "api-1", Container: "app"}}}
+	content, _ := json.Marshal(manifest)
+	os.MkdirAll(filepath.Join(rescanDirectory, "run"), 0755)
+	if err := os.WriteFile(filepath.Join(rescanDirectory, "run", "kubelse-manifest-1.json"), content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	found, err := findRunManifest(rescanDirectory, "1a2b3c4d")
+	if err != nil {
+		t.Fatal(err)
+	}
+	target := Target{Namespace: "payments", Pod: "api-1", Container: "app"}
+	if !manifestHasContainer(found, target) || manifestHasContainer(found, Target{Namespace: "payments", Pod: "api-2", Container: "app"}) {
+		t.Errorf("expected only api-1/app in scope of the run")
+	}
+
+	if err := applyRunOptions(newFlags(), found, target); err != nil {
+		t.Fatal(err)
+	}
+	if level != "2" || selector != "" || ns != "payments" || pods != "api-1" || containers != "app" || dir != rescanDirectory {
+		t.Errorf("expected the level of the run and the rescanned container only, got level %q selector %q %s/%s/%s in %s", level, selector, ns, pods, containers, dir)
+	}
+	if _, err := findRunManifest(rescanDirectory, "ffff"); err == nil {
+		t.Errorf("expected an unknown run rejected")
+	}
+}
@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// results DB CLI options variables
+var resultsDBFile string
+
+// ResultsDB keeps results of runs, which have to outlive the reports directory, e.g. tracking IDs of containers,
+// which could never be scanned, and triage decisions on findings. It is a json file in the user's configuration directory by default.
+type ResultsDB struct {
+	// Gaps are coverage gaps by their keys, see gapKey
+	Gaps map[string]*CoverageGap `json:"Gaps"`
+	// NextGap is the number of the next tracking ID of a gap
+	NextGap int `json:"NextGap"`
+	// Triage are triage decisions on findings by their keys, see triageKey
+	Triage map[string]*TriageDecision `json:"Triage,omitempty"`
+}
+
+// defaultResultsDB returns where the results DB is stored by default: in the user's configuration directory, next
+// to pseudonyms.
+func defaultResultsDB() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "kubelse", "results.json"), nil
+}
+
+// resultsDBPath returns the file of the results DB, '--results-db' if provided.
+func resultsDBPath() (string, error) {
+	if resultsDBFile != "" {
+		return resultsDBFile, nil
+	}
+	return defaultResultsDB()
+}
+
+// loadResultsDB reads the results DB, the file does not have to exist yet.
+func loadResultsDB(file string) (*ResultsDB, error) {
+	db := &ResultsDB{Gaps: make(map[string]*CoverageGap), Triage: make(map[string]*TriageDecision)}
+	content, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return db, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(content, db); err != nil {
+		return nil, fmt.Errorf("invalid results DB %s: %s", file, err.Error())
+	}
+	if db.Gaps == nil {
+		db.Gaps = make(map[string]*CoverageGap)
+	}
+	if db.Triage == nil {
+		db.Triage = make(map[string]*TriageDecision)
+	}
+	return db, nil
+}
+
+// save writes the results DB to a file, replacing it atomically, so that an interrupted run never leaves it
+// truncated.
+func (db *ResultsDB) save(file string) error {
+	content, err := json.MarshalIndent(db, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		return err
+	}
+	tmp := file + ".tmp"
+	if err := os.WriteFile(tmp, content, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, file)
+}
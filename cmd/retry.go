@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"github.com/hhruszka/k8sexec"
+	"os"
+)
+
+// failed tells if lse.sh did not finish successfully in a container.
+func (r Result) failed() bool {
+	return r.retCode != k8sexec.Success
+}
+
+// retryFailed scans again containers, in which scans failed, if it was requested with the retry option or
+// confirmed by a user. Results of the second pass replace the failed results, reports of the failed scans are
+// removed when the second pass succeeds.
+func retryFailed(ctx context.Context, k8s *k8sexec.K8SExec, results []Result) []Result {
+	var (
+		failedContainers []ContainerInfo
+		failedIdx        map[string]int = make(map[string]int)
+	)
+	for idx, result := range results {
+		if result.failed() {
+			failedContainers = append(failedContainers, result.container)
+			failedIdx[result.container.container.String()] = idx
+		}
+	}
+	// failures exhausting the error budget are not going to go away on their own
+	if len(failedContainers) == 0 || errorBudget.exhausted() || ctx.Err() != nil {
+		return results
+	}
+
+	switch {
+	case retryFailedScans:
+		log(fmt.Sprintf("[*] Scans of %d containers failed, scanning them again\n", len(failedContainers)))
+	case !quiet && interactive:
+		logContainers(fmt.Sprintf("[-] Scans of following %d containers failed:\n", len(failedContainers)), failedContainers)
+		if !promptYN("\nDo you wish to scan them again? (Y/N): ") {
+			return results
+		}
+	default:
+		return results
+	}
+
+	for _, retried := range scanTargets(ctx, k8s, failedContainers) {
+		idx := failedIdx[retried.container.container.String()]
+		retried.attempts = results[idx].attempts + 1
+		if !retried.failed() {
+			removeStaleReports(results[idx], retried)
+		}
+		results[idx] = retried
+	}
+	return results
+}
+
+// removeStaleReports removes report files of a failed scan of a container, which was successfully scanned again,
+// so that they are not mistaken for results of the container.
+func removeStaleReports(failed Result, retried Result) {
+	for _, fileName := range []string{failed.reportFile, failed.stderrFile} {
+		if fileName == "" || fileName == retried.reportFile || fileName == retried.stderrFile {
+			continue
+		}
+		if err := os.Remove(fileName); err != nil && !os.IsNotExist(err) {
+			log(fmt.Sprintf("[-] Error removing report %s of the failed scan: %s\n", fileName, err.Error()))
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRemoveStaleReportsOfRetriedScan(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string) string {
+		fileName := filepath.Join(dir, name)
+		if err := os.WriteFile(fileName, []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return fileName
+	}
+	failed := Result{reportFile: write("web-1-nginx-1.partial.ansi"), stderrFile: write("web-1-nginx-1.partial.err")}
+	retried := Result{reportFile: write("web-1-nginx-2.ansi")}
+
+	removeStaleReports(failed, retried)
+	for _, fileName := range []string{failed.reportFile, failed.stderrFile} {
+		if _, err := os.Stat(fileName); !os.IsNotExist(err) {
+			t.Errorf("expected %s of the failed scan removed, got %v", filepath.Base(fileName), err)
+		}
+	}
+	if _, err := os.Stat(retried.reportFile); err != nil {
+		t.Errorf("expected the report of the retried scan kept, got %v", err)
+	}
+
+	// a retry overwriting the report of the failed scan keeps it
+	removeStaleReports(retried, retried)
+	if _, err := os.Stat(retried.reportFile); err != nil {
+		t.Errorf("expected the overwritten report kept, got %v", err)
+	}
+}
@@ -0,0 +1,246 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"github.com/hhruszka/k8sexec"
+	authorizationV1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// risk scoring CLI options variables
+var minScore int
+
+// maxRiskScore is the highest risk score, scores of containers are capped at it
+const maxRiskScore = 100
+
+// capabilities, which allow to escape a container or take over its node
+var dangerousCapabilities = []string{"ALL", "SYS_ADMIN", "SYS_PTRACE", "SYS_MODULE", "SYS_RAWIO", "NET_ADMIN", "DAC_READ_SEARCH", "BPF"}
+
+// RiskFactor is a property of a container, or of its findings, which adds points to the container's risk score.
+type RiskFactor struct {
+	Factor string `json:"Factor"`
+	Points int    `json:"Points"`
+}
+
+// RiskScore is a composite risk of a container: how exposed its pod spec and service account are combined with
+// findings of lse.sh, from 0 to 100.
+type RiskScore struct {
+	Score   int          `json:"Score"`
+	Factors []RiskFactor `json:"Factors,omitempty"`
+}
+
+// String describes a risk score with its factors, e.g. "45 (privileged +30, 1 critical findings +10, ...)".
+func (r RiskScore) String() string {
+	var factors []string
+	for _, factor := range r.Factors {
+		factors = append(factors, fmt.Sprintf("%s +%d", factor.Factor, factor.Points))
+	}
+	if len(factors) == 0 {
+		return fmt.Sprint(r.Score)
+	}
+	return fmt.Sprintf("%d (%s)", r.Score, strings.Join(factors, ", "))
+}
+
+// podRiskFactors returns risk factors of a container in its pod spec: privileges, host namespaces and mounts,
+// capabilities, the user it runs as and whether it gets a service account token.
+func podRiskFactors(pod *corev1.Pod, name string) []RiskFactor {
+	var (
+		factors []RiskFactor
+		spec    *corev1.Container
+	)
+	add := func(points int, format string, args ...interface{}) {
+		factors = append(factors, RiskFactor{Factor: fmt.Sprintf(format, args...), Points: points})
+	}
+	for _, container := range podContainers(pod) {
+		if container.Name == name {
+			spec = &container
+			break
+		}
+	}
+	if spec == nil {
+		return nil
+	}
+	sc := spec.SecurityContext
+	if sc == nil {
+		sc = &corev1.SecurityContext{}
+	}
+	podSC := pod.Spec.SecurityContext
+	if podSC == nil {
+		podSC = &corev1.PodSecurityContext{}
+	}
+
+	if sc.Privileged != nil && *sc.Privileged {
+		add(30, "privileged")
+	}
+	if pod.Spec.HostPID {
+		add(10, "hostPID")
+	}
+	if pod.Spec.HostNetwork {
+		add(10, "hostNetwork")
+	}
+	if pod.Spec.HostIPC {
+		add(5, "hostIPC")
+	}
+
+	hostPaths := make(map[string]bool)
+	for _, volume := range pod.Spec.Volumes {
+		if volume.HostPath != nil {
+			hostPaths[volume.Name] = true
+		}
+	}
+	var mounted, writable []string
+	for _, mount := range spec.VolumeMounts {
+		if hostPaths[mount.Name] {
+			mounted = append(mounted, mount.MountPath)
+			if !mount.ReadOnly {
+				writable = append(writable, mount.MountPath)
+			}
+		}
+	}
+	switch {
+	case len(writable) > 0:
+		add(20, "writable host mounts %s", strings.Join(writable, ","))
+	case len(mounted) > 0:
+		add(15, "host mounts %s", strings.Join(mounted, ","))
+	}
+
+	if sc.Capabilities != nil {
+		var added []string
+		for _, capability := range sc.Capabilities.Add {
+			if contains(dangerousCapabilities, strings.TrimPrefix(strings.ToUpper(string(capability)), "CAP_")) {
+				added = append(added, string(capability))
+			}
+		}
+		if len(added) > 0 {
+			add(10, "capabilities %s", strings.Join(added, ","))
+		}
+	}
+	runAsNonRoot := (sc.RunAsNonRoot != nil && *sc.RunAsNonRoot) || (sc.RunAsNonRoot == nil && podSC.RunAsNonRoot != nil && *podSC.RunAsNonRoot)
+	runAsUser := sc.RunAsUser
+	if runAsUser == nil {
+		runAsUser = podSC.RunAsUser
+	}
+	if !runAsNonRoot && (runAsUser == nil || *runAsUser == 0) {
+		add(5, "may run as root")
+	}
+	if sc.AllowPrivilegeEscalation == nil || *sc.AllowPrivilegeEscalation {
+		add(5, "privilege escalation allowed")
+	}
+	if serviceAccountMounted(pod) {
+		add(5, "service account token %s", valueOrDefault(pod.Spec.ServiceAccountName, "default"))
+	}
+	return factors
+}
+
+// serviceAccountMounted tells if the service account token is mounted into containers of a pod.
+func serviceAccountMounted(pod *corev1.Pod) bool {
+	return pod.Spec.AutomountServiceAccountToken == nil || *pod.Spec.AutomountServiceAccountToken
+}
+
+// serviceAccountCheck is a permission of a service account, which adds points to risk scores of containers using it.
+type serviceAccountCheck struct {
+	attributes authorizationV1.ResourceAttributes
+	factor     string
+	points     int
+}
+
+// serviceAccountChecks are checked in order, a cluster admin is not checked any further
+var serviceAccountChecks = []serviceAccountCheck{
+	{authorizationV1.ResourceAttributes{Verb: "*", Group: "*", Resource: "*"}, "service account is cluster admin", 40},
+	{authorizationV1.ResourceAttributes{Verb: "list", Resource: "secrets"}, "service account can list secrets", 20},
+	{authorizationV1.ResourceAttributes{Verb: "create", Resource: "pods"}, "service account can create pods", 15},
+	{authorizationV1.ResourceAttributes{Verb: "create", Resource: "pods", Subresource: "exec"}, "service account can exec into pods", 15},
+}
+
+var (
+	// serviceAccountFactors caches risk factors of service accounts by run/namespace/name, so that permissions
+	// are checked again in each run of the operator
+	serviceAccountFactors sync.Map
+	// serviceAccountsFailed tells if permissions of a service account could not be checked, further failures are
+	// not logged, since they are most likely caused by missing RBAC permissions
+	serviceAccountsFailed atomic.Bool
+)
+
+// serviceAccountRiskFactors returns risk factors of permissions of a service account checked with
+// SubjectAccessReviews in the namespace of the service account.
+func serviceAccountRiskFactors(k8s *k8sexec.K8SExec, serviceAccount string) []RiskFactor {
+	key := runID + "/" + k8s.Namespace + "/" + serviceAccount
+	if cached, ok := serviceAccountFactors.Load(key); ok {
+		return cached.([]RiskFactor)
+	}
+	var factors []RiskFactor
+	for idx, check := range serviceAccountChecks {
+		attributes := check.attributes
+		attributes.Namespace = k8s.Namespace
+		if idx == 0 {
+			attributes.Namespace = ""
+		}
+		review := &authorizationV1.SubjectAccessReview{Spec: authorizationV1.SubjectAccessReviewSpec{
+			User:               fmt.Sprintf("system:serviceaccount:%s:%s", k8s.Namespace, serviceAccount),
+			Groups:             []string{"system:serviceaccounts", "system:serviceaccounts:" + k8s.Namespace, "system:authenticated"},
+			ResourceAttributes: &attributes,
+		}}
+		response, err := clientsetOf(k8s).AuthorizationV1().SubjectAccessReviews().Create(context.TODO(), review, metaV1.CreateOptions{})
+		if err != nil {
+			if serviceAccountsFailed.CompareAndSwap(false, true) {
+				log(fmt.Sprintf("\n[-] Error checking permissions of service accounts, they are left out of risk scores: %s\n", err.Error()))
+			}
+			// a failure is not cached, so that it is not mistaken for no permissions
+			return nil
+		}
+		if response.Status.Allowed {
+			factors = append(factors, RiskFactor{Factor: check.factor, Points: check.points})
+			if idx == 0 {
+				break
+			}
+		}
+	}
+	serviceAccountFactors.Store(key, factors)
+	return factors
+}
+
+// assessRisk scores risk of a scanned container: risk factors of its pod spec and service account, followed by
+// critical and interesting findings, which add at most 30 and 10 points.
+func assessRisk(k8s *k8sexec.K8SExec, result Result) RiskScore {
+	container := result.container.container
+	factors := append([]RiskFactor{}, container.RiskFactors...)
+	if container.ServiceAccount != "" {
+		factors = append(factors, serviceAccountRiskFactors(k8s, container.ServiceAccount)...)
+	}
+	counts := result.findings().CountBySeverity()
+	if critical := counts[SeverityCritical]; critical > 0 {
+		factors = append(factors, RiskFactor{Factor: fmt.Sprintf("%d critical findings", critical), Points: min(critical*10, 30)})
+	}
+	if interesting := counts[SeverityInteresting]; interesting > 0 {
+		factors = append(factors, RiskFactor{Factor: fmt.Sprintf("%d interesting findings", interesting), Points: min(interesting*2, 10)})
+	}
+
+	sort.SliceStable(factors, func(i, j int) bool { return factors[i].Points > factors[j].Points })
+	score := 0
+	for _, factor := range factors {
+		score += factor.Points
+	}
+	return RiskScore{Score: min(score, maxRiskScore), Factors: factors}
+}
+
+// reportable tells if a report of a container is to be saved, i.e. its risk score reaches '--min-score'.
+func (r Result) reportable() bool {
+	return r.risk.Score >= minScore
+}
+
+// reportableResults returns results, which risk scores reach '--min-score'.
+func reportableResults(results []Result) []Result {
+	var reportable []Result
+	for _, result := range results {
+		if result.reportable() {
+			reportable = append(reportable, result)
+		}
+	}
+	return reportable
+}
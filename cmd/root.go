@@ -1,53 +1,107 @@
 package cmd
 
 import (
+	"crypto/sha256"
 	"errors"
 	"fmt"
-	"github.com/hhruszka/k8sexec"
 	"github.com/spf13/cobra"
 	"k8s.io/client-go/util/homedir"
 	"os"
 	"path/filepath"
+	"time"
 )
 
 // CLI options variables
 var (
-	debug         bool
-	kubeconfig    string
-	namespace     string
-	format        string
-	podscli       string
-	containerscli string
-	directory     string
-	quiet         bool
-	version       bool
-	list          bool
+	debug             bool
+	kubeconfig        string
+	namespace         string
+	format            string
+	podscli           string
+	containerscli     string
+	directory         string
+	quiet             bool
+	version           bool
+	list              bool
+	canary            int
+	canaryThreshold   int
+	level             string
+	sections          string
+	labelSelector     string
+	fallbackDirectory string
+	pipeline          bool
+	retryFailedScans  bool
+	merge             bool
+	networkPolicies   bool
+	images            string
+	saveStderr        bool
+	skipHealthCheck   bool
+	showCommands      bool
+	dryRun            bool
+	window            string
+	scriptFile        string
 )
 
+// interactive tells if a user can be asked for confirmations, it is not the case e.g. in the operator mode
+var interactive bool = true
+
 var appName string = filepath.Base(os.Args[0])
 var AppVersion string
 
-func run() error {
-	// go executes defer statements in the LIFO order
-	defer stoplog()
-
+func run() (Manifest, error) {
 	if version {
-		fmt.Println(appName, AppVersion)
-		return nil
+		fmt.Println(appName, buildInfo())
+		return Manifest{}, nil
 	}
 
-	k8sExecClient, err := k8sexec.NewK8SExec(kubeconfig, namespace)
+	if scriptFile != "" {
+		script, err := loadScript(scriptFile)
+		if err != nil {
+			return Manifest{}, err
+		}
+		sum := sha256.Sum256(script)
+		lse, payloadName, payloadPath = script, fmt.Sprintf("%s (sha256 %x)", scriptFile, sum), scriptFile
+	} else {
+		useCachedPayload()
+	}
+
+	k8sExecClient, err := newClient(kubeconfig, namespace)
 	if err != nil {
-		return fmt.Errorf("Internal application error: %s\n", err.Error())
+		return Manifest{}, clientError(err)
+	}
+	defer func() {
+		if err := saveRecording(); err != nil {
+			log(fmt.Sprintf("[-] Error saving recording: %s\n", err.Error()))
+		}
+	}()
+
+	if !skipHealthCheck {
+		if err := healthCheck(k8sExecClient); err != nil {
+			return Manifest{}, err
+		}
+	}
+
+	startRun(k8sExecClient)
+	if argocdApp != "" {
+		return scanArgoCDApp(k8sExecClient)
+	}
+	if targetsFile != "" {
+		return scanTargetsFile(k8sExecClient)
+	}
+	if err := validateNamespaces(k8sExecClient, []string{namespace}); err != nil {
+		return Manifest{}, err
 	}
 
 	if list {
-		return listContainers(k8sExecClient)
+		return Manifest{}, listContainers(k8sExecClient)
 	}
 
 	containers, err := getContainers(k8sExecClient, untangleOption(podscli), untangleOption(containerscli))
 	if err != nil {
-		return err
+		return Manifest{}, err
+	}
+	if watch {
+		return watchPods(k8sExecClient, containers)
 	}
 	return scanContainers(k8sExecClient, containers)
 }
@@ -59,17 +113,210 @@ var cmd = &cobra.Command{
 This application enumerates containers in k8s environment with the Linux Smart Enumeration script. 
 It allows to enumerate all containers from a given namespace, selected pods or selected containers of a given pod.
 It saves an enumeration report for each container separately in a file. The report can be saved in 
-a plain text, ansi or html output format.`,
+a plain text, ansi, html or json output format.`,
 	SilenceErrors: true,
 	PreRunE: func(cmd *cobra.Command, args []string) error {
+		// options not provided on the command line can be set with KUBELSE_* environment variables
+		if err := applyEnvironment(cmd.Flags()); err != nil {
+			return err
+		}
+		if entrypoint {
+			prepareEntrypoint(cmd.Flags())
+		}
+		runOptions = changedOptions(cmd.Flags())
 		// verify value of 'format' option
-		if format != "ansi" && format != "text" && format != "json" {
-			return errors.New("Invalid value of the output format option '-o'. Valid values are ansi, text or html")
+		if format != "ansi" && format != "text" && format != "plain" && format != "html" && format != "json" {
+			return errors.New("Invalid value of the output format option '-o'. Valid values are ansi, text, plain, html or json")
+		}
+		if level != "" {
+			if err := validateLevel(level); err != nil {
+				return fmt.Errorf("Invalid value of the level option '--level': %s", err.Error())
+			}
+		}
+		if sections != "" {
+			if err := validateSections(sections); err != nil {
+				return fmt.Errorf("Invalid value of the sections option '--sections': %s", err.Error())
+			}
+		}
+		if err := compileImagePatterns(images); err != nil {
+			return fmt.Errorf("Invalid value of the images option '--images': %s", err.Error())
+		}
+		if helmRelease != "" && (podscli != "" || labelSelector != "") {
+			return errors.New("The Helm release option '--helm-release' cannot be used together with the options '--pods' and '--selector'")
+		}
+		if argocdApp != "" && (podscli != "" || containerscli != "" || labelSelector != "" || helmRelease != "") {
+			return errors.New("The ArgoCD application option '--argocd-app' cannot be used together with the options '--pods', '--containers', '--selector' and '--helm-release'")
+		}
+		if err := validateScriptVerification(scriptFile, "--script"); err != nil {
+			return err
+		}
+		if ciMode != "" && ciMode != "github" && ciMode != "gitlab" && ciMode != "sarif" {
+			return errors.New("Invalid value of the CI option '--ci'. Valid values are github, gitlab or sarif")
+		}
+		if tenantSink != "" && tenantAnnotation == "" {
+			return errors.New("The tenant sink option '--tenant-sink' requires namespaces partitioned with '--tenant-annotation'")
+		}
+		if err := validateOffline(); err != nil {
+			return err
+		}
+		if createIssues != "" {
+			if _, err := parseIssuesTarget(createIssues); err != nil {
+				return fmt.Errorf("Invalid value of the issues option '--create-issues': %s", err.Error())
+			}
+			if _, err := loadJiraConfig(jiraConfig); err != nil {
+				return fmt.Errorf("Invalid Jira configuration: %s", err.Error())
+			}
+		}
+		if err := loadLanguage(lang); err != nil {
+			return fmt.Errorf("Invalid value of the language option '--lang': %s", err.Error())
+		}
+		if err := loadRemediations(remediationFile); err != nil {
+			return fmt.Errorf("Invalid value of the remediation option '--remediation': %s", err.Error())
+		}
+		if anonymize {
+			if anonymizeMap == "" {
+				file, err := defaultAnonymizeMap()
+				if err != nil {
+					return fmt.Errorf("Invalid value of the pseudonyms option '--anonymize-map': %s", err.Error())
+				}
+				anonymizeMap = file
+			}
+			if err := loadPseudonyms(anonymizeMap); err != nil {
+				return fmt.Errorf("Invalid value of the pseudonyms option '--anonymize-map': %s", err.Error())
+			}
+		}
+		if err := loadIgnoreFile(ignoreFile); err != nil {
+			return fmt.Errorf("Invalid value of the ignore file option '--ignore-file': %s", err.Error())
+		}
+		if (pullSecrets || dockerConfig != "" || registryConfig != "") && !imageConfig {
+			return errors.New("The registry options '--pull-secrets', '--docker-config' and '--registry-config' require the image config option '--image-config'")
+		}
+		if imageConfig {
+			if err := loadDockerConfig(dockerConfig); err != nil {
+				return fmt.Errorf("Invalid value of the docker config option '--docker-config': %s", err.Error())
+			}
+			if err := loadRegistryConfig(registryConfig); err != nil {
+				return fmt.Errorf("Invalid value of the registry config option '--registry-config': %s", err.Error())
+			}
+		}
+		if err := loadTriage(); err != nil {
+			return fmt.Errorf("Invalid value of the results DB option '--results-db': %s", err.Error())
+		}
+		if err := loadOwners(ownersFile); err != nil {
+			return fmt.Errorf("Invalid value of the owners option '--owners': %s", err.Error())
+		}
+		if maxFailures != "" {
+			if _, err := parseMaxFailures(maxFailures, 0); err != nil {
+				return fmt.Errorf("Invalid value of the max failures option '--max-failures': %s", err.Error())
+			}
+		}
+		if policyExitCode < 1 || policyExitCode > 125 {
+			return errors.New("Invalid value of the policy exit code option '--policy-exit-code'. It has to be 1-125")
+		}
+		if err := validateSinks(sinkSpecs); err != nil {
+			return fmt.Errorf("Invalid value of the sink option '--sink': %s", err.Error())
+		}
+		if err := validateExcludePaths(excludePaths); err != nil {
+			return fmt.Errorf("Invalid value of the exclude paths option '--exclude-paths': %s", err.Error())
+		}
+		if _, err := parseLseEnv(lseEnv); err != nil {
+			return fmt.Errorf("Invalid value of the environment option '--lse-env': %s", err.Error())
+		}
+		if stallTimeout < 0 {
+			return errors.New("Invalid value of the stall timeout option '--stall-timeout'. It cannot be negative")
+		}
+		if watch && (podscli != "" || containerscli != "" || argocdApp != "" || targetsFile != "" || list || dryRun) {
+			return errors.New("The watch option '--watch' cannot be used together with the options '--pods', '--containers', '--argocd-app', '--targets-file', '--list' and '--dry-run'")
+		}
+		if watchInterval <= 0 {
+			return errors.New("Invalid value of the watch interval option '--watch-interval'. It has to be positive")
+		}
+		if err := validateProvider(provider); err != nil {
+			return fmt.Errorf("Invalid value of the provider option '--provider': %s", err.Error())
+		}
+		if err := validateStallAction(stallAction); err != nil {
+			return fmt.Errorf("Invalid value of the stall action option '--stall-action': %s", err.Error())
+		}
+		if maxReportSize != "" {
+			if _, err := parseSize(maxReportSize); err != nil {
+				return fmt.Errorf("Invalid value of the report size option '--max-report-size': %s", err.Error())
+			}
+		}
+		if maxOutputSize != "" {
+			if _, err := parseSize(maxOutputSize); err != nil {
+				return fmt.Errorf("Invalid value of the output size option '--max-output-size': %s", err.Error())
+			}
+		}
+		if targetsFile != "" && (podscli != "" || containerscli != "" || labelSelector != "" || helmRelease != "" || argocdApp != "") {
+			return errors.New("The targets option '--targets-file' cannot be used together with the options '--pods', '--containers', '--selector', '--helm-release' and '--argocd-app'")
+		}
+		if targetsFile == "-" {
+			// stdin is taken by the targets, so nobody can confirm anything
+			interactive = false
+		}
+		if err := validateTimestampFormat(timestampFormat); err != nil {
+			return fmt.Errorf("Invalid value of the timestamp format option '--timestamp-format': %s", err.Error())
+		}
+		if pipeline && (dryRun || showCommands) {
+			return errors.New("The options '--dry-run' and '--print-commands' cannot be used together with the pipeline option '--pipeline'")
+		}
+		if window != "" {
+			var err error
+			if scanWindow, err = parseWindow(window); err != nil {
+				return fmt.Errorf("Invalid value of the window option '--window': %s", err.Error())
+			}
+		}
+		if recordFile != "" && replayFile != "" {
+			return errors.New("The record option '--record' cannot be used together with the replay option '--replay'")
+		}
+		if pipeline && canary > 0 {
+			return errors.New("The canary option '--canary' cannot be used together with the pipeline option '--pipeline'")
+		}
+		if pace < 0 {
+			return errors.New("Invalid value of the pace option '--pace'. It cannot be negative")
+		}
+		if canary < 0 {
+			return errors.New("Invalid value of the canary option '--canary'. It cannot be negative")
+		}
+		if canaryThreshold < 0 || canaryThreshold > 100 {
+			return errors.New("Invalid value of the canary threshold option '--canary-threshold'. Valid values are 0-100")
+		}
+		if minScore < 0 || minScore > maxRiskScore {
+			return fmt.Errorf("Invalid value of the minimal risk score option '--min-score'. Valid values are 0-%d", maxRiskScore)
+		}
+		if goldenTimeout <= 0 {
+			return errors.New("Invalid value of the golden timeout option '--golden-timeout'. It has to be positive")
+		}
+		if err := validateImpersonation(); err != nil {
+			return err
+		}
+		if err := validateAsUser(asUser); err != nil {
+			return err
+		}
+		if err := validateSharding(); err != nil {
+			return err
+		}
+		if err := validateUsageThresholds(); err != nil {
+			return err
 		}
 		return nil
 	},
 	RunE: func(cmd *cobra.Command, args []string) error {
-		return run()
+		if controlSocket != "" && !version {
+			stop, err := serveControlSocket(controlSocket)
+			if err != nil {
+				return fmt.Errorf("[-] Error opening control socket %s: %s\n", controlSocket, err.Error())
+			}
+			defer stop()
+		}
+		started := now()
+		manifest, err := run()
+		if statusFile != "" && !version {
+			if statusErr := saveRunStatus(started, manifest, err); statusErr != nil {
+				log(fmt.Sprintf("[-] Error saving status file %s: %s\n", statusFile, statusErr.Error()))
+			}
+		}
+		return err
 	},
 }
 
@@ -85,13 +332,105 @@ func init() {
 		os.Exit(1)
 	}
 	cmd.Flags().StringVarP(&directory, "directory", "d", workingDirectory, "a directory where reports should be saved to")
-	cmd.Flags().StringVarP(&format, "output", "o", "ansi", "Output format: ansi, text, or html")
+	cmd.Flags().BoolVar(&flat, "flat", false, "save reports directly in the reports directory, instead of a directory of every run linked as latest")
+	cmd.Flags().StringVar(&fallbackDirectory, "fallback-directory", filepath.Join(os.TempDir(), "kubelse"), "a directory where reports are saved to, when saving them to the reports directory fails")
+	cmd.Flags().StringVarP(&format, "output", "o", "ansi", "Output format: ansi, text, plain, html or json, plain strips all escape sequences from lse.sh output")
 	cmd.Flags().StringVarP(&namespace, "namespace", "n", "default", "a namespace")
 	cmd.Flags().StringVarP(&podscli, "pods", "p", "", "a pod or comma-separated pods, which containers are to be enumerated, if not provided then all containers in a namespace will be enumerated.")
+	cmd.Flags().StringVar(&labelSelector, "selector", "", "a label selector of pods to be enumerated, e.g. app=nginx, if provided then all matching pods are enumerated")
+	cmd.Flags().StringVar(&helmRelease, "helm-release", "", "a Helm release, which pods are to be enumerated, e.g. myapp, pods are found by release labels and annotations")
+	cmd.Flags().StringVar(&argocdApp, "argocd-app", "", "an ArgoCD application, which pods are to be enumerated in all namespaces it deploys to")
+	cmd.Flags().StringVar(&argocdLabel, "argocd-label", "app.kubernetes.io/instance", "a label ArgoCD tracks application resources with")
+	cmd.Flags().StringVar(&targetsFile, "targets-file", "", "a file with containers to be enumerated, one namespace/pod/container or namespace/pod per line, '-' reads them from stdin")
 	cmd.Flags().StringVarP(&containerscli, "containers", "c", "", "a container or comma-separated containers to be enumerated")
 	cmd.Flags().BoolVarP(&quiet, "quiet", "q", false, "quiet execution - no status information")
 	cmd.Flags().BoolVarP(&version, "version", "v", false, "prints "+appName+" version")
 	cmd.Flags().BoolVarP(&list, "list", "l", false, "list containers, no enumeration executed")
+	cmd.Flags().StringVar(&level, "level", "", "lse.sh verbosity level: 0, 1 or 2, if not provided then lse.sh default is used")
+	cmd.Flags().StringVar(&asUser, "as-user", "", "a uid lse.sh is run as, where setpriv, runuser or su allow it, to enumerate from the perspective of a non-root application user")
+	cmd.Flags().StringVar(&sections, "sections", "", "comma-separated lse.sh sections or tests to be run, e.g. fst,sud, if not provided then all are run")
+	cmd.Flags().BoolVar(&retryFailedScans, "retry-failed", false, "scan again containers, in which scans failed, without asking for confirmation")
+	cmd.Flags().StringVar(&images, "images", "", "comma-separated image patterns, e.g. nginx:1.25,registry/internal/*, containers running matching images in all pods are enumerated")
+	cmd.Flags().BoolVar(&saveStderr, "save-stderr", false, "save stderr of lse.sh in a companion .err file of every report, or in the Stderr field of json reports")
+	cmd.Flags().BoolVar(&skipHealthCheck, "skip-health-check", false, "do not probe the connection to the cluster before discovering containers")
+	cmd.Flags().BoolVar(&showCommands, "print-commands", false, "print the exact command and payload delivery method used in every container before scanning")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "verify containers and print commands, which would be executed in them, without scanning")
+	cmd.Flags().StringVar(&recordFile, "record", "", "record container listings and exec responses of the run to a fixture file")
+	cmd.Flags().StringVar(&replayFile, "replay", "", "run against a fixture file recorded with '--record' instead of a cluster")
+	cmd.Flags().DurationVar(&pace, "pace", 0, "a minimum delay between successive exec starts of every worker, e.g. 500ms, to avoid API server bursts")
+	cmd.Flags().StringVar(&scriptFile, "script", "", "a script file run in containers instead of the embedded lse.sh, it has to accept lse.sh options")
+	addScriptVerificationFlags(cmd.Flags())
+	addAuditFlags(cmd.Flags())
+	addAuthFlags(cmd.Flags())
+	cmd.PersistentFlags().BoolVar(&offline, "offline", false, "disable all network access outside of the scanned cluster, e.g. sinks, issue trackers and updates, and fail any attempt of it, also enabled by KUBELSE_OFFLINE=1")
+	cmd.Flags().BoolVar(&entrypoint, "entrypoint", false, "run as a container entrypoint, e.g. in a Job: never ask for confirmations, use in-cluster configuration and write a status file")
+	cmd.Flags().StringVar(&controlSocket, "control-socket", "", "accept pause, resume, status and reduce-concurrency commands of 'kubelse control' on this unix socket during the run")
+	cmd.Flags().StringVar(&statusFile, "status-file", "", "write a machine-readable status of the run to this file, in the entrypoint mode it defaults to kubelse-status.json in the reports directory")
+	cmd.Flags().StringVar(&lang, "lang", defaultLanguage, "a language of text generated by kubelse, e.g. report headers, summaries and remediation guidance: en or de, output of lse.sh stays English")
+	cmd.Flags().StringVar(&maxCPU, "max-cpu", "", "abort the scan of a container, or do not start it, when its CPU usage read from the metrics API exceeds this quantity, e.g. 500m")
+	cmd.Flags().StringVar(&maxMemory, "max-memory", "", "abort the scan of a container, or do not start it, when its memory usage read from the metrics API exceeds this quantity, e.g. 1Gi")
+	cmd.Flags().DurationVar(&usageInterval, "usage-interval", 10*time.Second, "how often usage of containers is sampled during scans with '--max-cpu' or '--max-memory'")
+	cmd.Flags().BoolVar(&respectPDBCritical, "respect-pdb-critical", false, "skip containers of critical workloads, protected by PodDisruptionBudgets allowing no disruptions or of critical priority classes, instead of warning about them")
+	cmd.Flags().StringVar(&shard, "shard", "", "scan only pods of a shard, e.g. 2/5, pods are split between instances by a hash of their UID, so that large clusters can be scanned by several concurrent instances")
+	cmd.Flags().StringVar(&runIDFlag, "run-id", "", "an ID of the run, a UUID, shared by shards of a run, so that their results are merged by it, generated if not provided")
+	cmd.Flags().StringVar(&window, "window", "", "a maintenance window, in which containers can be scanned, e.g. \"Sat 01:00-05:00 UTC\", scans are paused outside of it")
+	cmd.Flags().BoolVar(&networkPolicies, "network-policies", false, "check if scanned pods are covered by ingress and egress network policies and report uncovered ones")
+	cmd.Flags().StringVar(&ciMode, "ci", "", "surface critical and interesting findings in a CI pipeline, keyed by workload: github (workflow annotations), gitlab (code quality report) or sarif (SARIF log)")
+	cmd.Flags().StringVar(&ciFile, "ci-file", "", "a file the GitLab code quality report or the SARIF log is saved to, if not provided then gl-code-quality-report.json or kubelse.sarif in the reports directory")
+	cmd.Flags().StringVar(&createIssues, "create-issues", "", "open an issue for every critical finding of a workload in an issue tracker, e.g. jira://SEC, findings with unresolved issues are skipped")
+	cmd.Flags().StringVar(&jiraConfig, "jira-config", "", "a json file configuring Jira issues: URL, User, IssueType, Labels and MinSeverity, the token is read from JIRA_API_TOKEN")
+	cmd.Flags().StringVar(&ignoreFile, "ignore-file", defaultIgnoreFile, "a file listing namespaces, workloads and containers to be skipped and findings to be suppressed, see README")
+	cmd.Flags().StringVar(&remediationFile, "remediation", "", "a YAML file mapping lse.sh test IDs to remediation guidance, which overrides and extends the embedded catalog")
+	cmd.Flags().BoolVar(&anonymize, "anonymize", false, "replace pod, workload, namespace and cluster names, hostnames and IP addresses in reports and manifests with stable pseudonyms, e.g. to share them externally")
+	cmd.Flags().StringVar(&anonymizeMap, "anonymize-map", "", "a file pseudonyms are kept in across runs, if not provided then pseudonyms.json in the kubelse user configuration directory")
+	cmd.Flags().BoolVar(&dashboard, "dashboard", false, "save also an html dashboard of the run with charts of findings by severity, the riskiest containers and findings by namespace")
+	cmd.Flags().StringVar(&ownersFile, "owners", "", "a json file attributing workloads and namespaces to owning teams, merged reports and issues are grouped by owner")
+	cmd.Flags().StringVar(&ownerLabel, "owner-label", "team", "a pod label naming the team owning the pod, used for workloads not in the owners file")
+	cmd.Flags().StringVar(&maxFailures, "max-failures", "", "abort the run when more execs fail than a number or a percentage of containers, e.g. 10 or 5%, remaining containers are not scanned")
+	cmd.Flags().StringVar(&grpcSink, "grpc-sink", "", "stream results of scanned containers to a gRPC collector at host:port, see proto/kubelse/collector/v1/collector.proto")
+	cmd.Flags().BoolVar(&grpcInsecure, "grpc-insecure", false, "connect to the gRPC collector without TLS")
+	cmd.Flags().StringVar(&postHook, "post-hook", "", "a command run with a shell for every completed report, it gets the report's metadata as json through stdin and KUBELSE_* environment variables")
+	cmd.Flags().DurationVar(&postHookTimeout, "post-hook-timeout", 5*time.Minute, "a timeout of a single run of the post hook")
+	cmd.Flags().StringVar(&sinkSpecs, "sink", "file", "comma separated destinations of reports: file, stdout, archive=<file.tar.gz>, http=<url>, splunk=<url> or s3://<bucket>/<prefix>")
+	cmd.Flags().StringVar(&policyCommand, "policy", "", "a command evaluating a policy, e.g. opa eval, against findings and pod metadata passed as json through its stdin, it prints a json list of violations")
+	cmd.Flags().IntVar(&policyExitCode, "policy-exit-code", 3, "an exit code of runs, which violate the policy")
+	cmd.Flags().StringVar(&excludePaths, "exclude-paths", "", "comma-separated absolute paths excluded from searches of lse.sh, e.g. /data,/mnt/share")
+	cmd.Flags().BoolVar(&excludeNetworkMounts, "exclude-network-mounts", false, "exclude mount points of network and FUSE filesystems, e.g. NFS or CIFS volumes, from searches of lse.sh")
+	cmd.Flags().StringVar(&lseEnv, "lse-env", "", "comma-separated NAME=VALUE environment variables set for lse.sh, e.g. LC_ALL=C")
+	cmd.Flags().DurationVar(&stallTimeout, "stall-timeout", 0, "cancel lse.sh in a container, once it produces no output for a duration, e.g. 10m, 0 disables stall detection")
+	cmd.Flags().StringVar(&stallAction, "stall-action", "retry", "what is done with stalled containers: retry (once at level 0 with network mounts excluded) or mark")
+	cmd.Flags().StringVar(&provider, "provider", "auto", "a provider of the cluster: auto (detected from its version and nodes), eks, gke, aks, openshift, k3s or none, adds provider-specific hints and checks")
+	cmd.Flags().BoolVar(&watch, "watch", false, "keep running after the scan and scan pods matching the selection as they become ready, e.g. CI runners or pods of cron jobs")
+	cmd.Flags().DurationVar(&watchInterval, "watch-interval", 15*time.Second, "how often new pods are looked for with '--watch'")
+	cmd.Flags().BoolVar(&chunked, "chunked", false, "run lse.sh in an exec per section and aggregate their output, for clusters, which kill exec sessions after a few minutes")
+	cmd.Flags().StringVar(&maxReportSize, "max-report-size", "", "truncate output of lse.sh exceeding a size, e.g. 10M, keeping its head and tail with a truncation banner in between")
+	cmd.Flags().StringVar(&maxOutputSize, "max-output-size", "", "stop writing reports, and scanning, when reports of the run exceed a size, e.g. 500M or 2G")
+	cmd.Flags().StringVar(&timestampFormat, "timestamp-format", "rfc3339", "a format of timestamps in reports, manifests and file names: rfc3339, rfc1123, legacy (2006-01-02-150405) or a Go time layout")
+	cmd.Flags().BoolVar(&utc, "utc", true, "use UTC in timestamps, '--utc=false' uses the local time zone")
+	cmd.Flags().BoolVar(&hashInventory, "hash-inventory", false, "hash key binaries and setuid binaries found by lse.sh in every container and report containers, which binaries differ from other containers of the same image")
+	cmd.Flags().BoolVar(&metadataProbe, "metadata-probe", false, "probe instance metadata services of AWS, GCP and Azure from every container and report containers, which can get cloud credentials (cld000, cld010)")
+	cmd.Flags().BoolVar(&imageConfig, "image-config", false, "read the config of the image of every container from its registry and compare it with the pod spec (img000, img010, img020, img030, img040)")
+	cmd.Flags().StringVar(&dockerConfig, "docker-config", "", "a docker config.json with credentials of registries images are read from, if not provided then config.json in DOCKER_CONFIG or ~/.docker if it exists")
+	cmd.Flags().StringVar(&registryConfig, "registry-config", "", "a json file with settings of registries images are read from, e.g. mirrors, CAs and credentials, see README")
+	cmd.Flags().BoolVar(&pullSecrets, "pull-secrets", false, "read images of pods from registries with their image pull secrets, which requires get access to secrets")
+	cmd.Flags().BoolVar(&apiProbe, "api-probe", false, "check from every container whether it reaches the Kubernetes API and what its service account token is allowed (api000, api010, api020)")
+	cmd.Flags().StringVar(&tenantAnnotation, "tenant-annotation", "", "a namespace annotation naming the tenant owning a namespace, e.g. example.com/tenant, reports of every tenant are saved in a directory of their own")
+	cmd.Flags().StringVar(&tenantSink, "tenant-sink", "", "additional sinks of reports of every tenant, {tenant} is replaced with the tenant, e.g. s3://reports/{tenant} or http=https://hooks.example.com/{tenant}")
+	cmd.Flags().BoolVar(&trackGaps, "track-gaps", false, "track containers, which could not be tested, across runs in the results DB by IDs, so that they can be accepted or remediated")
+	cmd.Flags().StringVar(&resultsDBFile, "results-db", "", "a file results of runs, e.g. coverage gaps and triage decisions, are kept in, if not provided then results.json in the kubelse user configuration directory")
+	cmd.Flags().BoolVar(&coverage, "coverage", false, "save a coverage report of the run: containers in scope, scanned, failed and skipped with reasons, and coverage per namespace and workload")
+	cmd.Flags().BoolVar(&cronSummary, "cron-summary", false, "save cron jobs and systemd timers found by lse.sh deduplicated per workload, each with the containers it was found in")
+	cmd.Flags().IntVar(&minScore, "min-score", 0, "save reports, merged reports, CI annotations and issues only for containers, which risk score (0-100) is at least this")
+	cmd.Flags().BoolVar(&events, "events", false, "emit Kubernetes Events LseScanStarted, LseScanCompleted and LseScanFailed on scanned pods, enabled by default in the entrypoint mode")
+	cmd.Flags().BoolVar(&golden, "golden", false, "deploy a golden pod, freshly started from the image, for every scanned image and save findings of containers missing in the golden container of their image, i.e. runtime drift")
+	cmd.Flags().DurationVar(&goldenTimeout, "golden-timeout", 2*time.Minute, "how long golden pods are waited for to start")
+	cmd.Flags().BoolVar(&suidPivot, "suid-pivot", false, "save a fleet-wide list of setuid and setgid binaries found by lse.sh, each with the containers it was found in")
+	cmd.Flags().BoolVar(&merge, "merge", false, "save also a merged report, in which identical findings of containers of the same workload are collapsed")
+	cmd.Flags().BoolVar(&pipeline, "pipeline", false, "start scanning containers as soon as they are verified, the confirmation is requested before verification")
+	cmd.Flags().IntVar(&canary, "canary", 0, "number of randomly selected containers to scan first, before proceeding with the rest")
+	cmd.Flags().IntVar(&canaryThreshold, "canary-threshold", 0, "minimal canary success rate in percent to proceed without confirmation, if not provided then confirmation is requested")
+
+	registerFlagCompletions(cmd)
 
 	// Disable automatic printing of usage when an error occurs
 	cmd.SilenceUsage = true
@@ -102,6 +441,7 @@ func init() {
 		if err := cmd.ParseFlags(args); err != nil {
 			return err
 		}
+		enterOfflineMode()
 		return nil
 	}
 
@@ -115,6 +455,22 @@ func init() {
 	})
 }
 
+// ExitError is an error, with which kubelse exits with a specific exit code, e.g. the exit code of a failed policy.
+type ExitError struct {
+	Code int
+	Err  error
+}
+
+func (e *ExitError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ExitError) Unwrap() error {
+	return e.Err
+}
+
 func Execute() error {
+	// go executes defer statements in the LIFO order, so log messages are flushed after a command finishes
+	defer stoplog()
 	return cmd.Execute()
 }
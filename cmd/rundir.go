@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// run directory CLI options variables
+var flat bool
+
+// latestRunLink is a symbolic link in the reports directory to the directory of the latest run
+const latestRunLink = "latest"
+
+// runDirectory is the directory reports of the latest run were saved in
+var runDirectory string
+
+// enterRunDirectory points the reports directory to a new directory of the run, named by its start and run ID, so
+// that files of consecutive or concurrent runs never interleave, and links it as latest. Directories of runs of a
+// tenant are created in the directory of the tenant. It returns a function pointing the reports directory back,
+// once the run is over. With '--flat' reports are saved in the reports directory, or the tenant's, itself.
+func enterRunDirectory(started time.Time) func() {
+	root := directory
+	runDirectory = root
+	dir := tenantDirectory(root)
+	if !flat {
+		dir = filepath.Join(dir, fmt.Sprintf("%s-%s", fileTimestamp(started), shortRunID()))
+	}
+	if dryRun || dir == root {
+		return func() {}
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		log(fmt.Sprintf("[-] Error creating a directory of the run, reports are saved in %s: %s\n", root, err.Error()))
+		return func() {}
+	}
+	if !flat {
+		linkLatestRun(filepath.Dir(dir), filepath.Base(dir))
+	}
+	log(fmt.Sprintf("[+] Reports of the run are saved in %s\n", dir))
+	directory, runDirectory = dir, dir
+	return func() { directory = root }
+}
+
+// linkLatestRun points the latest link of the reports directory to a directory of a run. The link is replaced
+// atomically, so that it always points to a complete name, even when runs start at the same time.
+func linkLatestRun(root string, name string) {
+	link := filepath.Join(root, latestRunLink)
+	if info, err := os.Lstat(link); err == nil && info.Mode()&os.ModeSymlink == 0 {
+		log(fmt.Sprintf("[-] %s is not a symbolic link, it is not pointed to the latest run\n", link))
+		return
+	}
+	tmp := fmt.Sprintf("%s.%s", link, shortRunID())
+	err := os.Symlink(name, tmp)
+	if err == nil {
+		err = os.Rename(tmp, link)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		log(fmt.Sprintf("[-] Error linking %s to the latest run: %s\n", link, err.Error()))
+	}
+}
@@ -0,0 +1,33 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/hhruszka/k8sexec"
+)
+
+// runID uniquely identifies a run, it is put in names and contents of all artifacts of the run and in logs, so
+// that artifacts of concurrent or repeated runs can be correlated
+var runID string
+
+// newRun generates an ID of a new run, or takes the one of '--run-id' shared by shards of a run.
+func newRun() {
+	runID = valueOrDefault(runIDFlag, uuid.NewString())
+}
+
+// startRun starts a new run with requests of the client identifying it. A run covers an invocation, all its
+// namespaces included; only scans started later by watching or by the operator are runs of their own.
+func startRun(k8s *k8sexec.K8SExec) {
+	newRun()
+	identifyRun(k8s)
+	log(fmt.Sprintf("[+] Started run %s\n", runID))
+}
+
+// shortRunID returns the first group of the run ID, followed by the shard of this instance, which is put in file
+// names.
+func shortRunID() string {
+	if len(runID) < 8 {
+		return runID + shardSuffix()
+	}
+	return runID[:8] + shardSuffix()
+}
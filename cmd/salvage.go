@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// number of attempts to write a report, before falling back to the fallback directory
+const writeAttempts = 3
+
+// writeReport writes a report to a file. Writing is retried a few times, because some failures, e.g. caused by
+// a full disk being cleaned up, are transient.
+func writeReport(fileName string, report []byte) error {
+	if err := outputQuota.reserve(len(report)); err != nil {
+		return err
+	}
+	var err error
+	for attempt := 1; attempt <= writeAttempts; attempt++ {
+		if err = os.WriteFile(fileName, report, 0666); err == nil {
+			return nil
+		}
+		if attempt < writeAttempts {
+			time.Sleep(time.Duration(attempt) * time.Second)
+		}
+	}
+	return err
+}
+
+// writeReportWithFallback writes a report to the reports directory and, if that fails, to the fallback
+// directory. It returns a name of the file the report was written to.
+func writeReportWithFallback(fileName string, report []byte) (string, error) {
+	// anonymizing is idempotent, reports of containers are anonymized already
+	fileName, report = anonymizedName(fileName), anonymized(report)
+	err := writeReport(filepath.Join(directory, fileName), report)
+	if err == nil {
+		return filepath.Join(directory, fileName), nil
+	}
+	if fallbackDirectory == "" || fallbackDirectory == directory || errors.Is(err, errOutputQuotaExceeded) {
+		return "", err
+	}
+
+	log(fmt.Sprintf("[-] Error saving report %s: %s, saving it to %s\n", fileName, err.Error(), fallbackDirectory))
+	if err := os.MkdirAll(fallbackDirectory, 0755); err != nil {
+		return "", err
+	}
+	if err := writeReport(filepath.Join(fallbackDirectory, fileName), report); err != nil {
+		return "", err
+	}
+	return filepath.Join(fallbackDirectory, fileName), nil
+}
+
+// salvageUnsaved lists containers, which reports could not be delivered to any sink, and writes their reports to a
+// salvage file of the run, so that they neither get lost nor mixed into reports streamed to the standard output.
+// The file is written to the fallback directory, if the reports directory is not writable, and, as a last resort,
+// reports are dumped to the standard error.
+func salvageUnsaved(started time.Time, results []Result) {
+	var (
+		unsaved []Result
+		content bytes.Buffer
+	)
+	for _, result := range results {
+		if !result.delivered && result.reportable() {
+			unsaved = append(unsaved, result)
+			content.WriteString(strings.Join(reportLines(result), "\n") + "\n")
+		}
+	}
+	if len(unsaved) == 0 {
+		return
+	}
+
+	log(fmt.Sprintf("[-] Reports of following %d containers could not be saved:\n", len(unsaved)))
+	for _, result := range unsaved {
+		log(fmt.Sprintf("    %s/%s\n", result.container.container.Pod, result.container.container.Container))
+	}
+	// the output quota is not reserved, reports may be unsaved because of it
+	report := anonymized(content.Bytes())
+	var err error
+	for _, dir := range []string{directory, fallbackDirectory} {
+		if dir == "" {
+			continue
+		}
+		fileName := filepath.Join(dir, reportFileName(dir, ".txt", "kubelse-salvaged", fileTimestamp(started), shortRunID()))
+		if err = os.WriteFile(fileName, report, 0666); err == nil {
+			log(fmt.Sprintf("[-] Their reports are salvaged to %s\n", fileName))
+			return
+		}
+	}
+	log(fmt.Sprintf("[-] Error saving salvaged reports: %s, dumping them to the standard error\n", err.Error()))
+	os.Stderr.Write(report)
+}
@@ -0,0 +1,164 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// sarifLevels maps finding severities to levels of SARIF results, info findings are not exported.
+var sarifLevels = map[string]string{
+	SeverityCritical:    "error",
+	SeverityInteresting: "warning",
+}
+
+// SarifLog is a SARIF 2.1.0 log of findings of a run, e.g. for GitHub code scanning or DefectDojo.
+type SarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []SarifRun `json:"runs"`
+}
+
+// SarifRun is a run of a SARIF log.
+type SarifRun struct {
+	Tool    SarifTool     `json:"tool"`
+	Results []SarifResult `json:"results"`
+}
+
+// SarifTool describes kubelse and tests of lse.sh as rules.
+type SarifTool struct {
+	Driver SarifDriver `json:"driver"`
+}
+
+// SarifDriver is the tool component of a SARIF run.
+type SarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Version        string      `json:"version,omitempty"`
+	Rules          []SarifRule `json:"rules"`
+}
+
+// SarifRule is an lse.sh test.
+type SarifRule struct {
+	ID               string        `json:"id"`
+	Name             string        `json:"name"`
+	ShortDescription SarifMessage  `json:"shortDescription"`
+	Help             *SarifMessage `json:"help,omitempty"`
+}
+
+// SarifMessage is a text of a SARIF object.
+type SarifMessage struct {
+	Text string `json:"text"`
+}
+
+// SarifResult is a finding of a workload, workloads are used as artifact locations.
+type SarifResult struct {
+	RuleID              string             `json:"ruleId"`
+	Level               string             `json:"level"`
+	Message             SarifMessage       `json:"message"`
+	Locations           []SarifLocation    `json:"locations"`
+	PartialFingerprints map[string]string  `json:"partialFingerprints"`
+	Suppressions        []SarifSuppression `json:"suppressions,omitempty"`
+}
+
+// SarifLocation is a location of a SARIF result.
+type SarifLocation struct {
+	PhysicalLocation SarifPhysicalLocation `json:"physicalLocation"`
+}
+
+// SarifPhysicalLocation points at the workload of a SARIF result.
+type SarifPhysicalLocation struct {
+	ArtifactLocation SarifArtifactLocation `json:"artifactLocation"`
+}
+
+// SarifArtifactLocation is a URI of the workload of a SARIF result.
+type SarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+// SarifSuppression records a triage decision dismissing a finding, so that downstream systems do not re-open it.
+type SarifSuppression struct {
+	Kind          string `json:"kind"`
+	Status        string `json:"status"`
+	Justification string `json:"justification,omitempty"`
+}
+
+// sarifSuppressions returns suppressions of a finding triaged as a false positive or an accepted risk.
+func sarifSuppressions(finding Finding) []SarifSuppression {
+	if finding.Triage == nil || (finding.Triage.State != TriageFalsePositive && finding.Triage.State != TriageAcceptedRisk) {
+		return nil
+	}
+	justification := finding.Triage.String()
+	if finding.Triage.Analyst != "" {
+		justification += " (" + finding.Triage.Analyst + ")"
+	}
+	return []SarifSuppression{{Kind: "external", Status: "accepted", Justification: justification}}
+}
+
+// sarifLog returns a SARIF log of critical and interesting findings of every workload. Unlike CI annotations,
+// findings triaged as false positives or accepted risks are kept and carry suppressions, so that downstream
+// systems close them instead of re-opening them as new.
+func sarifLog(results []Result) SarifLog {
+	var (
+		sarifResults = []SarifResult{}
+		rules        = make(map[string]SarifRule)
+		index        = make(map[string]int)
+		containers   = make(map[string][]string)
+	)
+	for _, result := range results {
+		workload := valueOrDefault(result.container.container.Workload, "unknown")
+		for _, finding := range result.findings().Findings {
+			level, ok := sarifLevels[finding.Severity]
+			if !ok || finding.Result != "yes!" || finding.Suppressed != "" {
+				continue
+			}
+			path := fmt.Sprintf("%s/%s", namespace, workload)
+			key := path + "\x00" + finding.ID + "\x00" + strings.Join(finding.Details, "\n")
+			containers[key] = append(containers[key], result.container.container.String())
+			if _, ok := index[key]; ok {
+				continue
+			}
+			if _, ok := rules[finding.ID]; !ok {
+				rule := SarifRule{ID: finding.ID, Name: finding.Name, ShortDescription: SarifMessage{Text: finding.Name}}
+				if finding.Remediation != "" {
+					rule.Help = &SarifMessage{Text: finding.Remediation}
+				}
+				rules[finding.ID] = rule
+			}
+			sum := sha256.Sum256([]byte(key))
+			index[key] = len(sarifResults)
+			sarifResults = append(sarifResults, SarifResult{
+				RuleID:              finding.ID,
+				Level:               level,
+				Locations:           []SarifLocation{{PhysicalLocation: SarifPhysicalLocation{ArtifactLocation: SarifArtifactLocation{URI: path}}}},
+				PartialFingerprints: map[string]string{"kubelse/v1": fmt.Sprintf("%x", sum)},
+				Suppressions:        sarifSuppressions(finding),
+			})
+		}
+	}
+	// messages list every affected container of a workload
+	for key, idx := range index {
+		parts := strings.SplitN(key, "\x00", 3)
+		message := fmt.Sprintf("%s %s: %s, affected containers: %s", parts[0], parts[1], rules[parts[1]].Name, strings.Join(containers[key], ", "))
+		if parts[2] != "" {
+			message += "\n" + parts[2]
+		}
+		sarifResults[idx].Message = SarifMessage{Text: message}
+	}
+
+	ids := make([]string, 0, len(rules))
+	for id := range rules {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	driver := SarifDriver{Name: "kubelse", InformationURI: "https://github.com/hhruszka/kubelse", Version: AppVersion, Rules: []SarifRule{}}
+	for _, id := range ids {
+		driver.Rules = append(driver.Rules, rules[id])
+	}
+	return SarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []SarifRun{{Tool: SarifTool{Driver: driver}, Results: sarifResults}},
+	}
+}
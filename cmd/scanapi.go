@@ -0,0 +1,222 @@
+package cmd
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/hhruszka/k8sexec"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// scan API CLI options variables
+var (
+	operatorListen  string
+	operatorTLSCert string
+	operatorTLSKey  string
+)
+
+// how long finished scans are kept, so their clients can read their outcome
+const scanRetention = 24 * time.Hour
+
+// an environment variable with the bearer token of the scan API, clients of the API send the same token
+const apiTokenVariable = "KUBELSE_API_TOKEN"
+
+// phases of scans requested through the scan API
+const (
+	PhasePending   = "Pending"
+	PhaseRunning   = "Running"
+	PhaseCompleted = "Completed"
+	PhaseFailed    = "Failed"
+)
+
+// ScanRequest describes a scan requested through the scan API.
+type ScanRequest struct {
+	Namespace string `json:"Namespace"`
+	Selector  string `json:"Selector,omitempty"`
+	Format    string `json:"Format,omitempty"`
+	Level     string `json:"Level,omitempty"`
+	Sections  string `json:"Sections,omitempty"`
+}
+
+// APIScan is a scan requested through the scan API and its outcome.
+type APIScan struct {
+	ID          string         `json:"ID"`
+	Request     ScanRequest    `json:"Request"`
+	Phase       string         `json:"Phase"`
+	Message     string         `json:"Message,omitempty"`
+	RunID       string         `json:"RunID,omitempty"`
+	Scanned     int            `json:"Scanned"`
+	NotTestable int            `json:"NotTestable"`
+	Skipped     int            `json:"Skipped"`
+	Findings    map[string]int `json:"Findings,omitempty"`
+	Created     time.Time      `json:"Created"`
+	Finished    *time.Time     `json:"Finished,omitempty"`
+}
+
+// scanAPI accepts scans requested by remote clients, e.g. 'kubelse remote scan', and reports their progress.
+// Requested scans are queued and run by the operator one by one, between its runs of LseScan resources.
+type scanAPI struct {
+	mu    sync.Mutex
+	token string
+	scans map[string]*APIScan
+	queue chan string
+}
+
+// newScanAPI creates a scan API authenticating clients with a bearer token.
+func newScanAPI(token string) *scanAPI {
+	return &scanAPI{token: token, scans: make(map[string]*APIScan), queue: make(chan string, 100)}
+}
+
+// authorized tells if a request carries the token of the API.
+func (a *scanAPI) authorized(req *http.Request) bool {
+	return subtle.ConstantTimeCompare([]byte(req.Header.Get("Authorization")), []byte("Bearer "+a.token)) == 1
+}
+
+// writeJSON writes a json response.
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
+
+// handler returns a handler of the scan API.
+func (a *scanAPI) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/v1/scans", func(w http.ResponseWriter, req *http.Request) {
+		var request ScanRequest
+		if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"Error": err.Error()})
+			return
+		}
+		request.Format = valueOrDefault(request.Format, "ansi")
+		if request.Namespace == "" {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"Error": "a namespace is required"})
+			return
+		}
+		if err := validateLseScanSpec(LseScanSpec{Format: request.Format, Level: request.Level, Sections: request.Sections}); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"Error": err.Error()})
+			return
+		}
+
+		scan := &APIScan{ID: uuid.NewString(), Request: request, Phase: PhasePending, Created: time.Now().UTC()}
+		// the scan is stored before it is queued, so the operator always finds the scans it takes from the queue
+		a.mu.Lock()
+		a.prune(scan.Created)
+		a.scans[scan.ID] = scan
+		copied := *scan
+		a.mu.Unlock()
+		select {
+		case a.queue <- scan.ID:
+		default:
+			a.mu.Lock()
+			delete(a.scans, scan.ID)
+			a.mu.Unlock()
+			writeJSON(w, http.StatusServiceUnavailable, map[string]string{"Error": "too many scans are queued"})
+			return
+		}
+		log(fmt.Sprintf("[+] Scan %s of namespace %s requested through the API\n", scan.ID, request.Namespace))
+		writeJSON(w, http.StatusAccepted, copied)
+	})
+	mux.HandleFunc("GET /api/v1/scans/{id}", func(w http.ResponseWriter, req *http.Request) {
+		a.mu.Lock()
+		scan, ok := a.scans[req.PathValue("id")]
+		var copied APIScan
+		if ok {
+			copied = *scan
+		}
+		a.mu.Unlock()
+		if !ok {
+			writeJSON(w, http.StatusNotFound, map[string]string{"Error": "no such scan"})
+			return
+		}
+		writeJSON(w, http.StatusOK, copied)
+	})
+
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if !a.authorized(req) {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"Error": "missing or invalid token"})
+			return
+		}
+		mux.ServeHTTP(w, req)
+	})
+}
+
+// prune removes scans finished longer than the retention ago, it has to be called under the lock of the API.
+func (a *scanAPI) prune(now time.Time) {
+	for id, scan := range a.scans {
+		if scan.Finished != nil && now.Sub(*scan.Finished) > scanRetention {
+			delete(a.scans, id)
+		}
+	}
+}
+
+// update changes a scan under the lock of the API.
+func (a *scanAPI) update(id string, change func(*APIScan)) APIScan {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	change(a.scans[id])
+	return *a.scans[id]
+}
+
+// run runs a requested scan.
+func (a *scanAPI) run(client *k8sexec.K8SExec, id string) {
+	scan := a.update(id, func(scan *APIScan) { scan.Phase = PhaseRunning })
+	log(fmt.Sprintf("[*] Running scan %s of namespace %s requested through the API\n", id, scan.Request.Namespace))
+
+	spec := LseScanSpec{
+		TargetNamespace: scan.Request.Namespace,
+		Selector:        scan.Request.Selector,
+		Format:          scan.Request.Format,
+		Directory:       operatorDirectory,
+		Level:           scan.Request.Level,
+		Sections:        scan.Request.Sections,
+	}
+	manifest, err := runLseScan(client, spec)
+	a.update(id, func(scan *APIScan) {
+		finished := time.Now().UTC()
+		scan.Phase, scan.Finished = PhaseCompleted, &finished
+		scan.Message = fmt.Sprintf("scanned %d containers", len(manifest.Scanned))
+		if err != nil {
+			scan.Phase, scan.Message = PhaseFailed, err.Error()
+			log(fmt.Sprintf("[-] Scan %s failed: %s\n", id, err.Error()))
+		}
+		scan.RunID = manifest.RunID
+		scan.Scanned, scan.NotTestable, scan.Skipped = len(manifest.Scanned), len(manifest.NotTestable), len(manifest.Skipped)
+		scan.Findings = manifest.FindingsCount()
+	})
+}
+
+// startScanAPI starts serving the scan API, it requires the token to be set. The API is served with TLS when
+// a certificate and its key are given, otherwise it has to be exposed only through a proxy terminating TLS.
+func startScanAPI(address, certFile, keyFile string) (*scanAPI, error) {
+	token := os.Getenv(apiTokenVariable)
+	if token == "" {
+		return nil, fmt.Errorf("the scan API requires a token in the %s environment variable", apiTokenVariable)
+	}
+	if (certFile == "") != (keyFile == "") {
+		return nil, fmt.Errorf("both a TLS certificate and its key have to be provided")
+	}
+	api := newScanAPI(token)
+	server := &http.Server{Addr: address, Handler: api.handler(), ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		var err error
+		if certFile != "" {
+			err = server.ListenAndServeTLS(certFile, keyFile)
+		} else {
+			err = server.ListenAndServe()
+		}
+		if err != nil {
+			log(fmt.Sprintf("[-] Scan API stopped: %s\n", err.Error()))
+		}
+	}()
+	if certFile != "" {
+		log(fmt.Sprintf("[+] Scan API listening with TLS on %s\n", address))
+	} else {
+		log(fmt.Sprintf("[!] Scan API listening without TLS on %s, it has to be exposed only through a proxy terminating TLS\n", address))
+	}
+	return api, nil
+}
@@ -2,59 +2,118 @@ package cmd
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"github.com/hhruszka/k8sexec"
 	"github.com/jedib0t/go-pretty/v6/table"
 	"github.com/jedib0t/go-pretty/v6/text"
-	"github.com/robert-nix/ansihtml"
 	corev1 "k8s.io/api/core/v1"
 	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8slse/data"
-	"os"
-	"path/filepath"
-	"runtime"
 	"strings"
-	"sync"
 	"text/tabwriter"
 	"time"
 )
 
-var (
-	htmlHeader = `
-<?xml version="1.0" encoding="UTF-8" ?>
-<!DOCTYPE html PUBLIC "-//W3C//DTD XHTML 1.0 Strict//EN" "http://www.w3.org/TR/xhtml1/DTD/xhtml1-strict.dtd">
-<html xmlns="http://www.w3.org/1999/xhtml">
-<head>
-<meta http-equiv="Content-Type" content="application/xml+xhtml; charset=UTF-8"/>
-<title>stdin</title>
-</head>
-<body style="color:white; background-color:black">
-<pre>`
-
-	htmlFooter = `
-</pre>
-</body>
-</html>`
-)
-
 type Container struct {
-	Pod       string `json:"Pod"`
-	Container string `json:"Container"`
+	Pod         string            `json:"Pod"`
+	Container   string            `json:"Container"`
+	Workload    string            `json:"Workload,omitempty"`
+	Image       string            `json:"Image,omitempty"`
+	Owner       string            `json:"Owner,omitempty"`
+	Annotations map[string]string `json:"-"`
+	Labels      map[string]string `json:"-"`
+	PSS         PSSResult         `json:"-"`
+	Suggestions []Suggestion      `json:"-"`
+	UID         types.UID         `json:"-"`
+	RiskFactors []RiskFactor      `json:"-"`
+	// ServiceAccount is set only if the service account token is mounted in the pod
+	ServiceAccount string `json:"-"`
+	// NetworkPolicies is nil, unless NetworkPolicy coverage was checked
+	NetworkPolicies *NetworkPolicyCoverage `json:"-"`
+	// Runtime is how the pod runs the container, compared with the image config with '--image-config'
+	Runtime RuntimeSpec `json:"-"`
+	// Disruption is how sensitive the container is to exec load, e.g. a critical workload with a CPU limit
+	Disruption DisruptionRisk `json:"-"`
+}
+
+// newContainer returns a container of a pod with the pod's details needed for scanning.
+func newContainer(pod corev1.Pod, name string) Container {
+	var image string
+	for _, container := range pod.Spec.Containers {
+		if container.Name == name {
+			image = container.Image
+		}
+	}
+	container := Container{Pod: pod.Name, Container: name, Workload: workloadOf(pod), Image: image, Owner: ownerOf(pod), Annotations: pod.Annotations, Labels: pod.Labels, PSS: evaluatePSS(&pod), Suggestions: podSuggestions(&pod, name), UID: pod.UID, RiskFactors: podRiskFactors(&pod, name), Runtime: podRuntimeSpec(&pod, name), Disruption: podDisruptionRisk(&pod, name)}
+	if serviceAccountMounted(&pod) {
+		container.ServiceAccount = valueOrDefault(pod.Spec.ServiceAccountName, "default")
+	}
+	return container
+}
+
+// String returns a pod/container identifier of a container.
+func (c Container) String() string {
+	return c.Pod + "/" + c.Container
 }
 
 type ContainerInfo struct {
-	container Container
-	shell     string
-	testable  bool
+	container    Container
+	shell        string
+	testable     bool
+	distro       string
+	pkgManager   string
+	readOnlyRoot bool
+	tmpWritable  bool
+	settings     scanSettings
 }
 
 type Result struct {
-	podName       string
-	containerName string
-	scanReport    []string
+	container  ContainerInfo
+	scanReport []string
+	stderr     []string
+	execErrors []string
+	retCode    k8sexec.ExitCode
+	duration   time.Duration
+	reportFile string
+	stderrFile string
+	attempts   int
+	// delivered is set when at least one sink accepted the report
+	delivered bool
+	// truncated is set when the output exceeded '--max-report-size'
+	truncated bool
+	// stalled is set when lse.sh was cancelled for producing no output for '--stall-timeout'
+	stalled bool
+	// overloaded is usage of the container over '--max-cpu' or '--max-memory', for which lse.sh was aborted
+	overloaded string
+	// hashes of binaries by path, collected with '--hash-inventory'
+	hashes map[string]string
+	risk   RiskScore
+	// suppress are entries of the ignore file suppressing findings in the container
+	suppress []IgnoreRule
+	// triage are triage decisions on findings of the container by test IDs
+	triage map[string]*TriageDecision
+	// api is access of the container to the Kubernetes API, probed with '--api-probe'
+	api *APIAccess
+}
+
+// newResult returns a result of a scan of a container.
+func newResult(container ContainerInfo, execStatus *k8sexec.ExecutionStatus, duration time.Duration) Result {
+	scanReport := truncateLines(execStatus.Stdout, reportSizeLimit())
+	return Result{
+		container:  container,
+		scanReport: scanReport,
+		stderr:     execStatus.Stderr,
+		execErrors: execStatus.Error,
+		retCode:    execStatus.RetCode,
+		duration:   duration,
+		attempts:   1,
+		truncated:  outputTruncated(scanReport),
+	}
 }
 
 // utils                                   []string = []string{"stat /usr/bin/find", "stat /bin/cat", "stat /bin/ps", "stat /bin/grep"}
@@ -65,36 +124,61 @@ var (
 	utils                 []string = []string{"stat /usr/bin/find", "stat /bin/cat", "stat /bin/grep"}
 	targetContainers      []ContainerInfo
 	nontestableContainers []ContainerInfo
+	skippedContainers     []SkippedContainer
 )
 
 // lse script is embeded in data package
 var lse []byte = data.GetScript()
 
-// checkShellsInContainer checks for the presence of specified shells in the given container of a pod.
-func getShellInContainer(k8s *k8sexec.K8SExec, container Container) (string, error) {
-	execStatus := k8s.Exec(container.Pod, container.Container, strings.Fields("sh --version"), nil)
+// payloadName describes the script run in containers, the embedded lse.sh unless a script file was provided
+var payloadName string = "lse.sh (embedded)"
 
-	if execStatus.RetCode == k8sexec.Success {
-		return "sh", nil
+// payloadPath is a file the script run in containers was read from, it is empty for the embedded lse.sh
+var payloadPath string
+
+// shells looked for in containers, in the order of preference
+var shells = []string{"sh", "bash"}
+
+// shellVersionCommand returns a command, which tells if a shell is present in a container.
+func shellVersionCommand(shell string) []string {
+	return []string{shell, "--version"}
+}
+
+// checkShellsInContainer checks for the presence of specified shells in the given container of a pod.
+func getShellInContainer(ctx context.Context, k8s *k8sexec.K8SExec, container Container) (string, error) {
+	var execStatus *k8sexec.ExecutionStatus
+	for _, shell := range shells {
+		execStatus = execInContainer(ctx, k8s, container.Pod, container.Container, shellVersionCommand(shell), nil)
+		if execStatus.RetCode == k8sexec.Success {
+			return shell, nil
+		}
 	}
+	return "", fmt.Errorf(strings.Join(execStatus.Error, "\n"))
+}
 
-	execStatus = k8s.Exec(container.Pod, container.Container, strings.Fields("bash --version"), nil)
+// shellCheckCommand returns a command, which tells if a given shell can be used in a container.
+func shellCheckCommand(shell string) []string {
+	return []string{shell, "-c", "exit 0"}
+}
+
+// checkShellInContainer checks if a given shell, e.g. requested with an annotation, can be used in a container.
+func checkShellInContainer(ctx context.Context, k8s *k8sexec.K8SExec, container Container, shell string) (string, error) {
+	execStatus := execInContainer(ctx, k8s, container.Pod, container.Container, shellCheckCommand(shell), nil)
 	if execStatus.RetCode == k8sexec.Success {
-		return "bash", nil
+		return shell, nil
 	}
-
 	return "", fmt.Errorf(strings.Join(execStatus.Error, "\n"))
 }
 
-func checkUtilInContainer(k8s *k8sexec.K8SExec, container Container, util string) (bool, error) {
-	execStatus := k8s.Exec(container.Pod, container.Container, strings.Fields(util), nil)
+func checkUtilInContainer(ctx context.Context, k8s *k8sexec.K8SExec, container Container, util string) (bool, error) {
+	execStatus := execInContainer(ctx, k8s, container.Pod, container.Container, strings.Fields(util), nil)
 	return execStatus.RetCode != k8sexec.CommandNotFound && execStatus.RetCode != k8sexec.CommandCannotExecute, fmt.Errorf(strings.Join(execStatus.Error, "\n"))
 }
 
-func checkUtils(k8s *k8sexec.K8SExec, container Container, utils []string) bool {
+func checkUtils(ctx context.Context, k8s *k8sexec.K8SExec, container Container, utils []string) bool {
 	var utilFound bool = true
 	for _, util := range utils {
-		result, _ := checkUtilInContainer(k8s, container, util)
+		result, _ := checkUtilInContainer(ctx, k8s, container, util)
 		utilFound = utilFound && result
 		if result == false {
 			break
@@ -103,211 +187,289 @@ func checkUtils(k8s *k8sexec.K8SExec, container Container, utils []string) bool
 	return utilFound
 }
 
-func verifyContainers(k8s *k8sexec.K8SExec, containers []Container) (target []ContainerInfo, nontestable []ContainerInfo) {
-	var (
-		podProdChan chan ContainerInfo = make(chan ContainerInfo, len(containers))
-		conProdChan chan ContainerInfo = make(chan ContainerInfo, runtime.NumCPU())
-	)
-	var (
-		podWg           sync.WaitGroup
-		contVerWorkerWg sync.WaitGroup
-		contCollectorWg sync.WaitGroup
-	)
-
+// verifyContainers checks which containers have a shell and utilities needed by lse.sh. If a testable channel is
+// provided, then testable containers are also sent through it as soon as they are verified.
+func verifyContainers(ctx context.Context, k8s *k8sexec.K8SExec, containers []Container, testable chan<- ContainerInfo) (target []ContainerInfo, nontestable []ContainerInfo) {
 	if len(utils) == 0 {
 		return nil, nil
 	}
 
-	// these are workers that check shell and utilities
-	for i := 0; i < len(containers); i++ {
-		contVerWorkerWg.Add(1)
-		go func() {
-			defer contVerWorkerWg.Done()
-			for container := range podProdChan {
-				container.shell, _ = getShellInContainer(k8s, container.container)
-				container.testable = checkUtils(k8s, container.container, utils) && container.shell != ""
-				conProdChan <- container
-			}
-		}()
-	}
-
-	// this goroutine distributes found pods through podProdChan for workers that check shell and utilities
-	podWg.Add(1)
-	go func() {
-		defer podWg.Done()
-		for _, container := range containers {
-			podProdChan <- ContainerInfo{container: container}
-		}
-	}()
-
-	// this results collector goroutine that gets verified containers from workers and puts them into two buckets (slices):
+	// verified containers are put into two buckets (slices):
 	// - bucket containing containers that will be tested with lse.sh because they have everything needed
 	// - bucket with containers that lack utilities and cannot be tested with lse.sh
-	contCollectorWg.Add(1)
-	go func() {
-		defer contCollectorWg.Done()
-		for container := range conProdChan {
-			switch {
-			case container.testable:
-				target = append(target, container)
-			case !container.testable:
-				nontestable = append(nontestable, container)
-			}
+	for container := range verifyStage(ctx, k8s, source(ctx, containers), len(containers)) {
+		if !container.testable {
+			nontestable = append(nontestable, container)
+			continue
 		}
-	}()
-
-	podWg.Wait()
-	close(podProdChan)
-	contVerWorkerWg.Wait()
-	close(conProdChan)
-	contCollectorWg.Wait()
-
+		target = append(target, container)
+		if testable != nil {
+			testable <- container
+		}
+	}
 	return target, nontestable
 }
 
-func saveScan(podName, containerName string, scanReport []string) error {
-	fileName := fmt.Sprintf("%s-%s-%s.%s", podName, containerName, time.Now().Format("2006-01-02-150405"), format)
-	fileName = filepath.Join(directory, fileName)
+func saveScan(result Result) (string, error) {
+	report, err := reportContent(result)
+	if err != nil {
+		return "", err
+	}
+	return writeReportWithFallback(reportName(result), report)
+}
 
+// renderReport renders lines of a report in the output format.
+func renderReport(lines []string) []byte {
 	var report []byte
 	switch format {
 	case "html":
-		report = []byte(htmlHeader)
-		report = append(report, ansihtml.ConvertToHTML([]byte(strings.Join(scanReport, "\n")))...)
-		report = append(report, []byte(htmlFooter)...)
+		report = renderHTMLReport(lines)
+	case "plain":
+		report = []byte(plainText(strings.Join(lines, "\n")))
 	default:
-		report = []byte(strings.Join(scanReport, "\n"))
-	}
-
-	err := os.WriteFile(fileName, report, 0666)
-	if err != nil {
-		return err
+		report = []byte(strings.Join(lines, "\n"))
 	}
-	return nil
+	return report
 }
 
-func scan(k8s *k8sexec.K8SExec, containers []Container) error {
+// scan verifies which containers can be tested, runs lse.sh in them and returns a manifest of the run. Once the
+// context of the run is cancelled, no more containers are verified or scanned.
+func scan(ctx context.Context, k8s *k8sexec.K8SExec, containers []Container) (Manifest, error) {
 	log(fmt.Sprintln("[*] Identifying containers that can be tested"))
-	targetContainers, nontestableContainers = verifyContainers(k8s, containers)
+	if pipeline {
+		return scanPipelined(ctx, k8s, containers)
+	}
+
+	targetContainers, nontestableContainers = verifyContainers(ctx, k8s, containers, nil)
+	if runInterrupted(ctx) {
+		return Manifest{}, errors.New("[-] Run interrupted while verifying containers\n")
+	}
 	log(fmt.Sprintf("[+] Found %d containers\n", len(targetContainers)+len(nontestableContainers)))
 
 	if len(targetContainers) > 0 {
-		log(fmt.Sprintf("[+] Following %d containers can be tested:\n", len(targetContainers)))
-		var buf bytes.Buffer
-		w := tabwriter.NewWriter(&buf, 0, 0, 1, ' ', 0)
-		for _, list := range targetContainers {
-			fmt.Fprintf(w, "%s\t%s\n", list.container.Pod, list.container.Container)
-		}
-		fmt.Fprintln(w, "\t")
-		w.Flush()
-		log(buf.String())
+		logContainers(fmt.Sprintf("[+] Following %d containers can be tested:\n", len(targetContainers)), targetContainers)
 	} else {
-		return errors.New("[-] Did not find any containers that can be tested")
+		return Manifest{}, errors.New("[-] Did not find any containers that can be tested")
 	}
 
 	if len(nontestableContainers) > 0 {
-		log(fmt.Sprintf("[-] Following %d containers cannot be tested:\n", len(nontestableContainers)))
-		var buf bytes.Buffer
-		w := tabwriter.NewWriter(&buf, 0, 0, 1, ' ', 0)
-		for _, container := range nontestableContainers {
-			fmt.Fprintf(w, "%s\t%s\n", container.container.Pod, container.container.Container)
-		}
-		fmt.Fprintln(w, "\t")
-		w.Flush()
-		log(buf.String())
+		logContainers(fmt.Sprintf("[-] Following %d containers cannot be tested:\n", len(nontestableContainers)), nontestableContainers)
 	}
 
-	if !quiet {
+	if showCommands || dryRun {
+		printCommands(targetContainers)
+	}
+	if dryRun {
+		log(fmt.Sprintln("[+] Dry run, no containers were scanned"))
+		return Manifest{}, nil
+	}
+
+	if err := checkOutputSpace(len(targetContainers)); err != nil {
+		return Manifest{}, err
+	}
+
+	if !quiet && interactive {
 		if promptYN("\nDo you wish to proceed with testing? (Y/N): ") {
 			log(fmt.Sprintln("Proceeding with testing..."))
 		} else {
-			return errors.New("Action cancelled.")
+			return Manifest{}, errors.New("Action cancelled.")
 		}
 	}
 
-	if len(targetContainers) > 0 {
-		var workers int = 200
+	var (
+		started = now()
+		results []Result
+	)
+	ctx = newErrorBudget(ctx, len(targetContainers))
+	newOutputQuota()
+	if err := newSinks(); err != nil {
+		return Manifest{}, err
+	}
 
-		if len(targetContainers) < 200 {
-			workers = len(targetContainers)
+	targets := targetContainers
+	if canary > 0 && canary < len(targets) {
+		canaryResults, remaining, err := runCanary(ctx, k8s, targets)
+		results = append(results, canaryResults...)
+		if err != nil {
+			manifest, saveErr := finishRun(ctx, started, results)
+			if saveErr != nil {
+				log(fmt.Sprintf("[-] Error saving run manifest: %s\n", saveErr.Error()))
+			}
+			return manifest, err
 		}
+		targets = remaining
+	}
 
-		var (
-			contProdChan    chan ContainerInfo = make(chan ContainerInfo, runtime.NumCPU()*2)
-			resultsProdChan chan Result        = make(chan Result, runtime.NumCPU()*2)
-		)
-
-		var (
-			contFanOutWg       sync.WaitGroup
-			testWorkerWg       sync.WaitGroup
-			resultsCollectorWg sync.WaitGroup
-		)
-
-		// this is necessary, when cross-compiling on windows
-		lsetmp := bytes.Replace(lse, []byte("\r\n"), []byte("\n"), -1)
-		lsetmp = bytes.Replace(lsetmp, []byte("\r"), []byte(""), -1)
-
-		contFanOutWg.Add(1)
-		go func() {
-			defer contFanOutWg.Done()
-			for _, container := range targetContainers {
-				contProdChan <- container
-			}
-		}()
-
-		for id := 0; id < workers; id++ {
-			testWorkerWg.Add(1)
-			go func() {
-				defer testWorkerWg.Done()
-				for container := range contProdChan {
-					lsescript := bytes.NewBuffer(lsetmp)
-					shell := container.shell
-					if format == "text" {
-						shell = fmt.Sprintf("%s -s -- -c", shell)
-					}
-					execStatus := k8s.Exec(container.container.Pod, container.container.Container, strings.Fields(shell), lsescript)
-					if execStatus.RetCode != k8sexec.Success {
-						log(strings.Join(execStatus.Error, "\n"))
-					}
-					resultsProdChan <- Result{container.container.Pod, container.container.Container, execStatus.Stdout}
-				}
-			}()
+	results = append(results, scanTargets(ctx, k8s, targets)...)
+	return finishRun(ctx, started, retryFailed(ctx, k8s, results))
+}
+
+// finishRun summarizes results of a run, salvages reports that could not be saved and saves the run manifest.
+// Containers, which were not scanned since the run was cancelled, are recorded as skipped.
+func finishRun(ctx context.Context, started time.Time, results []Result) (Manifest, error) {
+	sortResults(results)
+	sinks.close()
+	skipUnscanned(ctx, results)
+	terminatedPods.finish()
+	reportPanics()
+	budgetErr := interruptedRun.finish(ctx)
+	if err := errorBudget.finish(); err != nil && budgetErr == nil {
+		budgetErr = err
+	}
+	if err := outputQuota.finish(); err != nil && budgetErr == nil {
+		budgetErr = err
+	}
+	manifest := newManifest(started, results)
+	if trackGaps {
+		if err := trackCoverageGaps(&manifest); err != nil {
+			log(fmt.Sprintf("[-] Error tracking coverage gaps: %s\n", err.Error()))
+		}
+	}
+	printSummary(manifest)
+	salvageUnsaved(started, results)
+	// containers, which risk scores are below '--min-score', are left out of reports of findings
+	reported := reportableResults(results)
+	if merge {
+		saveMergedReport(started, reported)
+	}
+	if dashboard {
+		saveDashboard(manifest, "")
+	}
+	if hashInventory {
+		saveHashInventory(started, results)
+	}
+	if suidPivot {
+		saveSUIDPivot(started, results)
+	}
+	if cronSummary {
+		saveCronSummary(started, results)
+	}
+	if golden {
+		saveDrift(started, results)
+	}
+	if coverage {
+		saveCoverage(manifest)
+	}
+	if ciMode != "" {
+		emitCIAnnotations(reported)
+	}
+	if createIssues != "" {
+		createJiraIssues(reported)
+	}
+	if policyCommand != "" {
+		if err := applyPolicy(&manifest, results); err != nil && budgetErr == nil {
+			budgetErr = err
 		}
+	}
+	savePseudonyms()
+	if budgetErr != nil {
+		if err := saveManifest(manifest); err != nil {
+			log(fmt.Sprintf("[-] Error saving run manifest: %s\n", err.Error()))
+		}
+		return manifest, budgetErr
+	}
+	return manifest, saveManifest(manifest)
+}
 
-		resultsCollectorWg.Add(1)
-		go func() {
-			var cnt int
+// logContainers logs a list of containers preceded by a header. Details of verification are listed for
+// testable containers.
+func logContainers(header string, containers []ContainerInfo) {
+	log(header)
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 0, 1, ' ', 0)
+	for _, container := range containers {
+		if container.testable {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", container.container.Pod, container.container.Container, container.distro, filesystemStatus(container))
+		} else {
+			fmt.Fprintf(w, "%s\t%s\n", container.container.Pod, container.container.Container)
+		}
+	}
+	fmt.Fprintln(w, "\t")
+	w.Flush()
+	log(buf.String())
+}
 
-			defer resultsCollectorWg.Done()
-			for result := range resultsProdChan {
-				if err := saveScan(result.podName, result.containerName, result.scanReport); err != nil {
-					log(err.Error())
-					log(strings.Join(result.scanReport, "\n"))
-				}
-				cnt++
-				log(fmt.Sprintf("\rAnalyzed %d containers", cnt))
-			}
-			log(fmt.Sprintf("\n"))
-		}()
+// lseCommand returns a command, which runs lse.sh passed through stdin with the shell found in a container and
+// the container's scan settings.
+func lseCommand(container ContainerInfo) []string {
+	var args []string
+	// json reports keep lse.sh output without colors
+	if format == "text" || format == "json" {
+		args = append(args, "-c")
+	}
+	switch {
+	case container.settings.reduced:
+		args = append(args, "-l", "0")
+	case container.settings.level != "":
+		args = append(args, "-l", container.settings.level)
+	}
+	if container.settings.sections != "" {
+		args = append(args, "-s", container.settings.sections)
+	}
+	if container.settings.excludePaths != "" {
+		args = append(args, "-e", container.settings.excludePaths)
+	}
 
-		contFanOutWg.Wait()
-		close(contProdChan)
-		testWorkerWg.Wait()
-		close(resultsProdChan)
-		resultsCollectorWg.Wait()
+	command := []string{container.shell}
+	if len(args) > 0 {
+		command = append(command, "-s", "--")
+		command = append(command, args...)
 	}
-	return nil
+	return auditCommand(asUserCommand(truncateCommand(tuneCommand(command, excludeNetworkMounts || container.settings.reduced))))
 }
 
-func scanContainers(k8s *k8sexec.K8SExec, containers []Container) error {
-	log(fmt.Sprintln("[+] Started"))
+// scanTargets runs lse.sh in the given containers using a pool of workers, saves the reports and returns
+// the results of all executions.
+func scanTargets(ctx context.Context, k8s *k8sexec.K8SExec, containers []ContainerInfo) []Result {
+	if len(containers) == 0 {
+		return nil
+	}
+	return scanStream(ctx, k8s, source(ctx, containers), len(containers))
+}
+
+// scanStream runs lse.sh in containers received from a channel, until the channel is closed, using a pool of
+// workers, and delivers their reports. The pool is sized for the expected number of containers.
+func scanStream(ctx context.Context, k8s *k8sexec.K8SExec, contProdChan <-chan ContainerInfo, expected int) []Result {
+	lsetmp, _ := preparePayload(lse)
+	defer showRunning()()
+	return deliverStage(ctx, executeStage(ctx, k8s, contProdChan, workerCount(expected), lsetmp))
+}
+
+func scanContainers(k8s *k8sexec.K8SExec, containers []Container) (Manifest, error) {
+	if shardCount > 0 {
+		log(fmt.Sprintf("[+] Scanning shard %s, pods are split between shards by their UIDs\n", shard))
+	}
 	log(fmt.Sprintln("[+] Creating a list of unique pods"))
 
 	if len(containers) == 0 {
-		return errors.New(fmt.Sprintf("[-] No pods/containers found in namespace %q\n", namespace))
+		return Manifest{}, errors.New(fmt.Sprintf("[-] No pods/containers found in namespace %q\n", k8s.Namespace))
+	}
+	log(fmt.Sprintf("[+] Found %d containers in %s namespace\n", len(containers), k8s.Namespace))
+	if tenant = namespaceTenant(k8s); tenant != "" {
+		log(fmt.Sprintf("[+] Namespace %s belongs to tenant %s\n", k8s.Namespace, tenant))
 	}
-	log(fmt.Sprintf("[+] Found %d containers in %s namespace\n", len(containers), namespace))
-	return scan(k8s, containers)
+	defer enterRunDirectory(now())()
+	cluster = getClusterInfo(k8s)
+	if anonymize {
+		pseudonyms.addRun(k8s.Namespace, cluster, containers)
+	}
+	if networkPolicies {
+		var err error
+		if containers, err = checkNetworkPolicies(k8s, containers); err != nil {
+			return Manifest{}, err
+		}
+	}
+	if containers = checkDisruption(k8s, containers); len(containers) == 0 {
+		return Manifest{}, errors.New(fmt.Sprintf("[-] All containers in namespace %q belong to critical workloads\n", k8s.Namespace))
+	}
+	if golden && !dryRun {
+		goldens, created := deployGoldenPods(k8s, containers)
+		defer removeGoldenPods(k8s, created)
+		containers = append(containers, goldens...)
+	}
+	ctx, cancel := newRunContext()
+	defer cancel(nil)
+	return scan(ctx, k8s, containers)
 }
 
 func listContainers(k8s *k8sexec.K8SExec) error {
@@ -326,7 +488,7 @@ func listContainers(k8s *k8sexec.K8SExec) error {
 	} else {
 		var err error
 
-		_, pods, err = k8s.GetUniquePods()
+		pods, err = getPods(k8s)
 		if err != nil {
 			return err
 		}
@@ -352,6 +514,21 @@ func listContainers(k8s *k8sexec.K8SExec) error {
 	return nil
 }
 
+// getPods returns pods matching the label selector or, if no selector was provided, unique pods of a namespace, i.e.
+// a single pod of every deployment and statefulset and all other pods. All pods are returned when containers are
+// selected by images, since replicas of a workload may run different images during a rollout, and all pods of
+// a Helm release are returned when it was selected.
+func getPods(k8s *k8sexec.K8SExec) ([]corev1.Pod, error) {
+	if helmRelease != "" {
+		return helmReleasePods(k8s, helmRelease)
+	}
+	if labelSelector != "" || len(imagePatterns) > 0 {
+		return k8s.GetPods(metaV1.ListOptions{LabelSelector: labelSelector})
+	}
+	_, pods, err := k8s.GetUniquePods()
+	return pods, err
+}
+
 func getContainers(k8s *k8sexec.K8SExec, pods []string, containers []string) ([]Container, error) {
 	var containerList []Container
 
@@ -359,9 +536,32 @@ func getContainers(k8s *k8sexec.K8SExec, pods []string, containers []string) ([]
 		return nil, fmt.Errorf("List of containers to be tested can be provided only for a single pod\n")
 	}
 
+	skippedContainers = nil
+	skipNamespace := namespaceSkipped(k8s)
+	skipReason := fmt.Sprintf("namespace annotated with %s", annotationSkip)
+	if skipNamespace {
+		log(fmt.Sprintf("[-] Namespace %s is annotated with %s, its containers will not be tested\n", k8s.Namespace, annotationSkip))
+	}
+
 	if len(pods) == 1 && len(containers) > 0 {
+		foundPod, err := k8s.GetPod(pods[0], metaV1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		if !inShard(*foundPod) {
+			containers = nil
+		}
 		for _, container := range containers {
-			containerList = append(containerList, Container{pods[0], container})
+			switch {
+			case skipNamespace:
+				skippedContainers = append(skippedContainers, SkippedContainer{Container{Pod: pods[0], Container: container, Workload: workloadOf(*foundPod)}, skipReason})
+			case skipAnnotated(foundPod.Annotations):
+				skippedContainers = append(skippedContainers, SkippedContainer{Container{Pod: pods[0], Container: container, Workload: workloadOf(*foundPod)}, fmt.Sprintf("pod annotated with %s", annotationSkip)})
+			case foundPod.DeletionTimestamp != nil:
+				skippedContainers = append(skippedContainers, SkippedContainer{Container{Pod: pods[0], Container: container, Workload: workloadOf(*foundPod)}, skipReasonTerminating})
+			default:
+				containerList = append(containerList, newContainer(*foundPod, container))
+			}
 		}
 	}
 
@@ -371,29 +571,56 @@ func getContainers(k8s *k8sexec.K8SExec, pods []string, containers []string) ([]
 			if err != nil {
 				return nil, err
 			}
-			if foundPod.Status.Phase != "Running" {
+			if foundPod.Status.Phase != "Running" || !inShard(*foundPod) {
 				continue
 			}
-			for _, container := range foundPod.Spec.Containers {
-				containerList = append(containerList, Container{foundPod.Name, container.Name})
+			switch {
+			case skipNamespace:
+				skipContainers(*foundPod, skipReason)
+			case skipAnnotated(foundPod.Annotations):
+				skipContainers(*foundPod, fmt.Sprintf("pod annotated with %s", annotationSkip))
+			case foundPod.DeletionTimestamp != nil:
+				skipContainers(*foundPod, skipReasonTerminating)
+			default:
+				for _, container := range foundPod.Spec.Containers {
+					if imageSelected(container.Image) {
+						containerList = append(containerList, newContainer(*foundPod, container.Name))
+					}
+				}
 			}
 		}
 	}
 
 	if len(pods) == 0 && len(containers) == 0 {
-		_, pods, err := k8s.GetUniquePods()
+		pods, err := getPods(k8s)
 		if err != nil {
 			return nil, err
 		}
 		for _, pod := range pods {
-			if pod.Status.Phase != "Running" {
+			if pod.Status.Phase != "Running" || !inShard(pod) {
 				continue
 			}
-			for _, container := range pod.Spec.Containers {
-				containerList = append(containerList, Container{pod.Name, container.Name})
+			switch {
+			case skipNamespace:
+				skipContainers(pod, skipReason)
+			case skipAnnotated(pod.Annotations):
+				skipContainers(pod, fmt.Sprintf("pod annotated with %s", annotationSkip))
+			case pod.DeletionTimestamp != nil:
+				skipContainers(pod, skipReasonTerminating)
+			default:
+				for _, container := range pod.Spec.Containers {
+					if imageSelected(container.Image) {
+						containerList = append(containerList, newContainer(pod, container.Name))
+					}
+				}
 			}
 		}
 
 	}
+
+	containerList = skipIgnored(k8s.Namespace, containerList)
+	if len(skippedContainers) > 0 {
+		log(fmt.Sprintf("[-] Skipping %d containers annotated with %s, of terminating pods or listed in the ignore file\n", len(skippedContainers), annotationSkip))
+	}
 	return containerList, nil
 }
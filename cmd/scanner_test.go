@@ -0,0 +1,1916 @@
+package cmd
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/hhruszka/k8sexec"
+	"io"
+	appsV1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	policyV1 "k8s.io/api/policy/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8slse/internal/fakecluster"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var lseOutput = []string{
+	"=====================( users )=====================",
+	"[i] usr000 Current user groups.............................. yes!",
+	"---",
+	"uid=0(root) gid=0(root) groups=0(root)",
+	"---",
+	"[*] usr010 Is current user in an administrative group....... nope",
+	"=====================( file system )=====================",
+	"[!] fst010 Can we write to /etc/passwd?..................... yes!",
+	"---",
+	"-rw-rw-rw- 1 root root 922 /etc/passwd",
+	"---",
+	"==================================( FINISHED )==================================",
+}
+
+var debian = fakecluster.FakeContainer{
+	Shell:      "sh",
+	OSRelease:  []string{`PRETTY_NAME="Debian GNU/Linux 12 (bookworm)"`, "ID=debian"},
+	PkgManager: "dpkg",
+	LseOutput:  lseOutput,
+}
+
+func testPod(name string, image string, annotations map[string]string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metaV1.ObjectMeta{Name: name, Namespace: "default", Annotations: annotations},
+		Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "app", Image: image}}},
+		Status:     corev1.PodStatus{Phase: corev1.PodRunning},
+	}
+}
+
+// startTestCluster starts a fake cluster and points the scan pipeline at it and at a temporary reports directory.
+func startTestCluster(t *testing.T, objects ...runtime.Object) (*fakecluster.Cluster, *k8sexec.K8SExec) {
+	t.Helper()
+	objects = append(objects, &corev1.Namespace{ObjectMeta: metaV1.ObjectMeta{Name: "default"}})
+	cluster := fakecluster.New(objects...)
+	cluster.Start()
+
+	// reports are saved flat, so that tests find them in the temporary directory
+	directory, fallbackDirectory, format, namespace, kubeconfig, flat = t.TempDir(), "", "text", "default", "", true
+	quiet, interactive, execHook = true, false, cluster.Exec
+	newRun()
+	t.Cleanup(func() {
+		cluster.Close()
+		execHook, imagePatterns = nil, nil
+	})
+
+	k8s, err := cluster.Client("default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cluster, k8s
+}
+
+func TestScanSavesReportsAndManifest(t *testing.T) {
+	cluster, k8s := startTestCluster(t, testPod("web-1", "nginx:1.25", nil), testPod("distroless-1", "gcr.io/distroless/static", nil))
+	cluster.SetContainer("web-1", "app", debian)
+	cluster.SetContainer("distroless-1", "app", fakecluster.FakeContainer{})
+
+	containers, err := getContainers(k8s, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest, err := scanContainers(k8s, containers)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(manifest.Scanned) != 1 || len(manifest.NotTestable) != 1 {
+		t.Fatalf("expected 1 scanned and 1 not testable container, got %d and %d", len(manifest.Scanned), len(manifest.NotTestable))
+	}
+	entry := manifest.Scanned[0]
+	if entry.Pod != "web-1" || entry.Status != StatusComplete {
+		t.Errorf("unexpected manifest entry %+v", entry)
+	}
+	if entry.Findings[SeverityCritical] != 1 || entry.Findings[SeverityInfo] != 1 || entry.Findings[SeverityInteresting] != 0 {
+		t.Errorf("unexpected findings %v", entry.Findings)
+	}
+	if manifest.Cluster.Version != "v1.29.3" {
+		t.Errorf("expected cluster version v1.29.3, got %q", manifest.Cluster.Version)
+	}
+
+	runs, err := loadReports(directory)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reports := runs["."]
+	if len(reports) != 1 {
+		t.Fatalf("expected 1 report, got %d", len(reports))
+	}
+	report := reports[0]
+	if report.Pod() != "web-1" || report.Container() != "app" {
+		t.Errorf("report attributed to %s/%s", report.Pod(), report.Container())
+	}
+	if distro := report.Header["Distribution"]; distro != "Debian GNU/Linux 12 (bookworm) (debian)" {
+		t.Errorf("unexpected distribution %q", distro)
+	}
+	if positive := report.Positive(); len(positive) != 2 || positive[1].ID != "fst010" || len(positive[1].Details) != 1 {
+		t.Errorf("unexpected positive findings %+v", positive)
+	}
+}
+
+func TestSkipAnnotatedPods(t *testing.T) {
+	cluster, k8s := startTestCluster(t, testPod("web-1", "nginx:1.25", nil), testPod("web-2", "nginx:1.25", map[string]string{annotationSkip: "true"}))
+	cluster.SetContainer("web-1", "app", debian)
+	cluster.SetContainer("web-2", "app", debian)
+
+	containers, err := getContainers(k8s, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(containers) != 1 || containers[0].Pod != "web-1" {
+		t.Errorf("expected only web-1 to be selected, got %v", containers)
+	}
+	if len(skippedContainers) != 1 || skippedContainers[0].Container.Pod != "web-2" {
+		t.Errorf("expected web-2 to be skipped, got %v", skippedContainers)
+	}
+}
+
+func TestSelectContainersByImage(t *testing.T) {
+	_, k8s := startTestCluster(t, testPod("web-1", "docker.io/library/nginx:1.25", nil), testPod("db-1", "registry/internal/postgres:16", nil), testPod("cache-1", "redis:7", nil))
+
+	if err := compileImagePatterns("nginx:1.25,registry/internal/*"); err != nil {
+		t.Fatal(err)
+	}
+	containers, err := getContainers(k8s, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var selected []string
+	for _, container := range containers {
+		selected = append(selected, container.Pod)
+	}
+	if strings.Join(selected, ",") != "db-1,web-1" && strings.Join(selected, ",") != "web-1,db-1" {
+		t.Errorf("expected web-1 and db-1 to be selected, got %v", selected)
+	}
+}
+
+func TestSelectHelmReleasePods(t *testing.T) {
+	labeled := testPod("web-1", "nginx", nil)
+	labeled.Labels = map[string]string{"app.kubernetes.io/instance": "myapp", "app.kubernetes.io/managed-by": "Helm"}
+	unlabeled := testPod("worker-1", "busybox", nil)
+	unlabeled.Labels = map[string]string{"component": "worker"}
+	other := testPod("web-2", "nginx", nil)
+	other.Labels = map[string]string{"app.kubernetes.io/instance": "otherapp", "app.kubernetes.io/managed-by": "Helm"}
+	daemonSet := &appsV1.DaemonSet{
+		ObjectMeta: metaV1.ObjectMeta{Name: "worker", Namespace: "default", Annotations: map[string]string{helmReleaseAnnotation: "myapp"}},
+		Spec:       appsV1.DaemonSetSpec{Selector: &metaV1.LabelSelector{MatchLabels: map[string]string{"component": "worker"}}},
+	}
+	_, k8s := startTestCluster(t, labeled, unlabeled, other, daemonSet)
+
+	helmRelease = "myapp"
+	t.Cleanup(func() { helmRelease = "" })
+	containers, err := getContainers(k8s, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var selected []string
+	for _, container := range containers {
+		selected = append(selected, container.Pod)
+	}
+	if strings.Join(selected, ",") != "web-1,worker-1" {
+		t.Errorf("expected web-1 and worker-1 to be selected, got %v", selected)
+	}
+}
+
+func TestScanArgoCDAppAcrossNamespaces(t *testing.T) {
+	web := testPod("web-1", "nginx", nil)
+	web.Labels = map[string]string{"app.kubernetes.io/instance": "myapp"}
+	worker := testPod("worker-1", "busybox", nil)
+	worker.Namespace, worker.Labels = "jobs", map[string]string{"app.kubernetes.io/instance": "myapp"}
+	other := testPod("web-2", "nginx", nil)
+	other.Labels = map[string]string{"app.kubernetes.io/instance": "otherapp"}
+	cluster, k8s := startTestCluster(t, web, worker, other, &corev1.Namespace{ObjectMeta: metaV1.ObjectMeta{Name: "jobs"}})
+	for _, pod := range []string{"web-1", "worker-1", "web-2"} {
+		cluster.SetContainer(pod, "app", debian)
+	}
+
+	argocdApp, argocdLabel = "myapp", "app.kubernetes.io/instance"
+	t.Cleanup(func() { argocdApp, labelSelector = "", "" })
+	manifest, err := scanArgoCDApp(k8s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var scanned []string
+	for _, entry := range manifest.Scanned {
+		scanned = append(scanned, entry.Pod)
+	}
+	if strings.Join(scanned, ",") != "web-1,worker-1" {
+		t.Errorf("expected web-1 and worker-1 to be scanned, got %v", scanned)
+	}
+}
+
+func TestMergedReportsByOwner(t *testing.T) {
+	web := testPod("web-1", "nginx", nil)
+	web.Labels = map[string]string{"team": "frontend"}
+	cluster, k8s := startTestCluster(t, web, testPod("api-1", "nginx", nil), testPod("db-1", "postgres", nil))
+	for _, pod := range []string{"web-1", "api-1", "db-1"} {
+		cluster.SetContainer(pod, "app", debian)
+	}
+
+	ownerLabel, merge = "team", true
+	owners = OwnersMapping{Workloads: map[string]string{"default/Pod/api-1": "payments"}, Default: "security"}
+	t.Cleanup(func() { ownerLabel, merge, owners = "", false, OwnersMapping{} })
+	containers, err := getContainers(k8s, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest, err := scanContainers(k8s, containers)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, entry := range manifest.Scanned {
+		expected := map[string]string{"web-1": "frontend", "api-1": "payments", "db-1": "security"}[entry.Pod]
+		if entry.Owner != expected {
+			t.Errorf("expected %s to be owned by %s, got %q", entry.Pod, expected, entry.Owner)
+		}
+	}
+	for _, owner := range []string{"frontend", "payments", "security"} {
+		matches, _ := filepath.Glob(filepath.Join(directory, "kubelse-merged-*-"+owner+".text"))
+		if len(matches) != 1 {
+			t.Errorf("expected a merged report of %s, got %v", owner, matches)
+		}
+	}
+}
+
+func TestMistypedNamespaceIsReported(t *testing.T) {
+	_, k8s := startTestCluster(t, testPod("web-1", "nginx", nil))
+
+	if err := validateNamespaces(k8s, []string{"default"}); err != nil {
+		t.Fatal(err)
+	}
+	err := validateNamespaces(k8s, []string{"defualt"})
+	if err == nil || !strings.Contains(err.Error(), `Namespace "defualt" does not exist, did you mean default?`) {
+		t.Errorf("expected the namespace to be reported as missing, got %v", err)
+	}
+}
+
+func TestScanTargetsFile(t *testing.T) {
+	worker := testPod("worker-1", "busybox", nil)
+	worker.Namespace = "jobs"
+	sidecar := testPod("web-1", "nginx", nil)
+	sidecar.Spec.Containers = append(sidecar.Spec.Containers, corev1.Container{Name: "proxy", Image: "envoy"})
+	cluster, k8s := startTestCluster(t, sidecar, worker, testPod("web-2", "nginx", nil), &corev1.Namespace{ObjectMeta: metaV1.ObjectMeta{Name: "jobs"}})
+	cluster.SetContainer("web-1", "app", debian)
+	cluster.SetContainer("web-1", "proxy", debian)
+	cluster.SetContainer("worker-1", "app", debian)
+	cluster.SetContainer("web-2", "app", debian)
+
+	targetsFile = filepath.Join(t.TempDir(), "targets")
+	t.Cleanup(func() { targetsFile = "" })
+	if err := os.WriteFile(targetsFile, []byte("# from a CMDB export\ndefault/web-1/proxy\n\njobs/worker-1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	manifest, err := scanTargetsFile(k8s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var scanned []string
+	for _, entry := range manifest.Scanned {
+		scanned = append(scanned, entry.Pod+"/"+entry.Container)
+	}
+	if strings.Join(scanned, ",") != "web-1/proxy,worker-1/app" {
+		t.Errorf("expected web-1/proxy and worker-1/app to be scanned, got %v", scanned)
+	}
+
+	if _, err := parseTargets(strings.NewReader("default/web-1/app/extra\n")); err == nil {
+		t.Error("expected an invalid target to be rejected")
+	}
+}
+
+func TestPartialScanIsMarked(t *testing.T) {
+	cluster, k8s := startTestCluster(t, testPod("web-1", "nginx:1.25", nil))
+	killed := debian
+	killed.LseOutput, killed.LseRetCode = lseOutput[:5], k8sexec.FatalErrorSignal9
+	cluster.SetContainer("web-1", "app", killed)
+
+	containers, err := getContainers(k8s, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest, err := scanContainers(k8s, containers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry := manifest.Scanned[0]; entry.Status != StatusPartial || !strings.Contains(entry.Report, ".partial.") {
+		t.Errorf("expected a partial report, got %+v", entry)
+	}
+}
+
+func TestRunAbortsWhenErrorBudgetIsExhausted(t *testing.T) {
+	cluster, k8s := startTestCluster(t, testPod("web-1", "nginx", nil), testPod("web-2", "nginx", nil), testPod("web-3", "nginx", nil))
+	blocked := debian
+	blocked.LseOutput, blocked.LseRetCode = nil, k8sexec.InternalAppError
+	for _, pod := range []string{"web-1", "web-2", "web-3"} {
+		cluster.SetContainer(pod, "app", blocked)
+	}
+
+	// the canary container is scanned alone, so the budget is exhausted before the others are scanned
+	canary, maxFailures = 1, "0"
+	t.Cleanup(func() { canary, maxFailures, errorBudget = 0, "", nil })
+	containers, err := getContainers(k8s, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest, err := scanContainers(k8s, containers)
+	if err == nil || !strings.Contains(err.Error(), "Run aborted") {
+		t.Fatalf("expected the run to be aborted, got %v", err)
+	}
+	if len(manifest.Scanned) != 1 || len(manifest.Skipped) != 2 {
+		t.Errorf("expected 1 scanned and 2 skipped containers, got %d and %d", len(manifest.Scanned), len(manifest.Skipped))
+	}
+}
+
+func TestRunAbortsWhenNoCanaryIsScanned(t *testing.T) {
+	cluster, k8s := startTestCluster(t, testPod("web-1", "nginx", nil), testPod("web-2", "nginx", nil), testPod("web-3", "nginx", nil))
+	for _, pod := range []string{"web-1", "web-2", "web-3"} {
+		cluster.SetContainer(pod, "app", debian)
+	}
+
+	// every pod starts terminating once lse.sh is started in it, so the canary is never scanned
+	deleted := metaV1.Now()
+	var scans atomic.Int32
+	canary, terminationCheckInterval = 1, 10*time.Millisecond
+	t.Cleanup(func() { canary, terminationCheckInterval = 0, 5*time.Second })
+	execHook = func(pod string, container string, args []string, stdin []byte) *k8sexec.ExecutionStatus {
+		if len(stdin) > 0 {
+			scans.Add(1)
+			found, err := cluster.Clientset.CoreV1().Pods("default").Get(context.TODO(), pod, metaV1.GetOptions{})
+			if err == nil {
+				found.DeletionTimestamp = &deleted
+				cluster.Clientset.CoreV1().Pods("default").Update(context.TODO(), found, metaV1.UpdateOptions{})
+			}
+			time.Sleep(500 * time.Millisecond)
+		}
+		return cluster.Exec(pod, container, args, stdin)
+	}
+
+	containers, err := getContainers(k8s, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest, err := scanContainers(k8s, containers)
+	if err == nil || !strings.Contains(err.Error(), "canary") {
+		t.Fatalf("expected the run to be aborted, got %v", err)
+	}
+	if len(manifest.Scanned) != 0 || scans.Load() != 1 {
+		t.Errorf("expected only the canary to be started and nothing scanned, got %d scans and %+v", scans.Load(), manifest.Scanned)
+	}
+}
+
+func TestInterruptedRunAbandonsExecs(t *testing.T) {
+	cluster, k8s := startTestCluster(t, testPod("web-1", "nginx", nil), testPod("web-2", "nginx", nil), testPod("web-3", "nginx", nil))
+	for _, pod := range []string{"web-1", "web-2", "web-3"} {
+		cluster.SetContainer(pod, "app", debian)
+	}
+
+	// the run is interrupted, e.g. with Ctrl-C, once lse.sh is started
+	ctx, cancel := context.WithCancelCause(context.Background())
+	t.Cleanup(func() { cancel(nil) })
+	execHook = func(pod string, container string, args []string, stdin []byte) *k8sexec.ExecutionStatus {
+		if len(stdin) > 0 {
+			cancel(errInterrupted)
+			time.Sleep(100 * time.Millisecond)
+		}
+		return cluster.Exec(pod, container, args, stdin)
+	}
+
+	containers, err := getContainers(k8s, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest, err := scan(ctx, k8s, containers)
+	if err == nil || !strings.Contains(err.Error(), "Run interrupted, 3 containers were not scanned") {
+		t.Fatalf("expected the run to be interrupted, got %v", err)
+	}
+	if len(manifest.Scanned) != 0 || len(manifest.Skipped) != 3 || manifest.Skipped[0].Reason != skipReasonInterrupted {
+		t.Errorf("expected 3 interrupted containers, got %+v and %+v", manifest.Scanned, manifest.Skipped)
+	}
+}
+
+func TestRunStopsAtOutputSizeLimit(t *testing.T) {
+	cluster, k8s := startTestCluster(t, testPod("web-1", "nginx", nil), testPod("web-2", "nginx", nil), testPod("web-3", "nginx", nil))
+	for _, pod := range []string{"web-1", "web-2", "web-3"} {
+		cluster.SetContainer(pod, "app", debian)
+	}
+
+	// the canary report does not fit in the limit, so the others are not scanned
+	canary, maxOutputSize = 1, "1K"
+	t.Cleanup(func() { canary, maxOutputSize, outputQuota = 0, "", nil })
+	containers, err := getContainers(k8s, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest, err := scanContainers(k8s, containers)
+	if err == nil || !strings.Contains(err.Error(), "max-output-size") {
+		t.Fatalf("expected the run to be stopped, got %v", err)
+	}
+	if len(manifest.Skipped) != 2 {
+		t.Errorf("expected 2 skipped containers, got %d", len(manifest.Skipped))
+	}
+	if reports, _ := filepath.Glob(filepath.Join(directory, "web-*")); len(reports) != 0 {
+		t.Errorf("expected no reports above the limit, got %v", reports)
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	for value, expected := range map[string]int64{"512": 512, "1K": 1024, "500M": 500 << 20, "2GiB": 2 << 30, "3gb": 3 << 30} {
+		if actual, err := parseSize(value); err != nil || actual != expected {
+			t.Errorf("parseSize(%q) = %d, %v, expected %d", value, actual, err, expected)
+		}
+	}
+	for _, value := range []string{"", "0", "-1M", "1.5G", "10X"} {
+		if _, err := parseSize(value); err == nil {
+			t.Errorf("parseSize(%q) should fail", value)
+		}
+	}
+}
+
+func TestShellOfLatestScanIsReused(t *testing.T) {
+	cluster, k8s := startTestCluster(t, testPod("web-1", "nginx", nil))
+	cluster.SetContainer("web-1", "app", debian)
+	containers, err := getContainers(k8s, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest, err := scanContainers(k8s, containers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := loadReport(filepath.Join(directory, manifest.Scanned[0].Report))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if shell := reportedShell(directory, "default", "web-1", "app"); shell == "" || shell != report.Header["Shell"] {
+		t.Errorf("expected the shell of the scan %q, got %q", report.Header["Shell"], shell)
+	}
+	if shell := reportedShell(directory, "other", "web-1", "app"); shell != "" {
+		t.Errorf("expected no shell of a pod in another namespace, got %q", shell)
+	}
+}
+
+func TestReportsSuggestKubectlCommands(t *testing.T) {
+	pod := testPod("web-1", "nginx", nil)
+	privileged := true
+	pod.Spec.Containers[0].SecurityContext = &corev1.SecurityContext{Privileged: &privileged}
+	pod.Spec.Volumes = []corev1.Volume{
+		{Name: "docker", VolumeSource: corev1.VolumeSource{HostPath: &corev1.HostPathVolumeSource{Path: "/var/run/docker.sock"}}},
+		{Name: "logs", VolumeSource: corev1.VolumeSource{HostPath: &corev1.HostPathVolumeSource{Path: "/var/log"}}},
+		{Name: "certs", VolumeSource: corev1.VolumeSource{HostPath: &corev1.HostPathVolumeSource{Path: "/etc/ssl"}}},
+	}
+	pod.Spec.Containers[0].VolumeMounts = []corev1.VolumeMount{
+		{Name: "docker", MountPath: "/var/run/docker.sock"},
+		{Name: "logs", MountPath: "/logs"},
+		{Name: "certs", MountPath: "/certs", ReadOnly: true},
+	}
+	cluster, k8s := startTestCluster(t, pod)
+	cluster.SetContainer("web-1", "app", debian)
+
+	containers, err := getContainers(k8s, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest, err := scanContainers(k8s, containers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := os.ReadFile(filepath.Join(directory, manifest.Scanned[0].Report))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		`[!] container "app" is privileged`,
+		`[!] container "app" mounts the container runtime socket /var/run/docker.sock at /var/run/docker.sock`,
+		"kubectl exec -n default web-1 -c app -- ls -l /var/run/docker.sock",
+		`[!] container "app" mounts host path /var/log writable at /logs`,
+		"kubectl edit -n default pod/web-1",
+	} {
+		if !strings.Contains(string(report), expected) {
+			t.Errorf("expected %q in the report", expected)
+		}
+	}
+	if strings.Contains(string(report), "/certs") {
+		t.Errorf("read-only host paths should not be reported")
+	}
+}
+
+func TestPolicyViolationsFailTheRun(t *testing.T) {
+	cluster, k8s := startTestCluster(t, testPod("web-1", "nginx", nil))
+	cluster.SetContainer("web-1", "app", debian)
+
+	policyCommand, policyExitCode = `grep -q '"ID":"fst010"' && echo '[{"Pod":"web-1","Rule":"no-writable-passwd","Message":"/etc/passwd is writable"}]' || echo '[]'`, 3
+	t.Cleanup(func() { policyCommand = "" })
+	containers, err := getContainers(k8s, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest, err := scanContainers(k8s, containers)
+	var exitErr *ExitError
+	if !errors.As(err, &exitErr) || exitErr.Code != 3 {
+		t.Fatalf("expected the run to fail with the policy exit code, got %v", err)
+	}
+	if manifest.Policy == nil || manifest.Policy.Passed || len(manifest.Policy.Violations) != 1 || manifest.Policy.Violations[0].Rule != "no-writable-passwd" {
+		t.Errorf("unexpected policy verdict %+v", manifest.Policy)
+	}
+}
+
+func TestParseOPAViolations(t *testing.T) {
+	output := []byte(`{"result":[{"expressions":[{"value":["web-1 is privileged",{"Pod":"web-2","Message":"docker.sock is mounted"}],"text":"data.kubelse.deny"}]}]}`)
+	violations, err := parseViolations(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(violations) != 2 || violations[0].Message != "web-1 is privileged" || violations[1].String() != "web-2: docker.sock is mounted" {
+		t.Errorf("unexpected violations %+v", violations)
+	}
+	if violations, err := parseViolations([]byte(`{"result":[{"expressions":[{"value":[]}]}]}`)); err != nil || len(violations) != 0 {
+		t.Errorf("expected no violations, got %+v, %v", violations, err)
+	}
+}
+
+func TestTerminatingPodsAreSkipped(t *testing.T) {
+	deleted := metaV1.Now()
+	terminating := testPod("web-2", "nginx", nil)
+	terminating.DeletionTimestamp = &deleted
+	cluster, k8s := startTestCluster(t, testPod("web-1", "nginx", nil), terminating, testPod("web-3", "nginx", nil))
+	for _, pod := range []string{"web-1", "web-2", "web-3"} {
+		cluster.SetContainer(pod, "app", debian)
+	}
+
+	// web-3 is deleted, e.g. by a rollout, while lse.sh runs in it
+	terminationCheckInterval = 10 * time.Millisecond
+	t.Cleanup(func() { terminationCheckInterval = 5 * time.Second })
+	execHook = func(pod string, container string, args []string, stdin []byte) *k8sexec.ExecutionStatus {
+		if pod == "web-3" && len(stdin) > 0 {
+			found, err := cluster.Clientset.CoreV1().Pods("default").Get(context.TODO(), pod, metaV1.GetOptions{})
+			if err == nil {
+				found.DeletionTimestamp = &deleted
+				cluster.Clientset.CoreV1().Pods("default").Update(context.TODO(), found, metaV1.UpdateOptions{})
+			}
+			time.Sleep(500 * time.Millisecond)
+		}
+		return cluster.Exec(pod, container, args, stdin)
+	}
+
+	containers, err := getContainers(k8s, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest, err := scanContainers(k8s, containers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(manifest.Scanned) != 1 || manifest.Scanned[0].Pod != "web-1" {
+		t.Errorf("expected only web-1 to be scanned, got %+v", manifest.Scanned)
+	}
+	var skipped []string
+	for _, entry := range manifest.Skipped {
+		if entry.Reason == skipReasonTerminating {
+			skipped = append(skipped, entry.Pod)
+		}
+	}
+	if strings.Join(skipped, ",") != "web-2,web-3" {
+		t.Errorf("expected web-2 and web-3 to be skipped as terminating, got %v", skipped)
+	}
+}
+
+func TestDryRunListsCommandsExecutedByScans(t *testing.T) {
+	cluster, k8s := startTestCluster(t, testPod("web-1", "nginx", nil))
+	cluster.SetContainer("web-1", "app", debian)
+	hashInventory, metadataProbe, apiProbe = true, true, true
+	t.Cleanup(func() { hashInventory, metadataProbe, apiProbe = false, false, false })
+
+	var (
+		mu       sync.Mutex
+		executed = make(map[string]bool)
+	)
+	execHook = func(pod string, container string, args []string, stdin []byte) *k8sexec.ExecutionStatus {
+		mu.Lock()
+		executed[strings.Join(args, "\x00")] = true
+		mu.Unlock()
+		return cluster.Exec(pod, container, args, stdin)
+	}
+	containers, err := getContainers(k8s, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := scanContainers(k8s, containers); err != nil {
+		t.Fatal(err)
+	}
+
+	container := verifyContainer(context.Background(), k8s, &pacer{}, ContainerInfo{container: containers[0]})
+	stages := make(map[string]bool)
+	for _, command := range containerCommands(container) {
+		stages[command.stage] = true
+		args := command.args
+		if strings.HasPrefix(command.stage, "hash inventory") {
+			// setuid binaries are known only from the report
+			args = args[:4]
+			found := false
+			for key := range executed {
+				found = found || strings.HasPrefix(key, strings.Join(args, "\x00"))
+			}
+			if !found {
+				t.Errorf("expected the hash command %q to be executed", args)
+			}
+			continue
+		}
+		if !executed[strings.Join(args, "\x00")] {
+			t.Errorf("expected the %s command %q to be executed", command.stage, args)
+		}
+	}
+	for _, stage := range []string{"verify", "scan", "metadata probe", "API probe"} {
+		if !stages[stage] {
+			t.Errorf("expected commands of the %s stage listed, got %v", stage, stages)
+		}
+	}
+	if !strings.Contains(filesystemScript, tmpProbeFile) {
+		t.Errorf("expected the /tmp probe file %s in the filesystem check", tmpProbeFile)
+	}
+}
+
+func TestScanExecsIdentifyRun(t *testing.T) {
+	cluster, k8s := startTestCluster(t, testPod("web-1", "nginx", nil))
+	cluster.SetContainer("web-1", "app", debian)
+	auditComment = true
+	t.Cleanup(func() { auditComment = false })
+
+	var scanArgs []string
+	execHook = func(pod string, container string, args []string, stdin []byte) *k8sexec.ExecutionStatus {
+		if len(stdin) > 0 {
+			scanArgs = args
+		}
+		return cluster.Exec(pod, container, args, stdin)
+	}
+	containers, err := getContainers(k8s, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	startRun(k8s)
+	manifest, err := scanContainers(k8s, containers)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(scanArgs) < 4 || scanArgs[1] != "-c" || !strings.HasSuffix(scanArgs[2], "# kubelse run "+manifest.RunID) || scanArgs[3] != scanArgs[0] {
+		t.Errorf("expected the scan exec to carry the run ID in a comment, got %q", scanArgs)
+	}
+	if !strings.HasPrefix(k8s.Config.UserAgent, "kubelse/") || !strings.HasSuffix(k8s.Config.UserAgent, "run/"+manifest.RunID) {
+		t.Errorf("expected the user agent to identify kubelse and the run, got %q", k8s.Config.UserAgent)
+	}
+}
+
+func TestPanicInWorkerFailsOnlyItsContainer(t *testing.T) {
+	cluster, k8s := startTestCluster(t, testPod("web-1", "nginx", nil), testPod("web-2", "nginx", nil))
+	cluster.SetContainer("web-1", "app", debian)
+	cluster.SetContainer("web-2", "app", debian)
+	execHook = func(pod string, container string, args []string, stdin []byte) *k8sexec.ExecutionStatus {
+		if pod == "web-2" && len(stdin) > 0 {
+			var odd *k8sexec.ExecutionStatus
+			_ = odd.Stdout[0]
+		}
+		return cluster.Exec(pod, container, args, stdin)
+	}
+
+	containers, err := getContainers(k8s, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest, err := scanContainers(k8s, containers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	statuses := map[string]string{}
+	for _, entry := range manifest.Scanned {
+		statuses[entry.Pod] = entry.Status
+	}
+	if statuses["web-1"] != StatusComplete || statuses["web-2"] != StatusFailed {
+		t.Errorf("expected web-1 to be complete and web-2 failed, got %v", statuses)
+	}
+}
+
+func TestManifestIsOrderedRegardlessOfCompletion(t *testing.T) {
+	cluster, k8s := startTestCluster(t, testPod("web-a", "nginx", nil), testPod("web-b", "nginx", nil), testPod("web-c", "nginx", nil))
+	for _, pod := range []string{"web-a", "web-b", "web-c"} {
+		cluster.SetContainer(pod, "app", debian)
+	}
+	// web-a finishes last
+	execHook = func(pod string, container string, args []string, stdin []byte) *k8sexec.ExecutionStatus {
+		if pod == "web-a" && len(stdin) > 0 {
+			time.Sleep(100 * time.Millisecond)
+		}
+		return cluster.Exec(pod, container, args, stdin)
+	}
+
+	containers, err := getContainers(k8s, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest, err := scanContainers(k8s, containers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var pods []string
+	for _, entry := range manifest.Scanned {
+		pods = append(pods, entry.Namespace+"/"+entry.Pod)
+	}
+	if strings.Join(pods, ",") != "default/web-a,default/web-b,default/web-c" {
+		t.Errorf("expected scanned containers ordered by namespace and pod, got %v", pods)
+	}
+}
+
+func TestHashInventoryReportsTamperedBinaries(t *testing.T) {
+	cluster, k8s := startTestCluster(t, testPod("web-1", "nginx", nil), testPod("web-2", "nginx", nil), testPod("web-3", "nginx", nil))
+	for _, pod := range []string{"web-1", "web-2", "web-3"} {
+		cluster.SetContainer(pod, "app", debian)
+	}
+	hashInventory = true
+	t.Cleanup(func() { hashInventory = false })
+
+	genuine, tampered := strings.Repeat("a", 64), strings.Repeat("b", 64)
+	execHook = func(pod string, container string, args []string, stdin []byte) *k8sexec.ExecutionStatus {
+		if len(args) > 2 && args[2] == hashScript {
+			su := genuine
+			if pod == "web-3" {
+				su = tampered
+			}
+			return k8sexec.NewExecutionStatus(pod, container, k8sexec.Success, "", genuine+"  /bin/sh\n"+su+"  /bin/su", "")
+		}
+		return cluster.Exec(pod, container, args, stdin)
+	}
+
+	containers, err := getContainers(k8s, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := scanContainers(k8s, containers); err != nil {
+		t.Fatal(err)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(directory, "kubelse-hashes-*.json"))
+	if len(files) != 1 {
+		t.Fatalf("expected a hash inventory, got %v", files)
+	}
+	content, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var inventory HashInventory
+	if err := json.Unmarshal(content, &inventory); err != nil {
+		t.Fatal(err)
+	}
+	if len(inventory.Outliers) != 1 || inventory.Outliers[0].Container != "web-3/app" || inventory.Outliers[0].Path != "/bin/su" || inventory.Outliers[0].Expected != genuine {
+		t.Errorf("expected /bin/su of web-3 to be the only outlier, got %+v", inventory.Outliers)
+	}
+	if len(inventory.Images["nginx"]["/bin/sh"][genuine]) != 3 {
+		t.Errorf("expected /bin/sh of all containers in the inventory, got %v", inventory.Images["nginx"]["/bin/sh"])
+	}
+}
+
+func TestStalledScansAreRetriedWithReducedScope(t *testing.T) {
+	cluster, k8s := startTestCluster(t, testPod("web-1", "nginx", nil), testPod("stuck-1", "nginx", nil))
+	cluster.SetContainer("web-1", "app", debian)
+	cluster.SetContainer("stuck-1", "app", debian)
+	execHook = func(pod string, container string, args []string, stdin []byte) *k8sexec.ExecutionStatus {
+		// lse.sh hangs in stuck-1, unless it runs with a reduced scope
+		if pod == "stuck-1" && stdin != nil && !strings.Contains(strings.Join(args, " "), "-l 0") {
+			time.Sleep(time.Second)
+		}
+		return cluster.Exec(pod, container, args, stdin)
+	}
+	stallTimeout, stallGrace = 100*time.Millisecond, 10*time.Millisecond
+	t.Cleanup(func() { stallTimeout, stallAction, stallGrace = 0, "retry", 5*time.Second })
+
+	for _, action := range []string{"retry", "mark"} {
+		stallAction = action
+		containers, err := getContainers(k8s, nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		manifest, err := scanContainers(k8s, containers)
+		if err != nil && action == "retry" {
+			t.Fatal(err)
+		}
+		for _, entry := range manifest.Scanned {
+			switch {
+			case entry.Pod == "web-1" && (entry.Stalled || entry.Attempts != 1):
+				t.Errorf("%s: unexpected entry of a responsive container %+v", action, entry)
+			case entry.Pod == "stuck-1" && action == "retry" && (entry.Stalled || entry.Attempts != 2 || entry.Status != StatusComplete):
+				t.Errorf("%s: expected the stalled container to be retried, got %+v", action, entry)
+			case entry.Pod == "stuck-1" && action == "mark" && (!entry.Stalled || entry.Status != StatusFailed):
+				t.Errorf("%s: expected the stalled container to be marked, got %+v", action, entry)
+			}
+		}
+	}
+}
+
+func TestPostHookRunsForEveryReport(t *testing.T) {
+	cluster, k8s := startTestCluster(t, testPod("web-1", "nginx", nil), testPod("web-2", "nginx", nil))
+	cluster.SetContainer("web-1", "app", debian)
+	cluster.SetContainer("web-2", "app", debian)
+	events := t.TempDir()
+	t.Setenv("EVENTS", events)
+	postHook, postHookTimeout = `cat > "$EVENTS/$KUBELSE_POD.json"; test -f "$KUBELSE_REPORT"`, time.Minute
+	t.Cleanup(func() { postHook = "" })
+
+	containers, err := getContainers(k8s, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := scanContainers(k8s, containers); err != nil {
+		t.Fatal(err)
+	}
+	for _, pod := range []string{"web-1", "web-2"} {
+		content, err := os.ReadFile(filepath.Join(events, pod+".json"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var event HookEvent
+		if err := json.Unmarshal(content, &event); err != nil {
+			t.Fatal(err)
+		}
+		if event.RunID != runID || event.Pod != pod || event.Status != StatusComplete || !filepath.IsAbs(event.Report) || event.Findings[SeverityCritical] == 0 {
+			t.Errorf("unexpected event %+v", event)
+		}
+	}
+}
+
+func TestDriftFromGoldenImages(t *testing.T) {
+	cluster, k8s := startTestCluster(t, testPod("web-1", "nginx", nil), testPod("web-2", "nginx", nil), testPod("db-1", "postgres", nil))
+	cluster.SetContainer("web-1", "app", debian)
+	drifted := debian
+	drifted.LseOutput = append(append([]string{}, lseOutput[:len(lseOutput)-2]...), "-rwsr-xr-x 1 root root 1234 /tmp/.x", "---", lseOutput[len(lseOutput)-1])
+	cluster.SetContainer("web-2", "app", drifted)
+	cluster.SetContainer("db-1", "app", debian)
+	cluster.SetContainer(goldenPodName("nginx"), "golden", debian)
+	format, golden, goldenTimeout, goldenPollInterval = "json", true, time.Second, 10*time.Millisecond
+	t.Cleanup(func() { golden = false })
+
+	containers, err := getContainers(k8s, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := scanContainers(k8s, containers); err != nil {
+		t.Fatal(err)
+	}
+	files, _ := filepath.Glob(filepath.Join(directory, "kubelse-drift-*.json"))
+	if len(files) != 1 {
+		t.Fatalf("expected a drift file, got %v", files)
+	}
+	content, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var drift struct {
+		Containers []ContainerDrift
+		Uncompared []string
+	}
+	if err := json.Unmarshal(content, &drift); err != nil {
+		t.Fatal(err)
+	}
+	if len(drift.Containers) != 2 || len(drift.Containers[0].Findings) != 0 || len(drift.Containers[1].Findings) != 1 {
+		t.Fatalf("expected only web-2 to drift, got %+v", drift.Containers)
+	}
+	if finding := drift.Containers[1].Findings[0]; finding.ID != "fst010" || len(finding.Details) != 1 || finding.Details[0] != "-rwsr-xr-x 1 root root 1234 /tmp/.x" {
+		t.Errorf("expected the new file to be the only drift, got %+v", finding)
+	}
+	// golden pods of images, which could not be scanned, leave their containers uncompared
+	if len(drift.Uncompared) != 1 || drift.Uncompared[0] != "db-1/app" {
+		t.Errorf("expected db-1 not to be compared, got %v", drift.Uncompared)
+	}
+	if pods, _ := cluster.Clientset.CoreV1().Pods("default").List(context.TODO(), metaV1.ListOptions{}); len(pods.Items) != 3 {
+		t.Errorf("expected golden pods to be removed, got %d pods", len(pods.Items))
+	}
+}
+
+func TestScansEmitEventsOnPods(t *testing.T) {
+	cluster, k8s := startTestCluster(t, testPod("web-1", "nginx", nil), testPod("broken-1", "nginx", nil))
+	cluster.SetContainer("web-1", "app", debian)
+	broken := debian
+	broken.LseOutput, broken.LseRetCode = nil, k8sexec.ExitCode(1)
+	cluster.SetContainer("broken-1", "app", broken)
+	events = true
+	t.Cleanup(func() { events = false })
+
+	containers, err := getContainers(k8s, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := scanContainers(k8s, containers); err != nil {
+		t.Fatal(err)
+	}
+	list, err := cluster.Clientset.CoreV1().Events("default").List(context.TODO(), metaV1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	reasons := make(map[string][]string)
+	for _, event := range list.Items {
+		if event.InvolvedObject.Kind != "Pod" || event.InvolvedObject.FieldPath != "spec.containers{app}" || !strings.Contains(event.Message, runID) {
+			t.Errorf("unexpected event %+v", event)
+		}
+		reasons[event.InvolvedObject.Name] = append(reasons[event.InvolvedObject.Name], event.Reason)
+	}
+	if strings.Join(reasons["web-1"], ",") != "LseScanStarted,LseScanCompleted" || strings.Join(reasons["broken-1"], ",") != "LseScanStarted,LseScanFailed" {
+		t.Errorf("unexpected events %v", reasons)
+	}
+}
+
+func TestMinScoreFiltersReports(t *testing.T) {
+	yes := true
+	exposed := testPod("exposed-1", "nginx", nil)
+	exposed.Spec.HostPID = true
+	exposed.Spec.Volumes = []corev1.Volume{{Name: "root", VolumeSource: corev1.VolumeSource{HostPath: &corev1.HostPathVolumeSource{Path: "/"}}}}
+	exposed.Spec.Containers[0].SecurityContext = &corev1.SecurityContext{Privileged: &yes}
+	exposed.Spec.Containers[0].VolumeMounts = []corev1.VolumeMount{{Name: "root", MountPath: "/host"}}
+	cluster, k8s := startTestCluster(t, exposed, testPod("web-1", "nginx", nil))
+	cluster.SetContainer("exposed-1", "app", debian)
+	cluster.SetContainer("web-1", "app", debian)
+	cluster.Permissions["list secrets"] = true
+	minScore = 60
+	t.Cleanup(func() { minScore = 0 })
+
+	containers, err := getContainers(k8s, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest, err := scanContainers(k8s, containers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	scores := make(map[string]int)
+	for _, entry := range manifest.Scanned {
+		if entry.Risk == nil {
+			t.Fatalf("no risk score of %s", entry.Pod)
+		}
+		scores[entry.Pod] = entry.Risk.Score
+		if entry.Pod == "web-1" && !strings.Contains(entry.Reason, "below '--min-score'") {
+			t.Errorf("unexpected reason %q of web-1", entry.Reason)
+		}
+	}
+	if scores["exposed-1"] < 90 || scores["web-1"] >= 60 {
+		t.Errorf("unexpected risk scores %v", scores)
+	}
+	if reports, _ := filepath.Glob(filepath.Join(directory, "web-1*")); len(reports) != 0 {
+		t.Errorf("report of web-1 saved below '--min-score': %v", reports)
+	}
+	if reports, _ := filepath.Glob(filepath.Join(directory, "exposed-1*")); len(reports) != 1 {
+		t.Errorf("expected a report of exposed-1, got %v", reports)
+	}
+}
+
+func TestPodRiskFactors(t *testing.T) {
+	no, user := false, int64(1000)
+	hardened := testPod("hardened", "nginx", nil)
+	hardened.Spec.AutomountServiceAccountToken = &no
+	hardened.Spec.SecurityContext = &corev1.PodSecurityContext{RunAsUser: &user}
+	hardened.Spec.Containers[0].SecurityContext = &corev1.SecurityContext{AllowPrivilegeEscalation: &no}
+	if factors := podRiskFactors(hardened, "app"); len(factors) != 0 {
+		t.Errorf("unexpected risk factors of a hardened pod %+v", factors)
+	}
+
+	exposed := testPod("exposed", "nginx", nil)
+	exposed.Spec.HostNetwork = true
+	exposed.Spec.ServiceAccountName = "deployer"
+	exposed.Spec.Containers[0].SecurityContext = &corev1.SecurityContext{Capabilities: &corev1.Capabilities{Add: []corev1.Capability{"NET_BIND_SERVICE", "SYS_ADMIN"}}}
+	var factors []string
+	for _, factor := range podRiskFactors(exposed, "app") {
+		factors = append(factors, fmt.Sprintf("%s +%d", factor.Factor, factor.Points))
+	}
+	expected := "hostNetwork +10,capabilities SYS_ADMIN +10,may run as root +5,privilege escalation allowed +5,service account token deployer +5"
+	if strings.Join(factors, ",") != expected {
+		t.Errorf("expected risk factors %s, got %s", expected, strings.Join(factors, ","))
+	}
+}
+
+func TestDashboardOfRun(t *testing.T) {
+	cluster, k8s := startTestCluster(t, testPod("web-1", "nginx", nil), testPod("web-2", "nginx", nil), testPod("distroless-1", "gcr.io/distroless/static", nil))
+	cluster.SetContainer("web-1", "app", debian)
+	cluster.SetContainer("web-2", "app", debian)
+	cluster.SetContainer("distroless-1", "app", fakecluster.FakeContainer{})
+	dashboard = true
+	t.Cleanup(func() { dashboard = false })
+
+	containers, err := getContainers(k8s, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest, err := scanContainers(k8s, containers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := dashboardData(manifest)
+	if len(data.Riskiest) != 2 || data.Severities[SeverityCritical] != 2 {
+		t.Errorf("unexpected dashboard data %+v", data)
+	}
+	if len(data.Namespaces) != 1 || data.Namespaces[0] != (DashboardNamespace{Namespace: "default", Scanned: 2, NotTestable: 1, Critical: 2}) {
+		t.Errorf("unexpected namespaces %+v", data.Namespaces)
+	}
+	files, _ := filepath.Glob(filepath.Join(directory, "kubelse-dashboard-*.html"))
+	if len(files) != 1 {
+		t.Fatalf("expected a dashboard, got %v", files)
+	}
+	content, _ := os.ReadFile(files[0])
+	if !strings.Contains(string(content), `"Namespace":"default","Scanned":2`) {
+		t.Errorf("expected namespace counts in the dashboard data")
+	}
+}
+
+func TestChunkedExecutionAggregatesSections(t *testing.T) {
+	cluster, k8s := startTestCluster(t, testPod("web-1", "nginx", nil))
+	cluster.SetContainer("web-1", "app", debian)
+	chunked = true
+	t.Cleanup(func() { chunked = false })
+
+	var sections []string
+	execHook = func(pod string, container string, args []string, stdin []byte) *k8sexec.ExecutionStatus {
+		if len(stdin) == 0 {
+			return cluster.Exec(pod, container, args, stdin)
+		}
+		// the last -s option of the command selects sections of lse.sh
+		var section string
+		for idx := range args[:len(args)-1] {
+			if args[idx] == "-s" {
+				section = args[idx+1]
+			}
+		}
+		sections = append(sections, section)
+		output := []string{"    Hostname: web-1", "=====================( Current Output Verbosity Level: 1 )=====================",
+			fmt.Sprintf("=====================( %s )=====================", section)}
+		switch section {
+		case "usr":
+			output = append(output, lseOutput[1:6]...)
+		case "fst":
+			output = append(output, lseOutput[6:11]...)
+		}
+		output = append(output, "==================================( FINISHED )==================================")
+		status := k8sexec.NewExecutionStatus(pod, container, k8sexec.Success, "", strings.Join(output, "\n"), "")
+		if section == "net" {
+			status.RetCode, status.Error = 1, []string{"command terminated with exit code 1"}
+		}
+		return status
+	}
+
+	containers, err := getContainers(k8s, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest, err := scanContainers(k8s, containers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(sections, ",") != strings.Join(chunkSections, ",") {
+		t.Errorf("expected an exec per section, got %v", sections)
+	}
+	if len(manifest.Scanned) != 1 || manifest.Scanned[0].RetCode != 1 || manifest.Scanned[0].Findings[SeverityCritical] != 1 {
+		t.Fatalf("unexpected manifest %+v", manifest.Scanned)
+	}
+	reports, _ := filepath.Glob(filepath.Join(directory, "web-1*"))
+	if len(reports) != 1 {
+		t.Fatalf("expected a report, got %v", reports)
+	}
+	content, _ := os.ReadFile(reports[0])
+	for text, count := range map[string]int{"Hostname: web-1": 1, "Verbosity": 1, "( FINISHED )": 1, "usr000": 1, "fst010": 1, "( ctn )": 1} {
+		if n := strings.Count(string(content), text); n != count {
+			t.Errorf("expected %q %d times in the aggregated report, got %d", text, count, n)
+		}
+	}
+}
+
+func TestMetadataProbeFlagsCloudCredentials(t *testing.T) {
+	cluster, k8s := startTestCluster(t, testPod("web-1", "nginx", nil), testPod("web-2", "nginx", nil))
+	cluster.SetContainer("web-1", "app", debian)
+	cluster.SetContainer("web-2", "app", debian)
+	metadataProbe = true
+	t.Cleanup(func() { metadataProbe = false })
+
+	execHook = func(pod string, container string, args []string, stdin []byte) *k8sexec.ExecutionStatus {
+		if len(args) == 3 && args[2] == metadataProbeScript {
+			stdout := "aws credentials eks-node-role"
+			if pod == "web-2" {
+				stdout = "notool"
+			}
+			return k8sexec.NewExecutionStatus(pod, container, k8sexec.Success, "", stdout, "")
+		}
+		return cluster.Exec(pod, container, args, stdin)
+	}
+
+	containers, err := getContainers(k8s, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest, err := scanContainers(k8s, containers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	findings := map[string]map[string]int{}
+	for _, entry := range manifest.Scanned {
+		findings[entry.Pod] = entry.Findings
+	}
+	if findings["web-1"][SeverityCritical] != 2 || findings["web-1"][SeverityInteresting] != 1 {
+		t.Errorf("expected cld000 and cld010 found in web-1, got %v", findings["web-1"])
+	}
+	if findings["web-2"][SeverityCritical] != 1 || findings["web-2"][SeverityInteresting] != 0 {
+		t.Errorf("expected metadata tests skipped in web-2, got %v", findings["web-2"])
+	}
+	reports, _ := filepath.Glob(filepath.Join(directory, "web-1*"))
+	if len(reports) != 1 {
+		t.Fatalf("expected a report, got %v", reports)
+	}
+	content, _ := os.ReadFile(reports[0])
+	if !strings.Contains(string(content), "aws: credentials of eks-node-role\n---\n==================================( FINISHED )") {
+		t.Errorf("expected metadata findings before the end of lse.sh output, got\n%s", content)
+	}
+}
+
+func TestAPIProbeRecordsAllowedVerbs(t *testing.T) {
+	cluster, k8s := startTestCluster(t, testPod("web-1", "nginx", nil))
+	cluster.SetContainer("web-1", "app", debian)
+	apiProbe = true
+	t.Cleanup(func() { apiProbe = false })
+
+	review := `{"kind":"SelfSubjectRulesReview","status":{"resourceRules":[` +
+		`{"verbs":["create"],"apiGroups":["authorization.k8s.io"],"resources":["selfsubjectaccessreviews","selfsubjectrulesreviews"]},` +
+		`{"verbs":["get","list"],"apiGroups":[""],"resources":["configmaps"]},` +
+		`{"verbs":["get","list"],"apiGroups":[""],"resources":["secrets"]}]}}`
+	execHook = func(pod string, container string, args []string, stdin []byte) *k8sexec.ExecutionStatus {
+		if len(args) == 3 && args[2] == apiProbeScript {
+			return k8sexec.NewExecutionStatus(pod, container, k8sexec.Success, "", "reachable rules\n"+review, "")
+		}
+		return cluster.Exec(pod, container, args, stdin)
+	}
+
+	containers, err := getContainers(k8s, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest, err := scanContainers(k8s, containers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := manifest.Scanned[0]
+	if entry.API == nil || !entry.API.Authenticated || strings.Join(entry.API.Rules, ";") != "get,list configmaps;get,list secrets" {
+		t.Fatalf("unexpected API access %+v", entry.API)
+	}
+	if strings.Join(entry.API.Sensitive, ";") != "get,list secrets" {
+		t.Errorf("expected reading secrets to be sensitive, got %v", entry.API.Sensitive)
+	}
+	// fst010, api020, api010 and api000
+	if entry.Findings[SeverityCritical] != 2 || entry.Findings[SeverityInteresting] != 1 || entry.Findings[SeverityInfo] != 2 {
+		t.Errorf("unexpected findings %v", entry.Findings)
+	}
+}
+
+func TestRunDirectoriesAndLatestLink(t *testing.T) {
+	cluster, k8s := startTestCluster(t, testPod("web-1", "nginx", nil))
+	cluster.SetContainer("web-1", "app", debian)
+	flat = false
+	root := directory
+
+	var runs []string
+	for range 2 {
+		containers, err := getContainers(k8s, nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		startRun(k8s)
+		manifest, err := scanContainers(k8s, containers)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if directory != root {
+			t.Fatalf("expected the reports directory restored after the run, got %s", directory)
+		}
+		runs = append(runs, runDirectory)
+		if !strings.HasSuffix(runDirectory, manifest.RunID[:8]) {
+			t.Errorf("expected a directory named by the run %s, got %s", manifest.RunID, runDirectory)
+		}
+		if reports, _ := filepath.Glob(filepath.Join(runDirectory, "web-1-app-*")); len(reports) != 1 {
+			t.Errorf("expected a report in the directory of the run, got %v", reports)
+		}
+	}
+	if runs[0] == runs[1] || filepath.Dir(runs[1]) != root {
+		t.Errorf("expected different directories of runs in %s, got %v", root, runs)
+	}
+	if target, err := os.Readlink(filepath.Join(root, latestRunLink)); err != nil || target != filepath.Base(runs[1]) {
+		t.Errorf("expected latest linked to %s, got %s (%v)", filepath.Base(runs[1]), target, err)
+	}
+}
+
+func TestWatchScansPodsBecomingReady(t *testing.T) {
+	ready := func(pod *corev1.Pod) *corev1.Pod {
+		pod.Status.Conditions = []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}}
+		return pod
+	}
+	cluster, k8s := startTestCluster(t, ready(testPod("web-1", "nginx", nil)))
+	for _, pod := range []string{"web-1", "ci-runner-1", "ci-runner-2"} {
+		cluster.SetContainer(pod, "app", debian)
+	}
+	containers, err := getContainers(k8s, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(containers) != 1 {
+		t.Fatalf("expected web-1 found at the start, got %v", containers)
+	}
+	seen := newWatchedPods(k8s.Namespace, containers)
+
+	starting := testPod("ci-runner-2", "runner", nil)
+	starting.Status.Phase = corev1.PodPending
+	for _, pod := range []*corev1.Pod{ready(testPod("ci-runner-1", "runner", nil)), starting} {
+		if _, err := cluster.Clientset.CoreV1().Pods("default").Create(context.TODO(), pod, metaV1.CreateOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	manifest, err := scanNewPods(k8s, seen)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(manifest.Scanned) != 1 || manifest.Scanned[0].Pod != "ci-runner-1" {
+		t.Fatalf("expected only the ready ci-runner-1 scanned, got %+v", manifest.Scanned)
+	}
+
+	// ci-runner-2 becomes ready, ci-runner-1 is not scanned again
+	ready(starting).Status.Phase = corev1.PodRunning
+	if _, err := cluster.Clientset.CoreV1().Pods("default").UpdateStatus(context.TODO(), starting, metaV1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if manifest, err = scanNewPods(k8s, seen); err != nil {
+		t.Fatal(err)
+	}
+	if len(manifest.Scanned) != 1 || manifest.Scanned[0].Pod != "ci-runner-2" {
+		t.Errorf("expected only ci-runner-2 scanned, got %+v", manifest.Scanned)
+	}
+	if manifest, _ = scanNewPods(k8s, seen); len(manifest.Scanned) != 0 {
+		t.Errorf("expected no pods scanned again, got %+v", manifest.Scanned)
+	}
+}
+
+func TestTenantsGetReportsOfTheirOwn(t *testing.T) {
+	web := testPod("web-1", "nginx", nil)
+	web.Labels = map[string]string{"app.kubernetes.io/instance": "myapp"}
+	worker := testPod("worker-1", "busybox", nil)
+	worker.Namespace, worker.Labels = "jobs", map[string]string{"app.kubernetes.io/instance": "myapp"}
+	jobs := &corev1.Namespace{ObjectMeta: metaV1.ObjectMeta{Name: "jobs", Annotations: map[string]string{"example.com/tenant": "batch team"}}}
+	cluster, k8s := startTestCluster(t, web, worker, jobs)
+	for _, pod := range []string{"web-1", "worker-1"} {
+		cluster.SetContainer(pod, "app", debian)
+	}
+	var delivered []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		delivered = append(delivered, r.URL.EscapedPath())
+	}))
+	defer server.Close()
+
+	root := directory
+	flat, argocdApp, argocdLabel = false, "myapp", "app.kubernetes.io/instance"
+	tenantAnnotation, tenantSink = "example.com/tenant", "http="+server.URL+"/hooks/{tenant}"
+	t.Cleanup(func() { argocdApp, labelSelector, tenantAnnotation, tenantSink, tenant = "", "", "", "", "" })
+	manifest, err := scanArgoCDApp(k8s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if manifest.Tenant != "" {
+		t.Errorf("expected no tenant of a run across tenants, got %s", manifest.Tenant)
+	}
+	if reports, _ := filepath.Glob(filepath.Join(root, tenantsDirectory, "batch-team", latestRunLink, "worker-1-app-*")); len(reports) != 1 {
+		t.Errorf("expected the report of worker-1 in the directory of its tenant, got %v", reports)
+	}
+	if reports, _ := filepath.Glob(filepath.Join(root, latestRunLink, "*-app-*")); len(reports) != 1 || !strings.Contains(reports[0], "web-1") {
+		t.Errorf("expected only the report of web-1 in the reports directory, got %v", reports)
+	}
+	if strings.Join(delivered, ",") != "/hooks/batch%20team" {
+		t.Errorf("expected the report of worker-1 delivered to the sink of its tenant, got %v", delivered)
+	}
+}
+
+func TestNamespacesAreScannedInOneRun(t *testing.T) {
+	worker := testPod("worker-1", "busybox", nil)
+	worker.Namespace = "jobs"
+	cluster, k8s := startTestCluster(t, testPod("web-1", "nginx", nil), worker, &corev1.Namespace{ObjectMeta: metaV1.ObjectMeta{Name: "jobs"}})
+	for _, pod := range []string{"web-1", "worker-1"} {
+		cluster.SetContainer(pod, "app", debian)
+	}
+
+	startRun(k8s)
+	manifest, err := scanNamespaces(k8s, []string{"default", "jobs"}, func(client *k8sexec.K8SExec) ([]Container, error) {
+		return getContainers(client, nil, nil)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(manifest.Scanned) != 2 || manifest.RunID != runID {
+		t.Fatalf("expected 2 containers scanned in run %s, got %d in %s", runID, len(manifest.Scanned), manifest.RunID)
+	}
+	manifests, _ := filepath.Glob(filepath.Join(directory, "kubelse-manifest-*"))
+	for _, file := range manifests {
+		if !strings.Contains(file, runID[:8]) {
+			t.Errorf("expected manifests of namespaces saved in run %s, got %s", runID, file)
+		}
+	}
+	if namespace != "default" {
+		t.Errorf("expected '--namespace' unchanged by the scan, got %s", namespace)
+	}
+}
+
+func TestImageConfigIsComparedWithPodSpec(t *testing.T) {
+	var tokens int
+	var server *httptest.Server
+	server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/token" {
+			tokens++
+			json.NewEncoder(w).Encode(map[string]string{"token": "pull-" + req.URL.Query().Get("scope")})
+			return
+		}
+		if req.Header.Get("Authorization") != "Bearer pull-repository:org/app:pull" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="`+server.URL+`/token",service="registry",scope="repository:org/app:pull"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch req.URL.Path {
+		case "/v2/org/app/manifests/1.0":
+			w.Write([]byte(`{"manifests":[{"digest":"sha256:arm","platform":{"os":"linux","architecture":"arm64"}},{"digest":"sha256:amd","platform":{"os":"linux","architecture":"amd64"}}]}`))
+		case "/v2/org/app/manifests/sha256:amd":
+			w.Write([]byte(`{"config":{"digest":"sha256:cfg"}}`))
+		case "/v2/org/app/blobs/sha256:cfg":
+			w.Write([]byte(`{"config":{"User":"app","Entrypoint":["/app"],"ExposedPorts":{"8080/tcp":{}},"Labels":{"org.opencontainers.image.source":"https://example.com/app"}}}`))
+		default:
+			http.NotFound(w, req)
+		}
+	}))
+	defer server.Close()
+	host := strings.TrimPrefix(server.URL, "https://")
+
+	root := int64(0)
+	pod := testPod("web-1", host+"/org/app:1.0", nil)
+	pod.Spec.Containers[0].Command = []string{"/bin/sh", "-c", "/app"}
+	pod.Spec.Containers[0].Ports = []corev1.ContainerPort{{ContainerPort: 8080}, {ContainerPort: 9090}}
+	pod.Spec.Containers[0].SecurityContext = &corev1.SecurityContext{RunAsUser: &root}
+	cluster, k8s := startTestCluster(t, pod, testPod("web-2", host+"/org/app:1.0", nil))
+	cluster.SetContainer("web-1", "app", debian)
+	cluster.SetContainer("web-2", "app", debian)
+	imageConfig, registry = true, newRegistryClient(server.Client())
+	t.Cleanup(func() { imageConfig = false })
+
+	containers, err := getContainers(k8s, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := scanContainers(k8s, containers); err != nil {
+		t.Fatal(err)
+	}
+	if tokens != 1 {
+		t.Errorf("expected the config read once for both pods, %d tokens were requested", tokens)
+	}
+	positive := map[string][]string{}
+	for _, pod := range []string{"web-1", "web-2"} {
+		reports, _ := filepath.Glob(filepath.Join(directory, pod+"*"))
+		if len(reports) != 1 {
+			t.Fatalf("expected a report of %s, got %v", pod, reports)
+		}
+		report, err := loadReport(reports[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, finding := range report.Positive() {
+			if strings.HasPrefix(finding.ID, "img") {
+				positive[pod] = append(positive[pod], finding.ID)
+			}
+			if finding.ID == "img000" && !strings.Contains(strings.Join(finding.Details, "\n"), "User: app\nEntrypoint: /app") {
+				t.Errorf("expected the image config listed, got %q", finding.Details)
+			}
+		}
+	}
+	if strings.Join(positive["web-1"], ",") != "img000,img010,img030,img040" || strings.Join(positive["web-2"], ",") != "img000" {
+		t.Errorf("unexpected image config findings %v", positive)
+	}
+
+	ref, _ := parseImageReference("nginx:1.25", "docker.io/library/nginx@sha256:abc")
+	if ref.String() != "registry-1.docker.io/library/nginx@sha256:abc" {
+		t.Errorf("expected the digest run by the container runtime, got %s", ref)
+	}
+}
+
+func TestImageConfigOfPrivateRegistryIsReadWithPullSecret(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if user, password, ok := req.BasicAuth(); !ok || user != "ci" || password != "s3cret" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="registry"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch req.URL.Path {
+		case "/v2/team/api/manifests/2.0":
+			w.Write([]byte(`{"config":{"digest":"sha256:cfg"}}`))
+		case "/v2/team/api/blobs/sha256:cfg":
+			w.Write([]byte(`{"config":{"User":"1000"}}`))
+		default:
+			http.NotFound(w, req)
+		}
+	}))
+	defer server.Close()
+	host := strings.TrimPrefix(server.URL, "https://")
+
+	dir := t.TempDir()
+	caFile, settingsFile := filepath.Join(dir, "ca.pem"), filepath.Join(dir, "registries.json")
+	os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0600)
+	os.WriteFile(settingsFile, []byte(`{"`+host+`": {"CAFile": "`+caFile+`"}}`), 0600)
+	if err := loadRegistryConfig(settingsFile); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { registrySettings, imageConfig, pullSecrets = nil, false, false })
+
+	auths, _ := json.Marshal(map[string]any{"auths": map[string]any{"https://" + host: map[string]string{"auth": base64.StdEncoding.EncodeToString([]byte("ci:s3cret"))}}})
+	secret := &corev1.Secret{ObjectMeta: metaV1.ObjectMeta{Name: "regcred", Namespace: "default"}, Type: corev1.SecretTypeDockerConfigJson, Data: map[string][]byte{corev1.DockerConfigJsonKey: auths}}
+	private, public := testPod("api-1", host+"/team/api:2.0", nil), testPod("api-2", host+"/team/api:2.0", nil)
+	private.Spec.ImagePullSecrets = []corev1.LocalObjectReference{{Name: "regcred"}}
+	cluster, k8s := startTestCluster(t, private, public, secret)
+	cluster.SetContainer("api-1", "app", debian)
+	cluster.SetContainer("api-2", "app", debian)
+	imageConfig, pullSecrets, registry = true, true, newRegistryClient(&http.Client{Timeout: 10 * time.Second})
+
+	containers, err := getContainers(k8s, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := scanContainers(k8s, containers); err != nil {
+		t.Fatal(err)
+	}
+	for pod, expected := range map[string]string{"api-1": "User: 1000", "api-2": "img000 What is the configuration of the image?............... skip"} {
+		reports, _ := filepath.Glob(filepath.Join(directory, pod+"*"))
+		if len(reports) != 1 {
+			t.Fatalf("expected a report of %s, got %v", pod, reports)
+		}
+		if content, _ := os.ReadFile(reports[0]); !strings.Contains(string(content), expected) {
+			t.Errorf("expected %q in the report of %s, got\n%s", expected, pod, content)
+		}
+	}
+
+	dockerConfigFile := filepath.Join(dir, "config.json")
+	os.WriteFile(dockerConfigFile, []byte(`{"auths": {"https://index.docker.io/v1/": {"username": "me", "password": "token"}}}`), 0600)
+	if err := loadDockerConfig(dockerConfigFile); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { dockerCredentials = nil }()
+	if auth, err := dockerCredentials.auth("registry-1.docker.io", "docker config"); err != nil || auth == nil || auth.username != "me" {
+		t.Errorf("expected the Docker Hub credential of the docker config, got %+v (%v)", auth, err)
+	}
+}
+
+func TestShardsScanDisjointPodsOfOneRun(t *testing.T) {
+	var pods []runtime.Object
+	for i := 0; i < 8; i++ {
+		pod := testPod(fmt.Sprintf("web-%d", i), "nginx:1.25", nil)
+		pod.UID = types.UID(fmt.Sprintf("5f1c0d2e-0000-4000-8000-00000000000%d", i))
+		pods = append(pods, pod)
+	}
+	cluster, k8s := startTestCluster(t, pods...)
+	for i := 0; i < 8; i++ {
+		cluster.SetContainer(fmt.Sprintf("web-%d", i), "app", debian)
+	}
+	runIDFlag = "0b7e4a8c-2d1f-4c3a-9e5b-6a7d8c9e0f12"
+	t.Cleanup(func() { shard, runIDFlag, shardIndex, shardCount = "", "", 0, 0 })
+
+	scanned := make(map[string]string)
+	for _, s := range []string{"1/2", "2/2"} {
+		shard = s
+		if err := validateSharding(); err != nil {
+			t.Fatal(err)
+		}
+		containers, err := getContainers(k8s, nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		startRun(k8s)
+		manifest, err := scanContainers(k8s, containers)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if manifest.RunID != runIDFlag || manifest.Shard != s {
+			t.Errorf("expected shard %s of run %s, got %s of %s", s, runIDFlag, manifest.Shard, manifest.RunID)
+		}
+		for _, entry := range manifest.Scanned {
+			if other, ok := scanned[entry.Pod]; ok {
+				t.Errorf("expected %s scanned once, got shards %s and %s", entry.Pod, other, s)
+			}
+			scanned[entry.Pod] = s
+		}
+	}
+	shards := make(map[string]bool)
+	for _, s := range scanned {
+		shards[s] = true
+	}
+	if len(scanned) != 8 || len(shards) != 2 {
+		t.Errorf("expected 8 pods split between 2 shards, got %v", scanned)
+	}
+
+	merged, err := findRunManifest(directory, runIDFlag[:8])
+	if err != nil || len(merged.Scanned) != 8 {
+		t.Errorf("expected manifests of shards merged, got %d containers (%v)", len(merged.Scanned), err)
+	}
+	if shard = "3/2"; validateSharding() == nil {
+		t.Errorf("expected an invalid shard rejected")
+	}
+}
+
+func TestContainersOfCriticalWorkloadsAreSkippedWithRespectPDBCritical(t *testing.T) {
+	limited := testPod("db-0", "postgres:16", nil)
+	limited.Labels = map[string]string{"app": "db"}
+	limited.Spec.Containers[0].Resources = corev1.ResourceRequirements{
+		Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("500m"), corev1.ResourceMemory: resource.MustParse("1Gi")},
+		Limits:   corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("500m"), corev1.ResourceMemory: resource.MustParse("1Gi")},
+	}
+	dns := testPod("dns-0", "coredns:1.11", nil)
+	dns.Spec.PriorityClassName = "system-cluster-critical"
+	zero := intstr.FromInt32(0)
+	pdb := &policyV1.PodDisruptionBudget{
+		ObjectMeta: metaV1.ObjectMeta{Name: "db", Namespace: "default"},
+		Spec:       policyV1.PodDisruptionBudgetSpec{MaxUnavailable: &zero, Selector: &metaV1.LabelSelector{MatchLabels: map[string]string{"app": "db"}}},
+	}
+	cluster, k8s := startTestCluster(t, limited, dns, testPod("web-1", "nginx:1.25", nil), pdb)
+	for _, pod := range []string{"db-0", "dns-0", "web-1"} {
+		cluster.SetContainer(pod, "app", debian)
+	}
+	t.Cleanup(func() { respectPDBCritical = false })
+
+	containers, err := getContainers(k8s, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kept := checkDisruption(k8s, containers); len(kept) != 3 {
+		t.Errorf("expected containers of critical workloads only warned about, got %d containers", len(kept))
+	}
+
+	respectPDBCritical = true
+	manifest, err := scanContainers(k8s, containers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(manifest.Scanned) != 1 || manifest.Scanned[0].Pod != "web-1" {
+		t.Errorf("expected only web-1 scanned, got %+v", manifest.Scanned)
+	}
+	reasons := make(map[string]string)
+	for _, entry := range manifest.Skipped {
+		reasons[entry.Pod] = entry.Reason
+	}
+	if reasons["db-0"] != "critical workload, PodDisruptionBudget db allows no disruptions, QoS Guaranteed, CPU limit 500m" {
+		t.Errorf("expected db-0 skipped for its PodDisruptionBudget, got %q", reasons["db-0"])
+	}
+	if reasons["dns-0"] != "critical workload, priority class system-cluster-critical, QoS BestEffort" {
+		t.Errorf("expected dns-0 skipped for its priority class, got %q", reasons["dns-0"])
+	}
+}
+
+func TestScansAreAbortedWhenUsageExceedsThresholds(t *testing.T) {
+	cluster, k8s := startTestCluster(t, testPod("hot-1", "nginx:1.25", nil), testPod("web-1", "nginx:1.25", nil), testPod("cold-1", "nginx:1.25", nil))
+	slow := debian
+	slow.LseDuration = 2 * time.Second
+	cluster.SetContainer("hot-1", "app", debian)
+	cluster.SetContainer("web-1", "app", slow)
+	cluster.SetContainer("cold-1", "app", debian)
+	cluster.SetUsage("hot-1", "app", "900m", "64Mi")
+	cluster.SetUsage("web-1", "app", "100m", "64Mi")
+	maxCPU, usageInterval, stallGrace = "500m", 20*time.Millisecond, 10*time.Millisecond
+	t.Cleanup(func() {
+		maxCPU, usageInterval, stallGrace, usageThresholds = "", 10*time.Second, 5*time.Second, nil
+		metricsUnavailable.Store(false)
+	})
+	if err := validateUsageThresholds(); err != nil {
+		t.Fatal(err)
+	}
+	time.AfterFunc(200*time.Millisecond, func() { cluster.SetUsage("web-1", "app", "1200m", "64Mi") })
+
+	containers, err := getContainers(k8s, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	started := time.Now()
+	manifest, err := scanContainers(k8s, containers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(started); elapsed > 1500*time.Millisecond {
+		t.Errorf("expected the scan of web-1 aborted, the run took %s", elapsed)
+	}
+	overloaded := make(map[string]string)
+	for _, entry := range manifest.Scanned {
+		overloaded[entry.Pod] = entry.Overloaded
+	}
+	if overloaded["hot-1"] != "CPU usage 900m exceeds '--max-cpu' 500m" {
+		t.Errorf("expected the scan of hot-1 not started, got %q", overloaded["hot-1"])
+	}
+	if overloaded["web-1"] != "CPU usage 1200m exceeds '--max-cpu' 500m" {
+		t.Errorf("expected the scan of web-1 aborted, got %q", overloaded["web-1"])
+	}
+	if _, ok := overloaded["cold-1"]; !ok || overloaded["cold-1"] != "" {
+		t.Errorf("expected cold-1 without metrics scanned, got %v", overloaded)
+	}
+
+	maxMemory = "lots"
+	defer func() { maxMemory = "" }()
+	if err := validateUsageThresholds(); err == nil {
+		t.Errorf("expected an invalid memory threshold rejected")
+	}
+}
+
+func TestRemoteScanThroughScanAPI(t *testing.T) {
+	cluster, k8s := startTestCluster(t, testPod("web-1", "nginx", nil))
+	cluster.SetContainer("web-1", "app", debian)
+
+	api := newScanAPI("secret")
+	server := httptest.NewServer(api.handler())
+	defer server.Close()
+	// the operator runs requested scans
+	go func() {
+		api.run(k8s, <-api.queue)
+	}()
+
+	operatorDirectory, remoteServer, remotePoll = t.TempDir(), server.URL, 10*time.Millisecond
+	t.Cleanup(func() { remoteServer = "" })
+	t.Setenv(apiTokenVariable, "wrong")
+	client, err := newRemoteClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.requestScan(ScanRequest{Namespace: "default"}); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Fatalf("expected a request with a wrong token to be rejected, got %v", err)
+	}
+
+	client.token = "secret"
+	if _, err := client.requestScan(ScanRequest{Namespace: "default", Format: "pdf"}); err == nil || !strings.Contains(err.Error(), "invalid format") {
+		t.Fatalf("expected an invalid format to be rejected, got %v", err)
+	}
+	scan, err := client.requestScan(ScanRequest{Namespace: "default"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if scan, err = client.waitForScan(scan); err != nil {
+		t.Fatal(err)
+	}
+	if scan.Phase != PhaseCompleted || scan.Scanned != 1 || scan.Findings[SeverityCritical] != 1 || scan.RunID == "" {
+		t.Errorf("unexpected scan %+v", scan)
+	}
+}
+
+func TestPlainReportsHaveNoEscapeSequences(t *testing.T) {
+	cluster, k8s := startTestCluster(t, testPod("web-1", "nginx", nil))
+	colored := debian
+	colored.LseOutput = []string{"\x1b[1;31m[!]\x1b[0m fst010 Can we write to /etc/passwd?..................... \x1b[1;31myes!\x1b[0m\r", "\x1b[?25l---\x07"}
+	cluster.SetContainer("web-1", "app", colored)
+
+	format = "plain"
+	containers, err := getContainers(k8s, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest, err := scanContainers(k8s, containers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := os.ReadFile(filepath.Join(directory, manifest.Scanned[0].Report))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(report), "[!] fst010 Can we write to /etc/passwd?..................... yes!\n---") {
+		t.Errorf("expected lse.sh output without escape sequences, got %q", report)
+	}
+	if strings.ContainsAny(string(report), "\x1b\r\x07") {
+		t.Errorf("expected no control characters, got %q", report)
+	}
+}
+
+func TestHTMLReportsAreCollapsibleAndCanBeLoaded(t *testing.T) {
+	cluster, k8s := startTestCluster(t, testPod("web-1", "nginx", nil))
+	cluster.SetContainer("web-1", "app", debian)
+
+	format = "html"
+	containers, err := getContainers(k8s, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest, err := scanContainers(k8s, containers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(directory, manifest.Scanned[0].Report)
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(content), `<summary data-label="file system (1 critical, 0 interesting)">`) ||
+		!strings.Contains(string(content), `data-severity="critical" data-positive="true"`) {
+		t.Errorf("expected collapsible sections with severity filters, got %s", content)
+	}
+
+	report, err := loadReport(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Pod() != "web-1" || len(report.Positive()) != 2 {
+		t.Errorf("expected the report of web-1 with 2 positive findings, got %+v", report)
+	}
+	for _, line := range report.Lines {
+		if strings.Contains(line, "function") || strings.Contains(line, "{") {
+			t.Errorf("expected no scripts or styles in report lines, got %q", line)
+		}
+	}
+}
+
+func TestJSONReportsCanBeLoaded(t *testing.T) {
+	cluster, k8s := startTestCluster(t, testPod("web-1", "nginx:1.25", nil))
+	cluster.SetContainer("web-1", "app", debian)
+	format = "json"
+
+	containers, err := getContainers(k8s, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest, err := scanContainers(k8s, containers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(directory, manifest.Scanned[0].Report)); err != nil {
+		t.Fatal(err)
+	}
+
+	runs, err := loadReports(directory)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reports := runs["."]; len(reports) != 1 || reports[0].Pod() != "web-1" || len(reports[0].Positive()) != 2 {
+		t.Errorf("unexpected json reports %+v", reports)
+	}
+}
+
+func TestEvaluatePSS(t *testing.T) {
+	yes, no, root := true, false, int64(0)
+	privileged := testPod("privileged", "nginx", nil)
+	privileged.Spec.HostNetwork = true
+	privileged.Spec.Containers[0].SecurityContext = &corev1.SecurityContext{Privileged: &yes}
+
+	restricted := testPod("restricted", "nginx", nil)
+	restricted.Spec.SecurityContext = &corev1.PodSecurityContext{
+		RunAsNonRoot:   &yes,
+		SeccompProfile: &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeRuntimeDefault},
+	}
+	restricted.Spec.Containers[0].SecurityContext = &corev1.SecurityContext{
+		AllowPrivilegeEscalation: &no,
+		Capabilities:             &corev1.Capabilities{Drop: []corev1.Capability{"ALL"}},
+	}
+
+	rootUser := restricted.DeepCopy()
+	rootUser.Spec.Containers[0].SecurityContext.RunAsUser = &root
+
+	for _, tc := range []struct {
+		pod   *corev1.Pod
+		level string
+	}{
+		{privileged, PSSPrivileged},
+		{testPod("default", "nginx", nil), PSSBaseline},
+		{restricted, PSSRestricted},
+		{rootUser, PSSBaseline},
+	} {
+		if result := evaluatePSS(tc.pod); result.Level != tc.level {
+			t.Errorf("pod %s: expected %s, got %s with violations %+v", tc.pod.Name, tc.level, result.Level, result.Violations)
+		}
+	}
+}
+
+func TestJSONReportsKeepStderr(t *testing.T) {
+	result := Result{container: ContainerInfo{container: Container{Pod: "web-1", Container: "app"}}, scanReport: lseOutput, stderr: []string{"sh: sudo: not found"}}
+
+	saveStderr = true
+	defer func() { saveStderr = false }()
+	data, err := jsonReport(result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var report JSONReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Stderr) != 1 || report.Stderr[0] != "sh: sudo: not found" {
+		t.Errorf("expected stderr in the json report, got %v", report.Stderr)
+	}
+}
+
+func TestRecordingsRedactCredentials(t *testing.T) {
+	secret := RecordedResponse{Method: "GET", URI: "/api/v1/namespaces/default/secrets/pull", StatusCode: 200,
+		Body: `{"kind":"Secret","metadata":{"name":"pull"},"type":"kubernetes.io/dockerconfigjson","data":{".dockerconfigjson":"eyJhdXRocyI6e319"}}`}
+	if recorded := redactSecrets(secret); !strings.Contains(recorded.Body, `".dockerconfigjson":"REDACTED"`) || strings.Contains(recorded.Body, "eyJhdXRocyI6e319") {
+		t.Errorf("expected the secret redacted, got %s", recorded.Body)
+	}
+	secret.ContentType, secret.Body = "application/vnd.kubernetes.protobuf", "k8s\x00binary"
+	if recorded := redactSecrets(secret); recorded.StatusCode != http.StatusNotFound {
+		t.Errorf("expected a secret, which cannot be redacted, recorded as not found, got %d", recorded.StatusCode)
+	}
+
+	lines := redactTokens([]string{"Authorization: Bearer abc.def", "token eyJhbGciOiJSUzI1NiJ9.eyJzdWIiOiJ4In0.c2ln end"})
+	if lines[0] != "Authorization: Bearer REDACTED" || lines[1] != "token REDACTED end" {
+		t.Errorf("expected tokens redacted, got %q", lines)
+	}
+}
+
+func TestUnauthorizedIsDetectedFromStatusErrors(t *testing.T) {
+	if unauthorized(errors.New(`pods "api-401" not found: 401`)) {
+		t.Error("expected an exec error mentioning 401 not to be taken as unauthorized")
+	}
+
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		requests = append(requests, req.Method+" "+req.URL.Path)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		io.WriteString(w, `{"kind":"Status","apiVersion":"v1","status":"Failure","message":"Unauthorized","reason":"Unauthorized","code":401}`)
+	}))
+	defer server.Close()
+	config := &rest.Config{Host: server.URL}
+	expired, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	execStatus, err := streamExec(context.Background(), &k8sexec.K8SExec{Config: config, Clientset: expired, Namespace: "default"}, "api-401", "app", []string{"id"}, nil, nil)
+	if execStatus.RetCode != k8sexec.InternalAppError || !unauthorized(err) {
+		t.Errorf("expected an exec with expired credentials to be taken as unauthorized, got %v", err)
+	}
+	if strings.Join(requests, ",") != "POST /api/v1/namespaces/default/pods/api-401/exec" {
+		t.Errorf("expected only the exec sent to the API server, got %v", requests)
+	}
+}
+
+func TestFetchedFilesAreByteIdentical(t *testing.T) {
+	content := []byte("line\r\nbinary \x00\xff\n\n")
+	encoded := base64.StdEncoding.EncodeToString(content)
+	for _, lines := range [][]string{
+		{"base64", encoded[:12], encoded[12:]},
+		{"hex", " 6c 69 6e 65 0d 0a 62 69 6e 61 72 79", " 20 00 ff 0a 0a"},
+	} {
+		decoded, err := decodeFetched(lines)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(decoded) != string(content) {
+			t.Errorf("expected %q decoded from %s, got %q", content, lines[0], decoded)
+		}
+	}
+	if _, err := decodeFetched([]string{"cat"}); err == nil {
+		t.Error("expected an unknown encoding to be rejected")
+	}
+}
+
+func TestLseScanStatusIsWrittenToTheLatestVersion(t *testing.T) {
+	lseScan := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "kubelse.io/v1alpha1",
+		"kind":       "LseScan",
+		"metadata":   map[string]interface{}{"name": "nightly", "namespace": "default", "generation": int64(1)},
+		"spec":       map[string]interface{}{"selector": "app=web"},
+	}}
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{lseScanResource: "LseScanList"}, lseScan.DeepCopy())
+	resource := client.Resource(lseScanResource).Namespace("default")
+
+	// the resource is labelled while it is being scanned
+	changed, err := resource.Get(context.TODO(), "nightly", metaV1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	changed.SetLabels(map[string]string{"team": "security"})
+	if _, err := resource.Update(context.TODO(), changed, metaV1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := updateLseScanStatus(client, lseScan, LseScanSpec{}, Manifest{}, nil); err != nil {
+		t.Fatal(err)
+	}
+	updated, err := resource.Get(context.TODO(), "nightly", metaV1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if phase, _, _ := unstructured.NestedString(updated.Object, "status", "phase"); phase != "Completed" || updated.GetLabels()["team"] != "security" {
+		t.Errorf("expected the status written to the latest version, got %v", updated.Object)
+	}
+}
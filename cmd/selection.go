@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/hhruszka/k8sexec"
+	"github.com/spf13/cobra"
+	"k8s.io/client-go/util/homedir"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// defaultKubeconfig returns the kubeconfig file in the user's home directory, if there is one.
+func defaultKubeconfig() string {
+	if home := homedir.HomeDir(); home != "" {
+		return filepath.Join(home, ".kube", "config")
+	}
+	return ""
+}
+
+// selectionOptions are values of options selecting containers of a subcommand working on containers the same way
+// as scans do, e.g. exec. Every subcommand has variables of its own, so that registering its options does not
+// change defaults of the root command's options, and they are copied to the variables read by discovery once
+// the subcommand runs or its options are completed.
+type selectionOptions struct {
+	kubeconfig  string
+	namespace   string
+	pods        string
+	containers  string
+	selector    string
+	helmRelease string
+	images      string
+	directory   string
+	quiet       bool
+	pace        time.Duration
+}
+
+// commandSelections are selection options of subcommands
+var commandSelections = make(map[*cobra.Command]*selectionOptions)
+
+// apply copies selection options to the variables read by discovery and scans.
+func (o *selectionOptions) apply() {
+	kubeconfig, namespace, podscli, containerscli = o.kubeconfig, o.namespace, o.pods, o.containers
+	labelSelector, helmRelease, images, directory = o.selector, o.helmRelease, o.images, o.directory
+	quiet, pace = o.quiet, o.pace
+}
+
+// applySelection copies selection options of a subcommand, if it has any, to the variables read by discovery.
+func applySelection(command *cobra.Command) {
+	if options, ok := commandSelections[command]; ok {
+		options.apply()
+	}
+}
+
+// addSelectionFlags adds options selecting containers to a subcommand. Audit and auth options are shared with the
+// root command, they have the same defaults everywhere.
+func addSelectionFlags(command *cobra.Command) {
+	workingDirectory, err := os.Getwd()
+	if err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+	options := &selectionOptions{}
+	commandSelections[command] = options
+	flags := command.Flags()
+	flags.StringVarP(&options.kubeconfig, "kubeconfig", "k", defaultKubeconfig(), "(optional) absolute path to the kubeconfig file")
+	flags.StringVarP(&options.namespace, "namespace", "n", "default", "a namespace")
+	flags.StringVarP(&options.pods, "pods", "p", "", "a pod or comma-separated pods, if not provided then all containers in a namespace are selected")
+	flags.StringVarP(&options.containers, "containers", "c", "", "a container or comma-separated containers of a single pod")
+	flags.StringVar(&options.selector, "selector", "", "a label selector of pods, e.g. app=nginx")
+	flags.StringVar(&options.helmRelease, "helm-release", "", "a Helm release, which pods are selected, e.g. myapp")
+	flags.StringVar(&options.images, "images", "", "comma-separated image patterns, e.g. nginx:1.25,registry/internal/*")
+	flags.StringVarP(&options.directory, "directory", "d", workingDirectory, "a directory where results should be saved to")
+	flags.BoolVarP(&options.quiet, "quiet", "q", false, "quiet execution - no status information")
+	flags.DurationVar(&options.pace, "pace", 0, "a minimum delay between successive exec starts of every worker, e.g. 500ms")
+	addAuditFlags(flags)
+	addAuthFlags(flags)
+}
+
+// selectContainers connects to the cluster and returns containers selected with the selection options of a
+// subcommand.
+func selectContainers(command *cobra.Command) (*k8sexec.K8SExec, []Container, error) {
+	applySelection(command)
+	if err := compileImagePatterns(images); err != nil {
+		return nil, nil, fmt.Errorf("Invalid value of the images option '--images': %s", err.Error())
+	}
+	if helmRelease != "" && (podscli != "" || labelSelector != "") {
+		return nil, nil, errors.New("The Helm release option '--helm-release' cannot be used together with the options '--pods' and '--selector'")
+	}
+	if err := validateImpersonation(); err != nil {
+		return nil, nil, err
+	}
+	k8s, err := newClient(kubeconfig, namespace)
+	if err != nil {
+		return nil, nil, clientError(err)
+	}
+	if err := validateNamespaces(k8s, []string{namespace}); err != nil {
+		return nil, nil, err
+	}
+	containers, err := discoverStage(context.TODO(), k8s, untangleOption(podscli), untangleOption(containerscli))
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(containers) == 0 {
+		return nil, nil, fmt.Errorf("[-] No pods/containers found in namespace %q\n", namespace)
+	}
+	return k8s, containers, nil
+}
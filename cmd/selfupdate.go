@@ -0,0 +1,313 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/spf13/cobra"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	runtimedebug "runtime/debug"
+	"strings"
+	"time"
+)
+
+// AppCommit and AppBuildDate are set by main from its commit and date variables, which are injected at build
+// time like its version, see main.go.
+var (
+	AppCommit    string
+	AppBuildDate string
+)
+
+// ReleaseKey is a base64 encoded PEM public key of the cosign key signing releases, it is set by main from its
+// releaseKey variable injected at build time by the release pipeline, see .goreleaser.yaml.
+var ReleaseKey string
+
+// self-update CLI options variables
+var (
+	selfUpdateVersion string
+	selfUpdateRepo    string
+	selfUpdateKey     string
+	selfUpdateNoSig   bool
+	selfUpdateCheck   bool
+	selfUpdateForce   bool
+)
+
+// githubAPI is the GitHub API kubelse releases are looked up with
+var githubAPI = "https://api.github.com"
+
+// release assets, besides binaries, published by the release pipeline, see .goreleaser.yaml
+const (
+	checksumsAsset = "checksums.txt"
+	signatureAsset = "checksums.txt.sig"
+)
+
+// buildInfo describes the build of kubelse: its version, commit and build date injected at build time or,
+// if not injected, taken from version control information recorded by go build.
+func buildInfo() string {
+	commit, date, modified := AppCommit, AppBuildDate, false
+	if info, ok := runtimedebug.ReadBuildInfo(); ok {
+		for _, setting := range info.Settings {
+			switch {
+			case setting.Key == "vcs.revision" && commit == "":
+				commit = setting.Value
+			case setting.Key == "vcs.time" && date == "":
+				date = setting.Value
+			case setting.Key == "vcs.modified":
+				modified = setting.Value == "true" && AppCommit == ""
+			}
+		}
+	}
+	if len(commit) > 12 {
+		commit = commit[:12]
+	}
+	if modified {
+		commit += "-dirty"
+	}
+	return fmt.Sprintf("%s (commit %s, built %s, %s %s/%s)", valueOrDefault(AppVersion, "dev"), valueOrDefault(commit, "unknown"), valueOrDefault(date, "unknown"), runtime.Version(), runtime.GOOS, runtime.GOARCH)
+}
+
+// GitHubRelease is a release of kubelse as returned by the GitHub API.
+type GitHubRelease struct {
+	TagName string `json:"tag_name"`
+	Assets  []struct {
+		Name string `json:"name"`
+		URL  string `json:"browser_download_url"`
+	} `json:"assets"`
+}
+
+// assetURL returns a download URL of an asset of the release.
+func (r GitHubRelease) assetURL(name string) (string, error) {
+	for _, asset := range r.Assets {
+		if asset.Name == name {
+			return asset.URL, nil
+		}
+	}
+	return "", fmt.Errorf("release %s has no asset %s", r.TagName, name)
+}
+
+// releaseAsset returns a name of the release binary for an operating system and architecture, e.g.
+// kubelse-macos-arm64 or kubelse-windows-amd64.exe.
+func releaseAsset(goos string, goarch string) string {
+	name := fmt.Sprintf("kubelse-%s-%s", strings.Replace(goos, "darwin", "macos", 1), goarch)
+	if goos == "windows" {
+		name += ".exe"
+	}
+	return name
+}
+
+// download returns content of a URL, at most limit bytes of it.
+func download(url string, limit int64) ([]byte, error) {
+	client := &http.Client{Timeout: 5 * time.Minute}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned %s", url, resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, limit))
+}
+
+// getRelease looks up a release of a repository, the latest one if no version is given.
+func getRelease(repo string, version string) (GitHubRelease, error) {
+	url := fmt.Sprintf("%s/repos/%s/releases/latest", githubAPI, repo)
+	if version != "" {
+		url = fmt.Sprintf("%s/repos/%s/releases/tags/%s", githubAPI, repo, version)
+	}
+	content, err := download(url, 10<<20)
+	if err != nil {
+		return GitHubRelease{}, err
+	}
+	var release GitHubRelease
+	if err := json.Unmarshal(content, &release); err != nil {
+		return GitHubRelease{}, fmt.Errorf("invalid release %s: %s", url, err.Error())
+	}
+	return release, nil
+}
+
+// releaseChecksum returns a sha256 digest of an asset listed in checksums of a release, in the sha256sum format.
+func releaseChecksum(checksums []byte, asset string) (string, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(checksums))
+	for scanner.Scan() {
+		if fields := strings.Fields(scanner.Text()); len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == asset {
+			return fields[0], nil
+		}
+	}
+	return "", fmt.Errorf("no checksum of %s found", asset)
+}
+
+// replaceExecutable replaces an executable with a new binary. The binary is written next to the executable and
+// renamed over it, so that the executable is never partially written; the old executable is moved aside first,
+// since a running executable cannot be overwritten on Windows.
+func replaceExecutable(executable string, binary []byte) error {
+	info, err := os.Stat(executable)
+	if err != nil {
+		return err
+	}
+	temp, err := os.CreateTemp(filepath.Dir(executable), "."+filepath.Base(executable)+".new-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+	if _, err := temp.Write(binary); err != nil {
+		temp.Close()
+		return err
+	}
+	if err := temp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(temp.Name(), info.Mode().Perm()|0111); err != nil {
+		return err
+	}
+
+	old := executable + ".old"
+	if err := os.Rename(executable, old); err != nil {
+		return err
+	}
+	if err := os.Rename(temp.Name(), executable); err != nil {
+		// the old executable is put back, so that kubelse is not lost
+		os.Rename(old, executable)
+		return err
+	}
+	// on Windows the old executable cannot be removed while it runs, it is removed by the next update
+	os.Remove(old)
+	return nil
+}
+
+// releasePublicKey returns a PEM public key verifying signatures of releases and its name: the key given with
+// '--key' or the key shipped with the binary.
+func releasePublicKey() ([]byte, string, error) {
+	if selfUpdateKey != "" {
+		key, err := os.ReadFile(selfUpdateKey)
+		return key, selfUpdateKey, err
+	}
+	if ReleaseKey == "" {
+		return nil, "", errors.New("no release key is shipped with this build, a public key has to be given with '--key'")
+	}
+	key, err := base64.StdEncoding.DecodeString(ReleaseKey)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid release key shipped with this build: %s", err.Error())
+	}
+	return key, "the release key", nil
+}
+
+// selfUpdate replaces an executable with the release binary for the platform kubelse runs on, once it is verified
+// against checksums of the release and the checksums against their signature. The signature is verified unless
+// explicitly skipped with '--insecure-skip-signature'.
+func selfUpdate(executable string) error {
+	release, err := getRelease(selfUpdateRepo, selfUpdateVersion)
+	if err != nil {
+		return fmt.Errorf("[-] Error looking up the release: %s\n", err.Error())
+	}
+	current := strings.TrimPrefix(AppVersion, "v")
+	latest := strings.TrimPrefix(release.TagName, "v")
+	if selfUpdateCheck {
+		if current == latest {
+			log(fmt.Sprintf("[+] %s %s is up to date\n", appName, AppVersion))
+		} else {
+			fmt.Printf("%s %s is available, %s runs %s\n", appName, release.TagName, executable, valueOrDefault(AppVersion, "dev"))
+		}
+		return nil
+	}
+	if current == latest && !selfUpdateForce {
+		log(fmt.Sprintf("[+] %s %s is up to date\n", appName, AppVersion))
+		return nil
+	}
+
+	asset := releaseAsset(runtime.GOOS, runtime.GOARCH)
+	checksumsURL, err := release.assetURL(checksumsAsset)
+	if err != nil {
+		return fmt.Errorf("[-] Error verifying %s: %s\n", release.TagName, err.Error())
+	}
+	checksums, err := download(checksumsURL, 1<<20)
+	if err != nil {
+		return fmt.Errorf("[-] Error downloading checksums of %s: %s\n", release.TagName, err.Error())
+	}
+	if selfUpdateNoSig {
+		log(fmt.Sprintf("[-] Signature verification skipped with '--insecure-skip-signature', checksums of %s are not verified\n", release.TagName))
+	} else {
+		key, keyName, err := releasePublicKey()
+		if err != nil {
+			return fmt.Errorf("[-] Error verifying %s: %s\n", release.TagName, err.Error())
+		}
+		signatureURL, err := release.assetURL(signatureAsset)
+		if err != nil {
+			return fmt.Errorf("[-] Error verifying %s: %s\n", release.TagName, err.Error())
+		}
+		signature, err := download(signatureURL, 1<<20)
+		if err != nil {
+			return fmt.Errorf("[-] Error downloading the signature of %s: %s\n", release.TagName, err.Error())
+		}
+		if err := verifySignatureWithKey(checksums, signature, key, keyName); err != nil {
+			return fmt.Errorf("[-] Checksums of %s failed verification: %s\n", release.TagName, err.Error())
+		}
+		log(fmt.Sprintf("[+] Checksums of %s signature verified with %s\n", release.TagName, keyName))
+	}
+	digest, err := releaseChecksum(checksums, asset)
+	if err != nil {
+		return fmt.Errorf("[-] Error verifying %s: %s\n", release.TagName, err.Error())
+	}
+
+	binaryURL, err := release.assetURL(asset)
+	if err != nil {
+		return fmt.Errorf("[-] Error downloading %s: %s\n", release.TagName, err.Error())
+	}
+	log(fmt.Sprintf("[+] Downloading %s\n", binaryURL))
+	binary, err := download(binaryURL, 500<<20)
+	if err != nil {
+		return fmt.Errorf("[-] Error downloading %s: %s\n", release.TagName, err.Error())
+	}
+	if err := verifyDigest(binary, digest); err != nil {
+		return fmt.Errorf("[-] %s failed verification: %s\n", asset, err.Error())
+	}
+	if err := replaceExecutable(executable, binary); err != nil {
+		return fmt.Errorf("[-] Error replacing %s: %s\n", executable, err.Error())
+	}
+	log(fmt.Sprintf("[+] Updated %s from %s to %s\n", executable, valueOrDefault(AppVersion, "dev"), release.TagName))
+	return nil
+}
+
+var selfUpdateCmd = &cobra.Command{
+	Use:   "self-update [--version <release>] [--key <cosign.pub>]",
+	Short: "Replace kubelse with a release from GitHub",
+	Long: `
+Downloads a release of kubelse, the latest one by default, for the platform it runs on, verifies the binary
+against checksums of the release and the checksums against their cosign signature, with the release key shipped
+with kubelse or a key given with '--key', and replaces the running executable with it.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := requireOnline("kubelse self-update"); err != nil {
+			return err
+		}
+		executable, err := os.Executable()
+		if err != nil {
+			return err
+		}
+		if executable, err = filepath.EvalSymlinks(executable); err != nil {
+			return err
+		}
+		if selfUpdateCheck && selfUpdateForce {
+			return errors.New("The options '--check' and '--force' cannot be used together")
+		}
+		return selfUpdate(executable)
+	},
+}
+
+func init() {
+	selfUpdateCmd.Flags().StringVar(&selfUpdateVersion, "version", "", "a release to install, e.g. v1.2.0, if not provided then the latest release is installed")
+	selfUpdateCmd.Flags().StringVar(&selfUpdateRepo, "repo", "hhruszka/kubelse", "a GitHub repository releases are downloaded from, e.g. an internal fork")
+	selfUpdateCmd.Flags().StringVar(&selfUpdateKey, "key", "", "a PEM public key, e.g. cosign.pub, verifying the signature of checksums of the release instead of the release key shipped with kubelse")
+	selfUpdateCmd.Flags().BoolVar(&selfUpdateNoSig, "insecure-skip-signature", false, "install the release without verifying the signature of its checksums")
+	selfUpdateCmd.Flags().BoolVar(&selfUpdateCheck, "check", false, "only check if another release is available")
+	selfUpdateCmd.Flags().BoolVar(&selfUpdateForce, "force", false, "install the release even if it is the running version")
+
+	cmd.AddCommand(selfUpdateCmd)
+}
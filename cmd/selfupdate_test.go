@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestSelfUpdateVerifiesAndReplacesExecutable(t *testing.T) {
+	dir := t.TempDir()
+	binary := []byte("new kubelse")
+	asset := releaseAsset(runtime.GOOS, runtime.GOARCH)
+	sum := sha256.Sum256(binary)
+	checksums := []byte(fmt.Sprintf("%s  %s\n", hex.EncodeToString(sum[:]), asset))
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checksumsSum := sha256.Sum256(checksums)
+	signature, err := ecdsa.SignASN1(rand.Reader, key, checksumsSum[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicKey, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyFile := filepath.Join(dir, "cosign.pub")
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicKey}), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	served := map[string][]byte{"/" + asset: binary, "/checksums.txt": checksums, "/checksums.txt.sig": []byte(base64.StdEncoding.EncodeToString(signature))}
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/repos/hhruszka/kubelse/releases/latest" {
+			var release GitHubRelease
+			for name := range served {
+				release.Assets = append(release.Assets, struct {
+					Name string `json:"name"`
+					URL  string `json:"browser_download_url"`
+				}{name[1:], server.URL + name})
+			}
+			release.TagName = "v2.0.0"
+			json.NewEncoder(w).Encode(release)
+			return
+		}
+		w.Write(served[r.URL.Path])
+	}))
+	defer server.Close()
+	githubAPI, selfUpdateRepo, selfUpdateKey, AppVersion = server.URL, "hhruszka/kubelse", keyFile, "v1.0.0"
+	t.Cleanup(func() { githubAPI, selfUpdateKey, AppVersion = "https://api.github.com", "", "" })
+
+	executable := filepath.Join(dir, "kubelse")
+	if err := os.WriteFile(executable, []byte("old kubelse"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	// a tampered binary is not installed
+	served["/"+asset] = []byte("evil kubelse")
+	if err := selfUpdate(executable); err == nil {
+		t.Error("expected a tampered binary to fail verification")
+	}
+	served["/"+asset] = binary
+	if err := selfUpdate(executable); err != nil {
+		t.Fatal(err)
+	}
+	if content, _ := os.ReadFile(executable); string(content) != string(binary) {
+		t.Errorf("expected the executable to be replaced, got %q", content)
+	}
+	if info, _ := os.Stat(executable); runtime.GOOS != "windows" && info.Mode().Perm()&0100 == 0 {
+		t.Errorf("expected the executable to stay executable, got %s", info.Mode())
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 2 {
+		t.Errorf("expected no leftovers of the update, got %v", entries)
+	}
+
+	// without a key the signature is required unless explicitly skipped
+	selfUpdateKey = ""
+	if err := selfUpdate(executable); err == nil {
+		t.Error("expected an update without a release key to be refused")
+	}
+	ReleaseKey = base64.StdEncoding.EncodeToString(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicKey}))
+	t.Cleanup(func() { ReleaseKey = "" })
+	if err := selfUpdate(executable); err != nil {
+		t.Errorf("expected the release key shipped with the binary to verify the signature, got %v", err)
+	}
+	served["/checksums.txt.sig"] = []byte(base64.StdEncoding.EncodeToString([]byte("forged")))
+	if err := selfUpdate(executable); err == nil {
+		t.Error("expected a forged signature to fail verification")
+	}
+	selfUpdateNoSig = true
+	t.Cleanup(func() { selfUpdateNoSig = false })
+	if err := selfUpdate(executable); err != nil {
+		t.Errorf("expected the signature skipped with --insecure-skip-signature, got %v", err)
+	}
+}
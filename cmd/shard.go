@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/google/uuid"
+	"hash/fnv"
+	corev1 "k8s.io/api/core/v1"
+	"strconv"
+	"strings"
+)
+
+// sharding CLI options variables
+var (
+	shard     string
+	runIDFlag string
+)
+
+// shardIndex is the shard of pods scanned by this instance, from 1 to shardCount, shardCount is 0 if scans are
+// not sharded
+var shardIndex, shardCount int
+
+// parseShard parses a shard "<index>/<count>", e.g. 2/5 for the second of five shards.
+func parseShard(value string) (int, int, error) {
+	index, count, ok := strings.Cut(value, "/")
+	i, errIndex := strconv.Atoi(index)
+	n, errCount := strconv.Atoi(count)
+	if !ok || errIndex != nil || errCount != nil {
+		return 0, 0, fmt.Errorf("expected <index>/<count>, e.g. 2/5, got %q", value)
+	}
+	if n < 1 || i < 1 || i > n {
+		return 0, 0, fmt.Errorf("the index of %q has to be between 1 and the number of shards", value)
+	}
+	return i, n, nil
+}
+
+// validateSharding parses '--shard' and checks '--run-id', which shards of a run share, so that their results are
+// merged by the run ID.
+func validateSharding() error {
+	shardIndex, shardCount = 0, 0
+	if shard != "" {
+		var err error
+		if shardIndex, shardCount, err = parseShard(shard); err != nil {
+			return fmt.Errorf("Invalid value of the shard option '--shard': %s", err.Error())
+		}
+	}
+	if runIDFlag != "" {
+		if _, err := uuid.Parse(runIDFlag); err != nil {
+			return fmt.Errorf("Invalid value of the run ID option '--run-id': %s", err.Error())
+		}
+		if watch {
+			return fmt.Errorf("The run ID option '--run-id' cannot be used together with '--watch', which starts a run for every batch of new pods")
+		}
+	}
+	return nil
+}
+
+// inShard tells if a pod is scanned by this instance: pods are split between shards by a hash of their UID, so that
+// instances scanning the same pods never overlap.
+func inShard(pod corev1.Pod) bool {
+	if shardCount == 0 {
+		return true
+	}
+	key := string(pod.UID)
+	if key == "" {
+		key = pod.Namespace + "/" + pod.Name
+	}
+	hash := fnv.New32a()
+	hash.Write([]byte(key))
+	return int(hash.Sum32()%uint32(shardCount)) == shardIndex-1
+}
+
+// shardSuffix is put after the run ID in file names, so that files of shards of a run saved in the same directory
+// do not collide.
+func shardSuffix() string {
+	if shardCount == 0 {
+		return ""
+	}
+	return fmt.Sprintf("-%dof%d", shardIndex, shardCount)
+}
@@ -0,0 +1,172 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/hhruszka/k8sexec"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+	coreV1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/remotecommand"
+	"os"
+	"time"
+)
+
+// shell CLI options variables
+var shellOverride string
+
+// reportedShell returns a shell, which was used to scan a container, from the latest of its reports in a
+// directory, so that it does not have to be discovered again.
+func reportedShell(dir string, ns string, pod string, container string) string {
+	runs, err := loadReports(dir)
+	if err != nil {
+		return ""
+	}
+	var (
+		shell  string
+		latest time.Time
+	)
+	for _, reports := range runs {
+		for _, report := range reports {
+			if report.Pod() != pod || report.Container() != container || report.Header["Shell"] == "" {
+				continue
+			}
+			if reportNamespace := report.Header["Namespace"]; reportNamespace != "" && reportNamespace != ns {
+				continue
+			}
+			info, err := os.Stat(report.Path)
+			if err != nil || info.ModTime().Before(latest) {
+				continue
+			}
+			shell, latest = report.Header["Shell"], info.ModTime()
+		}
+	}
+	return shell
+}
+
+// interactiveShell returns a shell to be opened in a container: the requested one, the one used by the latest
+// scan of the container or the first working one, bash being preferred for its line editing.
+func interactiveShell(k8s *k8sexec.K8SExec, container Container) (string, error) {
+	if shellOverride != "" {
+		return shellOverride, nil
+	}
+	if shell := reportedShell(directory, namespace, container.Pod, container.Container); shell != "" {
+		log(fmt.Sprintf("[+] Using %s, the shell of the latest scan of %s\n", shell, container.String()))
+		return shell, nil
+	}
+	if shell, err := checkShellInContainer(context.Background(), k8s, container, "bash"); err == nil {
+		return shell, nil
+	}
+	return getShellInContainer(context.Background(), k8s, container)
+}
+
+// terminalSizes reports size changes of the local terminal to the container's terminal. Sizes are polled, since
+// resize signals are not available on every platform.
+type terminalSizes struct {
+	fd   int
+	last remotecommand.TerminalSize
+}
+
+// Next returns the next size of the terminal, it blocks until the size changes.
+func (s *terminalSizes) Next() *remotecommand.TerminalSize {
+	for {
+		if width, height, err := term.GetSize(s.fd); err == nil {
+			size := remotecommand.TerminalSize{Width: uint16(width), Height: uint16(height)}
+			if size != s.last {
+				s.last = size
+				return &size
+			}
+		}
+		time.Sleep(250 * time.Millisecond)
+	}
+}
+
+// openShell opens an interactive session with a shell in a container, the local terminal is switched to the raw
+// mode for the duration of the session.
+func openShell(k8s *k8sexec.K8SExec, container Container, shell string) error {
+	fd := int(os.Stdin.Fd())
+	tty := term.IsTerminal(fd)
+
+	request := clientsetOf(k8s).CoreV1().RESTClient().Post().
+		Resource("pods").Namespace(k8s.Namespace).Name(container.Pod).SubResource("exec").
+		VersionedParams(&coreV1.PodExecOptions{
+			Container: container.Container,
+			Command:   []string{shell},
+			Stdin:     true,
+			Stdout:    true,
+			Stderr:    !tty,
+			TTY:       tty,
+		}, scheme.ParameterCodec)
+	executor, err := remotecommand.NewSPDYExecutor(k8s.Config, "POST", request.URL())
+	if err != nil {
+		return err
+	}
+
+	options := remotecommand.StreamOptions{Stdin: os.Stdin, Stdout: os.Stdout, Stderr: os.Stderr, Tty: tty}
+	if tty {
+		state, err := term.MakeRaw(fd)
+		if err != nil {
+			return err
+		}
+		defer term.Restore(fd, state)
+		options.Stderr = nil
+		options.TerminalSizeQueue = &terminalSizes{fd: fd}
+	}
+	return executor.StreamWithContext(context.Background(), options)
+}
+
+var shellCmd = &cobra.Command{
+	Use:   "shell <pod> [container]",
+	Short: "Open an interactive shell in a container",
+	Long: `
+Opens an interactive shell in a container for a manual follow-up on findings. The shell used by the latest
+scan of the container found in the reports directory is reused, otherwise a working shell is looked for in the
+container. The container can be omitted for pods with a single container.`,
+	Args:              cobra.RangeArgs(1, 2),
+	ValidArgsFunction: completePodAndContainer,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		applySelection(cmd)
+		k8s, err := newClient(kubeconfig, namespace)
+		if err != nil {
+			return clientError(err)
+		}
+		pod, err := clientsetOf(k8s).CoreV1().Pods(namespace).Get(context.TODO(), args[0], metaV1.GetOptions{})
+		if err != nil {
+			return fmt.Errorf("[-] Error getting pod %s: %s\n", args[0], err.Error())
+		}
+
+		container := Container{Pod: pod.Name}
+		switch {
+		case len(args) == 2:
+			container.Container = args[1]
+		case len(pod.Spec.Containers) == 1:
+			container.Container = pod.Spec.Containers[0].Name
+		default:
+			return fmt.Errorf("[-] Pod %s has %d containers, a container has to be provided\n", pod.Name, len(pod.Spec.Containers))
+		}
+
+		shell, err := interactiveShell(k8s, container)
+		if err != nil || shell == "" {
+			return errors.New("[-] No shell found in the container")
+		}
+		log(fmt.Sprintf("[+] Opening %s in %s/%s\n", shell, namespace, container.String()))
+		return openShell(k8s, container, shell)
+	},
+}
+
+func init() {
+	// the shell has selection options of its own, so that their defaults do not change the root command's ones
+	shellSelection := &selectionOptions{}
+	commandSelections[shellCmd] = shellSelection
+	shellCmd.Flags().StringVarP(&shellSelection.kubeconfig, "kubeconfig", "k", defaultKubeconfig(), "(optional) absolute path to the kubeconfig file")
+	shellCmd.Flags().StringVarP(&shellSelection.namespace, "namespace", "n", "default", "a namespace")
+	shellCmd.Flags().StringVarP(&shellSelection.directory, "directory", "d", ".", "a directory with reports, the shell used by the latest scan of the container is reused")
+	addAuthFlags(shellCmd.Flags())
+	shellCmd.Flags().StringVar(&shellOverride, "shell", "", "a shell to be opened, e.g. /bin/ash, if not provided then the shell is discovered")
+
+	registerFlagCompletions(shellCmd)
+	cmd.AddCommand(shellCmd)
+}
@@ -0,0 +1,449 @@
+package cmd
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// sink CLI options variables
+var sinkSpecs string
+
+const (
+	// an environment variable with a bearer token sent to the HTTP sink
+	sinkTokenVariable = "KUBELSE_SINK_TOKEN"
+	// an environment variable with a token of the Splunk HTTP Event Collector
+	splunkTokenVariable = "SPLUNK_HEC_TOKEN"
+	// a timeout of a single delivery of a report over the network
+	sinkTimeout = 30 * time.Second
+)
+
+// Sink is a destination reports of scanned containers are delivered to, as soon as containers are scanned.
+// Sinks of a run are used by a single goroutine, one report at a time.
+type Sink interface {
+	// Write delivers a report of a scanned container, it may record where to in the result
+	Write(result *Result) error
+	// Close flushes reports and releases the sink at the end of a run
+	Close() error
+	// String describes the sink in logs
+	String() string
+}
+
+// sinks are destinations of reports of the current run
+var sinks sinkSet
+
+// sinkSet fans reports out to all sinks of a run.
+type sinkSet []Sink
+
+// write delivers a report to all sinks. A result is marked delivered, if at least one sink accepted it.
+func (s sinkSet) write(result *Result) {
+	for _, sink := range s {
+		if err := sink.Write(result); err != nil {
+			log(fmt.Sprintf("[-] Error delivering report of %s to %s: %s\n", result.container.container.String(), sink.String(), err.Error()))
+			continue
+		}
+		result.delivered = true
+	}
+}
+
+// close closes all sinks of a run.
+func (s sinkSet) close() {
+	for _, sink := range s {
+		if err := sink.Close(); err != nil {
+			log(fmt.Sprintf("[-] Error closing %s: %s\n", sink.String(), err.Error()))
+		}
+	}
+}
+
+// reportName returns a name of a report file of a result. Reports of scans that did not complete are marked in
+// their names, e.g. pod-container-<timestamp>-<run>.partial.ansi.
+func reportName(result Result) string {
+	suffix := fmt.Sprintf("-%s-%s.%s", fileTimestamp(time.Now()), shortRunID(), format)
+	if status := result.status(); status != StatusComplete {
+		suffix = fmt.Sprintf("-%s-%s.%s.%s", fileTimestamp(time.Now()), shortRunID(), status, format)
+	}
+	return reportFileName(directory, suffix, anonymizedName(result.container.container.Pod), result.container.container.Container)
+}
+
+// reportContent renders a report of a result in the output format.
+func reportContent(result Result) ([]byte, error) {
+	if format == "json" {
+		report, err := jsonReport(result)
+		return anonymized(report), err
+	}
+	return anonymized(renderReport(reportLines(result))), nil
+}
+
+// fileSink saves reports, and optionally stderr of lse.sh, in the reports directory.
+type fileSink struct{}
+
+func (fileSink) Write(result *Result) error {
+	fileName, err := saveScan(*result)
+	if err != nil {
+		return err
+	}
+	result.reportFile = fileName
+	if saveStderr && format != "json" {
+		if result.stderrFile, err = saveStderrOutput(*result); err != nil {
+			log(fmt.Sprintf("[-] Error saving stderr of %s/%s: %s\n", result.container.container.Pod, result.container.container.Container, err.Error()))
+		}
+	}
+	return nil
+}
+
+func (fileSink) Close() error { return nil }
+
+func (fileSink) String() string { return "reports directory" }
+
+// stdoutSink prints reports to the standard output, e.g. to be collected by a log shipper.
+type stdoutSink struct{}
+
+func (stdoutSink) Write(result *Result) error {
+	report, err := reportContent(*result)
+	if err != nil {
+		return err
+	}
+	if format == "json" {
+		// a report per line
+		var compact bytes.Buffer
+		if err := json.Compact(&compact, report); err != nil {
+			return err
+		}
+		report = compact.Bytes()
+	}
+	_, err = fmt.Fprintln(os.Stdout, string(report))
+	return err
+}
+
+func (stdoutSink) Close() error { return nil }
+
+func (stdoutSink) String() string { return "standard output" }
+
+// archiveSink collects reports of a run in a tar.gz archive.
+type archiveSink struct {
+	path  string
+	file  *os.File
+	gz    *gzip.Writer
+	tw    *tar.Writer
+	count int
+}
+
+func newArchiveSink(path string) (*archiveSink, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	gz := gzip.NewWriter(file)
+	return &archiveSink{path: path, file: file, gz: gz, tw: tar.NewWriter(gz)}, nil
+}
+
+func (s *archiveSink) Write(result *Result) error {
+	report, err := reportContent(*result)
+	if err != nil {
+		return err
+	}
+	header := &tar.Header{Name: reportName(*result), Mode: 0644, Size: int64(len(report)), ModTime: time.Now()}
+	if err := s.tw.WriteHeader(header); err != nil {
+		return err
+	}
+	if _, err := s.tw.Write(report); err != nil {
+		return err
+	}
+	s.count++
+	return nil
+}
+
+func (s *archiveSink) Close() error {
+	err := errors.Join(s.tw.Close(), s.gz.Close(), s.file.Close())
+	if err == nil {
+		log(fmt.Sprintf("[+] %d reports archived in %s\n", s.count, s.path))
+	}
+	return err
+}
+
+func (s *archiveSink) String() string { return "archive " + s.path }
+
+// post sends a json document to an HTTP endpoint with an authorization header.
+func post(client *http.Client, endpoint string, authorization string, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected HTTP status %s", resp.Status)
+	}
+	return nil
+}
+
+// httpSink posts json reports to an HTTP endpoint, e.g. a webhook or an ingestion API.
+type httpSink struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+func (s *httpSink) Write(result *Result) error {
+	report, err := jsonReport(*result)
+	if err != nil {
+		return err
+	}
+	report = anonymized(report)
+	authorization := ""
+	if s.token != "" {
+		authorization = "Bearer " + s.token
+	}
+	return post(s.client, s.url, authorization, report)
+}
+
+func (s *httpSink) Close() error { return nil }
+
+func (s *httpSink) String() string { return "HTTP sink " + s.url }
+
+// splunkSink sends json reports as events to a Splunk HTTP Event Collector, so that findings land in the SIEM.
+type splunkSink struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+func (s *splunkSink) Write(result *Result) error {
+	report, err := jsonReport(*result)
+	if err != nil {
+		return err
+	}
+	report = anonymized(report)
+	event, err := json.Marshal(map[string]interface{}{
+		"time":       now().Unix(),
+		"host":       valueOrDefault(cluster.Name, "unknown"),
+		"source":     "kubelse",
+		"sourcetype": "kubelse:report",
+		"event":      json.RawMessage(report),
+	})
+	if err != nil {
+		return err
+	}
+	return post(s.client, s.url, "Splunk "+s.token, event)
+}
+
+func (s *splunkSink) Close() error { return nil }
+
+func (s *splunkSink) String() string { return "Splunk " + s.url }
+
+// s3Sink uploads reports to an S3 bucket, or an S3 compatible storage, with AWS signature version 4. Credentials
+// and the region are read from the standard AWS environment variables.
+type s3Sink struct {
+	bucket, prefix, region, endpoint string
+	accessKey, secretKey, token      string
+	client                           *http.Client
+}
+
+func newS3Sink(location string) (*s3Sink, error) {
+	bucket, prefix, _ := strings.Cut(strings.TrimPrefix(location, "s3://"), "/")
+	sink := &s3Sink{
+		bucket:    bucket,
+		prefix:    strings.Trim(prefix, "/"),
+		region:    valueOrDefault(os.Getenv("AWS_REGION"), valueOrDefault(os.Getenv("AWS_DEFAULT_REGION"), "us-east-1")),
+		endpoint:  strings.TrimSuffix(os.Getenv("AWS_ENDPOINT_URL"), "/"),
+		accessKey: os.Getenv("AWS_ACCESS_KEY_ID"),
+		secretKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		token:     os.Getenv("AWS_SESSION_TOKEN"),
+		client:    &http.Client{Timeout: sinkTimeout},
+	}
+	if sink.accessKey == "" || sink.secretKey == "" {
+		return nil, errors.New("the S3 sink requires AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+	}
+	return sink, nil
+}
+
+// uriEncode encodes a path as required by AWS signatures, slashes are kept.
+func uriEncode(path string) string {
+	var encoded strings.Builder
+	for _, b := range []byte(path) {
+		switch {
+		case 'a' <= b && b <= 'z', 'A' <= b && b <= 'Z', '0' <= b && b <= '9', strings.IndexByte("-._~/", b) >= 0:
+			encoded.WriteByte(b)
+		default:
+			fmt.Fprintf(&encoded, "%%%02X", b)
+		}
+	}
+	return encoded.String()
+}
+
+// hmacSHA256 returns an HMAC-SHA256 of data.
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// put uploads an object, it is signed with AWS signature version 4.
+func (s *s3Sink) put(key string, body []byte, at time.Time) error {
+	// virtual-hosted-style on AWS, path-style on custom endpoints, e.g. MinIO
+	endpoint, path := fmt.Sprintf("https://%s.s3.%s.amazonaws.com", s.bucket, s.region), "/"+key
+	if s.endpoint != "" {
+		endpoint, path = s.endpoint, "/"+s.bucket+"/"+key
+	}
+	target, err := url.Parse(endpoint + uriEncode(path))
+	if err != nil {
+		return err
+	}
+
+	at = at.UTC()
+	amzDate, date := at.Format("20060102T150405Z"), at.Format("20060102")
+	sum := sha256.Sum256(body)
+	headers := map[string]string{
+		"host":                 target.Host,
+		"x-amz-content-sha256": hex.EncodeToString(sum[:]),
+		"x-amz-date":           amzDate,
+	}
+	if s.token != "" {
+		headers["x-amz-security-token"] = s.token
+	}
+	var names []string
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&canonicalHeaders, "%s:%s\n", name, headers[name])
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{http.MethodPut, uriEncode(path), "", canonicalHeaders.String(), signedHeaders, headers["x-amz-content-sha256"]}, "\n")
+	requestSum := sha256.Sum256([]byte(canonicalRequest))
+	scope := fmt.Sprintf("%s/%s/s3/aws4_request", date, s.region)
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, hex.EncodeToString(requestSum[:])}, "\n")
+	signingKey := hmacSHA256(hmacSHA256(hmacSHA256(hmacSHA256([]byte("AWS4"+s.secretKey), date), s.region), "s3"), "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	req, err := http.NewRequest(http.MethodPut, target.String(), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		if name != "host" {
+			req.Header.Set(name, headers[name])
+		}
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", s.accessKey, scope, signedHeaders, signature))
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected HTTP status %s", resp.Status)
+	}
+	return nil
+}
+
+func (s *s3Sink) Write(result *Result) error {
+	report, err := reportContent(*result)
+	if err != nil {
+		return err
+	}
+	key := reportName(*result)
+	if s.prefix != "" {
+		key = s.prefix + "/" + key
+	}
+	return s.put(key, report, time.Now())
+}
+
+func (s *s3Sink) Close() error { return nil }
+
+func (s *s3Sink) String() string { return "s3://" + s.bucket + "/" + s.prefix }
+
+// validateSinks checks the sinks option, so that a mistyped sink is reported before scanning.
+func validateSinks(specs string) error {
+	for _, spec := range untangleOption(specs) {
+		kind, value, _ := strings.Cut(strings.TrimSpace(spec), "=")
+		switch kind {
+		case "file", "stdout":
+		case "archive", "http", "splunk":
+			if value == "" {
+				return fmt.Errorf("the %s sink requires a value, e.g. %s=<%s>", kind, kind, map[string]string{"archive": "file.tar.gz", "http": "url", "splunk": "url"}[kind])
+			}
+		default:
+			if !strings.HasPrefix(spec, "s3://") || strings.TrimPrefix(spec, "s3://") == "" {
+				return fmt.Errorf("unknown sink %q, valid sinks are file, stdout, archive=<file>, http=<url>, splunk=<url> and s3://<bucket>/<prefix>", spec)
+			}
+		}
+	}
+	return nil
+}
+
+// newSink sets up a sink of a specification of the sinks option, e.g. http=<url>.
+func newSink(spec string) (Sink, error) {
+	kind, value, _ := strings.Cut(spec, "=")
+	switch {
+	case kind == "file":
+		return fileSink{}, nil
+	case kind == "stdout":
+		return stdoutSink{}, nil
+	case kind == "archive":
+		return newArchiveSink(value)
+	case kind == "http":
+		return &httpSink{url: value, token: os.Getenv(sinkTokenVariable), client: &http.Client{Timeout: sinkTimeout}}, nil
+	case kind == "splunk":
+		token := os.Getenv(splunkTokenVariable)
+		if token == "" {
+			return nil, fmt.Errorf("the Splunk sink requires a token in the %s environment variable", splunkTokenVariable)
+		}
+		return &splunkSink{url: value, token: token, client: &http.Client{Timeout: sinkTimeout}}, nil
+	case strings.HasPrefix(spec, "s3://"):
+		return newS3Sink(spec)
+	default:
+		return nil, fmt.Errorf("unknown sink %q", spec)
+	}
+}
+
+// newSinks sets up sinks of a run configured with the sinks option, sinks of the tenant of the run and the gRPC
+// collector.
+func newSinks() error {
+	sinks = nil
+	for _, spec := range append(untangleOption(valueOrDefault(sinkSpecs, "file")), tenantSinkSpecs()...) {
+		spec = strings.TrimSpace(spec)
+		sink, err := newSink(spec)
+		if err != nil {
+			sinks.close()
+			sinks = nil
+			return fmt.Errorf("[-] Error setting up sink %s: %s\n", spec, err.Error())
+		}
+		sinks = append(sinks, sink)
+	}
+	collector, err := newGRPCCollector()
+	if err != nil {
+		sinks.close()
+		sinks = nil
+		return fmt.Errorf("[-] Error setting up the gRPC collector %s: %s\n", grpcSink, err.Error())
+	}
+	if collector != nil {
+		sinks = append(sinks, collector)
+	}
+	return nil
+}
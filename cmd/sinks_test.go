@@ -0,0 +1,139 @@
+package cmd
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestReportsAreFannedOutToSinks(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		posted  []map[string]interface{}
+		uploads []string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case req.Method == http.MethodPost && req.Header.Get("Authorization") == "Bearer secret":
+			var report map[string]interface{}
+			if err := json.Unmarshal(body, &report); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			posted = append(posted, report)
+		case req.Method == http.MethodPut && strings.HasPrefix(req.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/") && req.Header.Get("X-Amz-Content-Sha256") != "":
+			uploads = append(uploads, req.URL.Path)
+		default:
+			http.Error(w, "unexpected request", http.StatusForbidden)
+		}
+	}))
+	defer server.Close()
+
+	cluster, k8s := startTestCluster(t, testPod("web-1", "nginx", nil), testPod("web-2", "nginx", nil))
+	cluster.SetContainer("web-1", "app", debian)
+	cluster.SetContainer("web-2", "app", debian)
+	t.Setenv(sinkTokenVariable, "secret")
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKID")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_ENDPOINT_URL", server.URL)
+	archive := filepath.Join(t.TempDir(), "reports.tar.gz")
+	sinkSpecs = "file,http=" + server.URL + ",archive=" + archive + ",s3://reports/prod"
+	t.Cleanup(func() { sinkSpecs, sinks = "file", nil })
+
+	containers, err := getContainers(k8s, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := scanContainers(k8s, containers); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(posted) != 2 || len(uploads) != 2 {
+		t.Fatalf("expected 2 reports posted and uploaded, got %d and %d", len(posted), len(uploads))
+	}
+	for _, upload := range uploads {
+		if !strings.HasPrefix(upload, "/reports/prod/web-") {
+			t.Errorf("unexpected upload %s", upload)
+		}
+	}
+
+	file, err := os.Open(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var archived []string
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		archived = append(archived, header.Name)
+	}
+	if len(archived) != 2 {
+		t.Fatalf("expected 2 archived reports, got %v", archived)
+	}
+	saved, _ := filepath.Glob(filepath.Join(directory, "web-*"))
+	if len(saved) != 2 {
+		t.Fatalf("expected 2 saved reports, got %v", saved)
+	}
+}
+
+func TestInvalidSinksAreRejected(t *testing.T) {
+	for _, spec := range []string{"ftp://host", "archive", "http=", "s3://"} {
+		if err := validateSinks(spec); err == nil {
+			t.Errorf("expected %q to be rejected", spec)
+		}
+	}
+	if err := validateSinks("file,stdout,splunk=https://splunk:8088/services/collector,s3://bucket"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestUndeliveredReportsAreSalvagedToFile(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	cluster, k8s := startTestCluster(t, testPod("web-1", "nginx", nil))
+	cluster.SetContainer("web-1", "app", debian)
+	sinkSpecs = "http=" + server.URL
+	t.Cleanup(func() { sinkSpecs, sinks = "file", nil })
+
+	containers, err := getContainers(k8s, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := scanContainers(k8s, containers); err != nil {
+		t.Fatal(err)
+	}
+
+	salvaged, _ := filepath.Glob(filepath.Join(directory, "kubelse-salvaged-*.txt"))
+	if len(salvaged) != 1 {
+		t.Fatalf("expected a salvage file of the run, got %v", salvaged)
+	}
+	content, _ := os.ReadFile(salvaged[0])
+	if !strings.Contains(string(content), "fst010") {
+		t.Errorf("expected the report of web-1 salvaged, got %q", content)
+	}
+}
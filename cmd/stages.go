@@ -0,0 +1,240 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"github.com/hhruszka/k8sexec"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A scan is a pipeline of stages: discovery lists containers, verification checks which of them can be tested,
+// execution runs lse.sh in testable ones and delivery hands results to sinks. Stages are connected with channels,
+// so that they run concurrently and can be reused separately, e.g. by the exec and fetch commands. Once a context
+// of a stage is cancelled, the stage drains its input without taking up new containers.
+
+// maxWorkers is the maximal size of a pool of workers of a stage
+const maxWorkers = 200
+
+// workerCount returns a size of a pool of workers for the expected number of containers.
+func workerCount(expected int) int {
+	return max(min(expected, maxWorkers), 1)
+}
+
+// source sends items through the returned channel, which is closed when all items are sent or the context is
+// cancelled.
+func source[T any](ctx context.Context, items []T) <-chan T {
+	out := make(chan T)
+	go func() {
+		defer close(out)
+		for _, item := range items {
+			select {
+			case out <- item:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// stage processes items received from a channel with a pool of workers and sends their outputs through the
+// returned channel, which is closed once the input is closed and all items are processed. Every worker has its own
+// pacer. work returns false, if an item has no output. Items received after the context is cancelled are dropped.
+func stage[In any, Out any](ctx context.Context, in <-chan In, workers int, work func(p *pacer, item In) (Out, bool)) <-chan Out {
+	var (
+		out      = make(chan Out, runtime.NumCPU()*2)
+		workerWg sync.WaitGroup
+	)
+	for id := 0; id < workers; id++ {
+		workerWg.Add(1)
+		go func() {
+			defer workerWg.Done()
+			var p pacer
+			for item := range in {
+				if ctx.Err() != nil {
+					continue
+				}
+				if output, ok := work(&p, item); ok {
+					out <- output
+				}
+			}
+		}()
+	}
+	go func() {
+		workerWg.Wait()
+		close(out)
+	}()
+	return out
+}
+
+// discoverStage lists containers selected with names of pods and containers, or all containers of the namespace
+// matching the selection options.
+func discoverStage(ctx context.Context, k8s *k8sexec.K8SExec, pods []string, containers []string) ([]Container, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return getContainers(k8s, pods, containers)
+}
+
+// verifyContainer checks if a container has a shell and utilities needed by lse.sh, and learns its distribution and
+// filesystem if it does.
+func verifyContainer(ctx context.Context, k8s *k8sexec.K8SExec, p *pacer, container ContainerInfo) ContainerInfo {
+	if err := supervise(container.container, "verifying", func() {
+		container.settings = settingsFor(container.container)
+		p.wait()
+		if container.settings.shell != "" {
+			container.shell, _ = checkShellInContainer(ctx, k8s, container.container, container.settings.shell)
+		} else {
+			container.shell, _ = getShellInContainer(ctx, k8s, container.container)
+		}
+		p.wait()
+		container.testable = checkUtils(ctx, k8s, container.container, utils) && container.shell != ""
+		if container.testable {
+			p.wait()
+			container.distro, container.pkgManager = getDistroInContainer(ctx, k8s, container)
+			p.wait()
+			container.readOnlyRoot, container.tmpWritable = getFilesystemInContainer(ctx, k8s, container)
+		}
+	}); err != nil {
+		// a container, which could not be verified, is not tested
+		container.testable = false
+	}
+	return container
+}
+
+// verifyStage verifies containers received from a channel, testable or not, with a pool of workers.
+func verifyStage(ctx context.Context, k8s *k8sexec.K8SExec, in <-chan Container, workers int) <-chan ContainerInfo {
+	return stage(ctx, in, workers, func(p *pacer, container Container) (ContainerInfo, bool) {
+		return verifyContainer(ctx, k8s, p, ContainerInfo{container: container}), true
+	})
+}
+
+// executeContainer runs lse.sh, the payload passed through stdin, in a container. It returns false if the container
+// was not scanned, because the run was aborted or cancelled, or the pod is terminating.
+func executeContainer(ctx context.Context, k8s *k8sexec.K8SExec, p *pacer, container ContainerInfo, payload []byte) (Result, bool) {
+	if errorBudget.exhausted() {
+		errorBudget.abort(container.container)
+		return Result{}, false
+	}
+	if outputQuota.reached() {
+		outputQuota.skip(container.container)
+		return Result{}, false
+	}
+	p.wait()
+	emitStarted(k8s, container.container)
+	start := time.Now()
+	var (
+		result      Result
+		terminating bool
+		// abandoned is set when the run was cancelled while lse.sh ran
+		abandoned bool
+	)
+	if err := supervise(container.container, "scanning", func() {
+		var execStatus *k8sexec.ExecutionStatus
+		execStatus, terminating = execLse(ctx, k8s, container, payload)
+		if abandoned = ctx.Err() != nil; terminating || abandoned {
+			return
+		}
+		retried := false
+		if execStalled(execStatus) && stallAction == "retry" {
+			log(fmt.Sprintf("\n[-] lse.sh produced no output in %s for %s, retrying with a reduced scope\n", container.container.String(), stallTimeout))
+			container.settings.reduced, retried = true, true
+			p.wait()
+			start = time.Now()
+			execStatus, terminating = execLse(ctx, k8s, container, payload)
+			if abandoned = ctx.Err() != nil; terminating || abandoned {
+				return
+			}
+		}
+		if execStalled(execStatus) {
+			log(fmt.Sprintf("\n[-] lse.sh produced no output in %s for %s, it was marked stalled\n", container.container.String(), stallTimeout))
+		} else if exceeded := execOverloaded(execStatus); exceeded != "" {
+			log(fmt.Sprintf("\n[-] Scan of %s was aborted to protect the workload: %s\n", container.container.String(), exceeded))
+		} else if execStatus.RetCode != k8sexec.Success {
+			log(strings.Join(execStatus.Error, "\n"))
+		}
+		result = newResult(container, execStatus, time.Since(start))
+		result.stalled = execStalled(execStatus)
+		result.overloaded = execOverloaded(execStatus)
+		if retried {
+			result.attempts++
+		}
+		if hashInventory && len(execStatus.Stdout) > 0 {
+			p.wait()
+			result.hashes = collectHashes(ctx, k8s, container, execStatus.Stdout)
+		}
+		if metadataProbe && len(execStatus.Stdout) > 0 {
+			p.wait()
+			result.scanReport = withProbeFindings(result.scanReport, metadataFindings(probeMetadata(ctx, k8s, container)))
+		}
+		if apiProbe && len(execStatus.Stdout) > 0 {
+			p.wait()
+			access, probed := probeAPI(ctx, k8s, container)
+			if probed {
+				result.api = &access
+			}
+			result.scanReport = withProbeFindings(result.scanReport, apiFindings(access, probed))
+		}
+		if imageConfig && len(execStatus.Stdout) > 0 {
+			result.scanReport = withProbeFindings(result.scanReport, imageConfigFindings(k8s, container.container))
+		}
+	}); err != nil {
+		terminating, abandoned, result = false, false, newResult(container, panickedExec(container.container, err), time.Since(start))
+	}
+	if terminating {
+		log(fmt.Sprintf("\n[-] Pod %s is terminating, its scan of %s was cancelled\n", container.container.Pod, container.container.Container))
+		terminatedPods.add(container.container)
+		return Result{}, false
+	}
+	if abandoned {
+		// recorded with other containers, which were not scanned, once the run finishes
+		return Result{}, false
+	}
+	result.suppress = findingRules(k8s.Namespace, container.container)
+	result.triage = containerTriage(k8s.Namespace, container.container)
+	result.risk = assessRisk(k8s, result)
+	emitFinished(k8s, result)
+	if errorBudget.record(result) {
+		log(fmt.Sprintf("\n[-] More than %d execs failed, execs may be blocked e.g. by RBAC or an admission webhook, aborting the run\n", errorBudget.limit))
+	}
+	return result, true
+}
+
+// executeStage runs lse.sh in containers received from a channel with a pool of workers.
+func executeStage(ctx context.Context, k8s *k8sexec.K8SExec, in <-chan ContainerInfo, workers int, payload []byte) <-chan Result {
+	return stage(ctx, in, workers, func(p *pacer, container ContainerInfo) (Result, bool) {
+		return executeContainer(ctx, k8s, p, container, payload)
+	})
+}
+
+// deliverStage delivers results received from a channel to sinks of the run, runs the post hook for each of them
+// and returns them, once the channel is closed. Results, which are already produced, are delivered even if the context is cancelled.
+func deliverStage(_ context.Context, in <-chan Result) []Result {
+	var (
+		results []Result
+		cnt     int
+	)
+	for result := range in {
+		// reports of containers, which risk scores are below '--min-score', are not saved
+		if result.reportable() {
+			if err := supervise(result.container.container, "saving the report of", func() {
+				sinks.write(&result)
+				if postHook != "" {
+					if err := runPostHook(result); err != nil {
+						log(fmt.Sprintf("\n[-] Error running post hook for %s: %s\n", result.container.container.String(), err.Error()))
+					}
+				}
+			}); err != nil {
+				result.execErrors = append(result.execErrors, err.Error())
+			}
+		}
+		results = append(results, result)
+		cnt++
+		log(fmt.Sprintf("\rAnalyzed %d containers", cnt))
+	}
+	log(fmt.Sprintf("\n"))
+	return results
+}
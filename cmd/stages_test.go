@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"context"
+	"k8slse/internal/fakecluster"
+	"sort"
+	"testing"
+)
+
+func TestStageDropsItemsOnceCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	in := make(chan int)
+	out := stage(ctx, in, 1, func(_ *pacer, item int) (int, bool) {
+		return item * 2, item%2 == 0
+	})
+
+	in <- 1
+	in <- 2
+	if doubled := <-out; doubled != 4 {
+		t.Fatalf("expected 4, got %d", doubled)
+	}
+	cancel()
+	in <- 4
+	close(in)
+	if doubled, ok := <-out; ok {
+		t.Fatalf("expected no output after cancellation, got %d", doubled)
+	}
+}
+
+func TestVerifyAndExecuteStages(t *testing.T) {
+	cluster, k8s := startTestCluster(t, testPod("web-1", "nginx", nil), testPod("distroless-1", "gcr.io/distroless/static", nil))
+	cluster.SetContainer("web-1", "app", debian)
+	cluster.SetContainer("distroless-1", "app", fakecluster.FakeContainer{})
+	newRun()
+	newErrorBudget(context.Background(), 2)
+	newOutputQuota()
+
+	ctx := context.Background()
+	containers, err := discoverStage(ctx, k8s, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var verified []ContainerInfo
+	for container := range verifyStage(ctx, k8s, source(ctx, containers), 2) {
+		verified = append(verified, container)
+	}
+	sort.Slice(verified, func(i, j int) bool { return verified[i].container.Pod < verified[j].container.Pod })
+	if len(verified) != 2 || verified[0].testable || !verified[1].testable || verified[1].shell == "" {
+		t.Fatalf("unexpected verified containers %+v", verified)
+	}
+
+	var results []Result
+	for result := range executeStage(ctx, k8s, source(ctx, verified[1:]), 1, lse) {
+		results = append(results, result)
+	}
+	if len(results) != 1 || results[0].status() != StatusComplete || len(results[0].scanReport) == 0 {
+		t.Fatalf("unexpected results %+v", results)
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := discoverStage(cancelled, k8s, nil, nil); err == nil {
+		t.Error("expected discovery to fail once cancelled")
+	}
+}
+
+func TestSubcommandSelectionOptionsAreKeptApart(t *testing.T) {
+	saved := selectionOptions{kubeconfig: kubeconfig, namespace: namespace, pods: podscli, containers: containerscli, selector: labelSelector,
+		helmRelease: helmRelease, images: images, directory: directory, quiet: quiet, pace: pace}
+	t.Cleanup(saved.apply)
+	if err := execCmd.Flags().Set("namespace", "payments"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { execCmd.Flags().Set("namespace", "default") })
+
+	if namespace == "payments" {
+		t.Fatal("expected options of exec not to change the root command's namespace")
+	}
+	applySelection(execCmd)
+	if namespace != "payments" {
+		t.Errorf("expected the namespace of exec applied, got %q", namespace)
+	}
+}
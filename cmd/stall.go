@@ -0,0 +1,204 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/hhruszka/k8sexec"
+	coreV1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/remotecommand"
+	utilexec "k8s.io/client-go/util/exec"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// stall detection CLI options variables
+var (
+	stallTimeout time.Duration
+	stallAction  string
+)
+
+var (
+	// runningViewInterval is how often containers, in which lse.sh is running, are listed
+	runningViewInterval = time.Minute
+	// stallGrace is how long partial output of a cancelled exec is waited for
+	stallGrace = 5 * time.Second
+)
+
+// stalledMessage is the error of execs cancelled for producing no output
+const stalledMessage = "kubelse: no output for %s, the exec was cancelled as stalled"
+
+// runningExec is an exec of lse.sh in progress.
+type runningExec struct {
+	container  Container
+	started    time.Time
+	lastOutput atomic.Int64
+}
+
+// touch records that the exec produced output.
+func (e *runningExec) touch() {
+	e.lastOutput.Store(time.Now().UnixNano())
+}
+
+// idle returns how long the exec has not produced any output.
+func (e *runningExec) idle() time.Duration {
+	return time.Since(time.Unix(0, e.lastOutput.Load()))
+}
+
+// runningExecs are execs of lse.sh in progress, so that stuck containers can be seen while a run goes on.
+type runningExecs struct {
+	mu    sync.Mutex
+	execs map[*runningExec]bool
+}
+
+var running = runningExecs{execs: make(map[*runningExec]bool)}
+
+// start registers an exec in a container.
+func (r *runningExecs) start(container Container) *runningExec {
+	exec := &runningExec{container: container, started: time.Now()}
+	exec.touch()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.execs[exec] = true
+	return exec
+}
+
+// finish unregisters a finished exec.
+func (r *runningExecs) finish(exec *runningExec) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.execs, exec)
+}
+
+// list returns execs in progress, the longest idle, or running, first.
+func (r *runningExecs) list() []*runningExec {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var execs []*runningExec
+	for exec := range r.execs {
+		execs = append(execs, exec)
+	}
+	sort.Slice(execs, func(i, j int) bool { return execs[i].lastOutput.Load() < execs[j].lastOutput.Load() })
+	return execs
+}
+
+// runningLines describes execs in progress, at most limit of them.
+func runningLines(execs []*runningExec, limit int) []string {
+	var lines []string
+	for idx, exec := range execs {
+		if idx == limit {
+			lines = append(lines, fmt.Sprintf("    ... and %d more", len(execs)-limit))
+			break
+		}
+		line := fmt.Sprintf("    %s running for %s", exec.container.String(), time.Since(exec.started).Round(time.Second))
+		if stallTimeout > 0 {
+			// output is followed only when stalls are detected
+			line += fmt.Sprintf(", no output for %s", exec.idle().Round(time.Second))
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// showRunning lists containers, in which lse.sh is running, every runningViewInterval until the returned function
+// is called.
+func showRunning() (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(runningViewInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if execs := running.list(); len(execs) > 0 {
+					log(fmt.Sprintf("\n[*] lse.sh is running in %d containers:\n%s\n", len(execs), strings.Join(runningLines(execs, 10), "\n")))
+				}
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+// validateStallAction checks what is done with stalled execs.
+func validateStallAction(value string) error {
+	if value != "retry" && value != "mark" {
+		return fmt.Errorf("invalid action %q, valid actions are retry or mark", value)
+	}
+	return nil
+}
+
+// stalledExec returns a status of an exec cancelled for producing no output for idle.
+func stalledExec(container Container, idle time.Duration) *k8sexec.ExecutionStatus {
+	return k8sexec.NewExecutionStatus(container.Pod, container.Container, k8sexec.InternalAppError, fmt.Sprintf(stalledMessage, idle.Round(time.Second)), "", "")
+}
+
+// execStalled tells if an exec was cancelled for producing no output.
+func execStalled(execStatus *k8sexec.ExecutionStatus) bool {
+	return execStatus != nil && len(execStatus.Error) > 0 && strings.HasPrefix(execStatus.Error[0], strings.SplitN(stalledMessage, "%", 2)[0])
+}
+
+// progressWriter buffers output of an exec and records every write as progress.
+type progressWriter struct {
+	mu       *sync.Mutex
+	buf      *bytes.Buffer
+	progress func()
+}
+
+func (w progressWriter) Write(p []byte) (int, error) {
+	if w.progress != nil {
+		w.progress()
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.Write(p)
+}
+
+// streamExec runs a command in a container as k8sexec does, but the exec is cancelled with the context and progress,
+// if given, is called whenever the command writes output. Output written until the exec is cancelled is returned,
+// along with the error of the exec, which k8sexec keeps only as a message.
+func streamExec(ctx context.Context, k8s *k8sexec.K8SExec, pod string, container string, args []string, stdin []byte, progress func()) (*k8sexec.ExecutionStatus, error) {
+	request := k8s.Clientset.CoreV1().RESTClient().Post().
+		Resource("pods").Namespace(k8s.Namespace).Name(pod).SubResource("exec").
+		VersionedParams(&coreV1.PodExecOptions{
+			Container: container,
+			Command:   args,
+			Stdin:     stdin != nil,
+			Stdout:    true,
+			Stderr:    true,
+		}, scheme.ParameterCodec)
+	executor, err := remotecommand.NewSPDYExecutor(k8s.Config, "POST", request.URL())
+	if err != nil {
+		return k8sexec.NewExecutionStatus(pod, container, k8sexec.InternalAppError, err.Error(), "", ""), err
+	}
+
+	var (
+		mu             sync.Mutex
+		stdout, stderr bytes.Buffer
+	)
+	options := remotecommand.StreamOptions{
+		Stdout: progressWriter{mu: &mu, buf: &stdout, progress: progress},
+		Stderr: progressWriter{mu: &mu, buf: &stderr, progress: progress},
+	}
+	if stdin != nil {
+		options.Stdin = bytes.NewReader(stdin)
+	}
+	retCode, message := k8sexec.Success, ""
+	err = executor.StreamWithContext(ctx, options)
+	if err != nil {
+		retCode, message = k8sexec.InternalAppError, err.Error()
+		var exitError utilexec.CodeExitError
+		if errors.As(err, &exitError) {
+			retCode = k8sexec.ExitCode(exitError.Code)
+		}
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	return k8sexec.NewExecutionStatus(pod, container, retCode, message, stdout.String(), stderr.String()), err
+}
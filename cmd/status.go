@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/hhruszka/k8sexec"
+	"strings"
+)
+
+// statuses of scan reports
+const (
+	// lse.sh exited successfully after running all tests
+	StatusComplete = "complete"
+	// lse.sh produced some output, but crashed, was killed or did not reach its end
+	StatusPartial = "partial"
+	// lse.sh did not produce any output
+	StatusFailed = "failed"
+)
+
+// lseFinished tells if lse.sh printed its final banner, which it does when it reaches the end of its tests.
+func lseFinished(lines []string) bool {
+	for idx := len(lines) - 1; idx >= 0; idx-- {
+		if match := sectionRegexp.FindStringSubmatch(strings.TrimSpace(stripANSI(lines[idx]))); match != nil {
+			return match[1] == "FINISHED"
+		}
+	}
+	return false
+}
+
+// status tells if a report of a scan is complete, partial or failed, based on lse.sh exit code and output.
+func (r Result) status() string {
+	switch {
+	case r.retCode == k8sexec.Success && lseFinished(r.scanReport):
+		return StatusComplete
+	case strings.TrimSpace(strings.Join(r.scanReport, "")) != "":
+		return StatusPartial
+	default:
+		return StatusFailed
+	}
+}
+
+// exitDescription describes lse.sh exit code.
+func (r Result) exitDescription() string {
+	return fmt.Sprintf("exit code %d: %s", int(r.retCode), valueOrDefault(k8sexec.GetExitCodeDescription(r.retCode), "unknown"))
+}
@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// stderrOutput returns lines of lse.sh standard error output followed by errors of the exec itself.
+func stderrOutput(result Result) []string {
+	lines := append([]string{}, result.stderr...)
+	for _, execError := range result.execErrors {
+		if execError != "" {
+			lines = append(lines, "exec error: "+execError)
+		}
+	}
+	return lines
+}
+
+// saveStderrOutput saves the standard error output of lse.sh in a companion .err file of the container's report.
+func saveStderrOutput(result Result) (string, error) {
+	fileName := reportFileName(directory, fmt.Sprintf("-%s-%s.err", fileTimestamp(time.Now()), shortRunID()), result.container.container.Pod, result.container.container.Container)
+	if result.reportFile != "" {
+		fileName = strings.TrimSuffix(filepath.Base(result.reportFile), filepath.Ext(result.reportFile)) + ".err"
+	}
+	return writeReportWithFallback(fileName, []byte(strings.Join(stderrOutput(result), "\n")))
+}
+
+// baseName returns a base name of a file or an empty string if there is no file.
+func baseName(fileName string) string {
+	if fileName == "" {
+		return ""
+	}
+	return filepath.Base(fileName)
+}
@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"fmt"
+	corev1 "k8s.io/api/core/v1"
+	"path"
+	"strings"
+)
+
+// Suggestion is a high-value finding of a pod's specification with kubectl commands, which verify it and help to
+// remediate it, so that platform engineers can act on reports directly.
+type Suggestion struct {
+	Finding   string   `json:"Finding"`
+	Verify    []string `json:"Verify"`
+	Remediate []string `json:"Remediate"`
+}
+
+// container runtime sockets, a container mounting one of them controls all containers of its node
+var runtimeSockets = []string{"docker.sock", "containerd.sock", "crio.sock", "cri-dockerd.sock"}
+
+// podSuggestions returns suggestions for a container of a pod: a mounted container runtime socket, a privileged
+// container and writable host paths.
+func podSuggestions(pod *corev1.Pod, name string) []Suggestion {
+	var container *corev1.Container
+	for idx := range pod.Spec.Containers {
+		if pod.Spec.Containers[idx].Name == name {
+			container = &pod.Spec.Containers[idx]
+		}
+	}
+	if container == nil {
+		return nil
+	}
+
+	var (
+		suggestions []Suggestion
+		exec        = fmt.Sprintf("kubectl exec -n %s %s -c %s --", pod.Namespace, pod.Name, name)
+		// workloads are changed, changes of their pods would be reverted by their controllers
+		edit    = fmt.Sprintf("kubectl edit -n %s %s", pod.Namespace, strings.ToLower(workloadOf(*pod)))
+		enforce = fmt.Sprintf("kubectl label --dry-run=server --overwrite namespace %s pod-security.kubernetes.io/enforce=baseline", pod.Namespace)
+	)
+
+	if container.SecurityContext != nil && container.SecurityContext.Privileged != nil && *container.SecurityContext.Privileged {
+		suggestions = append(suggestions, Suggestion{
+			Finding: fmt.Sprintf("container %q is privileged", name),
+			Verify: []string{
+				fmt.Sprintf("kubectl get pod -n %s %s -o jsonpath='{.spec.containers[?(@.name==\"%s\")].securityContext}'", pod.Namespace, pod.Name, name),
+				exec + " cat /proc/self/status",
+			},
+			Remediate: []string{
+				edit + fmt.Sprintf("  # set privileged: false in the securityContext of container %s", name),
+				enforce + "  # lists pods, which the baseline policy would reject",
+			},
+		})
+	}
+
+	hostPaths := make(map[string]string)
+	for _, volume := range pod.Spec.Volumes {
+		if volume.HostPath != nil {
+			hostPaths[volume.Name] = volume.HostPath.Path
+		}
+	}
+	for _, mount := range container.VolumeMounts {
+		hostPath, ok := hostPaths[mount.Name]
+		if !ok {
+			continue
+		}
+		switch {
+		case contains(runtimeSockets, path.Base(hostPath)) || contains(runtimeSockets, path.Base(mount.MountPath)):
+			suggestions = append(suggestions, Suggestion{
+				Finding: fmt.Sprintf("container %q mounts the container runtime socket %s at %s", name, hostPath, mount.MountPath),
+				Verify: []string{
+					fmt.Sprintf("%s ls -l %s", exec, mount.MountPath),
+					fmt.Sprintf("kubectl get pod -n %s %s -o jsonpath='{.spec.volumes[?(@.name==\"%s\")]}'", pod.Namespace, pod.Name, mount.Name),
+				},
+				Remediate: []string{
+					edit + fmt.Sprintf("  # remove volume %s and its mount", mount.Name),
+					enforce + "  # lists pods, which the baseline policy would reject",
+				},
+			})
+		case !mount.ReadOnly:
+			suggestions = append(suggestions, Suggestion{
+				Finding: fmt.Sprintf("container %q mounts host path %s writable at %s", name, hostPath, mount.MountPath),
+				Verify: []string{
+					fmt.Sprintf("%s sh -c 'test -w %s && echo writable'", exec, mount.MountPath),
+				},
+				Remediate: []string{
+					edit + fmt.Sprintf("  # set readOnly: true on the mount of volume %s, or remove it", mount.Name),
+					enforce + "  # lists pods, which the baseline policy would reject",
+				},
+			})
+		}
+	}
+	return suggestions
+}
+
+// reportSuggestions renders suggestions of a container as a section of its report.
+func reportSuggestions(container Container) []string {
+	if len(container.Suggestions) == 0 {
+		return nil
+	}
+	lines := []string{"", "===================================( suggestions )==================================="}
+	for _, suggestion := range container.Suggestions {
+		lines = append(lines, "[!] "+suggestion.Finding, "    verify:")
+		for _, command := range suggestion.Verify {
+			lines = append(lines, "      "+command)
+		}
+		lines = append(lines, "    remediate:")
+		for _, command := range suggestion.Remediate {
+			lines = append(lines, "      "+command)
+		}
+	}
+	return lines
+}
@@ -0,0 +1,152 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/jedib0t/go-pretty/v6/table"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SUID pivot CLI options variables
+var suidPivot bool
+
+// lse.sh tests listing setuid and setgid binaries: all of them and uncommon ones, i.e. not shipped by distributions
+var (
+	setuidBitTests = map[string]string{"fst010": "setuid", "fst020": "setuid", "fst040": "setgid", "fst050": "setgid"}
+	uncommonTests  = map[string]bool{"fst020": true, "fst050": true}
+)
+
+// SUIDBinary is a setuid or setgid binary found in one or more containers of the fleet.
+type SUIDBinary struct {
+	Path       string   `json:"Path"`
+	Bits       []string `json:"Bits"`
+	Uncommon   bool     `json:"Uncommon"`
+	Images     []string `json:"Images"`
+	Containers []string `json:"Containers"`
+}
+
+// sortedKeys returns keys of a set in order.
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// newSUIDPivot pivots setuid and setgid binaries found by lse.sh in scanned containers into a list of binaries
+// with containers they were found in, so that a binary shipped in a shared base image is a single entry. Uncommon
+// binaries come first, then binaries found in most containers.
+func newSUIDPivot(results []Result) []SUIDBinary {
+	type pivoted struct {
+		bits, images, containers map[string]bool
+		uncommon                 bool
+	}
+	binaries := make(map[string]*pivoted)
+	for _, result := range results {
+		for _, finding := range result.findings().Positive() {
+			bit, ok := setuidBitTests[finding.ID]
+			if !ok {
+				continue
+			}
+			for _, path := range findingPaths(finding) {
+				binary, ok := binaries[path]
+				if !ok {
+					binary = &pivoted{bits: map[string]bool{}, images: map[string]bool{}, containers: map[string]bool{}}
+					binaries[path] = binary
+				}
+				binary.bits[bit] = true
+				binary.uncommon = binary.uncommon || uncommonTests[finding.ID]
+				binary.images[valueOrDefault(result.container.container.Image, "unknown")] = true
+				binary.containers[result.container.container.String()] = true
+			}
+		}
+	}
+
+	pivot := []SUIDBinary{}
+	for path, binary := range binaries {
+		pivot = append(pivot, SUIDBinary{
+			Path:       path,
+			Bits:       sortedKeys(binary.bits),
+			Uncommon:   binary.uncommon,
+			Images:     sortedKeys(binary.images),
+			Containers: sortedKeys(binary.containers),
+		})
+	}
+	sort.Slice(pivot, func(i, j int) bool {
+		a, b := pivot[i], pivot[j]
+		if a.Uncommon != b.Uncommon {
+			return a.Uncommon
+		}
+		if len(a.Containers) != len(b.Containers) {
+			return len(a.Containers) > len(b.Containers)
+		}
+		return a.Path < b.Path
+	})
+	return pivot
+}
+
+// suidPivotLines renders the pivot of setuid and setgid binaries as a table followed by containers of every binary.
+func suidPivotLines(pivot []SUIDBinary, containers int) []string {
+	var buf bytes.Buffer
+	t := table.NewWriter()
+	t.SetOutputMirror(&buf)
+	t.AppendHeader(table.Row{"Binary", "Bits", "Uncommon", "Containers", "Images"})
+	for _, binary := range pivot {
+		uncommon := ""
+		if binary.Uncommon {
+			uncommon = "yes"
+		}
+		t.AppendRow(table.Row{binary.Path, strings.Join(binary.Bits, ","), uncommon, fmt.Sprintf("%d of %d", len(binary.Containers), containers), strings.Join(binary.Images, ", ")})
+	}
+	t.Render()
+
+	lines := []string{
+		"================================( kubelse setuid/setgid binaries )================================",
+		fmt.Sprintf("          Run ID: %s", runID),
+		fmt.Sprintf("         Cluster: %s (%s)", valueOrDefault(cluster.Name, "unknown"), valueOrDefault(cluster.Server, "unknown")),
+		fmt.Sprintf("       Namespace: %s", namespace),
+		fmt.Sprintf("      Containers: %d", containers),
+		fmt.Sprintf("        Binaries: %d", len(pivot)),
+		"",
+	}
+	lines = append(lines, strings.Split(strings.TrimRight(buf.String(), "\n"), "\n")...)
+	lines = append(lines, "")
+	for _, binary := range pivot {
+		lines = append(lines, fmt.Sprintf("=====( %s )=====", binary.Path), strings.Join(binary.Containers, ", "), "")
+	}
+	return lines
+}
+
+// saveSUIDPivot saves the pivot of setuid and setgid binaries of a run in the reports directory.
+func saveSUIDPivot(started time.Time, results []Result) {
+	pivot := newSUIDPivot(results)
+	report := renderReport(suidPivotLines(pivot, len(results)))
+	if format == "json" {
+		report, _ = json.MarshalIndent(map[string]interface{}{
+			"RunID":      runID,
+			"Cluster":    cluster,
+			"Namespace":  namespace,
+			"Containers": len(results),
+			"Binaries":   pivot,
+		}, "", "  ")
+	}
+
+	fileName := reportFileName(directory, "."+format, "kubelse-suid", fileTimestamp(started), shortRunID())
+	fileName, err := writeReportWithFallback(fileName, report)
+	if err != nil {
+		log(fmt.Sprintf("[-] Error saving setuid/setgid binaries: %s\n", err.Error()))
+		return
+	}
+	uncommon := 0
+	for _, binary := range pivot {
+		if binary.Uncommon {
+			uncommon++
+		}
+	}
+	log(fmt.Sprintf("[+] %d setuid/setgid binaries, %d of them uncommon, saved to %s\n", len(pivot), uncommon, fileName))
+}
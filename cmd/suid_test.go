@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"testing"
+)
+
+func TestSUIDPivotGroupsBinariesAcrossContainers(t *testing.T) {
+	report := func(uncommon string) []string {
+		lines := []string{
+			"=====================( file system )=====================",
+			"[*] fst010 Binaries with setuid bit......................... yes!",
+			"---",
+			"/usr/bin/su",
+			"/usr/bin/passwd",
+			"---",
+		}
+		if uncommon != "" {
+			lines = append(lines, "[!] fst020 Uncommon setuid binaries........................... yes!", "---", uncommon, "---")
+		}
+		return lines
+	}
+	result := func(pod string, image string, lines []string) Result {
+		return Result{container: ContainerInfo{container: Container{Pod: pod, Container: "app", Image: image}}, scanReport: lines}
+	}
+	pivot := newSUIDPivot([]Result{
+		result("web-1", "base:1", report("")),
+		result("web-2", "base:1", report("/opt/app/helper")),
+		result("db-1", "db:2", report("")),
+	})
+
+	if len(pivot) != 3 {
+		t.Fatalf("expected 3 binaries, got %+v", pivot)
+	}
+	if pivot[0].Path != "/opt/app/helper" || !pivot[0].Uncommon || len(pivot[0].Containers) != 1 || pivot[0].Containers[0] != "web-2/app" {
+		t.Errorf("expected the uncommon binary of web-2 first, got %+v", pivot[0])
+	}
+	if pivot[1].Path != "/usr/bin/passwd" || len(pivot[1].Containers) != 3 || len(pivot[1].Images) != 2 || pivot[1].Bits[0] != "setuid" {
+		t.Errorf("expected passwd to be found in all containers of both images, got %+v", pivot[1])
+	}
+}
@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/jedib0t/go-pretty/v6/table"
+)
+
+// printSummary renders a table summarizing what happened to every container during a run.
+func printSummary(manifest Manifest) {
+	var buf bytes.Buffer
+
+	t := table.NewWriter()
+	t.SetOutputMirror(&buf)
+	t.AppendHeader(table.Row{"#", tr("Pod"), tr("Container"), tr("Status"), tr("Duration"), tr("Risk"), tr("Critical"), tr("Interesting"), tr("Info")})
+
+	idx := 0
+	for _, entry := range manifest.Scanned {
+		idx++
+		status := tr(valueOrDefault(entry.Status, "scanned"))
+		risk := ""
+		if entry.Risk != nil {
+			risk = fmt.Sprint(entry.Risk.Score)
+		}
+		t.AppendRow(table.Row{idx, entry.Pod, entry.Container, status, entry.Duration, risk,
+			entry.Findings[SeverityCritical], entry.Findings[SeverityInteresting], entry.Findings[SeverityInfo]})
+	}
+	for _, entry := range manifest.NotTestable {
+		idx++
+		status := tr("skipped: not testable")
+		if entry.GapID != "" {
+			status += " (" + entry.GapID + ")"
+		}
+		t.AppendRow(table.Row{idx, entry.Pod, entry.Container, status, "", "", "", "", ""})
+	}
+	for _, entry := range manifest.Skipped {
+		idx++
+		t.AppendRow(table.Row{idx, entry.Pod, entry.Container, fmt.Sprintf(tr("skipped: %s"), entry.Reason), "", "", "", "", ""})
+	}
+
+	counts := manifest.FindingsCount()
+	t.AppendFooter(table.Row{"", "", "", "", tr("Total"), "", counts[SeverityCritical], counts[SeverityInteresting], counts[SeverityInfo]})
+	t.Render()
+	log(buf.String())
+}
@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/hhruszka/k8sexec"
+	runtimeDebug "runtime/debug"
+	"sync/atomic"
+)
+
+// recoveredPanics counts panics recovered by supervised workers during a run
+var recoveredPanics atomic.Int32
+
+// supervise runs work of a worker for a container and recovers from a panic in it. The panic is logged with its
+// stack and returned as an error, so that a bug hit by a single container, e.g. a nil dereference on an odd exec
+// response, does not kill an hours-long run.
+func supervise(container Container, stage string, work func()) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("kubelse panicked while %s %s: %v", stage, container.String(), r)
+			recoveredPanics.Add(1)
+			log(fmt.Sprintf("\n[-] %s\n%s\n", err.Error(), runtimeDebug.Stack()))
+		}
+	}()
+	work()
+	return nil
+}
+
+// panickedExec returns a status of an exec, which panicked, it is recorded as a failed scan.
+func panickedExec(container Container, err error) *k8sexec.ExecutionStatus {
+	return k8sexec.NewExecutionStatus(container.Pod, container.Container, k8sexec.InternalAppError, err.Error(), "", "")
+}
+
+// reportPanics logs how many panics were recovered during a run and resets the count for the next run.
+func reportPanics() {
+	if count := recoveredPanics.Swap(0); count > 0 {
+		log(fmt.Sprintf("[-] Recovered from %d panics, affected containers are recorded as failed, see the log for details\n", count))
+	}
+}
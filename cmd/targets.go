@@ -0,0 +1,119 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/hhruszka/k8sexec"
+	"io"
+	"os"
+	"strings"
+)
+
+// targets CLI options variables
+var targetsFile string
+
+// Target is a container, or all containers of a pod, listed in a targets file.
+type Target struct {
+	Namespace string
+	Pod       string
+	Container string
+}
+
+// parseTargets reads targets, one namespace/pod/container or namespace/pod per line. Empty lines and lines
+// starting with '#' are ignored.
+func parseTargets(r io.Reader) ([]Target, error) {
+	var targets []Target
+	scanner := bufio.NewScanner(r)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.Split(line, "/")
+		if (len(parts) != 2 && len(parts) != 3) || parts[0] == "" || parts[1] == "" || (len(parts) == 3 && parts[2] == "") {
+			return nil, fmt.Errorf("line %d: expected namespace/pod/container or namespace/pod, got %q", lineNo, line)
+		}
+		target := Target{Namespace: parts[0], Pod: parts[1]}
+		if len(parts) == 3 {
+			target.Container = parts[2]
+		}
+		targets = append(targets, target)
+	}
+	return targets, scanner.Err()
+}
+
+// readTargets reads targets from a file or, if the path is '-', from stdin.
+func readTargets(path string) ([]Target, error) {
+	if path == "-" {
+		return parseTargets(os.Stdin)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return parseTargets(file)
+}
+
+// scanTargetsFile scans containers listed in the targets file namespace by namespace.
+func scanTargetsFile(k8s *k8sexec.K8SExec) (Manifest, error) {
+	targets, err := readTargets(targetsFile)
+	if err != nil {
+		return Manifest{}, fmt.Errorf("[-] Error reading targets from %s: %s\n", targetsFile, err.Error())
+	}
+	if len(targets) == 0 {
+		return Manifest{}, fmt.Errorf("[-] No targets found in %s\n", targetsFile)
+	}
+
+	var (
+		namespaces []string
+		// containers of every pod of every namespace, an empty list means all containers of a pod
+		pods = make(map[string]map[string][]string)
+		// pods of every namespace in the order they were listed
+		podOrder = make(map[string][]string)
+	)
+	for _, target := range targets {
+		if _, ok := pods[target.Namespace]; !ok {
+			pods[target.Namespace] = make(map[string][]string)
+			namespaces = append(namespaces, target.Namespace)
+		}
+		containers, ok := pods[target.Namespace][target.Pod]
+		if !ok {
+			podOrder[target.Namespace] = append(podOrder[target.Namespace], target.Pod)
+		}
+		switch {
+		case target.Container == "" || (ok && len(containers) == 0):
+			// all containers of the pod
+			pods[target.Namespace][target.Pod] = []string{}
+		default:
+			pods[target.Namespace][target.Pod] = append(containers, target.Container)
+		}
+	}
+	log(fmt.Sprintf("[+] Read %d targets in %d namespaces from %s\n", len(targets), len(namespaces), targetsFile))
+	if err := validateNamespaces(k8s, namespaces); err != nil {
+		return Manifest{}, err
+	}
+
+	return scanNamespaces(k8s, namespaces, func(client *k8sexec.K8SExec) ([]Container, error) {
+		var (
+			containers []Container
+			skipped    []SkippedContainer
+		)
+		for _, pod := range podOrder[client.Namespace] {
+			found, err := getContainers(client, []string{pod}, pods[client.Namespace][pod])
+			if err != nil {
+				return nil, err
+			}
+			containers = append(containers, found...)
+			// getContainers resets skipped containers every time it is called
+			skipped = append(skipped, skippedContainers...)
+		}
+		skippedContainers = skipped
+		if list {
+			for _, container := range containers {
+				log(fmt.Sprintf("%s/%s\n", client.Namespace, container.String()))
+			}
+		}
+		return containers, nil
+	})
+}
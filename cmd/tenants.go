@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"github.com/hhruszka/k8sexec"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+// tenants CLI options variables
+var (
+	tenantAnnotation string
+	tenantSink       string
+)
+
+// tenantsDirectory is a directory in the reports directory, which reports of every tenant are saved under
+const tenantsDirectory = "tenants"
+
+// tenant is a tenant owning the namespace of the current run, empty if it has none
+var tenant string
+
+// namespaceTenant returns a tenant owning the scanned namespace, i.e. the value of its '--tenant-annotation'
+// annotation. Namespaces without the annotation, or which cannot be read, have no tenant.
+func namespaceTenant(k8s *k8sexec.K8SExec) string {
+	if tenantAnnotation == "" {
+		return ""
+	}
+	ns, err := clientsetOf(k8s).CoreV1().Namespaces().Get(context.TODO(), k8s.Namespace, metaV1.GetOptions{})
+	if err != nil {
+		log(fmt.Sprintf("[-] Cannot read the tenant of %s namespace: %s\n", k8s.Namespace, err.Error()))
+		return ""
+	}
+	return strings.TrimSpace(ns.Annotations[tenantAnnotation])
+}
+
+// tenantDirectory returns a directory reports of the tenant of the run are saved under, so that reports of every
+// tenant of a cluster-wide scan form a bundle of their own, e.g. <reports>/tenants/payments.
+func tenantDirectory(root string) string {
+	if tenant == "" {
+		return root
+	}
+	return filepath.Join(root, tenantsDirectory, safeFileName(tenant))
+}
+
+// tenantSinkSpecs returns sinks of the tenant of the run: '--tenant-sink' with {tenant} replaced by the tenant.
+func tenantSinkSpecs() []string {
+	if tenantSink == "" || tenant == "" {
+		return nil
+	}
+	return untangleOption(strings.ReplaceAll(tenantSink, "{tenant}", url.PathEscape(tenant)))
+}
@@ -0,0 +1,135 @@
+package cmd
+
+import (
+	"context"
+	"github.com/hhruszka/k8sexec"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sync"
+	"time"
+)
+
+// a reason, for which containers of terminating pods are skipped
+const skipReasonTerminating = "terminating"
+
+// an interval, in which pods being scanned are checked for termination
+var terminationCheckInterval = 5 * time.Second
+
+// terminatingPods collects containers, which scans were cancelled since their pods started terminating.
+type terminatingPods struct {
+	mu         sync.Mutex
+	containers []Container
+}
+
+// terminatedPods are containers of the current run, which scans were cancelled
+var terminatedPods terminatingPods
+
+// add records a container, which scan was cancelled.
+func (t *terminatingPods) add(container Container) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.containers = append(t.containers, container)
+}
+
+// finish adds containers, which scans were cancelled, to skipped containers of the run.
+func (t *terminatingPods) finish() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, container := range t.containers {
+		skippedContainers = append(skippedContainers, SkippedContainer{container, skipReasonTerminating})
+	}
+	t.containers = nil
+}
+
+// podTerminating tells if a pod is being deleted or is already gone.
+func podTerminating(k8s *k8sexec.K8SExec, pod string) bool {
+	found, err := clientsetOf(k8s).CoreV1().Pods(k8s.Namespace).Get(context.TODO(), pod, metaV1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return true
+	}
+	return err == nil && found.DeletionTimestamp != nil
+}
+
+// execUnlessTerminating runs a command in a container and checks in the meantime if its pod started terminating,
+// e.g. was deleted by a rollout, in which case the exec is abandoned and true is returned, since the output
+// would be cut at a random place once the container is killed. The abandoned exec ends with the container.
+// With '--stall-timeout' the exec is also cancelled, once it produces no output for the timeout, and a status of
+// a stalled exec with output produced until then is returned. With '--max-cpu' or '--max-memory' usage of the
+// container is sampled before and during the exec, which is not started or is cancelled over a threshold. The exec
+// is abandoned as well, once the run is cancelled.
+func execUnlessTerminating(runCtx context.Context, k8s *k8sexec.K8SExec, container Container, args []string, stdin []byte) (*k8sexec.ExecutionStatus, bool) {
+	if sampleUsage() {
+		if exceeded := checkUsage(k8s, container); exceeded != "" {
+			return overloadedExec(container, exceeded), false
+		}
+	}
+	ctx, cancel := context.WithCancel(runCtx)
+	defer cancel()
+	exec := running.start(container)
+	defer running.finish(exec)
+	var progress func()
+	if stallTimeout > 0 || sampleUsage() {
+		// only streamed execs can be cancelled
+		progress = exec.touch
+	}
+
+	done := make(chan *k8sexec.ExecutionStatus, 1)
+	go func() {
+		var execStatus *k8sexec.ExecutionStatus
+		if err := supervise(container, "running lse.sh in", func() {
+			execStatus = execInContainerContext(ctx, k8s, container.Pod, container.Container, args, stdin, progress)
+		}); err != nil {
+			execStatus = panickedExec(container, err)
+		}
+		done <- execStatus
+	}()
+
+	ticker := time.NewTicker(terminationCheckInterval)
+	defer ticker.Stop()
+	var stallCheck <-chan time.Time
+	if stallTimeout > 0 {
+		stallTicker := time.NewTicker(min(stallTimeout/4, terminationCheckInterval))
+		defer stallTicker.Stop()
+		stallCheck = stallTicker.C
+	}
+	var usageCheck <-chan time.Time
+	if sampleUsage() {
+		usageTicker := time.NewTicker(usageInterval)
+		defer usageTicker.Stop()
+		usageCheck = usageTicker.C
+	}
+	// cancelled cancels the exec and keeps output it produced until then in the status
+	cancelled := func(execStatus *k8sexec.ExecutionStatus) *k8sexec.ExecutionStatus {
+		cancel()
+		select {
+		case partial := <-done:
+			execStatus.Stdout, execStatus.Stderr = partial.Stdout, partial.Stderr
+		case <-time.After(stallGrace):
+		}
+		return execStatus
+	}
+	for {
+		select {
+		case <-usageCheck:
+			if exceeded := checkUsage(k8s, container); exceeded != "" {
+				return cancelled(overloadedExec(container, exceeded)), false
+			}
+		case <-stallCheck:
+			idle := exec.idle()
+			if idle < stallTimeout {
+				continue
+			}
+			return cancelled(stalledExec(container, idle)), false
+		case execStatus := <-done:
+			// an exec fails, when its container is killed before the pod is checked again
+			terminating := execStatus.RetCode != k8sexec.Success && replayFile == "" && podTerminating(k8s, container.Pod)
+			return execStatus, terminating
+		case <-ticker.C:
+			if replayFile == "" && podTerminating(k8s, container.Pod) {
+				return nil, true
+			}
+		case <-runCtx.Done():
+			return cancelled(cancelledExec(runCtx, container.Pod, container.Container)), false
+		}
+	}
+}
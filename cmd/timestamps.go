@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// timestamps CLI options variables
+var (
+	timestampFormat string = "rfc3339"
+	utc             bool   = true
+)
+
+// named timestamp formats, any other value of the timestamp format option is used as a Go time layout
+var timestampFormats = map[string]string{
+	"rfc3339": time.RFC3339,
+	"rfc1123": time.RFC1123Z,
+	// file names of kubelse before timestamps were configurable
+	"legacy": "2006-01-02-150405",
+}
+
+// timestampLayout returns the Go time layout of the timestamp format.
+func timestampLayout() string {
+	if layout, ok := timestampFormats[strings.ToLower(timestampFormat)]; ok {
+		return layout
+	}
+	return timestampFormat
+}
+
+// validateTimestampFormat checks that a custom timestamp format formats times, i.e. it is a Go time layout.
+func validateTimestampFormat(value string) error {
+	if _, ok := timestampFormats[strings.ToLower(value)]; ok {
+		return nil
+	}
+	reference := time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC)
+	if value == "" || reference.Format(value) == value {
+		return errors.New("expected rfc3339, rfc1123, legacy or a Go time layout, e.g. 2006-01-02T15:04:05Z07:00")
+	}
+	return nil
+}
+
+// now returns the current time in UTC or, if it was requested, in the local time zone.
+func now() time.Time {
+	if utc {
+		return time.Now().UTC()
+	}
+	return time.Now()
+}
+
+// formatTimestamp formats a time for reports.
+func formatTimestamp(t time.Time) string {
+	if utc {
+		t = t.UTC()
+	}
+	return t.Format(timestampLayout())
+}
+
+// fileTimestamp formats a time for file names, characters not allowed in file names on some systems, e.g. ':'
+// of RFC3339 timestamps, are removed.
+func fileTimestamp(t time.Time) string {
+	return sanitizeFileName(strings.NewReplacer(":", "", "/", "-", "\\", "-", " ", "_", ",", "").Replace(formatTimestamp(t)))
+}
@@ -0,0 +1,35 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFileTimestamp(t *testing.T) {
+	warsaw, err := time.LoadLocation("Europe/Warsaw")
+	if err != nil {
+		t.Skip("time zone database not available")
+	}
+	at := time.Date(2024, 6, 1, 3, 30, 5, 0, warsaw)
+	t.Cleanup(func() { timestampFormat, utc = "rfc3339", true })
+
+	for _, tc := range []struct {
+		format   string
+		utc      bool
+		expected string
+	}{
+		{"rfc3339", true, "2024-06-01T013005Z"},
+		{"rfc3339", false, "2024-06-01T033005+0200"},
+		{"legacy", true, "2024-06-01-013005"},
+		{"20060102-1504", true, "20240601-0130"},
+	} {
+		timestampFormat, utc = tc.format, tc.utc
+		if actual := fileTimestamp(at); actual != tc.expected {
+			t.Errorf("%s (utc %t): expected %s, got %s", tc.format, tc.utc, tc.expected, actual)
+		}
+	}
+
+	if err := validateTimestampFormat("yyyy-mm-dd"); err == nil {
+		t.Error("expected a format without Go layout elements to be rejected")
+	}
+}
@@ -0,0 +1,413 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+	"os"
+	"os/user"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// triage CLI options variables
+var triageDirectory string
+
+// triage states of findings
+const (
+	TriageConfirmed     = "confirmed"
+	TriageFalsePositive = "false-positive"
+	TriageAcceptedRisk  = "accepted-risk"
+)
+
+// TriageDecision is a decision of an analyst on a positive finding of a container, kept across runs, so that
+// false positives are not reported again and accepted risks are marked in reports and diffs.
+type TriageDecision struct {
+	State   string    `json:"State"`
+	Comment string    `json:"Comment,omitempty"`
+	Analyst string    `json:"Analyst,omitempty"`
+	Updated time.Time `json:"Updated"`
+	// Run is the run the finding was triaged in
+	Run string `json:"Run,omitempty"`
+}
+
+// String returns a state of a decision with its comment, e.g. in report headers.
+func (d *TriageDecision) String() string {
+	if d.Comment == "" {
+		return d.State
+	}
+	return d.State + ": " + d.Comment
+}
+
+// triageDecisions are decisions of the results DB of the run by their keys, see triageKey
+var triageDecisions map[string]*TriageDecision
+
+// triageKey identifies a finding of a container across runs by the cluster, namespace, workload and name of the
+// container and the test ID, so that replicas and recreated pods share decisions.
+func triageKey(clusterName string, ns string, workload string, container string, id string) string {
+	return strings.Join([]string{clusterName, ns, workload, container, id}, "/")
+}
+
+// triageWorkload returns a workload of a container in triage keys, pods without a workload are workloads themselves.
+func triageWorkload(workload string, pod string) string {
+	if workload == "" || workload == "unknown" {
+		return "Pod/" + pod
+	}
+	return workload
+}
+
+// reportTriageKey returns a key of a finding of a saved report.
+func reportTriageKey(report Report, id string) string {
+	return triageKey(valueOrDefault(report.Header["Cluster"], "unknown"), report.Header["Namespace"], triageWorkload(report.Header["Workload"], report.Pod()), report.Container(), id)
+}
+
+// loadTriage reads triage decisions of the results DB for the run.
+func loadTriage() error {
+	triageDecisions = nil
+	file, err := resultsDBPath()
+	if err != nil {
+		return err
+	}
+	db, err := loadResultsDB(file)
+	if err != nil {
+		return err
+	}
+	triageDecisions = db.Triage
+	return nil
+}
+
+// containerTriage returns triage decisions on findings of a container of a namespace by test IDs.
+func containerTriage(ns string, container Container) map[string]*TriageDecision {
+	decisions := make(map[string]*TriageDecision)
+	prefix := triageKey(valueOrDefault(cluster.Name, "unknown"), ns, triageWorkload(container.Workload, container.Pod), container.Container, "")
+	for key, decision := range triageDecisions {
+		if id, ok := strings.CutPrefix(key, prefix); ok && !strings.Contains(id, "/") {
+			decisions[id] = decision
+		}
+	}
+	return decisions
+}
+
+// applyTriage sets triage decisions of the results DB on findings of saved reports, also on reports saved before
+// the findings were triaged.
+func (db *ResultsDB) applyTriage(reports []Report) {
+	for _, report := range reports {
+		for idx, finding := range report.Findings {
+			if decision, ok := db.Triage[reportTriageKey(report, finding.ID)]; ok {
+				report.Findings[idx].Triage = decision
+			}
+		}
+	}
+}
+
+// triagedFindings returns findings of a scanned container with triage decisions and their states.
+func (r Result) triagedFindings() []string {
+	var triaged []string
+	for _, finding := range r.findings().Findings {
+		if finding.Triage != nil {
+			triaged = append(triaged, fmt.Sprintf("%s (%s)", finding.ID, finding.Triage))
+		}
+	}
+	return triaged
+}
+
+// analystName returns a name of the analyst triaging findings, the user running kubelse.
+func analystName() string {
+	if current, err := user.Current(); err == nil && current.Username != "" {
+		return current.Username
+	}
+	return os.Getenv("USER")
+}
+
+// triageItem is a positive finding of a report listed by the triage mode.
+type triageItem struct {
+	report  Report
+	finding Finding
+	key     string
+}
+
+// triager is the state of the triage mode: a list of positive findings of a run, a comment being typed and
+// the results DB decisions are saved in.
+type triager struct {
+	run     string
+	items   []triageItem
+	db      *ResultsDB
+	file    string
+	analyst string
+	cursor  int
+	top     int
+	// input is a comment being typed, nil unless the user is typing one
+	input   *string
+	message string
+	width   int
+	height  int
+}
+
+// newTriager creates the triage mode of positive findings of reports of a run, findings suppressed by the
+// ignore file are not listed.
+func newTriager(run string, reports []Report, db *ResultsDB, file string) *triager {
+	t := &triager{run: run, db: db, file: file, analyst: analystName(), width: 80, height: 24}
+	for _, report := range reports {
+		for _, finding := range report.Findings {
+			if finding.Result == "yes!" && finding.Suppressed == "" {
+				t.items = append(t.items, triageItem{report: report, finding: finding, key: reportTriageKey(report, finding.ID)})
+			}
+		}
+	}
+	sort.SliceStable(t.items, func(i, j int) bool {
+		a, b := t.items[i], t.items[j]
+		if a.finding.Severity != b.finding.Severity {
+			return a.finding.Severity == SeverityCritical
+		}
+		if a.report.Pod() != b.report.Pod() {
+			return a.report.Pod() < b.report.Pod()
+		}
+		if a.report.Container() != b.report.Container() {
+			return a.report.Container() < b.report.Container()
+		}
+		return a.finding.ID < b.finding.ID
+	})
+	return t
+}
+
+// resize sets the size of the terminal.
+func (t *triager) resize(width int, height int) {
+	t.width, t.height = width, height
+}
+
+// listSize returns the number of findings shown at once, the rest of the screen shows the current finding.
+func (t *triager) listSize() int {
+	return max(t.height-8, 1)
+}
+
+// scroll moves the cursor by delta findings within bounds.
+func (t *triager) scroll(delta int) {
+	t.cursor = max(min(t.cursor+delta, len(t.items)-1), 0)
+	if t.cursor < t.top {
+		t.top = t.cursor
+	} else if t.cursor >= t.top+t.listSize() {
+		t.top = t.cursor - t.listSize() + 1
+	}
+}
+
+// decide records a decision on the current finding, keeping its comment, and saves the results DB, an empty
+// state clears the decision.
+func (t *triager) decide(state string, comment *string) {
+	if len(t.items) == 0 {
+		return
+	}
+	item := t.items[t.cursor]
+	decision, ok := t.db.Triage[item.key]
+	switch {
+	case state == "":
+		delete(t.db.Triage, item.key)
+	case !ok:
+		decision = &TriageDecision{State: state}
+		t.db.Triage[item.key] = decision
+	default:
+		decision.State = state
+	}
+	if state != "" {
+		if comment != nil {
+			decision.Comment = *comment
+		}
+		decision.Analyst, decision.Updated, decision.Run = t.analyst, now(), t.run
+	}
+	if err := t.db.save(t.file); err != nil {
+		t.message = "error saving triage: " + err.Error()
+		return
+	}
+	t.message = fmt.Sprintf("%s of %s/%s: %s", item.finding.ID, item.report.Pod(), item.report.Container(), valueOrDefault(state, "decision cleared"))
+}
+
+// handle applies a key pressed by the user, it returns false when the triage mode is to be closed.
+func (t *triager) handle(key string) bool {
+	if t.input != nil {
+		switch key {
+		case "enter":
+			comment := *t.input
+			t.input = nil
+			if decision, ok := t.db.Triage[t.items[t.cursor].key]; ok {
+				t.decide(decision.State, &comment)
+			} else {
+				t.decide(TriageConfirmed, &comment)
+			}
+		case "esc":
+			t.input = nil
+		case "backspace":
+			if comment := *t.input; comment != "" {
+				_, size := utf8.DecodeLastRuneInString(comment)
+				*t.input = comment[:len(comment)-size]
+			}
+		default:
+			if utf8.RuneCountInString(key) == 1 {
+				*t.input += key
+			}
+		}
+		return true
+	}
+
+	t.message = ""
+	switch key {
+	case "q", "ctrl-c":
+		return false
+	case "j", "down":
+		t.scroll(1)
+	case "k", "up":
+		t.scroll(-1)
+	case " ", "pgdn":
+		t.scroll(t.listSize())
+	case "b", "pgup":
+		t.scroll(-t.listSize())
+	case "g", "home":
+		t.scroll(-len(t.items))
+	case "G", "end":
+		t.scroll(len(t.items))
+	case "c":
+		t.decide(TriageConfirmed, nil)
+	case "f":
+		t.decide(TriageFalsePositive, nil)
+	case "a":
+		t.decide(TriageAcceptedRisk, nil)
+	case "u":
+		t.decide("", nil)
+	case "m", "enter":
+		if len(t.items) > 0 {
+			comment := ""
+			if decision, ok := t.db.Triage[t.items[t.cursor].key]; ok {
+				comment = decision.Comment
+			}
+			t.input = &comment
+		}
+	}
+	return true
+}
+
+// render returns lines of the screen: a page of findings, details of the current one and the status bar.
+func (t *triager) render() []string {
+	screen := make([]string, 0, t.height)
+	for idx := t.top; idx < t.top+t.listSize(); idx++ {
+		if idx >= len(t.items) {
+			screen = append(screen, "~")
+			continue
+		}
+		item := t.items[idx]
+		state := "untriaged"
+		if decision, ok := t.db.Triage[item.key]; ok {
+			state = decision.State
+		}
+		line := fmt.Sprintf("%-15s %-11s %-8s %-30s %s/%s", state, item.finding.Severity, item.finding.ID, item.finding.Name, item.report.Pod(), item.report.Container())
+		if idx == t.cursor {
+			// reverse video marks the cursor
+			line = "\x1b[7m" + line
+		}
+		screen = append(screen, displayLine(line, t.width))
+	}
+
+	details := make([]string, 6)
+	if len(t.items) > 0 {
+		item := t.items[t.cursor]
+		details[0] = fmt.Sprintf("%s %s: %s", item.finding.ID, item.finding.Severity, item.finding.Name)
+		details[1] = fmt.Sprintf("%s/%s %s", valueOrDefault(item.report.Header["Namespace"], "unknown"), item.report.Pod(), item.report.Container())
+		for idx, detail := range item.finding.Details {
+			if idx == 2 {
+				break
+			}
+			details[2+idx] = "  " + plainText(detail)
+		}
+		if decision, ok := t.db.Triage[item.key]; ok {
+			details[4] = fmt.Sprintf("%s by %s on %s", decision.State, valueOrDefault(decision.Analyst, "unknown"), formatTimestamp(decision.Updated))
+			details[5] = "comment: " + decision.Comment
+		}
+	}
+	screen = append(screen, displayLine(strings.Repeat("─", t.width), t.width))
+	for _, detail := range details {
+		screen = append(screen, displayLine(detail, t.width))
+	}
+
+	var status string
+	switch {
+	case t.input != nil:
+		status = "comment: " + *t.input
+	case t.message != "":
+		status = t.message
+	default:
+		status = fmt.Sprintf("run %s | %d findings | c: confirmed  f: false positive  a: accepted risk  u: undo  m: comment  q: quit", t.run, len(t.items))
+	}
+	return append(screen, "\x1b[7m"+displayLine(status, t.width))
+}
+
+// runReports returns reports of a run saved in a directory, given the run ID or its prefix, e.g. the 8 characters
+// in file names.
+func runReports(dir string, id string) (string, []Report, error) {
+	runs, err := loadReports(dir)
+	if err != nil {
+		return "", nil, err
+	}
+	found := make(map[string][]Report)
+	for _, reports := range runs {
+		for _, report := range reports {
+			if run := report.Header["Run ID"]; run != "" && strings.HasPrefix(run, id) {
+				found[run] = append(found[run], report)
+			}
+		}
+	}
+	switch {
+	case len(found) == 0:
+		return "", nil, fmt.Errorf("[-] No reports of run %s found in %s\n", id, dir)
+	case len(found) > 1:
+		return "", nil, fmt.Errorf("[-] Run ID %s is ambiguous, it matches %d runs in %s\n", id, len(found), dir)
+	}
+	for run, reports := range found {
+		return run, reports, nil
+	}
+	return "", nil, nil
+}
+
+var triageCmd = &cobra.Command{
+	Use:   "triage <run>",
+	Short: "Mark findings of a run as confirmed, false positives or accepted risks",
+	Long: `
+Opens a terminal list of positive findings of a run, given by its ID or the 8 characters in file names, in which
+an analyst marks every finding as confirmed, a false positive or an accepted risk, optionally with a comment.
+Decisions are saved in the results DB right away and kept for the same finding of the same container in later
+runs: false positives are not reported as positive anymore and decisions are shown in reports and diffs.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if !term.IsTerminal(int(os.Stdin.Fd())) || !term.IsTerminal(int(os.Stdout.Fd())) {
+			return errors.New("The triage command requires a terminal")
+		}
+		run, reports, err := runReports(triageDirectory, args[0])
+		if err != nil {
+			return err
+		}
+		file, err := resultsDBPath()
+		if err != nil {
+			return err
+		}
+		db, err := loadResultsDB(file)
+		if err != nil {
+			return err
+		}
+		t := newTriager(run, reports, db, file)
+		if len(t.items) == 0 {
+			return fmt.Errorf("[-] Run %s has no positive findings\n", run)
+		}
+		return runViewer(t, os.Stdin, os.Stdout)
+	},
+}
+
+func init() {
+	workingDirectory, err := os.Getwd()
+	if err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+	triageCmd.Flags().StringVarP(&triageDirectory, "directory", "d", workingDirectory, "a reports directory with reports of the run")
+	triageCmd.Flags().StringVar(&resultsDBFile, "results-db", "", "a file results of runs are kept in, if not provided then results.json in the kubelse user configuration directory")
+
+	cmd.AddCommand(triageCmd)
+}
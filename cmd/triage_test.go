@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTriagedFalsePositivesAreNotReportedAgain(t *testing.T) {
+	resultsDBFile = filepath.Join(t.TempDir(), "results.json")
+	defer func() { resultsDBFile, triageDecisions = "", nil }()
+	cluster, k8s := startTestCluster(t, testPod("web-1", "nginx:1.25", nil))
+	cluster.SetContainer("web-1", "app", debian)
+
+	containers, err := getContainers(k8s, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, err := scanContainers(k8s, containers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	run, reports, err := runReports(directory, first.RunID[:8])
+	if err != nil || run != first.RunID || len(reports) != 1 {
+		t.Fatalf("expected the report of run %s, got %s %d (%v)", first.RunID, run, len(reports), err)
+	}
+	db, err := loadResultsDB(resultsDBFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	triage := newTriager(run, reports, db, resultsDBFile)
+	if len(triage.items) == 0 || triage.items[0].finding.ID != "fst010" {
+		t.Fatalf("expected critical findings listed first, got %v", triage.items)
+	}
+	for _, key := range []string{"f", "m", "t", "m", "p", "enter", "down", "a"} {
+		triage.handle(key)
+	}
+	if screen := triage.render(); !strings.HasPrefix(screen[0], "false-positive  critical    fst010") {
+		t.Errorf("expected the decision listed, got %q", screen)
+	}
+
+	if err := loadTriage(); err != nil {
+		t.Fatal(err)
+	}
+	decision := triageDecisions[reportTriageKey(reports[0], "fst010")]
+	if decision == nil || decision.State != TriageFalsePositive || decision.Comment != "tmp" || decision.Run != run {
+		t.Fatalf("expected fst010 triaged as a false positive with a comment, got %+v", decision)
+	}
+	if len(triageDecisions) != 2 {
+		t.Errorf("expected 2 triage decisions, got %d", len(triageDecisions))
+	}
+
+	format = "json"
+	defer func() { format = "text" }()
+	startRun(k8s)
+	second, err := scanContainers(k8s, containers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, reports, err = runReports(directory, second.RunID[:8])
+	if err != nil || len(reports) != 1 {
+		t.Fatalf("expected the report of the second run, got %d (%v)", len(reports), err)
+	}
+	if !strings.Contains(reports[0].Header["Triaged"], "fst010 (false-positive: tmp)") {
+		t.Errorf("expected triaged findings in the header, got %q", reports[0].Header["Triaged"])
+	}
+	for _, finding := range reports[0].Positive() {
+		if finding.ID == "fst010" {
+			t.Errorf("expected fst010 not positive anymore")
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+)
+
+// report size CLI options variables
+var maxReportSize string
+
+// truncationBanner starts a line, which replaces output of lse.sh omitted between the head and the tail of a report
+const truncationBanner = "[!] kubelse: output truncated"
+
+// truncateScript runs the wrapped command, the shell found in a container, and passes its output through awk,
+// which keeps the head and the tail of the output within the limit and replaces the rest with a banner. Output is
+// truncated in the container, so a pathological container does not stream gigabytes through the API server. The
+// exit code of the wrapped command is kept. Without awk the command is run as is and truncated by kubelse.
+const truncateScript = `command -v awk >/dev/null 2>&1 || exec "$0" "$@"
+exec 3>&1
+status=$( { { "$0" "$@" 3>&- 4>&-; echo $? >&4; } | awk -v head="$head" -v tail="$tail" -v limit="$limit" -v banner="$banner" '
+BEGIN { first = 0; last = 0 }
+{
+  n = length($0) + 1
+  if (!full && kept + n <= head) { print; kept += n; next }
+  full = 1
+  buf[last] = $0; size[last] = n; last++; buffered += n
+  while (buffered > tail && first < last) {
+    buffered -= size[first]; bytes += size[first]; lines++
+    delete buf[first]; delete size[first]; first++
+  }
+}
+END {
+  if (lines > 0) printf "%s, %d bytes (%d lines) omitted, --max-report-size %s\n", banner, bytes, lines, limit
+  for (i = first; i < last; i++) print buf[i]
+}' >&3; } 4>&1 )
+exit "$status"`
+
+// reportSizeLimit returns the maximal size of a report in bytes, or 0 if reports are not limited.
+func reportSizeLimit() int64 {
+	if maxReportSize == "" {
+		return 0
+	}
+	limit, _ := parseSize(maxReportSize)
+	return limit
+}
+
+// truncateCommand wraps a command running lse.sh, so that its output is truncated in the container, if reports
+// are limited with '--max-report-size'.
+func truncateCommand(command []string) []string {
+	limit := reportSizeLimit()
+	if limit == 0 {
+		return command
+	}
+	script := fmt.Sprintf("head=%d tail=%d limit=%s banner='%s'\n", limit/2, limit-limit/2, maxReportSize, truncationBanner) + truncateScript
+	wrapped := []string{command[0], "-c", script, command[0]}
+	return append(wrapped, command[1:]...)
+}
+
+// truncateLines keeps the head and the tail of lines within the limit, the lines in between are replaced with the
+// truncation banner. It is a fallback for containers without awk.
+func truncateLines(lines []string, limit int64) []string {
+	var total int64
+	for _, line := range lines {
+		total += int64(len(line)) + 1
+	}
+	if limit == 0 || total <= limit {
+		return lines
+	}
+
+	head, kept := 0, int64(0)
+	for head < len(lines) && kept+int64(len(lines[head]))+1 <= limit/2 {
+		kept += int64(len(lines[head])) + 1
+		head++
+	}
+	tail, buffered := len(lines), int64(0)
+	for tail > head && buffered+int64(len(lines[tail-1]))+1 <= limit-limit/2 {
+		buffered += int64(len(lines[tail-1])) + 1
+		tail--
+	}
+
+	truncated := append([]string{}, lines[:head]...)
+	truncated = append(truncated, fmt.Sprintf("%s, %d bytes (%d lines) omitted, --max-report-size %s", truncationBanner, total-kept-buffered, tail-head, maxReportSize))
+	return append(truncated, lines[tail:]...)
+}
+
+// outputTruncated tells if output of lse.sh was truncated, in the container or by kubelse.
+func outputTruncated(lines []string) bool {
+	for _, line := range lines {
+		if strings.HasPrefix(line, truncationBanner) {
+			return true
+		}
+	}
+	return false
+}
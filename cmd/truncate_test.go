@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestTruncateCommandKeepsHeadTailAndExitCode(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	if _, err := exec.LookPath("awk"); err != nil {
+		t.Skip("requires awk")
+	}
+	maxReportSize = "1K"
+	t.Cleanup(func() { maxReportSize = "" })
+
+	command := truncateCommand([]string{"sh", "-s", "--", "-l", "1"})
+	run := exec.Command(command[0], command[1:]...)
+	run.Stdin = strings.NewReader(`echo "args: $*"; i=0; while [ $i -lt 1000 ]; do echo "line $i"; i=$((i+1)); done; echo FINISHED; exit 3`)
+	output, err := run.Output()
+	if exitErr, ok := err.(*exec.ExitError); !ok || exitErr.ExitCode() != 3 {
+		t.Fatalf("expected exit code 3, got %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(string(output), "\n"), "\n")
+	if len(output) > 1024+100 || lines[0] != "args: -l 1" || lines[len(lines)-1] != "FINISHED" || !outputTruncated(lines) {
+		t.Fatalf("unexpected output of %d bytes: %q ... %q", len(output), lines[0], lines[len(lines)-1])
+	}
+}
+
+func TestTruncateLines(t *testing.T) {
+	maxReportSize = "1K"
+	t.Cleanup(func() { maxReportSize = "" })
+
+	var lines []string
+	for i := 0; i < 1000; i++ {
+		lines = append(lines, fmt.Sprintf("line %d", i))
+	}
+	truncated := truncateLines(lines, 1024)
+	if truncated[0] != "line 0" || truncated[len(truncated)-1] != "line 999" || !outputTruncated(truncated) || len(strings.Join(truncated, "\n")) > 1024+100 {
+		t.Fatalf("unexpected truncated lines %v", truncated)
+	}
+	if short := truncateLines(lines[:10], 1024); len(short) != 10 || outputTruncated(short) {
+		t.Errorf("lines within the limit were truncated: %v", short)
+	}
+}
@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// lse.sh tuning CLI options variables
+var (
+	excludePaths         string
+	excludeNetworkMounts bool
+	lseEnv               string
+)
+
+// envNameRegexp matches names of environment variables, which can be set for lse.sh
+var envNameRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// networkMountsScript finds mount points of network and FUSE filesystems in /proc/mounts and excludes them from
+// searches of lse.sh, which otherwise walks them for as long as they are large, e.g. a PVC backed by NFS. Mount
+// points with escaped characters, e.g. spaces, cannot be excluded by lse.sh and are left out.
+const networkMountsScript = `mounts=
+while read -r _ mnt fstype _; do
+  case "$mnt" in *\\*) continue;; esac
+  case "$fstype" in
+    nfs|nfs4|cifs|smb3|smbfs|ceph|glusterfs|lustre|gpfs|beegfs|afs|9p|fuse|fuse.*) mounts="${mounts:+$mounts,}$mnt";;
+  esac
+done < /proc/mounts 2>/dev/null
+if [ -n "$mounts" ]; then
+  echo "kubelse: excluding network mounts $mounts"
+  set -- "$@" -e "$mounts"
+fi
+`
+
+// validateExcludePaths checks that paths excluded from searches of lse.sh are absolute, as lse.sh requires.
+func validateExcludePaths(value string) error {
+	for _, path := range untangleOption(value) {
+		if path = strings.TrimSpace(path); !strings.HasPrefix(path, "/") || strings.ContainsAny(path, " \t") {
+			return fmt.Errorf("invalid path %q, absolute paths without spaces are expected", path)
+		}
+	}
+	return nil
+}
+
+// parseLseEnv parses environment variables set for lse.sh given as comma-separated NAME=VALUE pairs.
+func parseLseEnv(value string) ([][2]string, error) {
+	var env [][2]string
+	for _, pair := range untangleOption(value) {
+		name, value, found := strings.Cut(strings.TrimSpace(pair), "=")
+		if !found || !envNameRegexp.MatchString(name) {
+			return nil, fmt.Errorf("invalid variable %q, NAME=VALUE is expected", pair)
+		}
+		env = append(env, [2]string{name, value})
+	}
+	return env, nil
+}
+
+// tuneCommand wraps a command running lse.sh, so that network mounts are excluded from its searches, if asked to,
+// and it runs with the environment given with '--lse-env'.
+func tuneCommand(command []string, excludeMounts bool) []string {
+	env, _ := parseLseEnv(lseEnv)
+	if !excludeMounts && len(env) == 0 {
+		return command
+	}
+
+	var script strings.Builder
+	for _, pair := range env {
+		fmt.Fprintf(&script, "export %s=%s\n", pair[0], quoteArgs([]string{pair[1]}))
+	}
+	if excludeMounts {
+		script.WriteString(networkMountsScript)
+	}
+	script.WriteString(`exec "$0" "$@"`)
+	wrapped := []string{command[0], "-c", script.String(), command[0]}
+	if len(command) == 1 {
+		return append(wrapped, "-s", "--")
+	}
+	return append(wrapped, command[1:]...)
+}
@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"os/exec"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestTuneCommandSetsEnvironment(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	lseEnv = "GREETING=hello 'world',LC_ALL=C"
+	t.Cleanup(func() { lseEnv = "" })
+
+	command := tuneCommand([]string{"sh", "-s", "--", "-c", "-l", "1"}, false)
+	run := exec.Command(command[0], command[1:]...)
+	run.Stdin = strings.NewReader(`echo "$GREETING $LC_ALL: $*"`)
+	output, err := run.Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "hello 'world' C: -c -l 1\n"; string(output) != expected {
+		t.Errorf("expected %q, got %q", expected, output)
+	}
+	if _, err := parseLseEnv("1NAME=x"); err == nil {
+		t.Error("an invalid variable name was accepted")
+	}
+}
+
+func TestExcludePathsAnnotationExtendsOption(t *testing.T) {
+	previous := format
+	excludePaths, format = "/data", "ansi"
+	t.Cleanup(func() { excludePaths, format = "", previous })
+
+	settings := settingsFor(Container{Pod: "web-1", Annotations: map[string]string{annotationExclude: "/mnt/nfs, /srv"}})
+	if settings.excludePaths != "/data,/mnt/nfs,/srv" {
+		t.Errorf("unexpected excluded paths %q", settings.excludePaths)
+	}
+	command := lseCommand(ContainerInfo{shell: "sh", settings: settings})
+	if strings.Join(command, " ") != "sh -s -- -e /data,/mnt/nfs,/srv" {
+		t.Errorf("unexpected command %q", command)
+	}
+	if err := validateExcludePaths("data"); err == nil {
+		t.Error("a relative path was accepted")
+	}
+}
@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"github.com/spf13/cobra"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// lse.sh releases are published as assets of GitHub releases, e.g. .../releases/download/4.14nw/lse.sh
+const lseReleaseURL = "https://github.com/diego-treitos/linux-smart-enumeration/releases/download/%s/lse.sh"
+
+// update-script CLI options variables
+var (
+	updateVersion string
+	updateSHA256  string
+	updateURL     string
+)
+
+// downloadScript downloads a script and checks it against an expected sha256 digest before returning it.
+func downloadScript(url string, digest string) ([]byte, error) {
+	client := &http.Client{Timeout: 60 * time.Second}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned %s", url, resp.Status)
+	}
+	// lse.sh is about 50kB, anything much larger is not it
+	script, err := io.ReadAll(io.LimitReader(resp.Body, 10<<20))
+	if err != nil {
+		return nil, err
+	}
+	if err := verifyDigest(script, digest); err != nil {
+		return nil, err
+	}
+	return script, nil
+}
+
+var updateScriptCmd = &cobra.Command{
+	Use:   "update-script --version <release> --sha256 <digest>",
+	Short: "Download a release of lse.sh to be used instead of the embedded one",
+	Long: `Downloads a release of lse.sh, verifies it against its sha256 digest and installs it in the payload cache,
+where it is used in preference to the lse.sh embedded in kubelse. Use 'kubelse payload reset' to go back to
+the embedded script.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := requireOnline("kubelse update-script"); err != nil {
+			return err
+		}
+		if updateVersion == "" && updateURL == "" {
+			return errors.New("A release has to be provided with '--version' or '--url'")
+		}
+		if updateSHA256 == "" {
+			return errors.New("The sha256 digest of the script has to be provided with '--sha256'")
+		}
+		url := updateURL
+		if url == "" {
+			url = fmt.Sprintf(lseReleaseURL, updateVersion)
+		}
+
+		log(fmt.Sprintf("[+] Downloading %s\n", url))
+		script, err := downloadScript(url, updateSHA256)
+		if err != nil {
+			return fmt.Errorf("[-] Error downloading lse.sh: %s\n", err.Error())
+		}
+		version := lseVersion(script)
+		if updateVersion != "" && version != "" && !strings.EqualFold(strings.TrimPrefix(updateVersion, "v"), version) {
+			log(fmt.Sprintf("[-] Downloaded script declares lse_version %s, not %s\n", version, updateVersion))
+		}
+		info, err := installPayload(script, url)
+		if err != nil {
+			return fmt.Errorf("[-] Error installing lse.sh: %s\n", err.Error())
+		}
+		log(fmt.Sprintf("[+] lse.sh %s (sha256 %s) will be used instead of the embedded one\n", valueOrDefault(info.Version, "of unknown version"), info.SHA256))
+		return nil
+	},
+}
+
+func init() {
+	updateScriptCmd.Flags().StringVar(&updateVersion, "version", "", "a release of lse.sh to download, e.g. 4.14nw")
+	updateScriptCmd.Flags().StringVar(&updateSHA256, "sha256", "", "the expected sha256 digest of the downloaded lse.sh")
+	updateScriptCmd.Flags().StringVar(&updateURL, "url", "", "download lse.sh from this URL instead of a GitHub release, e.g. from a mirror")
+
+	cmd.AddCommand(updateScriptCmd)
+}
@@ -0,0 +1,133 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/hhruszka/k8sexec"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// resource usage sampling CLI options variables
+var (
+	maxCPU        string
+	maxMemory     string
+	usageInterval time.Duration
+)
+
+// usageThresholds are CPU and memory usage of '--max-cpu' and '--max-memory', above which scans are aborted,
+// a threshold is not checked if it is not set
+var usageThresholds corev1.ResourceList
+
+// metricsUnavailable is set once the metrics API failed, so that its errors are logged once
+var metricsUnavailable atomic.Bool
+
+// overloadedMessage is the error of execs aborted for usage of the container over a threshold
+const overloadedMessage = "kubelse: %s, the exec was aborted to protect the workload"
+
+// validateUsageThresholds parses '--max-cpu' and '--max-memory'.
+func validateUsageThresholds() error {
+	usageThresholds = nil
+	for _, option := range []struct {
+		name, description, value string
+		resource                 corev1.ResourceName
+	}{{"--max-cpu", "CPU threshold", maxCPU, corev1.ResourceCPU}, {"--max-memory", "memory threshold", maxMemory, corev1.ResourceMemory}} {
+		if option.value == "" {
+			continue
+		}
+		quantity, err := resource.ParseQuantity(option.value)
+		if err != nil || quantity.Sign() <= 0 {
+			return fmt.Errorf("Invalid value of the %s option '%s': expected a positive quantity, e.g. 500m or 1Gi, got %q", option.description, option.name, option.value)
+		}
+		if usageThresholds == nil {
+			usageThresholds = make(corev1.ResourceList)
+		}
+		usageThresholds[option.resource] = quantity
+	}
+	if usageThresholds != nil && usageInterval <= 0 {
+		return errors.New("Invalid value of the usage interval option '--usage-interval'. It has to be positive")
+	}
+	return nil
+}
+
+// sampleUsage tells if usage of containers is sampled during scans.
+func sampleUsage() bool {
+	return usageThresholds != nil && replayFile == ""
+}
+
+// containerUsage reads CPU and memory usage of a container from the metrics API.
+func containerUsage(k8s *k8sexec.K8SExec, container Container) (corev1.ResourceList, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	content, err := clientsetOf(k8s).Discovery().RESTClient().Get().AbsPath("/apis/metrics.k8s.io/v1beta1", "namespaces", k8s.Namespace, "pods", container.Pod).DoRaw(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var metrics struct {
+		Containers []struct {
+			Name  string              `json:"name"`
+			Usage corev1.ResourceList `json:"usage"`
+		} `json:"containers"`
+	}
+	if err := json.Unmarshal(content, &metrics); err != nil {
+		return nil, err
+	}
+	for _, metric := range metrics.Containers {
+		if metric.Name == container.Container {
+			return metric.Usage, nil
+		}
+	}
+	return nil, fmt.Errorf("no metrics of container %s", container.Container)
+}
+
+// usageExceeded describes usage over its threshold, empty if no threshold is exceeded.
+func usageExceeded(usage corev1.ResourceList) string {
+	var exceeded []string
+	for _, option := range []struct {
+		name, label string
+		resource    corev1.ResourceName
+	}{{"--max-cpu", "CPU", corev1.ResourceCPU}, {"--max-memory", "Memory", corev1.ResourceMemory}} {
+		threshold, checked := usageThresholds[option.resource]
+		used, sampled := usage[option.resource]
+		if checked && sampled && used.Cmp(threshold) > 0 {
+			exceeded = append(exceeded, fmt.Sprintf("%s usage %s exceeds '%s' %s", option.label, used.String(), option.name, threshold.String()))
+		}
+	}
+	return strings.Join(exceeded, ", ")
+}
+
+// checkUsage samples usage of a container and describes usage over its threshold. A container without metrics is
+// not checked, errors of the metrics API are logged once.
+func checkUsage(k8s *k8sexec.K8SExec, container Container) string {
+	usage, err := containerUsage(k8s, container)
+	if err != nil {
+		if !metricsUnavailable.Swap(true) {
+			log(fmt.Sprintf("\n[-] Error reading usage of %s from the metrics API, containers without metrics are scanned without checking their usage: %s\n", container.String(), err.Error()))
+		}
+		return ""
+	}
+	return usageExceeded(usage)
+}
+
+// overloadedExec returns a status of an exec aborted for usage of the container over a threshold.
+func overloadedExec(container Container, exceeded string) *k8sexec.ExecutionStatus {
+	return k8sexec.NewExecutionStatus(container.Pod, container.Container, k8sexec.InternalAppError, fmt.Sprintf(overloadedMessage, exceeded), "", "")
+}
+
+// execOverloaded returns usage over a threshold, for which an exec was aborted, empty if it was not.
+func execOverloaded(execStatus *k8sexec.ExecutionStatus) string {
+	if execStatus == nil || len(execStatus.Error) == 0 {
+		return ""
+	}
+	prefix, suffix, _ := strings.Cut(overloadedMessage, "%s")
+	exceeded, ok := strings.CutPrefix(execStatus.Error[0], prefix)
+	if !ok {
+		return ""
+	}
+	return strings.TrimSuffix(exceeded, suffix)
+}
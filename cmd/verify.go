@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/spf13/pflag"
+	"os"
+	"strings"
+)
+
+// script verification CLI options variables
+var (
+	scriptDigest    string
+	scriptSignature string
+	scriptKey       string
+)
+
+// verifyDigest checks a script against a sha256 digest given as hex, optionally prefixed with sha256:.
+func verifyDigest(script []byte, digest string) error {
+	expected := strings.ToLower(strings.TrimPrefix(strings.TrimSpace(digest), "sha256:"))
+	sum := sha256.Sum256(script)
+	if actual := hex.EncodeToString(sum[:]); actual != expected {
+		return fmt.Errorf("sha256 digest %s does not match the expected %s", actual, expected)
+	}
+	return nil
+}
+
+// verifySignature checks a script against a signature created with `cosign sign-blob --key`, i.e. a base64
+// encoded signature of the script's sha256 digest, and a PEM encoded public key.
+func verifySignature(script []byte, signatureFile string, keyFile string) error {
+	encoded, err := os.ReadFile(signatureFile)
+	if err != nil {
+		return err
+	}
+	keyPEM, err := os.ReadFile(keyFile)
+	if err != nil {
+		return err
+	}
+	return verifySignatureWithKey(script, encoded, keyPEM, keyFile)
+}
+
+// verifySignatureWithKey checks a script against a base64 encoded signature and a PEM encoded public key, the key
+// is named in errors.
+func verifySignatureWithKey(script []byte, encoded []byte, keyPEM []byte, keyName string) error {
+	signature, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(encoded)))
+	if err != nil {
+		return fmt.Errorf("invalid signature: %s", err.Error())
+	}
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return fmt.Errorf("invalid public key %s: no PEM data found", keyName)
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return fmt.Errorf("invalid public key %s: %s", keyName, err.Error())
+	}
+
+	digest := sha256.Sum256(script)
+	var valid bool
+	switch key := key.(type) {
+	case *ecdsa.PublicKey:
+		valid = ecdsa.VerifyASN1(key, digest[:], signature)
+	case *rsa.PublicKey:
+		valid = rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature) == nil || rsa.VerifyPSS(key, crypto.SHA256, digest[:], signature, nil) == nil
+	case ed25519.PublicKey:
+		valid = ed25519.Verify(key, script, signature)
+	default:
+		return fmt.Errorf("unsupported public key type %T", key)
+	}
+	if !valid {
+		return errors.New("signature verification failed")
+	}
+	return nil
+}
+
+// loadScript reads a user-supplied script and verifies it against the digest and the signature, if provided,
+// before it can be sent into containers.
+func loadScript(path string) ([]byte, error) {
+	script, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading script %s: %s\n", path, err.Error())
+	}
+	if (scriptSignature == "") != (scriptKey == "") {
+		return nil, errors.New("A signature '--script-signature' has to be provided together with a public key '--script-key'")
+	}
+	if scriptDigest != "" {
+		if err := verifyDigest(script, scriptDigest); err != nil {
+			return nil, fmt.Errorf("[-] Script %s failed verification: %s\n", path, err.Error())
+		}
+		log(fmt.Sprintf("[+] Script %s matches sha256 digest\n", path))
+	}
+	if scriptSignature != "" {
+		if err := verifySignature(script, scriptSignature, scriptKey); err != nil {
+			return nil, fmt.Errorf("[-] Script %s failed verification: %s\n", path, err.Error())
+		}
+		log(fmt.Sprintf("[+] Script %s signature verified with %s\n", path, scriptKey))
+	}
+	return script, nil
+}
+
+// validateScriptVerification rejects verification options given without a script, the option of which is named,
+// since the embedded or cached payload would run unverified.
+func validateScriptVerification(script string, option string) error {
+	if script == "" && (scriptDigest != "" || scriptSignature != "" || scriptKey != "") {
+		return fmt.Errorf("The options '--script-sha256', '--script-signature' and '--script-key' verify only a script provided with '%s'", option)
+	}
+	return nil
+}
+
+// addScriptVerificationFlags adds options verifying user-supplied scripts.
+func addScriptVerificationFlags(flags *pflag.FlagSet) {
+	flags.StringVar(&scriptDigest, "script-sha256", "", "an expected sha256 digest of the script, the script is not run if it does not match")
+	flags.StringVar(&scriptSignature, "script-signature", "", "a base64 signature of the script created with 'cosign sign-blob --key', verified with '--script-key'")
+	flags.StringVar(&scriptKey, "script-key", "", "a PEM public key, e.g. cosign.pub, verifying '--script-signature'")
+}
@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestVerifyScript(t *testing.T) {
+	dir := t.TempDir()
+	script := []byte("#!/bin/sh\nid\n")
+	tampered := []byte("#!/bin/sh\nid; curl evil\n")
+
+	sum := sha256.Sum256(script)
+	if err := verifyDigest(script, "sha256:"+hex.EncodeToString(sum[:])); err != nil {
+		t.Error(err)
+	}
+	if err := verifyDigest(tampered, hex.EncodeToString(sum[:])); err == nil {
+		t.Error("tampered script matched the digest")
+	}
+
+	// the same signature as created with cosign sign-blob
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature, err := ecdsa.SignASN1(rand.Reader, key, sum[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicKey, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signatureFile, keyFile := filepath.Join(dir, "script.sig"), filepath.Join(dir, "cosign.pub")
+	os.WriteFile(signatureFile, []byte(base64.StdEncoding.EncodeToString(signature)), 0666)
+	os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicKey}), 0666)
+
+	if err := verifySignature(script, signatureFile, keyFile); err != nil {
+		t.Error(err)
+	}
+	if err := verifySignature(tampered, signatureFile, keyFile); err == nil {
+		t.Error("tampered script passed signature verification")
+	}
+}
+
+func TestScriptVerificationRequiresScript(t *testing.T) {
+	scriptDigest = "sha256:00"
+	defer func() { scriptDigest = "" }()
+	if err := validateScriptVerification("", "--script"); err == nil {
+		t.Error("expected a digest without a script to be rejected")
+	}
+	if err := validateScriptVerification("lse.sh", "--script"); err != nil {
+		t.Error(err)
+	}
+}
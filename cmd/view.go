@@ -0,0 +1,403 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// sgrRegexp matches SGR escape sequences, i.e. colors and text attributes, the only sequences the viewer passes
+// through to the terminal
+var sgrRegexp = regexp.MustCompile(`^\x1b\[[0-9;]*m`)
+
+// escapeRegexp matches other CSI escape sequences, e.g. cursor movements, which are dropped
+var escapeRegexp = regexp.MustCompile(`^\x1b\[[0-9;?]*[@-~]`)
+
+// viewedReport is a report listed in the container picker of the viewer.
+type viewedReport struct {
+	name   string
+	report Report
+}
+
+// viewer is the state of the report viewer: the container picker or a report being paged through.
+type viewer struct {
+	reports []viewedReport
+	// current is the report shown, -1 when the container picker is shown
+	current int
+	cursor  int
+	top     int
+	lines   []string
+	query   string
+	// match is a line, or picker entry, of the latest search match, -1 if there is none
+	match int
+	// input is a search query being typed, nil unless the user is typing one
+	input  *string
+	width  int
+	height int
+}
+
+// newViewer creates a viewer of reports grouped by runs, the container picker is shown first.
+func newViewer(runs map[string][]Report) *viewer {
+	v := &viewer{current: -1, match: -1, width: 80, height: 24}
+	for run, reports := range runs {
+		for _, report := range reports {
+			v.reports = append(v.reports, viewedReport{name: filepath.Join(run, report.Pod(), report.Container()), report: report})
+		}
+	}
+	sort.Slice(v.reports, func(i, j int) bool { return v.reports[i].name < v.reports[j].name })
+	return v
+}
+
+// rawReportLines returns lines of a report with colors of lse.sh, which are stripped by parseReport, if the report
+// was saved with them.
+func rawReportLines(report Report) []string {
+	switch filepath.Ext(report.Path) {
+	case ".ansi", ".text", ".plain":
+		if content, err := os.ReadFile(report.Path); err == nil {
+			return strings.Split(strings.TrimRight(string(content), "\n"), "\n")
+		}
+	}
+	return report.Lines
+}
+
+// displayLine cuts a line to width visible characters keeping its colors. Other escape sequences and control
+// characters, which could move the cursor, are dropped and attributes are reset at the end of the line.
+func displayLine(line string, width int) string {
+	var (
+		out     strings.Builder
+		visible int
+	)
+	for len(line) > 0 && visible < width {
+		if line[0] == '\x1b' {
+			if sgr := sgrRegexp.FindString(line); sgr != "" {
+				out.WriteString(sgr)
+				line = line[len(sgr):]
+			} else if escape := escapeRegexp.FindString(line); escape != "" {
+				line = line[len(escape):]
+			} else {
+				line = line[1:]
+			}
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(line)
+		line = line[size:]
+		switch {
+		case r == '\t':
+			spaces := min(8-visible%8, width-visible)
+			out.WriteString(strings.Repeat(" ", spaces))
+			visible += spaces
+		case r < 0x20 || r == 0x7f:
+		default:
+			out.WriteRune(r)
+			visible++
+		}
+	}
+	out.WriteString("\x1b[0m")
+	return out.String()
+}
+
+// pageSize returns the number of lines shown at once, the last line of the terminal is the status bar.
+func (v *viewer) pageSize() int {
+	return max(v.height-1, 1)
+}
+
+// entries returns lines currently paged through: picker entries or lines of the report.
+func (v *viewer) entries() []string {
+	if v.current >= 0 {
+		return v.lines
+	}
+	names := make([]string, 0, len(v.reports))
+	for _, report := range v.reports {
+		counts := report.report.CountBySeverity()
+		names = append(names, fmt.Sprintf("%-60s %3d critical %3d interesting", report.name, counts[SeverityCritical], counts[SeverityInteresting]))
+	}
+	return names
+}
+
+// scroll moves the view by delta lines, or the picker cursor by delta entries, within bounds.
+func (v *viewer) scroll(delta int) {
+	last := len(v.entries()) - 1
+	if v.current < 0 {
+		v.cursor = max(min(v.cursor+delta, last), 0)
+		if v.cursor < v.top {
+			v.top = v.cursor
+		} else if v.cursor >= v.top+v.pageSize() {
+			v.top = v.cursor - v.pageSize() + 1
+		}
+		return
+	}
+	v.top = max(min(v.top+delta, last-v.pageSize()+1), 0)
+}
+
+// open shows a report, or the container picker if idx is -1.
+func (v *viewer) open(idx int) {
+	v.match = -1
+	if idx < 0 || idx >= len(v.reports) {
+		v.current, v.lines, v.top = -1, nil, 0
+		v.scroll(0)
+		return
+	}
+	v.current, v.cursor, v.top = idx, idx, 0
+	v.lines = rawReportLines(v.reports[idx].report)
+}
+
+// search jumps to the next, or previous if backwards, line matching the query case-insensitively, the search wraps
+// around the end.
+func (v *viewer) search(backwards bool) {
+	entries := v.entries()
+	if v.query == "" || len(entries) == 0 {
+		return
+	}
+	query := strings.ToLower(v.query)
+	start := v.top
+	if v.current < 0 {
+		start = v.cursor
+	}
+	if v.match >= 0 {
+		start = v.match
+	}
+	for step := 1; step <= len(entries); step++ {
+		idx := (start + step) % len(entries)
+		if backwards {
+			idx = ((start-step)%len(entries) + len(entries)) % len(entries)
+		}
+		if strings.Contains(strings.ToLower(stripANSI(entries[idx])), query) {
+			v.match = idx
+			if v.current < 0 {
+				v.scroll(idx - v.cursor)
+			} else {
+				v.top = 0
+				v.scroll(idx - v.pageSize()/2)
+			}
+			return
+		}
+	}
+	v.match = -1
+}
+
+// handle applies a key pressed by the user, it returns false when the viewer is to be closed.
+func (v *viewer) handle(key string) bool {
+	if v.input != nil {
+		switch key {
+		case "enter":
+			v.query, v.input, v.match = *v.input, nil, -1
+			v.search(false)
+		case "esc":
+			v.input = nil
+		case "backspace":
+			if query := *v.input; query != "" {
+				_, size := utf8.DecodeLastRuneInString(query)
+				*v.input = query[:len(query)-size]
+			}
+		default:
+			if utf8.RuneCountInString(key) == 1 {
+				*v.input += key
+			}
+		}
+		return true
+	}
+
+	switch key {
+	case "q", "ctrl-c":
+		return false
+	case "j", "down":
+		v.scroll(1)
+	case "k", "up":
+		v.scroll(-1)
+	case " ", "f", "pgdn":
+		v.scroll(v.pageSize())
+	case "b", "pgup":
+		v.scroll(-v.pageSize())
+	case "g", "home":
+		v.scroll(-len(v.entries()))
+	case "G", "end":
+		v.scroll(len(v.entries()))
+	case "/":
+		input := ""
+		v.input = &input
+	case "n":
+		v.search(false)
+	case "N":
+		v.search(true)
+	case "enter":
+		if v.current < 0 && len(v.reports) > 0 {
+			v.open(v.cursor)
+		}
+	case "p", "tab", "esc":
+		if v.current >= 0 {
+			v.open(-1)
+		}
+	case "]":
+		if v.current >= 0 && v.current+1 < len(v.reports) {
+			v.open(v.current + 1)
+		}
+	case "[":
+		if v.current > 0 {
+			v.open(v.current - 1)
+		}
+	}
+	return true
+}
+
+// render returns lines of the screen: a page of the picker or the report followed by the status bar.
+func (v *viewer) render() []string {
+	entries := v.entries()
+	screen := make([]string, 0, v.height)
+	for idx := v.top; idx < v.top+v.pageSize(); idx++ {
+		if idx >= len(entries) {
+			screen = append(screen, "~")
+			continue
+		}
+		line := displayLine(entries[idx], v.width)
+		if (v.current < 0 && idx == v.cursor) || idx == v.match {
+			// reverse video marks the picker cursor and the search match
+			line = "\x1b[7m" + displayLine(stripANSI(entries[idx]), v.width)
+		}
+		screen = append(screen, line)
+	}
+
+	var status string
+	switch {
+	case v.input != nil:
+		status = "/" + *v.input
+	case v.current < 0:
+		status = fmt.Sprintf("%d reports | enter: open  /: search  n/N: next/previous match  q: quit", len(v.reports))
+	default:
+		last := min(v.top+v.pageSize(), len(entries))
+		status = fmt.Sprintf("%s | lines %d-%d/%d | /: search  n/N: match  [/]: previous/next  p: containers  q: quit", v.reports[v.current].name, min(v.top+1, last), last, len(entries))
+	}
+	if v.query != "" && v.input == nil && v.match < 0 {
+		status = fmt.Sprintf("pattern %q not found | %s", v.query, status)
+	}
+	return append(screen, "\x1b[7m"+displayLine(status, v.width))
+}
+
+// readKey reads a key pressed in a raw mode terminal and names special keys, e.g. up or pgdn.
+func readKey(r *bufio.Reader) (string, error) {
+	c, _, err := r.ReadRune()
+	if err != nil {
+		return "", err
+	}
+	switch c {
+	case '\r', '\n':
+		return "enter", nil
+	case '\t':
+		return "tab", nil
+	case 0x7f, 0x08:
+		return "backspace", nil
+	case 0x03:
+		return "ctrl-c", nil
+	case 0x1b:
+		// a lone escape is not followed by anything already buffered
+		if r.Buffered() == 0 {
+			return "esc", nil
+		}
+		sequence, _ := r.ReadByte()
+		if sequence != '[' && sequence != 'O' {
+			return "esc", nil
+		}
+		var params []byte
+		for {
+			b, err := r.ReadByte()
+			if err != nil {
+				return "esc", nil
+			}
+			if b >= 0x40 && b <= 0x7e {
+				params = append(params, b)
+				break
+			}
+			params = append(params, b)
+		}
+		keys := map[string]string{"A": "up", "B": "down", "H": "home", "F": "end", "5~": "pgup", "6~": "pgdn", "1~": "home", "4~": "end"}
+		return keys[string(params)], nil
+	}
+	return string(c), nil
+}
+
+// terminalScreen is a full screen terminal mode, e.g. the report viewer or the triage mode.
+type terminalScreen interface {
+	handle(key string) bool
+	render() []string
+	resize(width int, height int)
+}
+
+// resize sets the size of the terminal.
+func (v *viewer) resize(width int, height int) {
+	v.width, v.height = width, height
+}
+
+// runViewer runs the viewer, or another full screen mode, in the terminal until the user quits, the alternate screen is used, so that
+// the terminal is left as it was.
+func runViewer(v terminalScreen, in *os.File, out io.Writer) error {
+	fd := int(in.Fd())
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return err
+	}
+	defer term.Restore(fd, state)
+	fmt.Fprint(out, "\x1b[?1049h\x1b[?25l")
+	defer fmt.Fprint(out, "\x1b[?25h\x1b[?1049l")
+
+	keys := bufio.NewReader(in)
+	for {
+		if width, height, err := term.GetSize(fd); err == nil {
+			v.resize(width, height)
+		}
+		fmt.Fprint(out, "\x1b[H"+strings.Join(v.render(), "\x1b[K\r\n")+"\x1b[K")
+		key, err := readKey(keys)
+		if err != nil {
+			return err
+		}
+		if !v.handle(key) {
+			return nil
+		}
+	}
+}
+
+var viewCmd = &cobra.Command{
+	Use:   "view [directory]",
+	Short: "Browse saved scan reports in the terminal",
+	Long: `
+Opens a terminal viewer of scan reports saved in a directory, the current directory by default. Reports are
+picked from a list of scanned containers and paged through with lse.sh colors preserved, they can be searched
+with '/'.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir, err := os.Getwd()
+		if err != nil {
+			return err
+		}
+		if len(args) > 0 {
+			dir = args[0]
+		}
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			return fmt.Errorf("Reports directory %q does not exist\n", dir)
+		}
+		if !term.IsTerminal(int(os.Stdin.Fd())) || !term.IsTerminal(int(os.Stdout.Fd())) {
+			return errors.New("The view command requires a terminal, use 'kubelse grep' or 'less -R' to read reports otherwise")
+		}
+
+		runs, err := loadReports(dir)
+		if err != nil {
+			return err
+		}
+		v := newViewer(runs)
+		if len(v.reports) == 0 {
+			return fmt.Errorf("[-] No scan reports found in %s\n", dir)
+		}
+		return runViewer(v, os.Stdin, os.Stdout)
+	},
+}
+
+func init() {
+	cmd.AddCommand(viewCmd)
+}
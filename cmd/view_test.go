@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestViewerPicksPagesAndSearchesReports(t *testing.T) {
+	dir := t.TempDir()
+	for _, pod := range []string{"web-1", "web-2"} {
+		lines := []string{"==========( kubelse )==========", "Pod: " + pod, "Container: app", ""}
+		lines = append(lines, "=====( file system )=====", "\x1b[1;31m[!] fst010 Can we write to /etc/passwd?....... yes!\x1b[0m", "---", "-rw-rw-rw- /etc/passwd", "---")
+		for idx := 0; idx < 50; idx++ {
+			lines = append(lines, "filler")
+		}
+		lines = append(lines, "needle in "+pod)
+		if err := os.WriteFile(filepath.Join(dir, pod+".ansi"), []byte(strings.Join(lines, "\n")), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	runs, err := loadReports(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v := newViewer(runs)
+	v.height = 10
+	if screen := v.render(); len(screen) != 10 || !strings.Contains(screen[0], "web-1/app") || !strings.Contains(screen[0], "1 critical") {
+		t.Fatalf("expected the picker to list containers, got %q", screen)
+	}
+	for _, key := range []string{"down", "enter"} {
+		v.handle(key)
+	}
+	if v.current != 1 || !strings.Contains(v.render()[5], "\x1b[1;31m[!] fst010") {
+		t.Fatalf("expected web-2 to be shown with colors, got %q", v.render())
+	}
+	for _, key := range []string{"/", "N", "E", "e", "d", "l", "e", "enter"} {
+		v.handle(key)
+	}
+	if v.match != 59 || v.top > v.match || v.match >= v.top+v.pageSize() {
+		t.Errorf("expected the view to jump to the match, got match %d at top %d", v.match, v.top)
+	}
+	if v.handle("["); v.current != 0 || v.handle("q") {
+		t.Errorf("expected the previous report to be shown and the viewer to quit")
+	}
+}
+
+func TestDisplayLineKeepsColorsOnly(t *testing.T) {
+	if line := displayLine("\x1b[32mgreen\x1b[0m\x1b[2Jtail\r", 7); line != "\x1b[32mgreen\x1b[0mta\x1b[0m" {
+		t.Errorf("unexpected line %q", line)
+	}
+	keys := bufio.NewReader(strings.NewReader("\x1b[A\x1b[6~q"))
+	for _, expected := range []string{"up", "pgdn", "q"} {
+		if key, err := readKey(keys); err != nil || key != expected {
+			t.Errorf("expected %s, got %q (%v)", expected, key, err)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"github.com/hhruszka/k8sexec"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// watch mode CLI options variables
+var (
+	watch         bool
+	watchInterval time.Duration
+)
+
+// podReady tells if a pod is running and ready.
+func podReady(pod corev1.Pod) bool {
+	if pod.Status.Phase != corev1.PodRunning || pod.DeletionTimestamp != nil {
+		return false
+	}
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// watchedPods are pods, which were already considered for a scan, by namespace, name and UID, so that a pod
+// recreated with the same name is scanned again.
+type watchedPods map[string]bool
+
+// watchedPod returns a key of a pod in watched pods.
+func watchedPod(ns string, name string, uid types.UID) string {
+	return fmt.Sprintf("%s/%s/%s", ns, name, uid)
+}
+
+// newWatchedPods returns watched pods seen already, the pods of containers found at the start.
+func newWatchedPods(ns string, containers []Container) watchedPods {
+	seen := make(watchedPods)
+	for _, container := range containers {
+		seen[watchedPod(ns, container.Pod, container.UID)] = true
+	}
+	return seen
+}
+
+// newPods returns names of ready pods, which were not seen yet, and marks them seen.
+func (w watchedPods) newPods(ns string, pods []corev1.Pod) []string {
+	var names []string
+	for _, pod := range pods {
+		key := watchedPod(ns, pod.Name, pod.UID)
+		if w[key] || !podReady(pod) {
+			continue
+		}
+		w[key] = true
+		names = append(names, pod.Name)
+	}
+	return names
+}
+
+// scanNewPods scans containers of pods, which became ready since the previous check, in a run of their own. It
+// returns an empty manifest if there were no new pods to scan.
+func scanNewPods(k8s *k8sexec.K8SExec, seen watchedPods) (Manifest, error) {
+	pods, err := getPods(k8s)
+	if err != nil {
+		return Manifest{}, err
+	}
+	names := seen.newPods(k8s.Namespace, pods)
+	if len(names) == 0 {
+		return Manifest{}, nil
+	}
+	containers, err := getContainers(k8s, names, nil)
+	if err != nil || len(containers) == 0 {
+		return Manifest{}, err
+	}
+	log(fmt.Sprintf("[+] New pods are ready: %s\n", strings.Join(names, ", ")))
+	startRun(k8s)
+	return scanContainers(k8s, containers)
+}
+
+// watchPods scans containers found at the start and then keeps scanning pods matching the selection, as they
+// become ready, every '--watch-interval' until kubelse is stopped with SIGINT or SIGTERM, so that short-lived pods,
+// e.g. CI runners or pods of cron jobs, are covered too. A run in progress is cancelled, when watching stops.
+func watchPods(k8s *k8sexec.K8SExec, containers []Container) (Manifest, error) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	seen := newWatchedPods(k8s.Namespace, containers)
+	if len(containers) > 0 {
+		if _, err := scanContainers(k8s, containers); err != nil {
+			log(fmt.Sprintf("[-] Scanning containers failed: %s\n", strings.TrimSpace(err.Error())))
+		}
+	}
+
+	// pods appearing later are scanned without confirmations
+	interactive = false
+	log(fmt.Sprintf("[+] Watching for new pods in %s namespace every %s\n", k8s.Namespace, watchInterval))
+	for {
+		select {
+		case <-ctx.Done():
+			log(fmt.Sprintln("[+] Watching stopped"))
+			return Manifest{}, nil
+		case <-time.After(watchInterval):
+		}
+		if _, err := scanNewPods(k8s, seen); err != nil {
+			log(fmt.Sprintf("[-] Scanning new pods failed: %s\n", strings.TrimSpace(err.Error())))
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maintenanceWindow is a recurring period of time, in which containers can be scanned, e.g. "Sat 01:00-05:00 UTC".
+type maintenanceWindow struct {
+	// days the window starts on, every day if empty
+	days map[time.Weekday]bool
+	// start and end of the window in minutes after midnight, the window spans midnight if end is before start
+	start, end int
+	location   *time.Location
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// scanWindow is the maintenance window provided with the window option, scans are not limited if it is nil
+var scanWindow *maintenanceWindow
+
+// parseClock parses HH:MM into minutes after midnight.
+func parseClock(clock string) (int, error) {
+	t, err := time.Parse("15:04", clock)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", clock)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// parseWindow parses a maintenance window "[days] HH:MM-HH:MM [timezone]", where days are comma-separated days
+// or ranges of days, e.g. Sat,Sun or Mon-Fri, and the timezone is an IANA name, UTC if not provided.
+func parseWindow(window string) (*maintenanceWindow, error) {
+	fields := strings.Fields(window)
+	w := &maintenanceWindow{days: make(map[time.Weekday]bool), location: time.UTC}
+
+	idx := 0
+	if len(fields) > 0 && !strings.Contains(fields[0], ":") {
+		for _, days := range strings.Split(fields[0], ",") {
+			first, last, isRange := strings.Cut(strings.ToLower(days), "-")
+			from, ok := weekdays[first]
+			if !ok {
+				return nil, fmt.Errorf("invalid day %q", first)
+			}
+			to := from
+			if isRange {
+				if to, ok = weekdays[last]; !ok {
+					return nil, fmt.Errorf("invalid day %q", last)
+				}
+			}
+			for day := from; ; day = (day + 1) % 7 {
+				w.days[day] = true
+				if day == to {
+					break
+				}
+			}
+		}
+		idx++
+	}
+	if idx >= len(fields) {
+		return nil, fmt.Errorf("missing time range HH:MM-HH:MM")
+	}
+
+	from, to, found := strings.Cut(fields[idx], "-")
+	if !found {
+		return nil, fmt.Errorf("invalid time range %q, expected HH:MM-HH:MM", fields[idx])
+	}
+	var err error
+	if w.start, err = parseClock(from); err != nil {
+		return nil, err
+	}
+	if w.end, err = parseClock(to); err != nil {
+		return nil, err
+	}
+	if w.start == w.end {
+		return nil, fmt.Errorf("empty time range %q", fields[idx])
+	}
+	idx++
+
+	if idx < len(fields) {
+		if w.location, err = time.LoadLocation(fields[idx]); err != nil {
+			return nil, fmt.Errorf("invalid timezone %q", fields[idx])
+		}
+		idx++
+	}
+	if idx < len(fields) {
+		return nil, fmt.Errorf("unexpected %q", strings.Join(fields[idx:], " "))
+	}
+	return w, nil
+}
+
+// startsOn tells if the window starts on a given day.
+func (w *maintenanceWindow) startsOn(day time.Weekday) bool {
+	return len(w.days) == 0 || w.days[day]
+}
+
+// open tells if the window is open at a given time.
+func (w *maintenanceWindow) open(t time.Time) bool {
+	t = t.In(w.location)
+	minute := t.Hour()*60 + t.Minute()
+	if w.start < w.end {
+		return w.startsOn(t.Weekday()) && minute >= w.start && minute < w.end
+	}
+	// the window spans midnight
+	return (w.startsOn(t.Weekday()) && minute >= w.start) || (w.startsOn(t.AddDate(0, 0, -1).Weekday()) && minute < w.end)
+}
+
+// next returns when the window opens next time after a given time.
+func (w *maintenanceWindow) next(t time.Time) time.Time {
+	t = t.In(w.location)
+	for day := 0; day <= 7; day++ {
+		date := t.AddDate(0, 0, day)
+		start := time.Date(date.Year(), date.Month(), date.Day(), w.start/60, w.start%60, 0, 0, w.location)
+		if start.After(t) && w.startsOn(start.Weekday()) {
+			return start
+		}
+	}
+	return t
+}
+
+var windowMu sync.Mutex
+
+// waitForWindow blocks until the maintenance window is open. Workers wait for the window one by one, so that
+// pausing and resuming is logged once.
+func waitForWindow() {
+	if scanWindow == nil {
+		return
+	}
+	windowMu.Lock()
+	defer windowMu.Unlock()
+
+	now := time.Now()
+	if scanWindow.open(now) {
+		return
+	}
+	next := scanWindow.next(now)
+	log(fmt.Sprintf("\n[*] Maintenance window is closed, pausing scans until %s\n", next.Format(time.RFC1123)))
+	time.Sleep(time.Until(next))
+	log(fmt.Sprintln("[*] Maintenance window is open, resuming scans"))
+}
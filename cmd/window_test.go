@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMaintenanceWindow(t *testing.T) {
+	// 2024-06-01 is Saturday
+	at := func(value string) time.Time {
+		parsed, err := time.Parse("2006-01-02 15:04 MST", value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return parsed
+	}
+
+	w, err := parseWindow("Sat 01:00-05:00 UTC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		time string
+		open bool
+	}{
+		{"2024-06-01 01:00 UTC", true},
+		{"2024-06-01 04:59 UTC", true},
+		{"2024-06-01 05:00 UTC", false},
+		{"2024-06-02 02:00 UTC", false},
+	} {
+		if open := w.open(at(tc.time)); open != tc.open {
+			t.Errorf("%s: expected open=%t", tc.time, tc.open)
+		}
+	}
+	if next := w.next(at("2024-06-01 06:00 UTC")); !next.Equal(at("2024-06-08 01:00 UTC")) {
+		t.Errorf("expected the window to open next Saturday, got %s", next)
+	}
+
+	overnight, err := parseWindow("Fri-Sat 23:00-02:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !overnight.open(at("2024-06-02 01:00 UTC")) || overnight.open(at("2024-06-03 01:00 UTC")) {
+		t.Errorf("window spanning midnight should be open after Saturday midnight only")
+	}
+
+	for _, invalid := range []string{"", "Sat", "Sat 01:00", "Foo 01:00-02:00", "01:00-01:00", "01:00-02:00 Mars/Base"} {
+		if _, err := parseWindow(invalid); err == nil {
+			t.Errorf("expected %q to be invalid", invalid)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package cmd
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"strings"
+)
+
+// workloadOf returns a kind/name identifier of a workload a pod belongs to, e.g. Deployment/nginx. Pods created
+// by deployments are owned by replica sets, which names are the deployment's name followed by the pod template
+// hash. Pods without an owner are workloads themselves.
+func workloadOf(pod corev1.Pod) string {
+	for _, owner := range pod.OwnerReferences {
+		if owner.Controller == nil || !*owner.Controller {
+			continue
+		}
+		if owner.Kind == "ReplicaSet" {
+			if hash, ok := pod.Labels["pod-template-hash"]; ok && strings.HasSuffix(owner.Name, "-"+hash) {
+				return "Deployment/" + strings.TrimSuffix(owner.Name, "-"+hash)
+			}
+		}
+		return owner.Kind + "/" + owner.Name
+	}
+	return "Pod/" + pod.Name
+}
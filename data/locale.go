@@ -0,0 +1,34 @@
+package data
+
+import (
+	"embed"
+	"io/fs"
+	"strings"
+)
+
+//go:embed locales/*.yaml
+var locales embed.FS
+
+// Languages returns languages tool-generated text can be translated into, besides English.
+func Languages() []string {
+	entries, _ := fs.Glob(locales, "locales/messages.*.yaml")
+	var languages []string
+	for _, entry := range entries {
+		languages = append(languages, strings.TrimSuffix(strings.TrimPrefix(entry, "locales/messages."), ".yaml"))
+	}
+	return languages
+}
+
+// GetMessages returns translations of tool-generated text into a language, keyed by the English text, or nil
+// if there are none.
+func GetMessages(language string) []byte {
+	content, _ := locales.ReadFile("locales/messages." + language + ".yaml")
+	return content
+}
+
+// GetLocalizedRemediation returns remediation guidance of lse.sh tests translated into a language, or nil if there
+// is none. Tests without a translation keep the English guidance.
+func GetLocalizedRemediation(language string) []byte {
+	content, _ := locales.ReadFile("locales/remediation." + language + ".yaml")
+	return content
+}
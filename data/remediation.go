@@ -0,0 +1,11 @@
+package data
+
+import _ "embed"
+
+//go:embed remediation.yaml
+var remediation []byte
+
+// GetRemediation returns the catalog of remediation guidance of lse.sh tests.
+func GetRemediation() []byte {
+	return remediation
+}
@@ -0,0 +1,324 @@
+// Package fakecluster provides a fake Kubernetes cluster for end-to-end tests of kubelse without a live cluster.
+// The API is served over HTTP from a fake clientset, so that the real client code is exercised, and execs in
+// containers are emulated by FakeContainer.
+package fakecluster
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/hhruszka/k8sexec"
+	appsV1 "k8s.io/api/apps/v1"
+	authorizationV1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingV1 "k8s.io/api/networking/v1"
+	policyV1 "k8s.io/api/policy/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/version"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// FakeContainer describes how a container responds to commands run by kubelse.
+type FakeContainer struct {
+	// Shell is a shell available in the container, sh or bash, the container is not testable if it is empty
+	Shell string
+	// OSRelease are lines of /etc/os-release
+	OSRelease []string
+	// PkgManager is a package manager available in the container, e.g. dpkg
+	PkgManager string
+	// ReadOnlyRoot tells if the root filesystem is mounted read-only
+	ReadOnlyRoot bool
+	// LseOutput is output of lse.sh, LseRetCode its exit code
+	LseOutput  []string
+	LseRetCode k8sexec.ExitCode
+	// LseDuration is how long lse.sh runs
+	LseDuration time.Duration
+}
+
+// Cluster is a fake cluster.
+type Cluster struct {
+	Clientset  *fake.Clientset
+	Containers map[string]FakeContainer
+	Version    string
+	// Permissions are verbs on resources allowed by SubjectAccessReviews of any user, e.g. "list secrets" or
+	// "create pods/exec", everything else is denied
+	Permissions map[string]bool
+
+	server    *httptest.Server
+	generated atomic.Int64
+	usageMu   sync.Mutex
+	usage     map[string]corev1.ResourceList
+}
+
+// New creates a fake cluster with given objects, e.g. pods and namespaces.
+func New(objects ...runtime.Object) *Cluster {
+	return &Cluster{
+		Clientset:   fake.NewSimpleClientset(objects...),
+		Containers:  make(map[string]FakeContainer),
+		Version:     "v1.29.3",
+		Permissions: make(map[string]bool),
+	}
+}
+
+// SetContainer sets how a container of a pod responds to commands.
+func (c *Cluster) SetContainer(pod string, container string, fakeContainer FakeContainer) {
+	c.Containers[pod+"/"+container] = fakeContainer
+}
+
+// SetUsage sets CPU and memory usage of a container of a pod served by the metrics API, e.g. 250m and 64Mi.
+func (c *Cluster) SetUsage(pod string, container string, cpu string, memory string) {
+	c.usageMu.Lock()
+	defer c.usageMu.Unlock()
+	if c.usage == nil {
+		c.usage = make(map[string]corev1.ResourceList)
+	}
+	c.usage[pod+"/"+container] = corev1.ResourceList{corev1.ResourceCPU: resource.MustParse(cpu), corev1.ResourceMemory: resource.MustParse(memory)}
+}
+
+// podMetrics returns usage of containers of a pod as the metrics API does, or nil if the pod has no metrics.
+func (c *Cluster) podMetrics(namespace string, pod string) map[string]interface{} {
+	c.usageMu.Lock()
+	defer c.usageMu.Unlock()
+	var containers []map[string]interface{}
+	for key, usage := range c.usage {
+		if name, found := strings.CutPrefix(key, pod+"/"); found {
+			containers = append(containers, map[string]interface{}{"name": name, "usage": usage})
+		}
+	}
+	if containers == nil {
+		return nil
+	}
+	return map[string]interface{}{
+		"kind": "PodMetrics", "apiVersion": "metrics.k8s.io/v1beta1",
+		"metadata": map[string]string{"name": pod, "namespace": namespace}, "containers": containers,
+	}
+}
+
+// Start starts serving the API of the cluster, Close has to be called when the cluster is not needed anymore.
+func (c *Cluster) Start() {
+	c.server = httptest.NewServer(http.HandlerFunc(c.serve))
+}
+
+// Close stops serving the API of the cluster.
+func (c *Cluster) Close() {
+	if c.server != nil {
+		c.server.Close()
+	}
+}
+
+// Client returns a kubelse client of the cluster for a namespace.
+func (c *Cluster) Client(namespace string) (*k8sexec.K8SExec, error) {
+	config := &rest.Config{Host: c.server.URL}
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	return &k8sexec.K8SExec{Config: config, Clientset: clientset, Namespace: namespace}, nil
+}
+
+// Exec emulates running a command in a container.
+func (c *Cluster) Exec(pod string, container string, args []string, stdin []byte) *k8sexec.ExecutionStatus {
+	fakeContainer, ok := c.Containers[pod+"/"+container]
+	if !ok {
+		return k8sexec.NewExecutionStatus(pod, container, k8sexec.InternalAppError, "container not found", "", "")
+	}
+	notFound := k8sexec.NewExecutionStatus(pod, container, k8sexec.CommandNotFound, "command terminated with exit code 127", "", "")
+	success := func(stdout []string) *k8sexec.ExecutionStatus {
+		return k8sexec.NewExecutionStatus(pod, container, k8sexec.Success, "", strings.Join(stdout, "\n"), "")
+	}
+
+	if len(args) == 0 || fakeContainer.Shell == "" {
+		return notFound
+	}
+	switch {
+	case len(args) == 2 && args[1] == "--version":
+		if args[0] == fakeContainer.Shell {
+			return success([]string{fakeContainer.Shell + " version"})
+		}
+		return notFound
+	case args[0] == "stat":
+		return success(nil)
+	case args[0] != fakeContainer.Shell:
+		return notFound
+	case stdin != nil:
+		time.Sleep(fakeContainer.LseDuration)
+		status := k8sexec.NewExecutionStatus(pod, container, fakeContainer.LseRetCode, "", strings.Join(fakeContainer.LseOutput, "\n"), "")
+		if fakeContainer.LseRetCode != k8sexec.Success {
+			status.Error = []string{"command terminated with non-zero exit code"}
+		}
+		return status
+	case len(args) == 3 && strings.Contains(args[2], "os-release"):
+		stdout := append([]string{}, fakeContainer.OSRelease...)
+		if fakeContainer.PkgManager != "" {
+			stdout = append(stdout, "PKG_MANAGER="+fakeContainer.PkgManager)
+		}
+		return success(stdout)
+	case len(args) == 3 && strings.Contains(args[2], "/proc/mounts"):
+		options := "rw,relatime"
+		if fakeContainer.ReadOnlyRoot {
+			options = "ro,relatime"
+		}
+		return success([]string{"ROOT_MOUNT=overlay / overlay " + options + " 0 0", "TMP_WRITABLE=yes"})
+	default:
+		return success(nil)
+	}
+}
+
+// serve serves the subset of the Kubernetes API used by kubelse from the fake clientset.
+func (c *Cluster) serve(w http.ResponseWriter, req *http.Request) {
+	ctx := context.TODO()
+	parts := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+	query := req.URL.Query()
+	listOptions := metaV1.ListOptions{LabelSelector: query.Get("labelSelector")}
+
+	var (
+		obj interface{}
+		err error
+	)
+	switch {
+	case req.URL.Path == "/version":
+		obj = version.Info{GitVersion: c.Version}
+	case req.Method == http.MethodPost && strings.HasSuffix(req.URL.Path, "/selfsubjectaccessreviews"):
+		obj = authorizationV1.SelfSubjectAccessReview{
+			TypeMeta: metaV1.TypeMeta{Kind: "SelfSubjectAccessReview", APIVersion: "authorization.k8s.io/v1"},
+			Status:   authorizationV1.SubjectAccessReviewStatus{Allowed: true},
+		}
+	case req.Method == http.MethodPost && strings.HasSuffix(req.URL.Path, "/subjectaccessreviews"):
+		var review authorizationV1.SubjectAccessReview
+		if err = json.NewDecoder(req.Body).Decode(&review); err == nil && review.Spec.ResourceAttributes != nil {
+			attributes := review.Spec.ResourceAttributes
+			resource := attributes.Resource
+			if attributes.Subresource != "" {
+				resource += "/" + attributes.Subresource
+			}
+			review.Status.Allowed = c.Permissions[attributes.Verb+" "+resource]
+		}
+		review.TypeMeta = metaV1.TypeMeta{Kind: "SubjectAccessReview", APIVersion: "authorization.k8s.io/v1"}
+		obj = review
+	case len(parts) == 3 && parts[0] == "api" && parts[2] == "namespaces":
+		var namespaces *corev1.NamespaceList
+		if namespaces, err = c.Clientset.CoreV1().Namespaces().List(ctx, listOptions); err == nil {
+			namespaces.TypeMeta = metaV1.TypeMeta{Kind: "NamespaceList", APIVersion: "v1"}
+			obj = namespaces
+		}
+	case len(parts) == 4 && parts[0] == "api" && parts[2] == "namespaces":
+		var ns *corev1.Namespace
+		if ns, err = c.Clientset.CoreV1().Namespaces().Get(ctx, parts[3], metaV1.GetOptions{}); err == nil {
+			ns.TypeMeta = metaV1.TypeMeta{Kind: "Namespace", APIVersion: "v1"}
+			obj = ns
+		}
+	case len(parts) == 3 && parts[0] == "api" && parts[2] == "nodes":
+		var nodes *corev1.NodeList
+		if nodes, err = c.Clientset.CoreV1().Nodes().List(ctx, listOptions); err == nil {
+			nodes.TypeMeta = metaV1.TypeMeta{Kind: "NodeList", APIVersion: "v1"}
+			obj = nodes
+		}
+	case len(parts) == 3 && parts[0] == "api" && parts[2] == "pods":
+		var pods *corev1.PodList
+		if pods, err = c.Clientset.CoreV1().Pods(metaV1.NamespaceAll).List(ctx, listOptions); err == nil {
+			pods.TypeMeta = metaV1.TypeMeta{Kind: "PodList", APIVersion: "v1"}
+			obj = pods
+		}
+	case req.Method == http.MethodPost && len(parts) == 5 && parts[0] == "api" && parts[4] == "pods":
+		// created pods are started right away
+		pod := &corev1.Pod{}
+		if err = json.NewDecoder(req.Body).Decode(pod); err == nil {
+			pod.Status.Phase = corev1.PodRunning
+			if pod, err = c.Clientset.CoreV1().Pods(parts[3]).Create(ctx, pod, metaV1.CreateOptions{}); err == nil {
+				pod.TypeMeta = metaV1.TypeMeta{Kind: "Pod", APIVersion: "v1"}
+				obj = pod
+			}
+		}
+	case req.Method == http.MethodPost && len(parts) == 5 && parts[0] == "api" && parts[4] == "events":
+		event := &corev1.Event{}
+		if err = json.NewDecoder(req.Body).Decode(event); err == nil {
+			if event.Name == "" {
+				// the fake clientset does not generate names
+				event.Name = fmt.Sprintf("%s%d", event.GenerateName, c.generated.Add(1))
+			}
+			if event, err = c.Clientset.CoreV1().Events(parts[3]).Create(ctx, event, metaV1.CreateOptions{}); err == nil {
+				event.TypeMeta = metaV1.TypeMeta{Kind: "Event", APIVersion: "v1"}
+				obj = event
+			}
+		}
+	case req.Method == http.MethodDelete && len(parts) == 6 && parts[0] == "api" && parts[4] == "pods":
+		if err = c.Clientset.CoreV1().Pods(parts[3]).Delete(ctx, parts[5], metaV1.DeleteOptions{}); err == nil {
+			obj = metaV1.Status{TypeMeta: metaV1.TypeMeta{Kind: "Status", APIVersion: "v1"}, Status: metaV1.StatusSuccess}
+		}
+	case len(parts) == 5 && parts[0] == "api" && parts[4] == "pods":
+		var pods *corev1.PodList
+		if pods, err = c.Clientset.CoreV1().Pods(parts[3]).List(ctx, listOptions); err == nil {
+			pods.TypeMeta = metaV1.TypeMeta{Kind: "PodList", APIVersion: "v1"}
+			obj = pods
+		}
+	case len(parts) == 6 && parts[0] == "api" && parts[4] == "pods":
+		var pod *corev1.Pod
+		if pod, err = c.Clientset.CoreV1().Pods(parts[3]).Get(ctx, parts[5], metaV1.GetOptions{}); err == nil {
+			pod.TypeMeta = metaV1.TypeMeta{Kind: "Pod", APIVersion: "v1"}
+			obj = pod
+		}
+	case len(parts) == 6 && parts[0] == "api" && parts[4] == "secrets":
+		var secret *corev1.Secret
+		if secret, err = c.Clientset.CoreV1().Secrets(parts[3]).Get(ctx, parts[5], metaV1.GetOptions{}); err == nil {
+			secret.TypeMeta = metaV1.TypeMeta{Kind: "Secret", APIVersion: "v1"}
+			obj = secret
+		}
+	case len(parts) == 6 && parts[1] == "apps" && parts[5] == "deployments":
+		var deployments *appsV1.DeploymentList
+		if deployments, err = c.Clientset.AppsV1().Deployments(parts[4]).List(ctx, listOptions); err == nil {
+			deployments.TypeMeta = metaV1.TypeMeta{Kind: "DeploymentList", APIVersion: "apps/v1"}
+			obj = deployments
+		}
+	case len(parts) == 6 && parts[1] == "apps" && parts[5] == "statefulsets":
+		var statefulSets *appsV1.StatefulSetList
+		if statefulSets, err = c.Clientset.AppsV1().StatefulSets(parts[4]).List(ctx, listOptions); err == nil {
+			statefulSets.TypeMeta = metaV1.TypeMeta{Kind: "StatefulSetList", APIVersion: "apps/v1"}
+			obj = statefulSets
+		}
+	case len(parts) == 6 && parts[1] == "apps" && parts[5] == "daemonsets":
+		var daemonSets *appsV1.DaemonSetList
+		if daemonSets, err = c.Clientset.AppsV1().DaemonSets(parts[4]).List(ctx, listOptions); err == nil {
+			daemonSets.TypeMeta = metaV1.TypeMeta{Kind: "DaemonSetList", APIVersion: "apps/v1"}
+			obj = daemonSets
+		}
+	case len(parts) == 6 && parts[1] == "networking.k8s.io" && parts[5] == "networkpolicies":
+		var policies *networkingV1.NetworkPolicyList
+		if policies, err = c.Clientset.NetworkingV1().NetworkPolicies(parts[4]).List(ctx, listOptions); err == nil {
+			policies.TypeMeta = metaV1.TypeMeta{Kind: "NetworkPolicyList", APIVersion: "networking.k8s.io/v1"}
+			obj = policies
+		}
+	case len(parts) == 6 && parts[1] == "policy" && parts[5] == "poddisruptionbudgets":
+		var budgets *policyV1.PodDisruptionBudgetList
+		if budgets, err = c.Clientset.PolicyV1().PodDisruptionBudgets(parts[4]).List(ctx, listOptions); err == nil {
+			budgets.TypeMeta = metaV1.TypeMeta{Kind: "PodDisruptionBudgetList", APIVersion: "policy/v1"}
+			obj = budgets
+		}
+	case len(parts) == 7 && parts[1] == "metrics.k8s.io" && parts[5] == "pods":
+		if obj = c.podMetrics(parts[4], parts[6]); obj == nil {
+			err = apierrors.NewNotFound(corev1.Resource("pods"), parts[6])
+		}
+	default:
+		err = apierrors.NewNotFound(corev1.Resource(req.URL.Path), "")
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err != nil {
+		status := err.(apierrors.APIStatus).Status()
+		status.TypeMeta = metaV1.TypeMeta{Kind: "Status", APIVersion: "v1"}
+		w.WriteHeader(int(status.Code))
+		json.NewEncoder(w).Encode(status)
+		return
+	}
+	json.NewEncoder(w).Encode(obj)
+}
@@ -2,17 +2,30 @@ package main
 
 import (
 	_ "embed"
+	"errors"
 	"fmt"
 	"k8slse/cmd"
 	"os"
 )
 
-var version string
+// version, commit and date are injected at build time with -ldflags "-X main.version=...", releaseKey is a base64
+// encoded public key verifying signatures of releases
+var (
+	version    string
+	commit     string
+	date       string
+	releaseKey string
+)
 
 func main() {
-	cmd.AppVersion = version
+	cmd.AppVersion, cmd.AppCommit, cmd.AppBuildDate = version, commit, date
+	cmd.ReleaseKey = releaseKey
 	if err := cmd.Execute(); err != nil {
 		fmt.Print(err.Error())
+		var exitErr *cmd.ExitError
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.Code)
+		}
 		os.Exit(1)
 	}
 }